@@ -0,0 +1,113 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"pipelined.dev/pipe"
+)
+
+// WithWriteAggregation makes the Sink collect PCM of pipe buffers and
+// pass it to the encoder in chunks of at least samples per channel, so
+// calls into lame are fewer and larger. If latency is positive, collected
+// PCM is passed earlier once it's held longer than latency. It's checked
+// when the next buffer arrives. ParallelSink and NogapSinks ignore this
+// option.
+func WithWriteAggregation(samples int, latency time.Duration) SinkOption {
+	return func(o *sinkOptions) {
+		if samples <= 0 {
+			o.fail(fmt.Errorf("%w: write aggregation of %d samples is not positive", ErrInvalidParameter, samples))
+			return
+		}
+		if latency < 0 {
+			o.fail(fmt.Errorf("%w: write aggregation latency %v is negative", ErrInvalidParameter, latency))
+			return
+		}
+		o.aggregation = &aggregation{samples: samples, latency: latency}
+	}
+}
+
+// aggregation is the size and latency bound of aggregated writes.
+type aggregation struct {
+	samples int
+	latency time.Duration
+}
+
+// aggregator collects PCM and writes it in chunks.
+type aggregator struct {
+	w       io.Writer
+	size    int
+	latency time.Duration
+	pcm     []byte
+	// since is the time when the first pending PCM arrived.
+	since time.Time
+}
+
+// newAggregator returns the writer that aggregates PCM of the channels
+// into w. Nil is returned if aggregation isn't configured.
+func newAggregator(w io.Writer, a *aggregation, channels int) *aggregator {
+	if a == nil {
+		return nil
+	}
+	size := a.samples * channels * bytesPerSample
+	return &aggregator{
+		w:       w,
+		size:    size,
+		latency: a.latency,
+		pcm:     make([]byte, 0, size),
+	}
+}
+
+// Write implements io.Writer.
+func (a *aggregator) Write(p []byte) (int, error) {
+	if len(a.pcm) == 0 {
+		a.since = time.Now()
+	}
+	a.pcm = append(a.pcm, p...)
+	if len(a.pcm) >= a.size || (a.latency > 0 && time.Since(a.since) >= a.latency) {
+		if err := a.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes pending PCM.
+func (a *aggregator) flush() error {
+	if len(a.pcm) == 0 {
+		return nil
+	}
+	_, err := a.w.Write(a.pcm)
+	a.pcm = a.pcm[:0]
+	return err
+}
+
+// flusher writes pending PCM before fn is called. Pending PCM is dropped
+// if the stream is discarded.
+func (a *aggregator) flusher(fn pipe.FlushFunc, o sinkOptions) pipe.FlushFunc {
+	return func(ctx context.Context) error {
+		var err error
+		if !o.discard(ctx) {
+			if err = a.flush(); err != nil {
+				err = fmt.Errorf("error writing MP3 buffer: %w", err)
+			}
+		}
+		if flushErr := fn(ctx); err == nil {
+			err = flushErr
+		}
+		return err
+	}
+}
+
+// reconfigurer writes pending PCM into the current encoder before it's
+// replaced.
+func (a *aggregator) reconfigurer(fn func(BitRateMode, EncodingQuality) error) func(BitRateMode, EncodingQuality) error {
+	return func(brm BitRateMode, eq EncodingQuality) error {
+		if err := a.flush(); err != nil {
+			return fmt.Errorf("error writing MP3 buffer: %w", err)
+		}
+		return fn(brm, eq)
+	}
+}
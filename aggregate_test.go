@@ -0,0 +1,85 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+)
+
+func TestWriteAggregation(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples int
+		latency time.Duration
+		writes  int
+	}{
+		{
+			name:    "chunks",
+			samples: 2048,
+			// four chunks of four buffers and the rest on flush.
+			writes: 5,
+		},
+		{
+			name:    "larger than input",
+			samples: 20000,
+			writes:  1,
+		},
+		{
+			name:    "latency bound",
+			samples: 20000,
+			latency: time.Nanosecond,
+			// every buffer is held longer than latency.
+			writes: 20,
+		},
+	}
+	for _, test := range tests {
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      10000,
+			Value:      0.5,
+		}
+		var encoder recordingEncoder
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithEncoder(func() mp3.Encoder { return &encoder }),
+					mp3.WithWriteAggregation(test.samples, test.latency),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if encoder.writes != test.writes {
+			t.Errorf("%s: expected writes: %d got: %d", test.name, test.writes, encoder.writes)
+		}
+		if expected := source.Limit * source.Channels * 2; encoder.written != expected {
+			t.Errorf("%s: expected %d bytes written got: %d", test.name, expected, encoder.written)
+		}
+	}
+}
+
+func TestWriteAggregationValidation(t *testing.T) {
+	for _, option := range []mp3.SinkOption{
+		mp3.WithWriteAggregation(0, 0),
+		mp3.WithWriteAggregation(1152, -time.Second),
+	} {
+		_, err := mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine), option)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+		}
+	}
+}
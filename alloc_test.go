@@ -0,0 +1,90 @@
+package mp3_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	gomp3 "github.com/hajimehoshi/go-mp3"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// sinkBackends are encoders of allocation tests and benchmarks.
+var sinkBackends = []struct {
+	name    string
+	backend mp3.Backend
+}{
+	{name: "lame", backend: mp3.Lame},
+	{name: "shine", backend: mp3.Shine},
+}
+
+// allocSizes are buffer sizes of allocation tests.
+var allocSizes = []int{bufferSize, 4096}
+
+func TestSinkAllocations(t *testing.T) {
+	for _, test := range sinkBackends {
+		if !test.backend.Available() {
+			continue
+		}
+		for _, size := range allocSizes {
+			sink, err := mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(test.backend),
+				mp3.WithProgress(func(mp3.Progress) {}),
+			)(mutable.Mutable(), size, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+			if err != nil {
+				t.Fatalf("%s %d: unexpected error: %v", test.name, size, err)
+			}
+			floats := signal.Allocator{Channels: 2, Length: size, Capacity: size}.Float64()
+			allocs := testing.AllocsPerRun(100, func() {
+				if err := sink.SinkFunc(floats); err != nil {
+					t.Fatalf("%s %d: unexpected error: %v", test.name, size, err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("%s %d: expected no allocations per buffer got: %v", test.name, size, allocs)
+			}
+		}
+	}
+}
+
+// TestSourceAllocations checks that Source doesn't allocate on top of
+// go-mp3 decoder, that allocates while decoding every frame.
+func TestSourceAllocations(t *testing.T) {
+	data, err := os.ReadFile(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, size := range allocSizes {
+		decoder, err := gomp3.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", size, err)
+		}
+		// decoder output is 16-bit stereo.
+		buf := make([]byte, size*2*2)
+		decoderAllocs := testing.AllocsPerRun(50, func() {
+			if _, err := io.ReadFull(decoder, buf); err != nil {
+				t.Fatalf("%d: unexpected error: %v", size, err)
+			}
+		})
+
+		source, err := mp3.Source(bytes.NewReader(data))(mutable.Mutable(), size)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", size, err)
+		}
+		floats := signal.Allocator{Channels: 2, Length: size, Capacity: size}.Float64()
+		// sample has enough buffers for warm up and all runs.
+		allocs := testing.AllocsPerRun(50, func() {
+			if _, err := source.SourceFunc(floats); err != nil {
+				t.Fatalf("%d: unexpected error: %v", size, err)
+			}
+		})
+		if allocs > decoderAllocs {
+			t.Errorf("%d: expected at most %v allocations of decoder per buffer got: %v", size, decoderAllocs, allocs)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package mp3
+
+import (
+	"errors"
+	"io"
+
+	"pipelined.dev/signal"
+)
+
+// Backend selects the encoder implementation used by the Sink.
+type Backend int
+
+const (
+	// Lame encodes with libmp3lame. It's the default backend. If the
+	// writer implements io.WriteSeeker, the first frame is rewritten on
+	// flush with Xing/Info tag that contains encoder delay and padding
+	// for gapless playback.
+	Lame Backend = iota
+	// Shine encodes with pure-Go port of shine fixed-point encoder. It
+	// doesn't require cgo, but supports only CBR, encodes JointStereo as
+	// Stereo and ignores EncodingQuality. Lame-specific options are
+	// rejected. Shine is distributed under LGPL, build with noshine tag
+	// to exclude it.
+	Shine
+)
+
+// ErrBackendUnavailable is returned when the selected backend isn't
+// compiled into the package.
+var ErrBackendUnavailable = errors.New("encoder backend is not available")
+
+type (
+	// Encoder encodes PCM into MP3 stream. The Sink initializes encoder
+	// once, writes interleaved 16 bit little-endian PCM and flushes it
+	// when the input is done. Flush must write all remaining data and
+	// release encoder resources.
+	Encoder interface {
+		Init(w io.Writer, params EncoderParams) error
+		Write(pcm []byte) (int, error)
+		Flush() error
+	}
+
+	// EncoderParams are validated parameters of the Sink.
+	EncoderParams struct {
+		BitRateMode BitRateMode
+		// ChannelMode is Mono if single channel is encoded.
+		ChannelMode ChannelMode
+		Quality     EncodingQuality
+		// SampleRate is the input sample rate.
+		SampleRate signal.Frequency
+		// OutSampleRate is zero if encoder picks it.
+		OutSampleRate signal.Frequency
+		// Channels is the number of encoded channels.
+		Channels int
+	}
+)
+
+// WithBackend selects the encoder implementation.
+func WithBackend(b Backend) SinkOption {
+	return func(o *sinkOptions) {
+		o.backend = b
+	}
+}
+
+// WithEncoder sets the function that creates custom encoders. Every call
+// must return a new encoder. It overrides WithBackend. Lame-specific
+// options are not passed to custom encoders.
+func WithEncoder(fn func() Encoder) SinkOption {
+	return func(o *sinkOptions) {
+		o.newEncoder = fn
+	}
+}
+
+// encoder returns a new uninitialized encoder.
+func (o sinkOptions) encoder() Encoder {
+	if o.newEncoder != nil {
+		return o.newEncoder()
+	}
+	if o.backend == Shine {
+		return &shineEncoder{}
+	}
+	return &lameEncoder{opts: o}
+}
+
+// encoderDelay returns the delay of the encoder in samples. It's zero
+// for custom encoders, their delay is known only after flush.
+func (o sinkOptions) encoderDelay() int {
+	if o.newEncoder != nil {
+		return 0
+	}
+	if o.backend == Shine {
+		return shineDelay
+	}
+	return lameDelay
+}
+
+// Available reports whether the backend can be used for encoding. Lame
+// backend is not available if the package is built without cgo or with
+// nolame build tag. Shine backend is not available if the package is
+// built with noshine build tag.
+func (b Backend) Available() bool {
+	switch b {
+	case Lame:
+		return lameAvailable
+	case Shine:
+		return shineAvailable
+	}
+	return false
+}
+
+func (b Backend) String() string {
+	switch b {
+	case Lame:
+		return "lame"
+	case Shine:
+		return "shine"
+	}
+	return "unknown"
+}
@@ -0,0 +1,179 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+type recordingEncoder struct {
+	params  mp3.EncoderParams
+	written int
+	writes  int
+	pcm     []byte
+	flushed bool
+}
+
+func (e *recordingEncoder) Init(w io.Writer, params mp3.EncoderParams) error {
+	e.params = params
+	return nil
+}
+
+func (e *recordingEncoder) Write(pcm []byte) (int, error) {
+	e.written += len(pcm)
+	e.writes++
+	e.pcm = append(e.pcm, pcm...)
+	return len(pcm), nil
+}
+
+func (e *recordingEncoder) Flush() error {
+	e.flushed = true
+	return nil
+}
+
+func TestSinkCustomEncoder(t *testing.T) {
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      10000,
+		Value:      0.5,
+	}
+	var (
+		encoder recordingEncoder
+		info    mp3.EncodingInfo
+	)
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink: mp3.Sink(io.Discard, mp3.VBR(2), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithEncoder(func() mp3.Encoder { return &encoder }),
+				mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := mp3.EncoderParams{
+		BitRateMode: mp3.VBR(2),
+		ChannelMode: mp3.JointStereo,
+		Quality:     mp3.DefaultEncodingQuality,
+		SampleRate:  44100,
+		Channels:    2,
+	}
+	if encoder.params != expected {
+		t.Errorf("expected params: %+v got: %+v", expected, encoder.params)
+	}
+	if encoder.written != source.Limit*source.Channels*2 {
+		t.Errorf("expected %d bytes written got: %d", source.Limit*source.Channels*2, encoder.written)
+	}
+	// samples of constant input are encoded little-endian.
+	for i := 0; i+1 < len(encoder.pcm); i += 2 {
+		if v := int16(encoder.pcm[i]) | int16(encoder.pcm[i+1])<<8; v < 16383 || v > 16384 {
+			t.Fatalf("expected PCM sample %d of 0.5 got: %d", i/2, v)
+		}
+	}
+	if !encoder.flushed {
+		t.Errorf("encoder wasn't flushed")
+	}
+	if expected := (mp3.EncodingInfo{Samples: source.Limit}); info != expected {
+		t.Errorf("expected info: %+v got: %+v", expected, info)
+	}
+}
+
+func TestBackendAvailable(t *testing.T) {
+	if mp3.Backend(-1).Available() {
+		t.Errorf("unknown backend must not be available")
+	}
+	for _, backend := range []mp3.Backend{mp3.Lame, mp3.Shine} {
+		if backend.Available() {
+			continue
+		}
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+		}
+		_, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(backend)),
+			},
+		)
+		if !errors.Is(err, mp3.ErrBackendUnavailable) {
+			t.Errorf("%v: expected error: %v got: %v", backend, mp3.ErrBackendUnavailable, err)
+		}
+	}
+}
+
+func TestSinkClipping(t *testing.T) {
+	testClipping := func(value float64, expected int16) func(*testing.T) {
+		return func(t *testing.T) {
+			source := mock.Source{
+				Channels:   1,
+				SampleRate: 44100,
+				Limit:      1000,
+				Value:      value,
+			}
+			var encoder recordingEncoder
+			p, err := pipe.New(
+				bufferSize,
+				pipe.Line{
+					Source: source.Source(),
+					Sink: mp3.Sink(io.Discard, mp3.CBR(128), mp3.Mono, mp3.DefaultEncodingQuality,
+						mp3.WithEncoder(func() mp3.Encoder { return &encoder }),
+					),
+				},
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := pipe.Wait(p.Start(context.Background())); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i := 0; i+1 < len(encoder.pcm); i += 2 {
+				if v := int16(encoder.pcm[i]) | int16(encoder.pcm[i+1])<<8; v != expected {
+					t.Fatalf("expected PCM sample %d of %v got: %d", i/2, value, v)
+				}
+			}
+		}
+	}
+	t.Run("max", testClipping(1, math.MaxInt16))
+	t.Run("above max", testClipping(1.5, math.MaxInt16))
+	t.Run("min", testClipping(-1, math.MinInt16))
+	t.Run("below min", testClipping(-1.5, math.MinInt16))
+}
+
+func TestSinkShortBuffer(t *testing.T) {
+	var encoder recordingEncoder
+	sink, err := mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+		mp3.WithEncoder(func() mp3.Encoder { return &encoder }),
+	)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// full buffer is written whole after the short one.
+	for _, length := range []int{bufferSize, 100, bufferSize, 1} {
+		floats := signal.Allocator{Channels: 2, Length: length, Capacity: length}.Float64()
+		written := encoder.written
+		if err := sink.SinkFunc(floats); err != nil {
+			t.Fatalf("%d: unexpected error: %v", length, err)
+		}
+		if expected := length * 2 * 2; encoder.written-written != expected {
+			t.Errorf("%d: expected %d bytes written got: %d", length, expected, encoder.written-written)
+		}
+	}
+}
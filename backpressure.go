@@ -0,0 +1,266 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// BackpressureStrategy determines what the Sink does when its writer
+// doesn't keep up with the stream.
+type BackpressureStrategy int
+
+const (
+	// BlockOnBackpressure makes the Sink wait until the writer has room
+	// in the buffer. It blocks the pipeline.
+	BlockOnBackpressure BackpressureStrategy = iota
+	// DropOnBackpressure makes the Sink drop encoded frames that don't
+	// fit into the buffer. Tags are never dropped.
+	DropOnBackpressure
+	// SpillOnBackpressure makes the Sink write the stream that doesn't
+	// fit into the buffer to a temporary file. The writer receives it
+	// from the file once it catches up.
+	SpillOnBackpressure
+)
+
+// Backpressure configures the buffer between the Sink and its writer.
+type Backpressure struct {
+	Strategy BackpressureStrategy
+	// Buffer is the number of bytes queued in memory. Default is 1 MiB.
+	Buffer int
+	// SpillDir is the directory of the spill file. Default is
+	// os.TempDir.
+	SpillDir string
+	// Report receives the counters every time they change. It's called
+	// from the goroutine of the write.
+	Report func(BackpressureStats)
+}
+
+// BackpressureStats are the counters of the stream that didn't fit into
+// the buffer.
+type BackpressureStats struct {
+	DroppedFrames int
+	DroppedBytes  int64
+	SpilledBytes  int64
+}
+
+const defaultBackpressureBuffer = 1 << 20
+
+// WithBackpressure makes the Sink write into its writer from the
+// background through the buffer, so the pipeline isn't blocked by the
+// slow network writer. Flush waits until the buffer is written. Writes
+// are aligned to frames and stream isn't seekable, so Xing header isn't
+// written. Writer errors are returned by the following writes of the
+// Sink.
+func WithBackpressure(b Backpressure) SinkOption {
+	return func(o *sinkOptions) {
+		if b.Strategy < BlockOnBackpressure || b.Strategy > SpillOnBackpressure {
+			o.fail(fmt.Errorf("%w: unknown backpressure strategy %d", ErrInvalidParameter, b.Strategy))
+			return
+		}
+		if b.Buffer < 0 {
+			o.fail(fmt.Errorf("%w: backpressure buffer %d is negative", ErrInvalidParameter, b.Buffer))
+			return
+		}
+		if b.Buffer == 0 {
+			b.Buffer = defaultBackpressureBuffer
+		}
+		o.backpressure = &b
+		o.alignFrames = true
+	}
+}
+
+// backpressureWriter writes into w from the background.
+type backpressureWriter struct {
+	w        io.Writer
+	strategy BackpressureStrategy
+	buffer   int
+	dir      string
+	report   func(BackpressureStats)
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// queue of writes and its size in bytes.
+	queue  [][]byte
+	queued int
+	// spill file holds writes prefixed with size between read and write
+	// offsets.
+	spill     *os.File
+	spillRead int64
+	spillSize int64
+	stats     BackpressureStats
+	closed    bool
+	aborted   bool
+	// err is the error of the background write.
+	err  error
+	done chan struct{}
+}
+
+func newBackpressureWriter(w io.Writer, b Backpressure) *backpressureWriter {
+	bw := backpressureWriter{
+		w:        w,
+		strategy: b.Strategy,
+		buffer:   b.Buffer,
+		dir:      b.SpillDir,
+		report:   b.Report,
+		done:     make(chan struct{}),
+	}
+	bw.cond = sync.NewCond(&bw.mu)
+	go bw.run()
+	return &bw
+}
+
+func (b *backpressureWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.strategy == BlockOnBackpressure {
+		// single write bigger than the buffer is queued when it's empty.
+		for b.err == nil && b.queued > 0 && b.queued+len(p) > b.buffer {
+			b.cond.Wait()
+		}
+	}
+	if b.err != nil {
+		err := b.err
+		b.mu.Unlock()
+		return 0, err
+	}
+	_, tagErr := parseFrameHeader(p)
+	// queue keeps the buffer, so it can't be reused.
+	data := append([]byte(nil), p...)
+	var (
+		report bool
+		err    error
+	)
+	switch {
+	case b.spillSize > b.spillRead:
+		// once spilled, the order is kept by the file.
+		err = b.spillWrite(data)
+		report = true
+	case b.queued+len(p) <= b.buffer || tagErr != nil:
+		b.queue = append(b.queue, data)
+		b.queued += len(data)
+	case b.strategy == DropOnBackpressure:
+		b.stats.DroppedFrames += frameCount(p)
+		b.stats.DroppedBytes += int64(len(p))
+		report = true
+	case b.strategy == SpillOnBackpressure:
+		err = b.spillWrite(data)
+		report = true
+	default:
+		b.queue = append(b.queue, data)
+		b.queued += len(data)
+	}
+	b.cond.Broadcast()
+	stats := b.stats
+	b.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	if report && b.report != nil {
+		b.report(stats)
+	}
+	return len(p), nil
+}
+
+// spillWrite appends the write to the spill file. It must be called
+// with lock held.
+func (b *backpressureWriter) spillWrite(p []byte) error {
+	if b.spill == nil {
+		f, err := os.CreateTemp(b.dir, "mp3-spill")
+		if err != nil {
+			return fmt.Errorf("error creating spill file: %w", err)
+		}
+		b.spill = f
+	}
+	record := make([]byte, 4+len(p))
+	binary.BigEndian.PutUint32(record, uint32(len(p)))
+	copy(record[4:], p)
+	if _, err := b.spill.WriteAt(record, b.spillSize); err != nil {
+		return fmt.Errorf("error writing spill file: %w", err)
+	}
+	b.spillSize += int64(len(record))
+	b.stats.SpilledBytes += int64(len(p))
+	return nil
+}
+
+// spillNext reads the next write from the spill file. It must be called
+// with lock held.
+func (b *backpressureWriter) spillNext() ([]byte, error) {
+	var size [4]byte
+	if _, err := b.spill.ReadAt(size[:], b.spillRead); err != nil {
+		return nil, fmt.Errorf("error reading spill file: %w", err)
+	}
+	p := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := b.spill.ReadAt(p, b.spillRead+4); err != nil {
+		return nil, fmt.Errorf("error reading spill file: %w", err)
+	}
+	b.spillRead += int64(4 + len(p))
+	// drained file is reused from the start.
+	if b.spillRead == b.spillSize {
+		b.spillRead, b.spillSize = 0, 0
+	}
+	return p, nil
+}
+
+// run writes queued and spilled data until the writer is closed.
+func (b *backpressureWriter) run() {
+	defer close(b.done)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		for !b.aborted && len(b.queue) == 0 && b.spillSize == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if b.aborted || b.err != nil {
+			return
+		}
+		var (
+			p   []byte
+			err error
+		)
+		switch {
+		case len(b.queue) > 0:
+			p = b.queue[0]
+			b.queue[0] = nil
+			b.queue = b.queue[1:]
+			b.queued -= len(p)
+		case b.spillSize > 0:
+			p, err = b.spillNext()
+		default:
+			return
+		}
+		if err == nil {
+			b.mu.Unlock()
+			_, err = b.w.Write(p)
+			b.mu.Lock()
+		}
+		if err != nil {
+			b.err = err
+		}
+		b.cond.Broadcast()
+	}
+}
+
+// close waits until the buffer is written if drain is true. Otherwise
+// the buffer is dropped.
+func (b *backpressureWriter) close(drain bool) error {
+	b.mu.Lock()
+	b.closed = true
+	b.aborted = !drain
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	<-b.done
+
+	if b.spill != nil {
+		b.spill.Close()
+		os.Remove(b.spill.Name())
+	}
+	return b.err
+}
+
+// frameCount returns the number of frames in p.
+func frameCount(p []byte) int {
+	frames, _, _ := splitFrames(p)
+	return len(frames)
+}
@@ -0,0 +1,171 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// blockingWriter blocks writes until it's released and then delays
+// every write.
+type blockingWriter struct {
+	w       io.Writer
+	delay   time.Duration
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingWriter(w io.Writer, delay time.Duration) *blockingWriter {
+	return &blockingWriter{w: w, delay: delay, release: make(chan struct{})}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	time.Sleep(b.delay)
+	return b.w.Write(p)
+}
+
+func (b *blockingWriter) unblock() {
+	b.once.Do(func() { close(b.release) })
+}
+
+// encodeStream encodes a second of signal into w.
+func encodeStream(w io.Writer, options ...mp3.SinkOption) error {
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	options = append([]mp3.SinkOption{mp3.WithBackend(mp3.Shine)}, options...)
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.Sink(w, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return pipe.Wait(p.Start(context.Background()))
+}
+
+func TestSinkBackpressure(t *testing.T) {
+	var expected bytes.Buffer
+	if err := encodeStream(&expected); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		strategy mp3.BackpressureStrategy
+		delay    time.Duration
+		dropped  bool
+		spilled  bool
+	}{
+		{
+			name:     "block",
+			strategy: mp3.BlockOnBackpressure,
+			delay:    time.Millisecond,
+		},
+		{
+			name:     "drop",
+			strategy: mp3.DropOnBackpressure,
+			dropped:  true,
+		},
+		{
+			name:     "spill",
+			strategy: mp3.SpillOnBackpressure,
+			spilled:  true,
+		},
+	}
+	for _, test := range tests {
+		dir, err := os.MkdirTemp("", "spill")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		defer os.RemoveAll(dir)
+		var (
+			buf    bytes.Buffer
+			last   mp3.BackpressureStats
+			stats  mp3.Stats
+			writer = newBlockingWriter(&buf, test.delay)
+		)
+		if test.strategy == mp3.BlockOnBackpressure {
+			writer.unblock()
+		}
+		err = encodeStream(writer,
+			mp3.WithStats(func(s mp3.Stats) { stats = s }),
+			mp3.WithBackpressure(mp3.Backpressure{
+				Strategy: test.strategy,
+				Buffer:   4096,
+				SpillDir: dir,
+				Report: func(s mp3.BackpressureStats) {
+					last = s
+					writer.unblock()
+				},
+			}),
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames := splitFrames(t, buf.Bytes())
+		if test.dropped {
+			if last.DroppedFrames == 0 || last.DroppedBytes == 0 {
+				t.Errorf("%s: expected dropped frames got: %+v", test.name, last)
+			}
+			if len(frames)+last.DroppedFrames != stats.Frames {
+				t.Errorf("%s: expected frames: %d got: %d written and %d dropped", test.name, stats.Frames, len(frames), last.DroppedFrames)
+			}
+		} else if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+			t.Errorf("%s: unexpected stream of %d bytes expected: %d", test.name, buf.Len(), expected.Len())
+		}
+		if test.spilled && last.SpilledBytes == 0 {
+			t.Errorf("%s: expected spilled bytes", test.name)
+		}
+		if !test.dropped && !test.spilled && last != (mp3.BackpressureStats{}) {
+			t.Errorf("%s: unexpected stats: %+v", test.name, last)
+		}
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("%s: expected removed spill file", test.name)
+		}
+	}
+}
+
+func TestSinkBackpressureWriterError(t *testing.T) {
+	err := encodeStream(&failingWriter{limit: 1000}, mp3.WithBackpressure(mp3.Backpressure{}))
+	if !errors.Is(err, errWriter) {
+		t.Errorf("expected error: %v got: %v", errWriter, err)
+	}
+}
+
+func TestSinkBackpressureInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		backpressure mp3.Backpressure
+	}{
+		{
+			name:         "strategy",
+			backpressure: mp3.Backpressure{Strategy: 10},
+		},
+		{
+			name:         "buffer",
+			backpressure: mp3.Backpressure{Buffer: -1},
+		},
+	}
+	for _, test := range tests {
+		err := encodeStream(&bytes.Buffer{}, mp3.WithBackpressure(test.backpressure))
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
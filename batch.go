@@ -0,0 +1,179 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"pipelined.dev/pipe"
+)
+
+type (
+	// Batch transcodes many files with the same settings by a pool of
+	// workers.
+	Batch struct {
+		// Workers is the number of files transcoded concurrently.
+		// Default is the number of CPUs.
+		Workers         int
+		BitRateMode     BitRateMode
+		ChannelMode     ChannelMode
+		EncodingQuality EncodingQuality
+		// Options are applied to every output. Progress, stats and
+		// encoding info functions are set by the Batch. Options that can
+		// be bound only to a single Sink, like controller, ICY metadata,
+		// tag template, cues and file commit, are rejected.
+		Options []SinkOption
+		// Progress is called with the index of the job when its buffer
+		// is consumed. It's called concurrently by workers.
+		Progress func(job int, p Progress)
+		// Result is called when the job is done. Calls are serialized.
+		Result func(BatchResult)
+	}
+
+	// BatchJob is the file transcoded by Batch. Output is written
+	// atomically the same way FileSink does and it's kept only if the
+	// whole input is transcoded.
+	BatchJob struct {
+		Input  string
+		Output string
+	}
+
+	// BatchResult is the result of the job.
+	BatchResult struct {
+		// Job is the index of the job.
+		Job   int
+		Info  EncodingInfo
+		Stats Stats
+		Err   error
+	}
+
+	// BatchStats is the throughput of the batch.
+	BatchStats struct {
+		Files  int
+		Failed int
+		// InputBytes and OutputBytes are sizes of files of successful
+		// jobs.
+		InputBytes  int64
+		OutputBytes int64
+		// Samples is the number of encoded samples per channel.
+		Samples int64
+		// Duration is the wall time of the batch.
+		Duration time.Duration
+	}
+)
+
+// Run transcodes jobs and returns the stats when all of them are done.
+// Failed job doesn't stop others, the error of the first failed one is
+// returned. Jobs that aren't started when ctx is done fail with its
+// error.
+func (b Batch) Run(ctx context.Context, jobs []BatchJob) (BatchStats, error) {
+	if b.Workers < 0 {
+		return BatchStats{}, fmt.Errorf("%w: %d workers", ErrInvalidParameter, b.Workers)
+	}
+	var opts sinkOptions
+	for _, option := range b.Options {
+		option(&opts)
+	}
+	if opts.controller != nil || opts.icy != nil || opts.template != nil || opts.cues != nil || opts.fileCommit != nil {
+		return BatchStats{}, fmt.Errorf("%w: batch options can't be bound to a single sink", ErrInvalidParameter)
+	}
+	workers := b.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	var (
+		start = time.Now()
+		stats = BatchStats{Files: len(jobs)}
+		first error
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		queue = make(chan int)
+	)
+	done := func(r BatchResult, input, output int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Err != nil {
+			stats.Failed++
+			if first == nil {
+				first = fmt.Errorf("job %d: %w", r.Job, r.Err)
+			}
+		} else {
+			stats.InputBytes += input
+			stats.OutputBytes += output
+			stats.Samples += int64(r.Info.Samples)
+		}
+		if b.Result != nil {
+			b.Result(r)
+		}
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				r, input, output := b.transcode(ctx, job, jobs[job])
+				done(r, input, output)
+			}
+		}()
+	}
+	for i := range jobs {
+		if err := ctx.Err(); err != nil {
+			done(BatchResult{Job: i, Err: err}, 0, 0)
+			continue
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	stats.Duration = time.Since(start)
+	return stats, first
+}
+
+// transcode runs the job and returns its result with sizes of input and
+// output files.
+func (b Batch) transcode(ctx context.Context, i int, job BatchJob) (BatchResult, int64, int64) {
+	r := BatchResult{Job: i}
+	f, err := os.Open(job.Input)
+	if err != nil {
+		r.Err = err
+		return r, 0, 0
+	}
+	defer f.Close()
+	var commit FileCommit
+	options := append(b.Options[:len(b.Options):len(b.Options)],
+		WithEncodingInfo(func(info EncodingInfo) { r.Info = info }),
+		WithStats(func(s Stats) { r.Stats = s }),
+		WithFileCommit(&commit),
+	)
+	if b.Progress != nil {
+		options = append(options, WithProgress(func(p Progress) { b.Progress(i, p) }))
+	}
+	p, err := pipe.New(
+		transcodeBufferSize,
+		pipe.Line{
+			Source: Source(f),
+			Sink:   FileSink(job.Output, b.BitRateMode, b.ChannelMode, b.EncodingQuality, options...),
+		},
+	)
+	if err != nil {
+		r.Err = err
+		return r, 0, 0
+	}
+	if r.Err = commit.Commit(pipe.Wait(p.Start(ctx))); r.Err != nil {
+		return r, 0, 0
+	}
+	input, err := f.Stat()
+	if err != nil {
+		r.Err = err
+		return r, 0, 0
+	}
+	output, err := os.Stat(job.Output)
+	if err != nil {
+		r.Err = err
+		return r, 0, 0
+	}
+	return r, input.Size(), output.Size()
+}
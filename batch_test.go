@@ -0,0 +1,97 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestBatch(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "input.mp3")
+	if err := os.WriteFile(input, encodeTracks(t, tracksSource(time.Second)), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jobs := []mp3.BatchJob{
+		{Input: input, Output: filepath.Join(dir, "0.mp3")},
+		{Input: filepath.Join(dir, "missing.mp3"), Output: filepath.Join(dir, "1.mp3")},
+		{Input: input, Output: filepath.Join(dir, "2.mp3")},
+	}
+	var (
+		mu       sync.Mutex
+		progress = map[int]int{}
+		results  = map[int]mp3.BatchResult{}
+	)
+	b := mp3.Batch{
+		Workers:         2,
+		BitRateMode:     mp3.CBR(128),
+		ChannelMode:     mp3.JointStereo,
+		EncodingQuality: mp3.DefaultEncodingQuality,
+		Options:         []mp3.SinkOption{mp3.WithBackend(mp3.Shine)},
+		Progress: func(job int, p mp3.Progress) {
+			mu.Lock()
+			progress[job] = p.Samples
+			mu.Unlock()
+		},
+		Result: func(r mp3.BatchResult) {
+			results[r.Job] = r
+		},
+	}
+	stats, err := b.Run(context.Background(), jobs)
+	if err == nil {
+		t.Errorf("expected error of missing input")
+	}
+	if len(results) != len(jobs) {
+		t.Fatalf("expected %d results got: %v", len(jobs), results)
+	}
+	if results[1].Err == nil {
+		t.Errorf("expected error of job 1")
+	}
+	var outputBytes int64
+	for _, job := range []int{0, 2} {
+		r := results[job]
+		if r.Err != nil {
+			t.Errorf("job %d: unexpected error: %v", job, r.Err)
+		}
+		if r.Info.Samples == 0 || progress[job] != r.Info.Samples {
+			t.Errorf("job %d: expected progress of %d samples got: %d", job, r.Info.Samples, progress[job])
+		}
+		fi, err := os.Stat(jobs[job].Output)
+		if err != nil {
+			t.Fatalf("job %d: unexpected error: %v", job, err)
+		}
+		outputBytes += fi.Size()
+	}
+	if stats.Files != 3 || stats.Failed != 1 || stats.OutputBytes != outputBytes || stats.Samples != int64(2*results[0].Info.Samples) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stats, err = b.Run(ctx, jobs)
+	if !errors.Is(err, context.Canceled) || stats.Failed != len(jobs) {
+		t.Errorf("expected canceled jobs got: %+v %v", stats, err)
+	}
+	if _, err := (mp3.Batch{Workers: -1}).Run(context.Background(), jobs); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+	// metadata can't be shared by concurrent sinks.
+	b.Options = []mp3.SinkOption{mp3.WithICYMetadata(&mp3.ICYMetadata{Interval: 8192})}
+	if _, err := b.Run(context.Background(), jobs); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+	b.Options = []mp3.SinkOption{mp3.WithFileCommit(&mp3.FileCommit{})}
+	if _, err := b.Run(context.Background(), jobs); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,109 @@
+package mp3_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Benchmarks report throughput as the multiple of realtime per core of
+// 44.1 kHz stereo. Measured throughput and profiles are in README.
+
+// benchSizes are buffer sizes of benchmarks.
+var benchSizes = []int{256, 512, 1152, 4096}
+
+// reportRealtime reports the multiple of realtime of samples per channel
+// processed in every iteration.
+func reportRealtime(b *testing.B, samples int) {
+	audio := time.Duration(b.N) * signal.Frequency(44100).Duration(samples)
+	b.ReportMetric(float64(audio)/float64(b.Elapsed()), "x-realtime")
+}
+
+// benchChunks are chunk sizes of benchmarks with the largest buffer size.
+var benchChunks = []int{256, 1152, 4096}
+
+// BenchmarkSource allocations are made by the decoder, conversion of
+// buffers doesn't allocate.
+func BenchmarkSource(b *testing.B) {
+	data, err := os.ReadFile(sample)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%d", size), benchmarkSource(data, size))
+	}
+	size := benchSizes[len(benchSizes)-1]
+	for _, chunk := range benchChunks {
+		b.Run(fmt.Sprintf("%d/chunk/%d", size, chunk), benchmarkSource(data, size, mp3.WithReadChunkSize(chunk)))
+	}
+}
+
+func benchmarkSource(data []byte, size int, options ...mp3.SourceOption) func(*testing.B) {
+	return func(b *testing.B) {
+		floats := signal.Allocator{Channels: 2, Length: size, Capacity: size}.Float64()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			source, err := mp3.Source(bytes.NewReader(data), options...)(mutable.Mutable(), size)
+			if err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+			for {
+				if _, err := source.SourceFunc(floats); err != nil {
+					break
+				}
+			}
+		}
+		reportRealtime(b, mp3Samples)
+	}
+}
+
+func BenchmarkSink(b *testing.B) {
+	modes := []mp3.BitRateMode{mp3.CBR(128), mp3.CBR(320), mp3.VBR(2)}
+	for _, backend := range sinkBackends {
+		if !backend.backend.Available() {
+			continue
+		}
+		for _, brm := range modes {
+			// shine supports only CBR.
+			if _, ok := brm.(mp3.CBR); !ok && backend.backend == mp3.Shine {
+				continue
+			}
+			for _, size := range benchSizes {
+				b.Run(fmt.Sprintf("%s/%v/%d", backend.name, brm, size), benchmarkSink(backend.backend, brm, size))
+			}
+		}
+		size := benchSizes[len(benchSizes)-1]
+		for _, chunk := range benchChunks {
+			b.Run(fmt.Sprintf("%s/%v/%d/chunk/%d", backend.name, modes[0], size, chunk),
+				benchmarkSink(backend.backend, modes[0], size, mp3.WithConvertChunkSize(chunk)))
+		}
+	}
+}
+
+func benchmarkSink(backend mp3.Backend, brm mp3.BitRateMode, size int, options ...mp3.SinkOption) func(*testing.B) {
+	return func(b *testing.B) {
+		options = append(options, mp3.WithBackend(backend))
+		sink, err := mp3.Sink(io.Discard, brm, mp3.JointStereo, mp3.DefaultEncodingQuality, options...)(
+			mutable.Mutable(), size, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		floats := signal.Allocator{Channels: 2, Length: size, Capacity: size}.Float64()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := sink.SinkFunc(floats); err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+		}
+		reportRealtime(b, size)
+	}
+}
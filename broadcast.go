@@ -0,0 +1,233 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+const (
+	// defaultMetaInterval is the interval of ICY metadata blocks sent to
+	// listeners.
+	defaultMetaInterval = 16000
+	// defaultSubscriberBuffer is the number of writes buffered per
+	// subscriber.
+	defaultSubscriberBuffer = 64
+)
+
+// Broadcast is the http.Handler that serves the live stream written by
+// BroadcastSink to connected listeners. Listeners that send Icy-MetaData
+// header receive ICY metadata with the stream title. Listeners that
+// don't keep up with the stream are disconnected. Listeners are
+// disconnected when the stream ends, but Broadcast can be used by the
+// next Sink. If Burst is set, new listeners receive the last frames of
+// the stream at once, so players can start without waiting for the
+// buffer to fill.
+type Broadcast struct {
+	// Station information sent in icy-* headers.
+	Name        string
+	Description string
+	Genre       string
+	URL         string
+	// MetaInterval is the number of bytes between ICY metadata blocks.
+	// Default is 16000.
+	MetaInterval int
+	// ListenerBuffer is the number of writes buffered per listener.
+	// Default is 64.
+	ListenerBuffer int
+	// Burst is the duration of the stream sent to new listeners on
+	// connect. It's rounded up to whole frames.
+	Burst time.Duration
+
+	mu      sync.Mutex
+	hub     Hub
+	title   string
+	bitRate int
+	// burst contains the last frames of the stream.
+	burst         []burstFrame
+	burstDuration time.Duration
+}
+
+// burstFrame is the frame kept for new listeners.
+type burstFrame struct {
+	data     []byte
+	duration time.Duration
+}
+
+// BroadcastSink writes the stream to listeners of Broadcast.
+func BroadcastSink(b *Broadcast, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		aligned := append([]SinkOption{WithFrameAlignedWrites(0)}, options...)
+		sink, err := Sink(b, brm, cm, eq, aligned...)(mctx, bufferSize, props)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		b.mu.Lock()
+		b.bitRate = 0
+		if cbr, ok := brm.(CBR); ok {
+			b.bitRate = int(cbr)
+		}
+		b.mu.Unlock()
+		return pipe.Sink{
+			Context:  sink.Context,
+			SinkFunc: sink.SinkFunc,
+			FlushFunc: func(ctx context.Context) error {
+				err := sink.FlushFunc(ctx)
+				b.disconnect()
+				return err
+			},
+		}, nil
+	}
+}
+
+// SetStreamTitle sets the title sent to listeners in ICY metadata. It's
+// safe to call concurrently with the stream.
+func (b *Broadcast) SetStreamTitle(title string) {
+	b.mu.Lock()
+	b.title = title
+	b.mu.Unlock()
+}
+
+// Listeners returns the number of connected listeners.
+func (b *Broadcast) Listeners() int {
+	return b.hub.Subscribers()
+}
+
+// Write sends p to all listeners. If Burst is set, p must contain whole
+// frames.
+func (b *Broadcast) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Burst > 0 {
+		if err := b.remember(append([]byte(nil), p...)); err != nil {
+			return 0, err
+		}
+	}
+	return b.hub.Write(p)
+}
+
+// ServeHTTP implements http.Handler.
+func (b *Broadcast) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l := b.add()
+	defer l.Close()
+
+	b.mu.Lock()
+	header := w.Header()
+	header.Set("Content-Type", "audio/mpeg")
+	header.Set("Cache-Control", "no-cache, no-store")
+	setHeader(header, "icy-name", b.Name)
+	setHeader(header, "icy-description", b.Description)
+	setHeader(header, "icy-genre", b.Genre)
+	setHeader(header, "icy-url", b.URL)
+	if b.bitRate > 0 {
+		header.Set("icy-br", strconv.Itoa(b.bitRate))
+	}
+	var meta *ICYMetadata
+	if r.Header.Get("Icy-MetaData") == "1" {
+		meta = &ICYMetadata{Interval: b.MetaInterval, StreamTitle: b.title}
+		if meta.Interval <= 0 {
+			meta.Interval = defaultMetaInterval
+		}
+		header.Set("icy-metaint", strconv.Itoa(meta.Interval))
+	}
+	b.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	var iw *icyWriter
+	if meta != nil {
+		iw = &icyWriter{w: w, meta: meta}
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, ok := <-l.ch:
+			if !ok {
+				return
+			}
+			var err error
+			if iw != nil {
+				b.mu.Lock()
+				meta.StreamTitle = b.title
+				b.mu.Unlock()
+				_, err = iw.Write(data)
+			} else {
+				_, err = w.Write(data)
+			}
+			if err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// remember adds frames of p to the burst and drops the frames that
+// aren't needed to fill it.
+func (b *Broadcast) remember(p []byte) error {
+	frames, rest, err := splitFrames(p)
+	if err != nil {
+		return fmt.Errorf("error splitting MP3 frames: %w", err)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("incomplete frame of %d bytes", len(rest))
+	}
+	for _, frame := range frames {
+		h, _ := parseFrameHeader(frame)
+		d := h.duration()
+		b.burst = append(b.burst, burstFrame{data: frame, duration: d})
+		b.burstDuration += d
+	}
+	var n int
+	for n < len(b.burst) && b.burstDuration-b.burst[n].duration >= b.Burst {
+		b.burstDuration -= b.burst[n].duration
+		n++
+	}
+	// copy to release the dropped frames.
+	if n > 0 {
+		b.burst = append(b.burst[:0], b.burst[n:]...)
+	}
+	return nil
+}
+
+// add subscribes a new listener and sends the burst to it.
+func (b *Broadcast) add() *Subscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var burst []byte
+	for _, f := range b.burst {
+		burst = append(burst, f.data...)
+	}
+	return b.hub.subscribe(b.ListenerBuffer, burst, false)
+}
+
+// disconnect closes all listeners and drops the burst at the end of the
+// stream.
+func (b *Broadcast) disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hub.Close()
+	b.burst = nil
+	b.burstDuration = 0
+}
+
+func setHeader(h http.Header, key, value string) {
+	if value != "" {
+		h.Set(key, value)
+	}
+}
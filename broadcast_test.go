@@ -0,0 +1,166 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestBroadcast(t *testing.T) {
+	b := mp3.Broadcast{
+		Name:         "Radio",
+		Genre:        "Jazz",
+		MetaInterval: 2000,
+	}
+	b.SetStreamTitle("Live")
+	server := httptest.NewServer(&b)
+	defer server.Close()
+
+	listen := func(meta bool) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if meta {
+			req.Header.Set("Icy-MetaData", "1")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return resp
+	}
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.BroadcastSink(&b, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plain, icy := listen(false), listen(true)
+	defer plain.Body.Close()
+	defer icy.Body.Close()
+	if b.Listeners() != 2 {
+		t.Fatalf("expected listeners: 2 got: %d", b.Listeners())
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "audio/mpeg",
+		"icy-name":     "Radio",
+		"icy-genre":    "Jazz",
+		"icy-br":       "128",
+	}
+	for k, v := range headers {
+		if plain.Header.Get(k) != v {
+			t.Errorf("expected header %s: %q got: %q", k, v, plain.Header.Get(k))
+		}
+	}
+	if plain.Header.Get("icy-metaint") != "" || icy.Header.Get("icy-metaint") != "2000" {
+		t.Errorf("unexpected icy-metaint headers: %q %q", plain.Header.Get("icy-metaint"), icy.Header.Get("icy-metaint"))
+	}
+	stream, err := io.ReadAll(plain.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if frames := splitFrames(t, stream); len(frames) < 44100/1152 {
+		t.Errorf("expected frames: %d got: %d", 44100/1152, len(frames))
+	}
+	data, err := io.ReadAll(icy.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	interval, _ := strconv.Atoi(icy.Header.Get("icy-metaint"))
+	var audio []byte
+	for len(data) > interval {
+		audio = append(audio, data[:interval]...)
+		size := int(data[interval]) * 16
+		if size > 0 {
+			if meta := string(bytes.TrimRight(data[interval+1:interval+1+size], "\x00")); meta != "StreamTitle='Live';" {
+				t.Errorf("unexpected metadata: %q", meta)
+			}
+		}
+		data = data[interval+1+size:]
+	}
+	audio = append(audio, data...)
+	if !bytes.Equal(audio, stream) {
+		t.Errorf("listeners received different streams")
+	}
+	if b.Listeners() != 0 {
+		t.Errorf("expected listeners disconnected, got: %d", b.Listeners())
+	}
+}
+
+func TestBroadcastBurst(t *testing.T) {
+	var encoded bytes.Buffer
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.Sink(&encoded, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames := splitFrames(t, encoded.Bytes())
+
+	b := mp3.Broadcast{Burst: 200 * time.Millisecond}
+	server := httptest.NewServer(&b)
+	defer server.Close()
+	for _, frame := range frames {
+		if _, err := b.Write(frame); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 8 frames of 1152 samples are needed to fill 200ms at 44100 Hz.
+	var expected []byte
+	for _, frame := range frames[len(frames)-8:] {
+		expected = append(expected, frame...)
+	}
+	burst := make([]byte, len(expected))
+	if _, err := io.ReadFull(resp.Body, burst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(burst, expected) {
+		t.Errorf("listener received unexpected burst")
+	}
+	if _, err := b.Write([]byte{1, 2, 3}); err == nil {
+		t.Errorf("expected error writing incomplete frame")
+	}
+}
@@ -0,0 +1,57 @@
+package mp3
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// CancelPolicy determines what the Sink does when the pipe is cancelled
+// before the input is done.
+type CancelPolicy int
+
+const (
+	// FinalizeOnCancel makes the Sink flush the encoder and write
+	// headers, so the output is a valid shorter stream. It's the default
+	// policy.
+	FinalizeOnCancel CancelPolicy = iota
+	// DiscardOnCancel makes the Sink release the encoder without writing
+	// remaining data and headers. FileSink deletes the output.
+	DiscardOnCancel
+)
+
+// WithCancelPolicy sets the policy of the Sink when the pipe is
+// cancelled.
+func WithCancelPolicy(p CancelPolicy) SinkOption {
+	return func(o *sinkOptions) {
+		o.cancelPolicy = p
+	}
+}
+
+// discard reports whether the output must be discarded on flush.
+func (o sinkOptions) discard(ctx context.Context) bool {
+	return o.cancelPolicy == DiscardOnCancel && ctx.Err() != nil
+}
+
+// gateWriter drops all writes after it's closed. It allows to release
+// encoders that write data on flush.
+type gateWriter struct {
+	w      io.Writer
+	closed bool
+}
+
+func (g *gateWriter) Write(p []byte) (int, error) {
+	if g.closed {
+		return len(p), nil
+	}
+	return g.w.Write(p)
+}
+
+// Seek implements io.Seeker.
+func (g *gateWriter) Seek(offset int64, whence int) (int64, error) {
+	ws, ok := g.w.(io.WriteSeeker)
+	if !ok {
+		return 0, errors.New("writer is not seekable")
+	}
+	return ws.Seek(offset, whence)
+}
@@ -0,0 +1,71 @@
+package mp3_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkCancelPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy mp3.CancelPolicy
+		// output must start with Info tag.
+		tagged bool
+	}{
+		{name: "finalize", policy: mp3.FinalizeOnCancel, tagged: true},
+		{name: "discard", policy: mp3.DiscardOnCancel},
+	}
+	for _, test := range tests {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      1 << 30,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithCancelPolicy(test.policy),
+					mp3.WithProgress(func(p mp3.Progress) {
+						if p.Frames > 10 {
+							cancel()
+						}
+					}),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		pipe.Wait(p.Start(ctx))
+		cancel()
+		f.Close()
+
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames := splitFrames(t, data)
+		if len(frames) < 10 {
+			t.Fatalf("%s: expected at least 10 frames got: %d", test.name, len(frames))
+		}
+		if tagged := string(frames[0][4+32:4+36]) == "Info"; tagged != test.tagged {
+			t.Errorf("%s: expected tagged: %v got: %v", test.name, test.tagged, tagged)
+		}
+	}
+}
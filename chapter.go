@@ -0,0 +1,208 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxChapters is the max number of chapters in the table of contents.
+const maxChapters = 255
+
+// Chapter is the part of the stream written as CHAP frame. All chapters
+// are listed in the top-level ordered CTOC frame.
+type Chapter struct {
+	Title string
+	// Description is written as TIT3 frame of the chapter.
+	Description string
+	Start       time.Duration
+	End         time.Duration
+	// URL is written as WXXX frame of the chapter.
+	URL string
+	// Links are additional WXXX frames of the chapter, like show notes.
+	Links []UserURL
+	// Image is written as APIC frame of the chapter.
+	Image *Picture
+	// Images are additional APIC frames of the chapter.
+	Images []Picture
+}
+
+func (c Chapter) validate() error {
+	if c.Start < 0 || c.End < c.Start {
+		return fmt.Errorf("%w: chapter time %v-%v is invalid", ErrInvalidParameter, c.Start, c.End)
+	}
+	return validatePictures(c.pictures())
+}
+
+// pictures returns all images of the chapter.
+func (c Chapter) pictures() []Picture {
+	if c.Image == nil {
+		return c.Images
+	}
+	return append([]Picture{*c.Image}, c.Images...)
+}
+
+// chapterFrames returns CTOC frame followed by CHAP frames.
+func chapterFrames(t id3Text, chapters []Chapter) []id3Frame {
+	if len(chapters) == 0 {
+		return nil
+	}
+	toc := append([]byte("toc"), 0)
+	// top-level and ordered flags.
+	toc = append(toc, 0x03, byte(len(chapters)))
+	frames := []id3Frame{{id: "CTOC"}}
+	for i, c := range chapters {
+		id := "chp" + strconv.Itoa(i)
+		toc = append(append(toc, id...), 0)
+		frames = append(frames, c.frame(t, id))
+	}
+	frames[0].body = toc
+	return frames
+}
+
+// frame returns CHAP frame with embedded frames.
+func (c Chapter) frame(t id3Text, id string) id3Frame {
+	body := append([]byte(id), 0)
+	var times [16]byte
+	binary.BigEndian.PutUint32(times[0:], uint32(c.Start/time.Millisecond))
+	binary.BigEndian.PutUint32(times[4:], uint32(c.End/time.Millisecond))
+	// byte offsets are not used.
+	binary.BigEndian.PutUint32(times[8:], 0xffffffff)
+	binary.BigEndian.PutUint32(times[12:], 0xffffffff)
+	body = append(body, times[:]...)
+
+	sub := []id3Frame{textFrame(t, "TIT2", c.Title)}
+	if c.Description != "" {
+		sub = append(sub, textFrame(t, "TIT3", c.Description))
+	}
+	if c.URL != "" {
+		sub = append(sub, userURLFrame(t, "", c.URL))
+	}
+	for _, l := range c.Links {
+		sub = append(sub, l.frame(t))
+	}
+	for _, p := range c.pictures() {
+		sub = append(sub, p.frame(t))
+	}
+	return id3Frame{id: "CHAP", body: appendFrames(body, t.version, sub)}
+}
+
+// ReadChapters returns chapters of CHAP frames of ID3v2 tag at the start
+// of r. Only the tag is read. Chapters are in order of the top-level
+// table of contents if there is one, otherwise in order of time. Title,
+// description and URL of the chapter are read from its embedded frames.
+func ReadChapters(r io.Reader) ([]Chapter, error) {
+	tag, err := readID3v2(r)
+	if err != nil || tag == nil {
+		return nil, err
+	}
+	v, frames, err := parseID3v2Frames(tag)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		chapters = make(map[string]Chapter)
+		ids      []string
+		order    []string
+	)
+	for _, f := range frames {
+		switch f.id {
+		case "CHAP":
+			id, c, err := parseChapter(f.body, v)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := chapters[id]; !ok {
+				ids = append(ids, id)
+			}
+			chapters[id] = c
+		case "CTOC":
+			if entries, ok := parseTopLevelTOC(f.body); ok {
+				order = entries
+			}
+		}
+	}
+	if order == nil {
+		sort.SliceStable(ids, func(i, j int) bool {
+			return chapters[ids[i]].Start < chapters[ids[j]].Start
+		})
+		order = ids
+	}
+	result := make([]Chapter, 0, len(order))
+	for _, id := range order {
+		if c, ok := chapters[id]; ok {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// parseChapter returns element id and the chapter of CHAP frame body.
+func parseChapter(body []byte, v ID3Version) (string, Chapter, error) {
+	id := id3Terminated(body, 0)
+	if len(body) < len(id)+1+16 {
+		return "", Chapter{}, fmt.Errorf("%w: CHAP frame of %d bytes", errInvalidID3v2, len(body))
+	}
+	times := body[len(id)+1:]
+	c := Chapter{
+		Start: time.Duration(binary.BigEndian.Uint32(times[0:])) * time.Millisecond,
+		End:   time.Duration(binary.BigEndian.Uint32(times[4:])) * time.Millisecond,
+	}
+	sub, err := parseFrames(times[16:], v)
+	if err != nil {
+		return "", Chapter{}, err
+	}
+	for _, f := range sub {
+		if len(f.body) == 0 {
+			continue
+		}
+		e := f.body[0]
+		switch f.id {
+		case "TIT2":
+			c.Title = decodeString(e, id3Terminated(f.body[1:], e))
+		case "TIT3":
+			c.Description = decodeString(e, id3Terminated(f.body[1:], e))
+		case "WXXX":
+			// URL of the chapter has empty description and is followed
+			// by links.
+			desc := id3Terminated(f.body[1:], e)
+			if decodeString(e, desc) != "" || c.URL != "" {
+				continue
+			}
+			terminator := 1
+			if e == 1 || e == 2 {
+				terminator = 2
+			}
+			if url := f.body[1+len(desc):]; len(url) >= terminator {
+				c.URL = string(id3Terminated(url[terminator:], 0))
+			}
+		}
+	}
+	return string(id), c, nil
+}
+
+// parseTopLevelTOC returns child element ids of the top-level CTOC
+// frame body.
+func parseTopLevelTOC(body []byte) ([]string, bool) {
+	id := id3Terminated(body, 0)
+	if len(body) < len(id)+3 || body[len(id)+1]&0x02 == 0 {
+		return nil, false
+	}
+	var (
+		count   = int(body[len(id)+2])
+		entries = body[len(id)+3:]
+		ids     = make([]string, 0, count)
+	)
+	for i := 0; i < count && len(entries) > 0; i++ {
+		entry := id3Terminated(entries, 0)
+		ids = append(ids, string(entry))
+		if len(entry) == len(entries) {
+			break
+		}
+		entries = entries[len(entry)+1:]
+	}
+	return ids, true
+}
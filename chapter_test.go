@@ -0,0 +1,138 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestSinkChapters(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), 1)
+	tests := []struct {
+		name     string
+		chapters []mp3.Chapter
+		// expected CTOC and CHAP frame bodies.
+		expected [][]byte
+		err      error
+	}{
+		{
+			name: "chapters",
+			chapters: []mp3.Chapter{
+				{Title: "Intro", End: 1500 * time.Millisecond},
+				{Title: "Main", Start: 1500 * time.Millisecond, End: 3 * time.Second, URL: "https://example.com", Image: &mp3.Picture{Data: png}},
+			},
+			expected: [][]byte{
+				[]byte("toc\x00\x03\x02chp0\x00chp1\x00"),
+				concat(
+					[]byte("chp0\x00\x00\x00\x00\x00\x00\x00\x05\xdc\xff\xff\xff\xff\xff\xff\xff\xff"),
+					[]byte("TIT2\x00\x00\x00\x06\x00\x00\x03Intro"),
+				),
+				concat(
+					[]byte("chp1\x00\x00\x00\x05\xdc\x00\x00\x0b\xb8\xff\xff\xff\xff\xff\xff\xff\xff"),
+					[]byte("TIT2\x00\x00\x00\x05\x00\x00\x03Main"),
+					[]byte("WXXX\x00\x00\x00\x15\x00\x00\x03\x00https://example.com"),
+					[]byte("APIC\x00\x00\x00\x16\x00\x00\x03image/png\x00\x00\x00"), png,
+				),
+			},
+		},
+		{
+			name: "links and images",
+			chapters: []mp3.Chapter{
+				{
+					Title:       "Intro",
+					Description: "Hello",
+					End:         time.Second,
+					Links:       []mp3.UserURL{{Description: "notes", URL: "https://a"}},
+					Image:       &mp3.Picture{Type: mp3.FrontCover, Data: png},
+					Images:      []mp3.Picture{{Type: mp3.Illustration, Data: png}},
+				},
+			},
+			expected: [][]byte{
+				[]byte("toc\x00\x03\x01chp0\x00"),
+				concat(
+					[]byte("chp0\x00\x00\x00\x00\x00\x00\x00\x03\xe8\xff\xff\xff\xff\xff\xff\xff\xff"),
+					[]byte("TIT2\x00\x00\x00\x06\x00\x00\x03Intro"),
+					[]byte("TIT3\x00\x00\x00\x06\x00\x00\x03Hello"),
+					[]byte("WXXX\x00\x00\x00\x10\x00\x00\x03notes\x00https://a"),
+					[]byte("APIC\x00\x00\x00\x16\x00\x00\x03image/png\x00\x03\x00"), png,
+					[]byte("APIC\x00\x00\x00\x16\x00\x00\x03image/png\x00\x12\x00"), png,
+				),
+			},
+		},
+		{
+			name: "invalid image",
+			chapters: []mp3.Chapter{
+				{End: time.Second, Images: []mp3.Picture{{Type: mp3.FrontCover}}},
+			},
+			err: mp3.ErrInvalidParameter,
+		},
+		{
+			name:     "invalid time",
+			chapters: []mp3.Chapter{{Start: time.Second}},
+			err:      mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(mp3.WithID3v2(mp3.ID3v2{Chapters: test.chapters}))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames, _ := parseID3v2(t, data)
+		if len(frames) != len(test.expected) {
+			t.Fatalf("%s: expected frames: %d got: %d", test.name, len(test.expected), len(frames))
+		}
+		for i, f := range frames {
+			id := "CHAP"
+			if i == 0 {
+				id = "CTOC"
+			}
+			if f.id != id || !bytes.Equal(f.body, test.expected[i]) {
+				t.Errorf("%s: expected %s frame:\n%q\ngot %s:\n%q", test.name, id, test.expected[i], f.id, f.body)
+			}
+		}
+	}
+}
+
+func concat(b ...[]byte) []byte {
+	return bytes.Join(b, nil)
+}
+
+func TestReadChapters(t *testing.T) {
+	chapters := []mp3.Chapter{
+		{Title: "Second", Description: "Later", Start: 500 * time.Millisecond, End: time.Second, URL: "https://example.com"},
+		{Title: "First", End: 500 * time.Millisecond, Links: []mp3.UserURL{{Description: "notes", URL: "https://example.com/notes"}}},
+	}
+	for _, v := range []mp3.ID3Version{mp3.ID3v23, mp3.ID3v24} {
+		data, err := encodeTagged(mp3.WithID3v2(mp3.ID3v2{Version: v, Encoding: mp3.UTF16, Chapters: chapters}))
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", v, err)
+		}
+		got, err := mp3.ReadChapters(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", v, err)
+		}
+		// links and images aren't read.
+		expected := []mp3.Chapter{chapters[0], {Title: "First", End: 500 * time.Millisecond}}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%v: expected chapters: %+v got: %+v", v, expected, got)
+		}
+	}
+
+	data, err := encodeTagged()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := mp3.ReadChapters(bytes.NewReader(data)); err != nil || got != nil {
+		t.Errorf("expected no chapters got: %v %v", got, err)
+	}
+}
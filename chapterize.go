@@ -0,0 +1,228 @@
+package mp3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTimestamps is returned when the timestamps file can't be
+// parsed.
+var ErrInvalidTimestamps = errors.New("invalid timestamps")
+
+// ParseTimestamps parses chapters from lines like "01:02:03.500 Title"
+// or "02:03 - Title" where hours and milliseconds are optional. Empty
+// lines and lines that start with # are ignored. Every chapter ends at the
+// start of the next one and the last chapter has zero end, so it lasts
+// until the end of the stream. Chapters must be in order of time.
+func ParseTimestamps(r io.Reader) ([]Chapter, error) {
+	var (
+		chapters []Chapter
+		scanner  = bufio.NewScanner(r)
+		line     int
+	)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		start, err := parseTimestamp(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidTimestamps, line, err)
+		}
+		var title string
+		if len(fields) > 1 {
+			title = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(fields[1]), "-"))
+		}
+		if n := len(chapters); n > 0 {
+			if start <= chapters[n-1].Start {
+				return nil, fmt.Errorf("%w: line %d: chapter starts before the previous one", ErrInvalidTimestamps, line)
+			}
+			chapters[n-1].End = start
+		}
+		chapters = append(chapters, Chapter{Title: title, Start: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading timestamps: %w", err)
+	}
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("%w: no chapters", ErrInvalidTimestamps)
+	}
+	return chapters, nil
+}
+
+// parseTimestamp parses [HH:]MM:SS[.mmm] timestamp.
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("timestamp %q is invalid", s)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 || seconds >= 60 {
+		return 0, fmt.Errorf("timestamp %q is invalid", s)
+	}
+	d := time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+	for i, unit := range []time.Duration{time.Minute, time.Hour}[:len(parts)-1] {
+		v, err := strconv.Atoi(parts[len(parts)-2-i])
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("timestamp %q is invalid", s)
+		}
+		d += time.Duration(v) * unit
+	}
+	return d, nil
+}
+
+// Chapterize copies MP3 stream from r into w and writes chapters as CHAP
+// frames with table of contents into its ID3v2 tag without re-encoding.
+// Chapters of the existing tag are replaced, other frames are kept.
+// Chapters with zero end last until the end of the stream.
+func Chapterize(r io.Reader, w io.Writer, chapters []Chapter) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading MP3 data: %w", err)
+	}
+	in, err := readCutInput(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	chapters, err = resolveChapters(chapters, in.rate.Duration(in.samples()))
+	if err != nil {
+		return err
+	}
+	var (
+		tag     []byte
+		version = ID3v24
+		kept    []id3Frame
+	)
+	if bytes.HasPrefix(data, []byte("ID3")) && len(data) >= id3v2HeaderSize {
+		size := id3v2TagSize(data)
+		if size > len(data) {
+			return fmt.Errorf("%w: tag of %d bytes exceeds the stream", errInvalidID3v2, size)
+		}
+		tag, data = data[:size], data[size:]
+		var frames []id3Frame
+		if version, frames, err = parseID3v2Frames(tag); err != nil {
+			return err
+		}
+		for _, f := range frames {
+			if f.id != "CHAP" && f.id != "CTOC" {
+				kept = append(kept, f)
+			}
+		}
+	}
+	t := ID3v2{Version: version, Chapters: chapters}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	// padding of the existing tag is kept if frames fit it.
+	if tag, err = t.bytes(kept, len(tag)); err != nil {
+		return err
+	}
+	if _, err := w.Write(tag); err != nil {
+		return fmt.Errorf("error writing ID3v2 tag: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("error copying audio: %w", err)
+	}
+	return nil
+}
+
+// SplitChapters splits MP3 stream from r into files of chapters without
+// re-encoding. Chapters are cut at the frame boundaries with gapless
+// offsets the same way as Cut does and tagged with their titles and
+// numbers. Chapters with zero end last until the end of the stream. Path
+// returns the path of the chapter file with its index.
+func SplitChapters(r io.Reader, chapters []Chapter, path func(chapter int) string, options ...SinkOption) ([]Track, error) {
+	if path == nil {
+		return nil, fmt.Errorf("%w: chapter path function is nil", ErrInvalidParameter)
+	}
+	in, err := readCutInput(r)
+	if err != nil {
+		return nil, err
+	}
+	return in.splitChapters(chapters, path, options)
+}
+
+// SplitByChapters splits MP3 stream from r into files of chapters of its
+// ID3v2 tag the same way as SplitChapters does. Chapters that end after
+// the end of the stream are cut at its end.
+func SplitByChapters(r io.Reader, path func(chapter int) string, options ...SinkOption) ([]Track, error) {
+	if path == nil {
+		return nil, fmt.Errorf("%w: chapter path function is nil", ErrInvalidParameter)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading MP3 data: %w", err)
+	}
+	chapters, err := ReadChapters(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("%w: stream has no chapters", ErrInvalidParameter)
+	}
+	in, err := readCutInput(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	// chapter times are rounded to milliseconds.
+	duration := in.rate.Duration(in.samples())
+	for i := range chapters {
+		if chapters[i].End > duration {
+			chapters[i].End = duration
+		}
+	}
+	return in.splitChapters(chapters, path, options)
+}
+
+// splitChapters writes files of chapters.
+func (in cutInput) splitChapters(chapters []Chapter, path func(int) string, options []SinkOption) ([]Track, error) {
+	chapters, err := resolveChapters(chapters, in.rate.Duration(in.samples()))
+	if err != nil {
+		return nil, err
+	}
+	tracks := make([]Track, 0, len(chapters))
+	for i, c := range chapters {
+		tag := ID3v2{
+			Text: []TextFrame{
+				{ID: "TIT2", Value: c.Title},
+				{ID: "TRCK", Value: strconv.Itoa(i+1) + "/" + strconv.Itoa(len(chapters))},
+			},
+		}
+		from, to := in.rate.Events(c.Start), in.rate.Events(c.End)
+		if err := in.writeTrack(path(i), from, to, append(options[:len(options):len(options)], WithID3v2(tag))); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, Track{Start: c.Start, End: c.End})
+	}
+	return tracks, nil
+}
+
+// resolveChapters returns the copy of chapters with zero ends set to the
+// duration and checks that chapters are within the stream.
+func resolveChapters(chapters []Chapter, duration time.Duration) ([]Chapter, error) {
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("%w: no chapters", ErrInvalidParameter)
+	}
+	resolved := make([]Chapter, len(chapters))
+	for i, c := range chapters {
+		if c.End == 0 {
+			c.End = duration
+		}
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("chapter %d: %w", i, err)
+		}
+		if c.Start >= duration || c.End > duration {
+			return nil, fmt.Errorf("%w: chapter %d %v-%v exceeds the stream of %v", ErrInvalidParameter, i, c.Start, c.End, duration)
+		}
+		resolved[i] = c
+	}
+	return resolved, nil
+}
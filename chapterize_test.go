@@ -0,0 +1,202 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestParseTimestamps(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []mp3.Chapter
+		err      error
+	}{
+		{
+			name:  "timestamps",
+			input: "# lecture\n00:00 Intro\n\n1:30.250 - Part one\n01:02:03 Part two\n",
+			expected: []mp3.Chapter{
+				{Title: "Intro", Start: 0, End: 90250 * time.Millisecond},
+				{Title: "Part one", Start: 90250 * time.Millisecond, End: time.Hour + 2*time.Minute + 3*time.Second},
+				{Title: "Part two", Start: time.Hour + 2*time.Minute + 3*time.Second},
+			},
+		},
+		{
+			name:  "without title",
+			input: "0:05",
+			expected: []mp3.Chapter{
+				{Start: 5 * time.Second},
+			},
+		},
+		{
+			name:  "invalid timestamp",
+			input: "0:61 Title",
+			err:   mp3.ErrInvalidTimestamps,
+		},
+		{
+			name:  "out of order",
+			input: "0:10 Second\n0:05 First",
+			err:   mp3.ErrInvalidTimestamps,
+		},
+		{
+			name:  "empty",
+			input: "# nothing",
+			err:   mp3.ErrInvalidTimestamps,
+		},
+	}
+	for _, test := range tests {
+		chapters, err := mp3.ParseTimestamps(strings.NewReader(test.input))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(chapters, test.expected) {
+			t.Errorf("%s: expected chapters: %+v got: %+v", test.name, test.expected, chapters)
+		}
+	}
+}
+
+func TestChapterize(t *testing.T) {
+	input, err := encodeTagged(mp3.WithID3v2(mp3.ID3v2{
+		Text:     []mp3.TextFrame{{ID: "TIT2", Value: "Lecture"}},
+		Chapters: []mp3.Chapter{{Title: "Old", End: time.Second}},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chapters, err := mp3.ParseTimestamps(strings.NewReader("0:00 Intro\n0:00.400 Outro"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := mp3.Chapterize(bytes.NewReader(input), &buf, chapters); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames, audio := parseID3v2(t, buf.Bytes())
+	var ids []string
+	for _, f := range frames {
+		ids = append(ids, f.id)
+	}
+	if expected := []string{"CTOC", "CHAP", "CHAP", "TIT2"}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected frames: %v got: %v", expected, ids)
+	}
+	// the last chapter ends at the end of the stream of a second.
+	if end := frames[2].body[len("chp1")+1+4 : len("chp1")+1+8]; !bytes.Equal(end, []byte{0, 0, 0x03, 0xe8}) {
+		t.Errorf("expected end of the last chapter at 1000 ms got: %v", end)
+	}
+	if _, original := parseID3v2(t, input); !bytes.Equal(audio, original) {
+		t.Errorf("expected audio copied as is")
+	}
+
+	chapters[1].Start = time.Minute
+	if err := mp3.Chapterize(bytes.NewReader(input), &buf, chapters); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
+
+func TestSplitChapters(t *testing.T) {
+	input := encodeTracks(t, tracksSource(3*time.Second))
+	dir, err := os.MkdirTemp("", "chapters")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := func(chapter int) string {
+		return filepath.Join(dir, strconv.Itoa(chapter)+".mp3")
+	}
+	chapters := []mp3.Chapter{
+		{Title: "First", End: time.Second},
+		{Title: "Second", Start: 2 * time.Second},
+	}
+	tracks, err := mp3.SplitChapters(bytes.NewReader(input), chapters, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []mp3.Track{
+		{Start: 0, End: time.Second},
+		{Start: 2 * time.Second, End: 3 * time.Second},
+	}
+	if !reflect.DeepEqual(tracks, expected) {
+		t.Errorf("expected tracks: %v got: %v", expected, tracks)
+	}
+	for i, c := range chapters {
+		data, err := os.ReadFile(path(i))
+		if err != nil {
+			t.Fatalf("chapter %d: unexpected error: %v", i, err)
+		}
+		frames, _ := parseID3v2(t, data)
+		var text []string
+		for _, f := range frames {
+			// skip encoding byte and trailing null.
+			text = append(text, f.id+" "+strings.TrimRight(string(f.body[1:]), "\x00"))
+		}
+		if expected := []string{"TIT2 " + c.Title, "TRCK " + strconv.Itoa(i+1) + "/2"}; !reflect.DeepEqual(text, expected) {
+			t.Errorf("chapter %d: expected frames: %q got: %q", i, expected, text)
+		}
+	}
+
+	if _, err := mp3.SplitChapters(bytes.NewReader(input), chapters, nil); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
+
+func TestSplitByChapters(t *testing.T) {
+	dir, err := os.MkdirTemp("", "chapters")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := func(chapter int) string {
+		return filepath.Join(dir, strconv.Itoa(chapter)+".mp3")
+	}
+	input, err := encodeTagged(mp3.WithID3v2(mp3.ID3v2{Chapters: []mp3.Chapter{
+		{Title: "First", End: 500 * time.Millisecond},
+		// ends after the stream of a second because of rounding.
+		{Title: "Second", Start: 500 * time.Millisecond, End: 1001 * time.Millisecond},
+	}}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tracks, err := mp3.SplitByChapters(bytes.NewReader(input), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []mp3.Track{
+		{Start: 0, End: 500 * time.Millisecond},
+		{Start: 500 * time.Millisecond, End: time.Second},
+	}
+	if !reflect.DeepEqual(tracks, expected) {
+		t.Errorf("expected tracks: %v got: %v", expected, tracks)
+	}
+	for i, title := range []string{"First", "Second"} {
+		data, err := os.ReadFile(path(i))
+		if err != nil {
+			t.Fatalf("chapter %d: unexpected error: %v", i, err)
+		}
+		if frames, _ := parseID3v2(t, data); len(frames) == 0 || strings.TrimRight(string(frames[0].body[1:]), "\x00") != title {
+			t.Errorf("chapter %d: expected title %q got: %q", i, title, frames)
+		}
+	}
+
+	untagged, err := encodeTagged()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := mp3.SplitByChapters(bytes.NewReader(untagged), path); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
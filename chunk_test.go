@@ -0,0 +1,113 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestReadChunkSize(t *testing.T) {
+	var expected signal.Floating
+	for _, chunk := range []int{0, 1, 100, bufferSize, 4096} {
+		inFile, err := os.Open(sample)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var options []mp3.SourceOption
+		if chunk != 0 {
+			options = append(options, mp3.WithReadChunkSize(chunk))
+		}
+		sink := mock.Sink{}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: mp3.Source(inFile, options...),
+				Sink:   sink.Sink(),
+			},
+		)
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", chunk, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", chunk, err)
+		}
+		_ = inFile.Close()
+		if sink.Counter.Samples != mp3Samples {
+			t.Errorf("chunk %d: expected samples: %d got: %d", chunk, mp3Samples, sink.Counter.Samples)
+		}
+		// decoded signal doesn't depend on the chunk size.
+		if expected == nil {
+			expected = sink.Values
+			continue
+		}
+		for i := 0; i < expected.Len(); i++ {
+			if expected.Sample(i) != sink.Values.Sample(i) {
+				t.Fatalf("chunk %d: expected sample %d: %v got: %v", chunk, i, expected.Sample(i), sink.Values.Sample(i))
+			}
+		}
+	}
+}
+
+func TestConvertChunkSize(t *testing.T) {
+	floats := signal.Allocator{Channels: 2, Length: bufferSize, Capacity: bufferSize}.Float64()
+	for i := 0; i < floats.Len(); i++ {
+		floats.SetSample(i, float64(i%200-100)/100)
+	}
+	var expected []byte
+	for _, test := range []struct {
+		chunk  int
+		writes int
+	}{
+		{chunk: 0, writes: 1},
+		{chunk: 100, writes: 6},
+		{chunk: bufferSize, writes: 1},
+		{chunk: 4096, writes: 1},
+	} {
+		var (
+			encoder recordingEncoder
+			options = []mp3.SinkOption{mp3.WithEncoder(func() mp3.Encoder { return &encoder })}
+		)
+		if test.chunk != 0 {
+			options = append(options, mp3.WithConvertChunkSize(test.chunk))
+		}
+		sink, err := mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)(
+			mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", test.chunk, err)
+		}
+		if err := sink.SinkFunc(floats); err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", test.chunk, err)
+		}
+		if encoder.writes != test.writes {
+			t.Errorf("chunk %d: expected %d writes got: %d", test.chunk, test.writes, encoder.writes)
+		}
+		// converted PCM doesn't depend on the chunk size.
+		if expected == nil {
+			expected = encoder.pcm
+			continue
+		}
+		if !bytes.Equal(expected, encoder.pcm) {
+			t.Errorf("chunk %d: PCM doesn't match", test.chunk)
+		}
+	}
+}
+
+func TestChunkSizeInvalid(t *testing.T) {
+	if _, err := mp3.Source(bytes.NewReader(nil), mp3.WithReadChunkSize(0))(mutable.Mutable(), bufferSize); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+	_, err := mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithConvertChunkSize(-1))(
+		mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,124 @@
+package mp3
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// maxAlignOffset is the max shift in samples between streams without
+	// gapless metadata that is detected by Compare. It's two MPEG-1
+	// frames.
+	maxAlignOffset = 2 * 1152
+	// alignWindow is the number of samples used to align streams.
+	alignWindow = 16384
+)
+
+// Difference is the difference between decoded audio of two streams.
+// Sample values are normalized to full scale.
+type Difference struct {
+	// Samples is the number of compared samples per channel.
+	Samples int
+	// Offset is the number of samples per channel the second stream is
+	// shifted by to align with the first one.
+	Offset int
+	// Length is the length of the second stream minus the length of the
+	// first one in samples per channel.
+	Length int
+	// Max is the max absolute difference of samples.
+	Max float64
+	// RMS is the root mean square of the difference.
+	RMS float64
+}
+
+// Compare decodes two MP3 streams and returns the difference of their
+// audio. Encoder delay and padding are removed if the stream has them in
+// Xing/Info frame. If any of the streams doesn't, the offset up to two
+// frames that aligns their audio best is detected. Only overlapping parts
+// of aligned streams are compared.
+func Compare(a, b io.Reader) (Difference, error) {
+	first, gapless, err := readCompared(a)
+	if err != nil {
+		return Difference{}, fmt.Errorf("first stream: %w", err)
+	}
+	second, secondGapless, err := readCompared(b)
+	if err != nil {
+		return Difference{}, fmt.Errorf("second stream: %w", err)
+	}
+	d := Difference{
+		Length: len(second) - len(first),
+	}
+	if !gapless || !secondGapless {
+		d.Offset = alignOffset(first, second, maxAlignOffset)
+	}
+	var sum float64
+	for i := range first {
+		j := i + d.Offset
+		if j < 0 {
+			continue
+		}
+		if j >= len(second) {
+			break
+		}
+		for c := range first[i] {
+			diff := math.Abs(float64(first[i][c])-float64(second[j][c])) / math.MaxInt16
+			d.Max = math.Max(d.Max, diff)
+			sum += diff * diff
+		}
+		d.Samples++
+	}
+	if d.Samples > 0 {
+		d.RMS = math.Sqrt(sum / float64(2*d.Samples))
+	}
+	return d, nil
+}
+
+// readCompared returns decoded stereo samples of the stream and reports
+// if delay and padding are removed.
+func readCompared(r io.Reader) ([][2]int16, bool, error) {
+	in, err := readCutInput(r)
+	if err != nil {
+		return nil, false, err
+	}
+	samples := make([][2]int16, 0, len(in.frames)*in.perFrame)
+	err = in.decode(func(left, right int16) {
+		samples = append(samples, [2]int16{left, right})
+	})
+	return samples, in.gapless, err
+}
+
+// alignOffset returns the shift of b within [-max, max] that has the
+// least mean squared difference with the start of a.
+func alignOffset(a, b [][2]int16, max int) int {
+	var (
+		offset int
+		best   = math.Inf(1)
+	)
+	for lag := -max; lag <= max; lag++ {
+		var (
+			diff float64
+			n    int
+		)
+		for i := 0; i < len(a) && i < alignWindow; i++ {
+			j := i + lag
+			if j < 0 {
+				continue
+			}
+			if j >= len(b) {
+				break
+			}
+			l := float64(a[i][0]) - float64(b[j][0])
+			r := float64(a[i][1]) - float64(b[j][1])
+			diff += l*l + r*r
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		if diff /= float64(n); diff < best {
+			best, offset = diff, lag
+		}
+	}
+	return offset
+}
@@ -0,0 +1,65 @@
+package mp3_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestCompare(t *testing.T) {
+	input := encodeTracks(t, chirpSource(time.Second))
+	var louder bytes.Buffer
+	if err := mp3.AdjustGain(bytes.NewReader(input), &louder, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames := splitFrames(t, input)
+	// shine delay and decoder delay are kept without Info frame.
+	stripped := bytes.Join(frames[1:], nil)
+	tests := []struct {
+		name     string
+		second   []byte
+		offset   int
+		length   int
+		max      float64
+		maxDelta float64
+	}{
+		{
+			name:   "identical",
+			second: input,
+		},
+		{
+			name:   "without gapless metadata",
+			second: stripped,
+			offset: 528 + 529,
+			length: 528 + 1452,
+		},
+		{
+			name:   "louder",
+			second: louder.Bytes(),
+			// peak is 3 dB louder.
+			max:      decodedPeak(t, input) * (math.Sqrt2 - 1),
+			maxDelta: 0.01,
+		},
+	}
+	for _, test := range tests {
+		d, err := mp3.Compare(bytes.NewReader(input), bytes.NewReader(test.second))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if d.Offset != test.offset || d.Length != test.length || math.Abs(d.Max-test.max) > test.maxDelta {
+			t.Errorf("%s: unexpected difference: %+v", test.name, d)
+		}
+		if d.Samples != 44100 {
+			t.Errorf("%s: expected %d samples got: %d", test.name, 44100, d.Samples)
+		}
+		if test.max == 0 && d.RMS != 0 || test.max != 0 && (d.RMS == 0 || d.RMS > d.Max) {
+			t.Errorf("%s: unexpected RMS: %v", test.name, d.RMS)
+		}
+	}
+	if _, err := mp3.Compare(bytes.NewReader(input), bytes.NewReader([]byte("junk"))); err == nil {
+		t.Errorf("expected error")
+	}
+}
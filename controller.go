@@ -0,0 +1,268 @@
+package mp3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"pipelined.dev/pipe/mutable"
+)
+
+// Controller allows to change encoder settings of the running Sink with
+// pipe mutations. Controller can be bound only to a single Sink.
+type Controller struct {
+	mctx        mutable.Context
+	reconfigure func(BitRateMode, EncodingQuality) error
+}
+
+// WithController binds the controller to the Sink. Sink with controller
+// doesn't write VBR header, because it would describe only the stream
+// encoded before the first reconfiguration. It doesn't use bit reservoir
+// and encodes at the input sample rate unless output sample rate is set,
+// so streams of encoders can be spliced at frame boundaries.
+func WithController(c *Controller) SinkOption {
+	return func(o *sinkOptions) {
+		o.controller = c
+		o.disableVBRTag = true
+		o.disableReservoir = true
+	}
+}
+
+// Reconfigure returns the mutation that changes bit rate mode and
+// quality of the Sink. New encoder is primed with the input of a few
+// frames before the next frame boundary and its stream replaces the
+// stream of the current encoder from that boundary, so the switch
+// doesn't insert a gap. Resampled streams and custom encoders don't keep
+// the frame grid aligned with the input, then current encoder is flushed
+// and the switch inserts a gap of about one to two frames. Encoding info
+// reported after flush describes only the stream of the last encoder in
+// that case. The mutation fails if the settings are invalid. It panics if
+// controller isn't bound to the Sink yet.
+func (c *Controller) Reconfigure(brm BitRateMode, eq EncodingQuality) mutable.Mutation {
+	return c.mctx.Mutate(func() error {
+		return c.reconfigure(brm, eq)
+	})
+}
+
+func (c *Controller) bind(mctx mutable.Context, fn func(BitRateMode, EncodingQuality) error) {
+	c.mctx = mctx
+	c.reconfigure = fn
+}
+
+// switchEncoder allows to replace encoder while the sink is running. If
+// streams are spliced, replaced encoder keeps encoding the input until
+// it covers the overlap after the boundary and the output of the new
+// encoder is held until then.
+type switchEncoder struct {
+	Encoder
+	cfg encoderConfig
+	w   io.Writer
+	// out is the writer of the current encoder. It's nil if streams
+	// aren't spliced.
+	out          *spliceWriter
+	frameSamples int
+	blockAlign   int
+	// history is the last input to prime the next encoder.
+	history []byte
+	// samples is the number of input samples per channel.
+	samples int
+
+	prev    Encoder
+	prevOut *spliceWriter
+	// prevEnd is the input sample where replaced encoder is flushed.
+	prevEnd int
+	held    bytes.Buffer
+	// frames written by replaced encoders and delay of the first one.
+	frames   int
+	delay    int
+	switched bool
+}
+
+// spliceWriter passes whole frames of the encoder stream that are within
+// [first, last) of the frame grid. Frames are numbered from the start of
+// the sink stream and frame is the number of the next encoded frame.
+type spliceWriter struct {
+	w     io.Writer
+	buf   []byte
+	frame int
+	first int
+	// last is negative if stream isn't limited.
+	last    int
+	written int
+}
+
+// newSwitchEncoder returns the encoder that writes into w.
+func newSwitchEncoder(cfg encoderConfig, w io.Writer) (*switchEncoder, error) {
+	se := switchEncoder{cfg: cfg, w: w}
+	if cfg.opts.controller != nil && cfg.opts.newEncoder == nil && cfg.opts.outSampleRate == cfg.sampleRate {
+		se.frameSamples = frameHeader{version: mpeg1}.samples()
+		if cfg.sampleRate < 32000 {
+			se.frameSamples = frameHeader{version: mpeg2}.samples()
+		}
+		se.blockAlign = bytesPerSample * cfg.channels
+		se.out = &spliceWriter{w: w, last: -1}
+		w = se.out
+	}
+	encoder, err := cfg.newEncoder(w)
+	if err != nil {
+		return nil, err
+	}
+	se.Encoder = encoder
+	return &se, nil
+}
+
+// Write implements Encoder.
+func (se *switchEncoder) Write(p []byte) (int, error) {
+	if se.out == nil {
+		return se.Encoder.Write(p)
+	}
+	if se.prev != nil {
+		left := (se.prevEnd - se.samples) * se.blockAlign
+		n := left
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := se.prev.Write(p[:n]); err != nil {
+			return 0, err
+		}
+		if n == left {
+			if err := se.finishSwitch(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := se.Encoder.Write(p)
+	se.remember(p[:n])
+	return n, err
+}
+
+// remember keeps the input of overlap and the incomplete frame.
+func (se *switchEncoder) remember(p []byte) {
+	se.samples += len(p) / se.blockAlign
+	size := (parallelOverlapFrames + 1) * se.frameSamples * se.blockAlign
+	if len(p) >= size {
+		se.history = append(se.history[:0], p[len(p)-size:]...)
+		return
+	}
+	if keep := size - len(p); len(se.history) > keep {
+		se.history = append(se.history[:0], se.history[len(se.history)-keep:]...)
+	}
+	se.history = append(se.history, p...)
+}
+
+// Flush implements Encoder.
+func (se *switchEncoder) Flush() error {
+	if se.prev != nil {
+		if err := se.finishSwitch(); err != nil {
+			se.Encoder.Flush()
+			return err
+		}
+	}
+	return se.Encoder.Flush()
+}
+
+// reconfigure replaces the encoder with the new one.
+func (se *switchEncoder) reconfigure(brm BitRateMode, eq EncodingQuality) error {
+	next := se.cfg
+	next.brm, next.eq = brm, eq
+	if err := validate(brm, next.cm, eq, next.opts.outSampleRate, next.channels); err != nil {
+		return err
+	}
+	if err := next.validateBackend(); err != nil {
+		return err
+	}
+	if se.out == nil {
+		if err := se.Encoder.Flush(); err != nil {
+			return fmt.Errorf("error flushing MP3 encoder: %w", err)
+		}
+		encoder, err := next.newEncoder(se.w)
+		if err != nil {
+			return err
+		}
+		se.Encoder, se.cfg = encoder, next
+		return nil
+	}
+	if se.prev != nil {
+		if err := se.finishSwitch(); err != nil {
+			return err
+		}
+	}
+	boundary := se.samples/se.frameSamples + 1
+	start := (boundary - parallelOverlapFrames) * se.frameSamples
+	if start < 0 {
+		start = 0
+	}
+	out := &spliceWriter{w: &se.held, frame: start / se.frameSamples, first: boundary, last: -1}
+	encoder, err := next.newEncoder(out)
+	if err != nil {
+		return err
+	}
+	if _, err := encoder.Write(se.history[len(se.history)-(se.samples-start)*se.blockAlign:]); err != nil {
+		encoder.Flush()
+		return fmt.Errorf("error writing MP3 buffer: %w", err)
+	}
+	se.out.last = boundary
+	se.prev, se.prevOut = se.Encoder, se.out
+	se.prevEnd = (boundary + parallelOverlapFrames) * se.frameSamples
+	se.Encoder, se.out, se.cfg = encoder, out, next
+	return nil
+}
+
+// finishSwitch flushes replaced encoder and writes the held output of
+// the current one.
+func (se *switchEncoder) finishSwitch() error {
+	prev := se.prev
+	se.prev = nil
+	if err := prev.Flush(); err != nil {
+		return fmt.Errorf("error flushing MP3 encoder: %w", err)
+	}
+	if ie, ok := prev.(InfoEncoder); ok && !se.switched {
+		se.delay = ie.EncodingInfo().Delay
+	}
+	se.frames += se.prevOut.written
+	se.switched = true
+	if _, err := se.w.Write(se.held.Bytes()); err != nil {
+		return err
+	}
+	se.held.Reset()
+	se.out.w = se.w
+	return nil
+}
+
+// encodingInfo returns the info of flushed encoder. Spliced streams are
+// described as a single stream.
+func (se *switchEncoder) encodingInfo(samples int) EncodingInfo {
+	info := encodingInfo(se.Encoder, samples)
+	if !se.switched || info.Frames == 0 {
+		return info
+	}
+	info.Frames = se.frames + se.out.written
+	info.Delay = se.delay
+	info.Padding = info.Frames*se.frameSamples - info.Delay - samples
+	return info
+}
+
+func (s *spliceWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	b := s.buf
+	for len(b) >= frameHeaderSize {
+		h, err := parseFrameHeader(b)
+		if err != nil {
+			return 0, fmt.Errorf("error splitting MP3 frames: %w", err)
+		}
+		size := h.size()
+		if size > len(b) {
+			break
+		}
+		if s.frame >= s.first && (s.last < 0 || s.frame < s.last) {
+			if _, err := s.w.Write(b[:size]); err != nil {
+				return 0, err
+			}
+			s.written++
+		}
+		s.frame++
+		b = b[size:]
+	}
+	s.buf = append(s.buf[:0], b...)
+	return len(p), nil
+}
@@ -0,0 +1,160 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/audio/mp3/mp3test"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkController(t *testing.T) {
+	tests := []struct {
+		name string
+		brm  mp3.BitRateMode
+		err  error
+	}{
+		{name: "cbr 64", brm: mp3.CBR(64)},
+		{name: "invalid", brm: mp3.CBR(1000), err: mp3.ErrInvalidParameter},
+	}
+	for _, test := range tests {
+		var (
+			buf      bytes.Buffer
+			ctrl     mp3.Controller
+			p        *pipe.Pipe
+			switched bool
+		)
+		ctx, cancel := context.WithCancel(context.Background())
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      1 << 30,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.Sink(&buf, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithController(&ctrl),
+					mp3.WithProgress(func(pr mp3.Progress) {
+						switch {
+						case pr.Frames > 10 && !switched:
+							switched = true
+							go p.Push(ctrl.Reconfigure(test.brm, mp3.DefaultEncodingQuality))
+						case pr.Frames > 100:
+							cancel()
+						}
+					}),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		err = pipe.Wait(p.Start(ctx))
+		cancel()
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		frames := splitFrames(t, buf.Bytes())
+		if string(frames[0][4+32:4+36]) == "Info" {
+			t.Errorf("%s: unexpected Info tag", test.name)
+		}
+		if first := frameBitRate(frames[0]); first != 128 {
+			t.Errorf("%s: expected first frame bit rate: 128 got: %d", test.name, first)
+		}
+		if last := frameBitRate(frames[len(frames)-1]); last != 64 {
+			t.Errorf("%s: expected last frame bit rate: 64 got: %d", test.name, last)
+		}
+	}
+}
+
+func TestSinkControllerGapless(t *testing.T) {
+	var (
+		buf      bytes.Buffer
+		ctrl     mp3.Controller
+		info     mp3.EncodingInfo
+		p        *pipe.Pipe
+		switched bool
+	)
+	props := pipe.SignalProperties{Channels: 2, SampleRate: 44100}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3test.Tone(props, 440, 0.5, 2*time.Second),
+			Sink: mp3.Sink(&buf, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithController(&ctrl),
+				mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+				mp3.WithProgress(func(pr mp3.Progress) {
+					if pr.Frames > 20 && !switched {
+						switched = true
+						go p.Push(ctrl.Reconfigure(mp3.CBR(64), mp3.DefaultEncodingQuality))
+					}
+				}),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	frames := splitFrames(t, buf.Bytes())
+	if first, last := frameBitRate(frames[0]), frameBitRate(frames[len(frames)-1]); first != 128 || last != 64 {
+		t.Fatalf("expected bit rates: 128 and 64 got: %d and %d", first, last)
+	}
+	samples := props.SampleRate.Events(2 * time.Second)
+	if info.Samples != samples || info.Frames != len(frames) || info.Padding != len(frames)*1152-info.Delay-samples {
+		t.Errorf("unexpected info of %d frames: %+v", len(frames), info)
+	}
+
+	sink := mock.Sink{}
+	p, err = pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3.Source(bytes.NewReader(buf.Bytes())),
+			Sink:   sink.Sink(),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded := sink.Values.Length(); decoded != len(frames)*1152 {
+		t.Fatalf("expected decoded samples: %d got: %d", len(frames)*1152, decoded)
+	}
+	// decoded tone follows the input without gaps, decoder adds 529
+	// samples of delay.
+	for i := 0; i < samples; i++ {
+		expected := 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+		if e := math.Abs(sink.Values.Sample((info.Delay+529+i)*2) - expected); e > 0.15 {
+			t.Fatalf("expected sample %d: %f got: %f", i, expected, sink.Values.Sample((info.Delay+529+i)*2))
+		}
+	}
+}
+
+// frameBitRate returns bit rate of MPEG-1 Layer III frame.
+func frameBitRate(frame []byte) int {
+	bitRates := [...]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	return bitRates[frame[2]>>4]
+}
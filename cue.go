@@ -0,0 +1,169 @@
+package mp3
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"pipelined.dev/pipe/mutable"
+)
+
+// Cue is the marker inserted into the stream at the time, like the start
+// of ad break. It's written as ID3v2 tag with PRIV frame of the owner
+// between audio frames, so downstream systems can detect it in the
+// stream.
+type Cue struct {
+	// Time of the cue from the start of the stream. Cue is inserted
+	// before the first frame that starts at or after it.
+	Time time.Duration
+	// Owner identifies the kind of cue, like URL of ad insertion system.
+	Owner string
+	Data  []byte
+	// Title is set as ICY stream title at the cue if Sink has ICY
+	// metadata.
+	Title string
+}
+
+// Cues are the markers that WithCues inserts into the stream. More cues
+// can be scheduled with Insert mutation during the stream. Cues can be
+// bound only to a single Sink.
+type Cues struct {
+	Scheduled []Cue
+
+	mctx mutable.Context
+}
+
+// WithCues makes the Sink insert cues into the stream. Writes are
+// aligned to frames. Cues aren't written if the Sink has no frames
+// after them.
+func WithCues(c *Cues) SinkOption {
+	return func(o *sinkOptions) {
+		for _, cue := range c.Scheduled {
+			if err := cue.validate(); err != nil {
+				o.fail(err)
+				return
+			}
+		}
+		o.cues = c
+		o.alignFrames = true
+	}
+}
+
+// Insert returns the mutation that schedules cues. Cues with time that
+// has passed are inserted before the next frame. It panics if cues
+// aren't bound to the Sink yet.
+func (c *Cues) Insert(cues ...Cue) mutable.Mutation {
+	return c.mctx.Mutate(func() error {
+		for _, cue := range cues {
+			if err := cue.validate(); err != nil {
+				return err
+			}
+		}
+		c.Scheduled = append(c.Scheduled, cues...)
+		return nil
+	})
+}
+
+func (c *Cues) bind(mctx mutable.Context) {
+	c.mctx = mctx
+}
+
+func (c Cue) validate() error {
+	if c.Owner == "" || c.Time < 0 {
+		return fmt.Errorf("%w: cue must have owner and non-negative time, got %q at %v", ErrInvalidParameter, c.Owner, c.Time)
+	}
+	return nil
+}
+
+// cueWriter inserts cues between frames.
+type cueWriter struct {
+	w    io.Writer
+	cues *Cues
+	icy  *ICYMetadata
+	// position is the time of written frames.
+	position time.Duration
+}
+
+// Write expects whole frames.
+func (c *cueWriter) Write(p []byte) (int, error) {
+	// tags written before the frames are passed through.
+	if _, err := parseFrameHeader(p); err != nil {
+		return c.w.Write(p)
+	}
+	frames, rest, err := splitFrames(p)
+	if err != nil {
+		return 0, fmt.Errorf("error splitting MP3 frames: %w", err)
+	}
+	if len(rest) > 0 {
+		return 0, fmt.Errorf("incomplete frame of %d bytes", len(rest))
+	}
+	var start, offset int
+	for _, frame := range frames {
+		if due := c.due(); len(due) > 0 {
+			if start < offset {
+				if _, err := c.w.Write(p[start:offset]); err != nil {
+					return 0, err
+				}
+			}
+			if err := c.insert(due); err != nil {
+				return 0, err
+			}
+			start = offset
+		}
+		h, _ := parseFrameHeader(frame)
+		c.position += h.duration()
+		offset += len(frame)
+	}
+	if start < len(p) {
+		if _, err := c.w.Write(p[start:]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// due removes the cues that are due at the current position and returns
+// them in order of time.
+func (c *cueWriter) due() []Cue {
+	if len(c.cues.Scheduled) == 0 {
+		return nil
+	}
+	var due []Cue
+	scheduled := c.cues.Scheduled[:0]
+	for _, cue := range c.cues.Scheduled {
+		if cue.Time <= c.position {
+			due = append(due, cue)
+		} else {
+			scheduled = append(scheduled, cue)
+		}
+	}
+	c.cues.Scheduled = scheduled
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Time < due[j].Time })
+	return due
+}
+
+// insert writes the tag with cues and updates ICY stream title.
+func (c *cueWriter) insert(cues []Cue) error {
+	frames := make([]id3Frame, 0, len(cues))
+	for _, cue := range cues {
+		frames = append(frames, privFrame(cue.Owner, cue.Data))
+		if cue.Title != "" && c.icy != nil {
+			c.icy.StreamTitle = cue.Title
+		}
+	}
+	tag, err := ID3v2{}.bytes(frames, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := c.w.Write(tag); err != nil {
+		return fmt.Errorf("error writing cue: %w", err)
+	}
+	return nil
+}
+
+// privFrame returns PRIV frame with data of the owner.
+func privFrame(owner string, data []byte) id3Frame {
+	body := append([]byte(owner), 0)
+	return id3Frame{id: "PRIV", body: append(body, data...)}
+}
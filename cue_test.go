@@ -0,0 +1,196 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// cueTag is the tag found in the stream before the frame.
+type cueTag struct {
+	frame  int
+	frames []id3Frame
+}
+
+// splitCues returns frames of the stream with 44100 sample rate and tags
+// inserted between them.
+func splitCues(t *testing.T, data []byte) ([][]byte, []cueTag) {
+	t.Helper()
+	var (
+		frames [][]byte
+		tags   []cueTag
+	)
+	for len(data) > 0 {
+		if bytes.HasPrefix(data, []byte("ID3")) {
+			var tag cueTag
+			tag.frames, data = parseID3v2(t, data)
+			tag.frame = len(frames)
+			tags = append(tags, tag)
+			continue
+		}
+		size := 144*frameBitRate(data)*1000/44100 + int(data[2]>>1&0x01)
+		frames = append(frames, splitFrames(t, data[:size])...)
+		data = data[size:]
+	}
+	return frames, tags
+}
+
+func TestSinkCues(t *testing.T) {
+	cues := mp3.Cues{
+		Scheduled: []mp3.Cue{
+			{Time: time.Second, Owner: "com.example.break", Data: []byte("start")},
+			{Time: 0, Owner: "com.example.show", Data: []byte("intro")},
+			{Time: time.Second, Owner: "com.example.ad", Data: []byte("1")},
+			{Time: 10 * time.Second, Owner: "com.example.never"},
+		},
+	}
+	var buf bytes.Buffer
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      2 * 44100,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink: mp3.Sink(&buf, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithCues(&cues),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	frames, tags := splitCues(t, buf.Bytes())
+	if len(frames) < 2*44100/1152 {
+		t.Fatalf("expected frames: %d got: %d", 2*44100/1152, len(frames))
+	}
+	expected := []struct {
+		frame int
+		privs []string
+	}{
+		{frame: 0, privs: []string{"com.example.show\x00intro"}},
+		// first frame that starts at or after a second.
+		{frame: 39, privs: []string{"com.example.break\x00start", "com.example.ad\x001"}},
+	}
+	if len(tags) != len(expected) {
+		t.Fatalf("expected tags: %d got: %d", len(expected), len(tags))
+	}
+	for i, tag := range tags {
+		if tag.frame != expected[i].frame {
+			t.Errorf("tag %d: expected frame: %d got: %d", i, expected[i].frame, tag.frame)
+		}
+		if len(tag.frames) != len(expected[i].privs) {
+			t.Fatalf("tag %d: expected frames: %d got: %d", i, len(expected[i].privs), len(tag.frames))
+		}
+		for j, f := range tag.frames {
+			if f.id != "PRIV" || string(f.body) != expected[i].privs[j] {
+				t.Errorf("tag %d: expected frame PRIV %q got: %s %q", i, expected[i].privs[j], f.id, f.body)
+			}
+		}
+	}
+	if len(cues.Scheduled) != 1 {
+		t.Errorf("expected scheduled cues: 1 got: %d", len(cues.Scheduled))
+	}
+}
+
+func TestSinkCuesInsert(t *testing.T) {
+	const interval = 1000
+	var (
+		cues mp3.Cues
+		meta = mp3.ICYMetadata{Interval: interval, StreamTitle: "Show"}
+		buf  bytes.Buffer
+		p    *pipe.Pipe
+		set  bool
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      1 << 30,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink: mp3.Sink(&buf, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithICYMetadata(&meta),
+				mp3.WithCues(&cues),
+				mp3.WithProgress(func(pr mp3.Progress) {
+					switch {
+					case pr.Frames > 10 && !set:
+						set = true
+						go p.Push(cues.Insert(mp3.Cue{Owner: "com.example.break", Title: "Break"}))
+					case pr.Frames > 100:
+						cancel()
+					}
+				}),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(ctx)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var (
+		audio  []byte
+		blocks []string
+		data   = buf.Bytes()
+	)
+	for len(data) > interval {
+		audio = append(audio, data[:interval]...)
+		size := int(data[interval]) * 16
+		if size > 0 {
+			blocks = append(blocks, string(bytes.TrimRight(data[interval+1:interval+1+size], "\x00")))
+		}
+		data = data[interval+1+size:]
+	}
+	expected := []string{"StreamTitle='Show';", "StreamTitle='Break';"}
+	if len(blocks) != len(expected) || blocks[0] != expected[0] || blocks[1] != expected[1] {
+		t.Errorf("expected metadata blocks: %q got: %q", expected, blocks)
+	}
+	if !bytes.Contains(audio, []byte("com.example.break\x00")) {
+		t.Errorf("expected cue in the stream")
+	}
+}
+
+func TestSinkCuesInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cue  mp3.Cue
+	}{
+		{
+			name: "no owner",
+			cue:  mp3.Cue{Time: time.Second},
+		},
+		{
+			name: "negative time",
+			cue:  mp3.Cue{Time: -time.Second, Owner: "com.example"},
+		},
+	}
+	for _, test := range tests {
+		_, err := encodeTagged(mp3.WithCues(&mp3.Cues{Scheduled: []mp3.Cue{test.cue}}))
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
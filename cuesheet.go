@@ -0,0 +1,250 @@
+package mp3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCueSheet is returned when the cue sheet can't be parsed.
+var ErrInvalidCueSheet = errors.New("invalid cue sheet")
+
+// cueFramesPerSecond is the number of frames in a second of cue sheet
+// timestamps.
+const cueFramesPerSecond = 75
+
+type (
+	// CueSheet is the cue sheet of the album stored in a single file.
+	CueSheet struct {
+		Title     string
+		Performer string
+		// Genre and Date are taken from REM comments.
+		Genre  string
+		Date   string
+		Tracks []CueTrack
+	}
+
+	// CueTrack is the track of the cue sheet.
+	CueTrack struct {
+		Number    int
+		Title     string
+		Performer string
+		// Start is the time of INDEX 01 of the track.
+		Start time.Duration
+	}
+
+	// CueSplit configures SplitCueSheet.
+	CueSplit struct {
+		Sheet CueSheet
+		// Path returns the path of the track file with the index in
+		// the sheet.
+		Path func(track int) string
+		// Version of ID3v2 tags written into track files.
+		Version ID3Version
+	}
+)
+
+// ParseCueSheet parses the cue sheet. Only commands that describe the
+// album and its tracks are parsed, others are ignored. Every track must
+// have INDEX 01 and tracks must be in order of time. Sheets that
+// reference several files aren't supported.
+func ParseCueSheet(r io.Reader) (CueSheet, error) {
+	var (
+		sheet   CueSheet
+		track   *CueTrack
+		indexed bool
+		files   int
+		line    int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		fields := cueFields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		invalid := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: line %d: %s", ErrInvalidCueSheet, line, fmt.Sprintf(format, args...))
+		}
+		switch command := strings.ToUpper(fields[0]); {
+		case command == "FILE":
+			if files++; files > 1 {
+				return CueSheet{}, invalid("multiple files aren't supported")
+			}
+		case command == "TRACK":
+			if len(fields) < 2 {
+				return CueSheet{}, invalid("track without number")
+			}
+			if track != nil && !indexed {
+				return CueSheet{}, invalid("track %d has no INDEX 01", track.Number)
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return CueSheet{}, invalid("invalid track number %q", fields[1])
+			}
+			sheet.Tracks = append(sheet.Tracks, CueTrack{Number: n})
+			track, indexed = &sheet.Tracks[len(sheet.Tracks)-1], false
+		case command == "INDEX" && len(fields) == 3 && fields[1] == "01" && track != nil:
+			start, err := parseCueTime(fields[2])
+			if err != nil {
+				return CueSheet{}, invalid("%v", err)
+			}
+			if n := len(sheet.Tracks); n > 1 && start <= sheet.Tracks[n-2].Start {
+				return CueSheet{}, invalid("track %d starts before the previous one", track.Number)
+			}
+			track.Start, indexed = start, true
+		case command == "TITLE" && len(fields) > 1:
+			if track != nil {
+				track.Title = fields[1]
+			} else {
+				sheet.Title = fields[1]
+			}
+		case command == "PERFORMER" && len(fields) > 1:
+			if track != nil {
+				track.Performer = fields[1]
+			} else {
+				sheet.Performer = fields[1]
+			}
+		case command == "REM" && len(fields) > 2:
+			switch strings.ToUpper(fields[1]) {
+			case "GENRE":
+				sheet.Genre = fields[2]
+			case "DATE":
+				sheet.Date = fields[2]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return CueSheet{}, fmt.Errorf("error reading cue sheet: %w", err)
+	}
+	if len(sheet.Tracks) == 0 {
+		return CueSheet{}, fmt.Errorf("%w: no tracks", ErrInvalidCueSheet)
+	}
+	if !indexed {
+		return CueSheet{}, fmt.Errorf("%w: track %d has no INDEX 01", ErrInvalidCueSheet, track.Number)
+	}
+	return sheet, nil
+}
+
+// cueFields splits the line into fields. Quoted fields can contain
+// spaces.
+func cueFields(line string) []string {
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return append(fields, line[1:])
+			}
+			fields = append(fields, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			return append(fields, line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+	return fields
+}
+
+// parseCueTime parses mm:ss:ff timestamp.
+func parseCueTime(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var values [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		values[i] = v
+	}
+	if values[1] >= 60 || values[2] >= cueFramesPerSecond {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(values[0])*time.Minute +
+		time.Duration(values[1])*time.Second +
+		time.Duration(values[2])*time.Second/cueFramesPerSecond, nil
+}
+
+// ID3v2 returns the tag of the track with index in the sheet. Track
+// performer defaults to the album one.
+func (c CueSheet) ID3v2(track int, v ID3Version) ID3v2 {
+	t := c.Tracks[track]
+	tag := ID3v2{Version: v}
+	text := func(id, value string) {
+		if value != "" {
+			tag.Text = append(tag.Text, TextFrame{ID: id, Value: value})
+		}
+	}
+	performer := t.Performer
+	if performer == "" {
+		performer = c.Performer
+	}
+	text("TIT2", t.Title)
+	text("TPE1", performer)
+	text("TALB", c.Title)
+	if t.Performer != "" {
+		text("TPE2", c.Performer)
+	}
+	text("TRCK", strconv.Itoa(t.Number)+"/"+strconv.Itoa(len(c.Tracks)))
+	if v == ID3v23 {
+		if len(c.Date) >= 4 {
+			text("TYER", c.Date[:4])
+		}
+	} else {
+		text("TDRC", c.Date)
+	}
+	if c.Genre != "" {
+		tag.Text = append(tag.Text, GenreFrame(v, c.Genre))
+	}
+	return tag
+}
+
+// SplitCueSheet splits MP3 stream of the album from r into track files
+// without re-encoding. Tracks are cut at the frame boundaries with
+// gapless offsets the same way as Cut does and tagged with ID3v2 tags of
+// the cue sheet. Audio before the first track is added to it.
+func SplitCueSheet(r io.Reader, s CueSplit, options ...SinkOption) ([]Track, error) {
+	if s.Path == nil {
+		return nil, fmt.Errorf("%w: track path function is nil", ErrInvalidParameter)
+	}
+	if len(s.Sheet.Tracks) == 0 {
+		return nil, fmt.Errorf("%w: no tracks", ErrInvalidCueSheet)
+	}
+	in, err := readCutInput(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cuts = make([]int, 0, len(s.Sheet.Tracks)-1)
+		prev int
+	)
+	for _, t := range s.Sheet.Tracks[1:] {
+		cut := in.rate.Events(t.Start)
+		if cut >= in.samples() {
+			return nil, fmt.Errorf("%w: track %d starts after the end of the stream", ErrInvalidCueSheet, t.Number)
+		}
+		if cut <= prev {
+			return nil, fmt.Errorf("%w: track %d starts before the previous one", ErrInvalidCueSheet, t.Number)
+		}
+		cuts = append(cuts, cut)
+		prev = cut
+	}
+	return in.split(cuts, s.Path, func(track int) []SinkOption {
+		return append(options[:len(options):len(options)], WithID3v2(s.Sheet.ID3v2(track, s.Version)))
+	})
+}
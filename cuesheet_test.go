@@ -0,0 +1,150 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+const cueSheet = "\ufeffREM GENRE Rock\r\n" + `REM DATE 1999
+PERFORMER "The Band"
+TITLE "The Album"
+FILE "album.mp3" MP3
+  TRACK 01 AUDIO
+    TITLE "First"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Second Song"
+    PERFORMER "Guest"
+    INDEX 00 00:01:50
+    INDEX 01 00:02:00
+  TRACK 03 AUDIO
+    TITLE "Third"
+    FLAGS DCP
+    INDEX 01 00:03:37
+`
+
+func TestParseCueSheet(t *testing.T) {
+	sheet, err := mp3.ParseCueSheet(strings.NewReader(cueSheet))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := mp3.CueSheet{
+		Title:     "The Album",
+		Performer: "The Band",
+		Genre:     "Rock",
+		Date:      "1999",
+		Tracks: []mp3.CueTrack{
+			{Number: 1, Title: "First"},
+			{Number: 2, Title: "Second Song", Performer: "Guest", Start: 2 * time.Second},
+			{Number: 3, Title: "Third", Start: 3*time.Second + 37*time.Second/75},
+		},
+	}
+	if !reflect.DeepEqual(sheet, expected) {
+		t.Errorf("expected sheet: %+v got: %+v", expected, sheet)
+	}
+}
+
+func TestParseCueSheetInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+	}{
+		{
+			name:  "no tracks",
+			sheet: `TITLE "Album"`,
+		},
+		{
+			name:  "no index",
+			sheet: "TRACK 01 AUDIO\nTRACK 02 AUDIO\nINDEX 01 00:01:00",
+		},
+		{
+			name:  "last track without index",
+			sheet: "TRACK 01 AUDIO\nINDEX 01 00:00:00\nTRACK 02 AUDIO",
+		},
+		{
+			name:  "invalid time",
+			sheet: "TRACK 01 AUDIO\nINDEX 01 00:00:75",
+		},
+		{
+			name:  "order",
+			sheet: "TRACK 01 AUDIO\nINDEX 01 00:02:00\nTRACK 02 AUDIO\nINDEX 01 00:01:00",
+		},
+		{
+			name:  "multiple files",
+			sheet: "FILE \"1.mp3\" MP3\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nFILE \"2.mp3\" MP3",
+		},
+	}
+	for _, test := range tests {
+		_, err := mp3.ParseCueSheet(strings.NewReader(test.sheet))
+		if !errors.Is(err, mp3.ErrInvalidCueSheet) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidCueSheet, err)
+		}
+	}
+}
+
+func TestSplitCueSheet(t *testing.T) {
+	sheet, err := mp3.ParseCueSheet(strings.NewReader(cueSheet))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	input := encodeTracks(t, tracksSource(5*time.Second))
+	dir, err := os.MkdirTemp("", "cue")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := func(track int) string {
+		return filepath.Join(dir, strconv.Itoa(track)+".mp3")
+	}
+
+	tracks, err := mp3.SplitCueSheet(bytes.NewReader(input), mp3.CueSplit{Sheet: sheet, Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tracks) != len(sheet.Tracks) {
+		t.Fatalf("expected tracks: %d got: %d", len(sheet.Tracks), len(tracks))
+	}
+	expected := [][]string{
+		{"TIT2 First", "TPE1 The Band", "TALB The Album", "TRCK 1/3", "TDRC 1999", "TCON Rock"},
+		{"TIT2 Second Song", "TPE1 Guest", "TALB The Album", "TPE2 The Band", "TRCK 2/3", "TDRC 1999", "TCON Rock"},
+		{"TIT2 Third", "TPE1 The Band", "TALB The Album", "TRCK 3/3", "TDRC 1999", "TCON Rock"},
+	}
+	for i, track := range tracks {
+		if d := track.Start - sheet.Tracks[i].Start; d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf("track %d: expected start: %v got: %v", i, sheet.Tracks[i].Start, track.Start)
+		}
+		data, err := os.ReadFile(path(i))
+		if err != nil {
+			t.Fatalf("track %d: unexpected error: %v", i, err)
+		}
+		frames, audio := parseID3v2(t, data)
+		var text []string
+		for _, f := range frames {
+			// skip encoding byte and trailing null.
+			text = append(text, f.id+" "+strings.TrimRight(string(f.body[1:]), "\x00"))
+		}
+		if !reflect.DeepEqual(text, expected[i]) {
+			t.Errorf("track %d: expected frames: %q got: %q", i, expected[i], text)
+		}
+		if frames := splitFrames(t, audio); string(frames[0][4+32:4+36]) != "Info" {
+			t.Errorf("track %d: expected Info tag", i)
+		}
+	}
+	if tracks[2].End != 5*time.Second {
+		t.Errorf("expected end: %v got: %v", 5*time.Second, tracks[2].End)
+	}
+
+	sheet.Tracks[2].Start = time.Minute
+	if _, err := mp3.SplitCueSheet(bytes.NewReader(input), mp3.CueSplit{Sheet: sheet, Path: path}); !errors.Is(err, mp3.ErrInvalidCueSheet) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidCueSheet, err)
+	}
+}
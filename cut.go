@@ -0,0 +1,203 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	mp3 "github.com/hajimehoshi/go-mp3"
+
+	"pipelined.dev/signal"
+)
+
+// maxGaplessSamples is the maximum delay and padding that LAME extension
+// can hold.
+const maxGaplessSamples = 1<<12 - 1
+
+// Cut copies the time range of MP3 stream from r into w without
+// re-encoding. Whole frames that cover the range are copied and the
+// remainder is removed by gapless players with delay and padding of the
+// new Xing/Info frame, which is written if w is seekable. Frames that
+// hold the bit reservoir and overlap of the first audible frame are
+// copied too. End of zero cuts until the end of the input. Input tags
+// are dropped. Options are applied as with NewFrameSink.
+func Cut(r io.Reader, w io.Writer, start, end time.Duration, options ...SinkOption) error {
+	if start < 0 || end < 0 || (end != 0 && end <= start) {
+		return fmt.Errorf("%w: invalid cut range from %v to %v", ErrInvalidParameter, start, end)
+	}
+	in, err := readCutInput(r)
+	if err != nil {
+		return err
+	}
+	from, to := in.rate.Events(start), in.samples()
+	if end != 0 && in.rate.Events(end) < to {
+		to = in.rate.Events(end)
+	}
+	if from >= to {
+		return fmt.Errorf("%w: cut start %v is after the end of the stream", ErrInvalidParameter, start)
+	}
+	return in.cut(w, from, to, options)
+}
+
+// cutInput is the stream split into audio frames.
+type cutInput struct {
+	// audio frames without tags and Xing/Info frame.
+	audio  []byte
+	frames [][]byte
+	info   EncodingInfo
+	// gapless is true if Xing/Info frame has encoder delay and
+	// padding.
+	gapless  bool
+	perFrame int
+	rate     signal.Frequency
+}
+
+// readCutInput reads the stream and drops its tags and Xing/Info frame.
+func readCutInput(r io.Reader) (cutInput, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return cutInput{}, fmt.Errorf("error reading MP3 data: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte("ID3")) && len(data) >= id3v2HeaderSize {
+		if size := id3v2TagSize(data); size <= len(data) {
+			data = data[size:]
+		}
+	}
+	if len(data) >= id3v1Size && isID3v1(data[len(data)-id3v1Size:]) {
+		data = data[:len(data)-id3v1Size]
+	}
+	frames, rest, err := splitFrames(data)
+	if err != nil {
+		return cutInput{}, fmt.Errorf("error parsing MP3 frame: %w", err)
+	}
+	if len(frames) == 0 || len(rest) > 0 {
+		return cutInput{}, fmt.Errorf("error parsing MP3 frame: %w: incomplete frame of %d bytes", errInvalidFrame, len(rest))
+	}
+	h, _ := parseFrameHeader(frames[0])
+	info, ok := parseXingFrame(frames[0], h)
+	ext, _ := xingExtension(frames[0], h)
+	if ok {
+		data = data[len(frames[0]):]
+		frames = frames[1:]
+	}
+	return cutInput{
+		audio:    data,
+		frames:   frames,
+		info:     info,
+		gapless:  ext != nil,
+		perFrame: h.samples(),
+		rate:     signal.Frequency(h.sampleRate),
+	}, nil
+}
+
+// samples returns the number of audio samples per channel.
+func (in cutInput) samples() int {
+	return len(in.frames)*in.perFrame - in.info.Delay - in.info.Padding
+}
+
+// decode calls fn with stereo samples of decoded audio. Delay and padding
+// are removed the same way gapless players do if the stream has them,
+// whole decoded frames are provided otherwise.
+func (in cutInput) decode(fn func(left, right int16)) error {
+	decoder, err := mp3.NewDecoder(bytes.NewReader(in.audio))
+	if err != nil {
+		return fmt.Errorf("error creating MP3 decoder: %w", err)
+	}
+	var (
+		pos int
+		end = len(in.frames) * in.perFrame
+		// current decoder always provides stereo.
+		buf = make([]byte, 4*in.perFrame)
+	)
+	if in.gapless {
+		// decoded samples are shifted by encoder and decoder delays.
+		pos = -in.info.Delay - decoderDelay
+		end = in.samples()
+	}
+	for {
+		n, err := io.ReadFull(decoder, buf)
+		for i := 0; i+4 <= n && pos < end; i, pos = i+4, pos+1 {
+			if pos < 0 {
+				continue
+			}
+			fn(int16(binary.LittleEndian.Uint16(buf[i:])), int16(binary.LittleEndian.Uint16(buf[i+2:])))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading MP3 data: %w", err)
+		}
+	}
+}
+
+// cut writes frames that cover samples in range [from, to).
+func (in cutInput) cut(w io.Writer, from, to int, options []SinkOption) error {
+	// decoded samples are shifted by encoder and decoder delays. The
+	// frame before the first audible one provides the overlap.
+	first := (from+in.info.Delay+decoderDelay)/in.perFrame - 1
+	if first < 0 {
+		first = 0
+	}
+	first = reservoirStart(in.frames, first)
+	for from+in.info.Delay-first*in.perFrame > maxGaplessSamples {
+		first++
+	}
+	last := (to + in.info.Delay + decoderDelay - 1) / in.perFrame
+	if last >= len(in.frames) {
+		last = len(in.frames) - 1
+	}
+
+	s, err := NewFrameSink(w, options...)
+	if err != nil {
+		return err
+	}
+	for _, frame := range in.frames[first : last+1] {
+		if _, err := s.Write(frame); err != nil {
+			return err
+		}
+	}
+	s.info = EncodingInfo{
+		Delay:   from + in.info.Delay - first*in.perFrame,
+		Padding: (last+1)*in.perFrame - in.info.Delay - to,
+	}
+	return s.Flush()
+}
+
+// reservoirStart returns the index of the first frame that holds the bit
+// reservoir used by the frame i.
+func reservoirStart(frames [][]byte, i int) int {
+	need := mainDataBegin(frames[i])
+	for need > 0 && i > 0 {
+		i--
+		need -= mainDataSize(frames[i])
+	}
+	return i
+}
+
+// mainDataBegin returns the number of bytes of the frame main data that
+// are stored in the previous frames.
+func mainDataBegin(frame []byte) int {
+	h, _ := parseFrameHeader(frame)
+	b := frame[frameHeaderSize:]
+	if h.protected {
+		b = b[2:]
+	}
+	if h.version == mpeg1 {
+		return int(b[0])<<1 | int(b[1]>>7)
+	}
+	return int(b[0])
+}
+
+// mainDataSize returns the number of bytes available for main data in
+// the frame.
+func mainDataSize(frame []byte) int {
+	h, _ := parseFrameHeader(frame)
+	size := len(frame) - frameHeaderSize - h.sideInfoSize()
+	if h.protected {
+		size -= 2
+	}
+	return size
+}
@@ -0,0 +1,116 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestCut(t *testing.T) {
+	input, inputInfo := encodeShine(t)
+	inFrames := splitFrames(t, input)[1:]
+	tests := []struct {
+		name       string
+		start, end time.Duration
+		samples    int
+	}{
+		{
+			name:    "range",
+			start:   500 * time.Millisecond,
+			end:     time.Second + 500*time.Millisecond,
+			samples: 44100,
+		},
+		{
+			name:    "start",
+			end:     100 * time.Millisecond,
+			samples: 4410,
+		},
+		{
+			name:    "until end",
+			start:   2 * time.Second,
+			samples: 100000 - 2*44100,
+		},
+		{
+			name:    "end after stream",
+			start:   time.Second,
+			end:     time.Hour,
+			samples: 100000 - 44100,
+		},
+	}
+	for _, test := range tests {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		defer os.Remove(f.Name())
+		var info mp3.EncodingInfo
+		err = mp3.Cut(bytes.NewReader(input), f, test.start, test.end, mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		f.Close()
+		output, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if info.Samples != test.samples {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, test.samples, info.Samples)
+		}
+		if info.Delay < 0 || info.Delay > 4095 || info.Padding < 529 || info.Padding > 4095 {
+			t.Errorf("%s: invalid delay and padding: %d %d", test.name, info.Delay, info.Padding)
+		}
+		if test.start == 0 && info.Delay != inputInfo.Delay {
+			t.Errorf("%s: expected delay: %d got: %d", test.name, inputInfo.Delay, info.Delay)
+		}
+		outFrames := splitFrames(t, output)
+		if string(outFrames[0][4+32:4+36]) != "Info" {
+			t.Fatalf("%s: expected Info tag", test.name)
+		}
+		outFrames = outFrames[1:]
+		if len(outFrames)*1152 != info.Delay+info.Samples+info.Padding {
+			t.Errorf("%s: frames: %d don't match delay, samples and padding: %+v", test.name, len(outFrames), info)
+		}
+		// frames are copied as is.
+		audio := bytes.Join(outFrames, nil)
+		if !bytes.Contains(bytes.Join(inFrames, nil), audio) {
+			t.Errorf("%s: frames don't match the input", test.name)
+		}
+	}
+}
+
+func TestCutInvalid(t *testing.T) {
+	input, _ := encodeShine(t)
+	tests := []struct {
+		name       string
+		start, end time.Duration
+	}{
+		{
+			name:  "negative start",
+			start: -time.Second,
+		},
+		{
+			name:  "end before start",
+			start: time.Second,
+			end:   time.Millisecond,
+		},
+		{
+			name:  "start after stream",
+			start: time.Hour,
+		},
+	}
+	for _, test := range tests {
+		err := mp3.Cut(bytes.NewReader(input), io.Discard, test.start, test.end)
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+	if err := mp3.Cut(bytes.NewReader(input[:len(input)-10]), io.Discard, 0, 0); err == nil {
+		t.Errorf("expected error of truncated input")
+	}
+}
@@ -0,0 +1,149 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+type (
+	// DecodeBatch decodes many independent files by a pool of workers,
+	// like library scanners do. Workers share PCM buffers of the Source,
+	// so the memory is bound by the number of workers, not files.
+	DecodeBatch struct {
+		// Workers is the number of files decoded concurrently. Default
+		// is the number of CPUs.
+		Workers int
+		// Sink returns the sink of decoded signal of the file. It's
+		// called concurrently by workers. Signal is discarded if Sink
+		// is nil.
+		Sink func(file int) pipe.SinkAllocatorFunc
+		// Result is called when the file is decoded. Calls are
+		// serialized.
+		Result func(DecodeResult)
+	}
+
+	// DecodeResult is the result of the decoded file.
+	DecodeResult struct {
+		// File is the index of the file.
+		File       int
+		SampleRate signal.Frequency
+		Channels   int
+		// Samples is the number of decoded samples per channel.
+		Samples int
+		Err     error
+	}
+)
+
+// Run decodes files and returns their results in the same order when
+// all of them are done. Failed file doesn't stop others, the error of
+// the first failed one is returned. Files that aren't started when ctx
+// is done fail with its error.
+func (b DecodeBatch) Run(ctx context.Context, paths []string) ([]DecodeResult, error) {
+	if b.Workers < 0 {
+		return nil, fmt.Errorf("%w: %d workers", ErrInvalidParameter, b.Workers)
+	}
+	workers := b.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	var (
+		results = make([]DecodeResult, len(paths))
+		first   error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		queue   = make(chan int)
+	)
+	done := func(r DecodeResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[r.File] = r
+		if r.Err != nil && first == nil {
+			first = fmt.Errorf("file %d: %w", r.File, r.Err)
+		}
+		if b.Result != nil {
+			b.Result(r)
+		}
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range queue {
+				done(b.decode(ctx, file, paths[file]))
+			}
+		}()
+	}
+	for i := range paths {
+		if err := ctx.Err(); err != nil {
+			done(DecodeResult{File: i, Err: err})
+			continue
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results, first
+}
+
+// decode runs the pipe of the file.
+func (b DecodeBatch) decode(ctx context.Context, i int, path string) DecodeResult {
+	r := DecodeResult{File: i}
+	f, err := os.Open(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer f.Close()
+	sink := discardSink
+	if b.Sink != nil {
+		sink = b.Sink(i)
+	}
+	p, err := pipe.New(
+		transcodeBufferSize,
+		pipe.Line{
+			Source: Source(f),
+			Sink:   r.counter(sink),
+		},
+	)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Err = pipe.Wait(p.Start(ctx))
+	return r
+}
+
+// counter returns the sink that records properties of the signal and
+// counts samples passed to fn.
+func (r *DecodeResult) counter(fn pipe.SinkAllocatorFunc) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		sink, err := fn(mctx, bufferSize, props)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		r.SampleRate, r.Channels = props.SampleRate, props.Channels
+		sinkFn := sink.SinkFunc
+		sink.SinkFunc = func(floats signal.Floating) error {
+			if err := sinkFn(floats); err != nil {
+				return err
+			}
+			r.Samples += floats.Length()
+			return nil
+		}
+		return sink, nil
+	}
+}
+
+// discardSink drops the signal.
+func discardSink(mutable.Context, int, pipe.SignalProperties) (pipe.Sink, error) {
+	return pipe.Sink{
+		SinkFunc: func(signal.Floating) error { return nil },
+	}, nil
+}
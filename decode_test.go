@@ -0,0 +1,77 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestDecodeBatch(t *testing.T) {
+	paths := []string{
+		sample,
+		filepath.Join("_testdata", "missing.mp3"),
+		sample,
+	}
+	sinks := make([]mock.Sink, len(paths))
+	var (
+		calls int
+		b     = mp3.DecodeBatch{
+			Workers: 2,
+			Sink: func(file int) pipe.SinkAllocatorFunc {
+				return sinks[file].Sink()
+			},
+			Result: func(mp3.DecodeResult) { calls++ },
+		}
+	)
+	results, err := b.Run(context.Background(), paths)
+	if err == nil {
+		t.Errorf("expected error of missing file")
+	}
+	if len(results) != len(paths) || calls != len(paths) {
+		t.Fatalf("expected %d results got: %d results %d calls", len(paths), len(results), calls)
+	}
+	if results[1].File != 1 || results[1].Err == nil {
+		t.Errorf("expected error of file 1 got: %+v", results[1])
+	}
+	for _, file := range []int{0, 2} {
+		r := results[file]
+		if r.Err != nil {
+			t.Errorf("file %d: unexpected error: %v", file, r.Err)
+		}
+		if r.File != file || r.SampleRate != 44100 || r.Channels != 2 || r.Samples != mp3Samples {
+			t.Errorf("file %d: unexpected result: %+v", file, r)
+		}
+		if sinks[file].Counter.Samples != mp3Samples {
+			t.Errorf("file %d: expected %d samples in sink got: %d", file, mp3Samples, sinks[file].Counter.Samples)
+		}
+	}
+
+	// signal is discarded without sink.
+	results, err = mp3.DecodeBatch{}.Run(context.Background(), paths[:1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Samples != mp3Samples {
+		t.Errorf("expected %d samples got: %d", mp3Samples, results[0].Samples)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = b.Run(ctx, paths)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error: %v got: %v", context.Canceled, err)
+	}
+	for _, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("file %d: expected error: %v got: %v", r.File, context.Canceled, r.Err)
+		}
+	}
+	if _, err := (mp3.DecodeBatch{Workers: -1}).Run(context.Background(), paths); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,38 @@
+package mp3
+
+import "fmt"
+
+// WithDeterministic makes identical input produce byte-identical output
+// across runs and machines. The output sample rate is pinned to the input
+// one unless it's set explicitly, so it doesn't depend on the choice of
+// the encoder. Xing/Info frame is written by the Sink for all backends,
+// so it doesn't carry the version of lame. Options which output depends
+// on timing of writes are rejected: write timeout, keepalive, controller
+// and dropping backpressure. Output of lame still depends on the version
+// of the library.
+func WithDeterministic() SinkOption {
+	return func(o *sinkOptions) {
+		o.deterministic = true
+	}
+}
+
+// validateDeterministic checks that options don't depend on timing.
+func (o sinkOptions) validateDeterministic() error {
+	if !o.deterministic {
+		return nil
+	}
+	var option string
+	switch {
+	case o.writeTimeout > 0:
+		option = "write timeout"
+	case o.keepalive > 0:
+		option = "keepalive"
+	case o.controller != nil:
+		option = "controller"
+	case o.backpressure != nil && o.backpressure.Strategy == DropOnBackpressure:
+		option = "dropping backpressure"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: deterministic encoding doesn't support %s", ErrInvalidParameter, option)
+}
@@ -0,0 +1,67 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+)
+
+func TestSinkDeterministic(t *testing.T) {
+	encode := func(options ...mp3.SinkOption) ([]byte, error) {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: chirpSource(time.Second),
+				Sink:   mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, append(options, mp3.WithDeterministic())...),
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(f.Name())
+	}
+	first, err := encode(mp3.WithBackend(mp3.Shine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := encode(mp3.WithBackend(mp3.Shine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("expected identical streams")
+	}
+	if frames := splitFrames(t, first); !bytes.Contains(frames[0], []byte("pipelined")) {
+		t.Errorf("expected Info frame written by the sink")
+	}
+
+	tests := []struct {
+		name   string
+		option mp3.SinkOption
+	}{
+		{name: "write timeout", option: mp3.WithWriteTimeout(time.Second)},
+		{name: "keepalive", option: mp3.WithKeepalive(time.Second)},
+		{name: "controller", option: mp3.WithController(&mp3.Controller{})},
+		{name: "backpressure", option: mp3.WithBackpressure(mp3.Backpressure{Strategy: mp3.DropOnBackpressure})},
+	}
+	for _, test := range tests {
+		if _, err := encode(mp3.WithBackend(mp3.Shine), test.option); !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
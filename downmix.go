@@ -0,0 +1,80 @@
+package mp3
+
+import (
+	"fmt"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+// DownmixMatrix contains coefficients to mix input channels into the
+// encoded channels. It has a row per output channel and a column per input
+// channel. MP3 supports one or two output channels.
+type DownmixMatrix [][]float64
+
+// Downmix51 mixes 5.1 signal with L, R, C, LFE, Ls, Rs channel order into
+// stereo according to ITU-R BS.775. LFE channel is discarded.
+var Downmix51 = DownmixMatrix{
+	{1, 0, 0.7071, 0, 0.7071, 0},
+	{0, 1, 0.7071, 0, 0, 0.7071},
+}
+
+// WithDownmix sets the matrix to mix input channels before encoding. If
+// input has more than two channels and this option isn't provided, 5.1
+// input is mixed with Downmix51 and other layouts are rejected.
+func WithDownmix(m DownmixMatrix) SinkOption {
+	return func(o *sinkOptions) {
+		o.downmix = m
+	}
+}
+
+// resolveDownmix selects the downmix matrix for the number of input
+// channels. Nil matrix is returned if no mixing is needed.
+func (o sinkOptions) resolveDownmix(channels int) (DownmixMatrix, error) {
+	m := o.downmix
+	if m == nil {
+		switch {
+		case channels <= 2:
+			return nil, nil
+		case channels == len(Downmix51[0]):
+			m = Downmix51
+		default:
+			return nil, fmt.Errorf("%w: downmix matrix is required for %d channels", ErrInvalidParameter, channels)
+		}
+	}
+	if len(m) < 1 || len(m) > 2 {
+		return nil, fmt.Errorf("%w: downmix matrix must have 1 or 2 rows, got %d", ErrInvalidParameter, len(m))
+	}
+	for i := range m {
+		if len(m[i]) != channels {
+			return nil, fmt.Errorf("%w: downmix matrix row %d has %d coefficients for %d channels", ErrInvalidParameter, i, len(m[i]), channels)
+		}
+	}
+	return m, nil
+}
+
+// downmix mixes input using the matrix and passes the result to the sink
+// function.
+func downmix(m DownmixMatrix, bufferSize int, fn pipe.SinkFunc) pipe.SinkFunc {
+	mixed := signal.Allocator{
+		Channels: len(m),
+		Capacity: bufferSize,
+		Length:   bufferSize,
+	}.Float64()
+	return func(floats signal.Floating) error {
+		length := floats.Length()
+		for i := 0; i < length; i++ {
+			for out := range m {
+				var sample float64
+				for in, coef := range m[out] {
+					sample += coef * floats.Sample(floats.BufferIndex(in, i))
+				}
+				mixed.SetSample(mixed.BufferIndex(out, i), sample)
+			}
+		}
+		if length != mixed.Length() {
+			return fn(mixed.Slice(0, length))
+		}
+		return fn(mixed)
+	}
+}
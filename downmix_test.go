@@ -0,0 +1,107 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestDownmix(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		channels    int
+		channelMode mp3.ChannelMode
+		options     []mp3.SinkOption
+		err         bool
+	}{
+		{
+			channels:    6,
+			channelMode: mp3.JointStereo,
+		},
+		{
+			channels:    6,
+			channelMode: mp3.Mono,
+			options: []mp3.SinkOption{
+				mp3.WithDownmix(mp3.DownmixMatrix{{0.2, 0.2, 0.2, 0, 0.2, 0.2}}),
+			},
+		},
+		{
+			channels:    2,
+			channelMode: mp3.Mono,
+			options: []mp3.SinkOption{
+				mp3.WithDownmix(mp3.DownmixMatrix{{0.5, 0.5}}),
+			},
+		},
+		{
+			channels:    4,
+			channelMode: mp3.JointStereo,
+			err:         true,
+		},
+		{
+			channels:    6,
+			channelMode: mp3.JointStereo,
+			options: []mp3.SinkOption{
+				mp3.WithDownmix(mp3.DownmixMatrix{{1, 1}, {1, 1}}),
+			},
+			err: true,
+		},
+		{
+			channels:    6,
+			channelMode: mp3.JointStereo,
+			options: []mp3.SinkOption{
+				mp3.WithDownmix(mp3.DownmixMatrix{{1, 1, 1, 1, 1, 1}}),
+			},
+		},
+		{
+			channels:    6,
+			channelMode: mp3.JointStereo,
+			options: []mp3.SinkOption{
+				mp3.WithDownmix(mp3.DownmixMatrix{{1}, {1}, {1}}),
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		source := mock.Source{
+			Channels:   test.channels,
+			SampleRate: 44100,
+			Limit:      44100,
+			Value:      0.5,
+		}
+		var out bytes.Buffer
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.Sink(
+					&out,
+					mp3.CBR(128),
+					test.channelMode,
+					mp3.DefaultEncodingQuality,
+					test.options...,
+				),
+			},
+		)
+		if test.err {
+			if !errors.Is(err, mp3.ErrInvalidParameter) {
+				t.Errorf("expected invalid parameter error, got: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.Len() == 0 {
+			t.Errorf("nothing was encoded")
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package mp3
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+const (
+	// lameDelay is the encoder delay of lame in samples.
+	lameDelay = 576
+	// lamePostDelay is the padding that lame adds after the input to
+	// make the last granule decodable.
+	lamePostDelay = 1152
+	// shineDelay is the delay of shine filter bank in samples.
+	shineDelay = 528
+)
+
+// typicalVBRBitRates are average bit rates of lame VBR presets in kbps
+// for the stereo music at 44100 Hz.
+var typicalVBRBitRates = [...]int{245, 225, 190, 175, 165, 130, 115, 100, 85, 65}
+
+// monoVBRRatio is the share of typical stereo bit rate that lame VBR
+// presets spend on mono music.
+const monoVBRRatio = 0.55
+
+// SizeEstimate is the predicted size of encoded stream in bytes.
+type SizeEstimate struct {
+	// Min is the smallest possible size.
+	Min int64
+	// Expected is the size at the target or typical bit rate.
+	Expected int64
+	// Max is the largest possible size.
+	Max int64
+}
+
+// EstimateSize predicts the size of the stream produced by the Sink with
+// Lame backend and default options from the duration of the input, the
+// output sample rate, the number of output channels and the bit rate
+// mode. The estimate includes Xing header frame. The size is exact for
+// CBR. The size of ABR is expected at the target bit rate. The size of
+// VBR is expected at average bit rate of lame VBR preset, scaled down
+// for lower sample rates and mono. Min and Max are the sizes at the
+// lowest and the highest bit rates of MPEG version and Expected never
+// exceeds them.
+func EstimateSize(d time.Duration, sampleRate signal.Frequency, channels int, brm BitRateMode) (SizeEstimate, error) {
+	if brm == nil {
+		return SizeEstimate{}, fmt.Errorf("%w: bit rate mode is not set", ErrInvalidParameter)
+	}
+	if !isSupportedSampleRate(sampleRate) {
+		return SizeEstimate{}, &UnsupportedSampleRateError{SampleRate: sampleRate}
+	}
+	if channels != 1 && channels != 2 {
+		return SizeEstimate{}, fmt.Errorf("%w: %d channels", ErrInvalidParameter, channels)
+	}
+	if d < 0 {
+		return SizeEstimate{}, fmt.Errorf("%w: duration %v is negative", ErrInvalidParameter, d)
+	}
+	if err := brm.validate(sampleRate); err != nil {
+		return SizeEstimate{}, err
+	}
+
+	h := frameHeader{
+		version:    mpeg1,
+		sampleRate: int(sampleRate),
+	}
+	switch {
+	case sampleRate < 16000:
+		h.version = mpeg25
+	case sampleRate < 32000:
+		h.version = mpeg2
+	}
+	samples := int64(math.Ceil(d.Seconds() * float64(sampleRate)))
+	frameSamples := int64(h.samples())
+	frames := (samples + lameDelay + lamePostDelay + frameSamples - 1) / frameSamples
+
+	// size returns the size of the stream with provided bit rate. Xing
+	// header frame isn't padded and padding of audio frames distributes
+	// fractional bytes.
+	size := func(bitRate int) int64 {
+		h.bitRate = bitRate
+		coef := int64(144)
+		if h.version != mpeg1 {
+			coef = 72
+		}
+		return int64(h.size()) + frames*coef*int64(bitRate)*1000/int64(h.sampleRate)
+	}
+	bitRates := frameBitRates[h.version]
+	low, high := size(bitRates[1]), size(bitRates[len(bitRates)-1])
+	switch brm := brm.(type) {
+	case CBR:
+		s := size(int(brm))
+		return SizeEstimate{Min: s, Expected: s, Max: s}, nil
+	case ABR:
+		return boundedEstimate(low, size(int(brm)), high), nil
+	case VBR:
+		return boundedEstimate(low, size(typicalVBRBitRate(brm, sampleRate, channels)), high), nil
+	}
+	return SizeEstimate{}, fmt.Errorf("%w: unknown bit rate mode %v", ErrInvalidParameter, brm)
+}
+
+// typicalVBRBitRate returns average bit rate of lame VBR preset. Bit
+// rate is proportional to the sample rate, because lower sample rates
+// have narrower bandwidth to encode.
+func typicalVBRBitRate(vbr VBR, sampleRate signal.Frequency, channels int) int {
+	bitRate := float64(typicalVBRBitRates[vbr]) * float64(sampleRate) / 44100
+	if channels == 1 {
+		bitRate *= monoVBRRatio
+	}
+	return int(bitRate)
+}
+
+// boundedEstimate returns the estimate with expected size clamped to
+// [low, high].
+func boundedEstimate(low, expected, high int64) SizeEstimate {
+	switch {
+	case expected < low:
+		expected = low
+	case expected > high:
+		expected = high
+	}
+	return SizeEstimate{Min: low, Expected: expected, Max: high}
+}
@@ -0,0 +1,180 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/signal"
+)
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		duration    time.Duration
+		sampleRate  signal.Frequency
+		channels    int
+		bitRateMode mp3.BitRateMode
+		expected    mp3.SizeEstimate
+		err         error
+	}{
+		{
+			// 44100 + 576 + 1152 samples fit into 40 frames.
+			duration:    time.Second,
+			sampleRate:  44100,
+			channels:    2,
+			bitRateMode: mp3.CBR(128),
+			expected:    mp3.SizeEstimate{Min: 417 + 16718, Expected: 417 + 16718, Max: 417 + 16718},
+		},
+		{
+			duration:    time.Second,
+			sampleRate:  48000,
+			channels:    2,
+			bitRateMode: mp3.CBR(320),
+			expected:    mp3.SizeEstimate{Min: 960 + 42240, Expected: 960 + 42240, Max: 960 + 42240},
+		},
+		{
+			// 22050 + 576 + 1152 samples fit into 42 frames.
+			duration:    time.Second,
+			sampleRate:  22050,
+			channels:    2,
+			bitRateMode: mp3.ABR(64),
+			expected:    mp3.SizeEstimate{Min: 26 + 1097, Expected: 208 + 8777, Max: 522 + 21942},
+		},
+		{
+			// typical bit rate is halved to 122 kbps at 22050 Hz.
+			duration:    time.Second,
+			sampleRate:  22050,
+			channels:    2,
+			bitRateMode: mp3.VBR(0),
+			expected:    mp3.SizeEstimate{Min: 26 + 1097, Expected: 398 + 16731, Max: 522 + 21942},
+		},
+		{
+			// 8000 + 576 + 1152 samples fit into 17 frames, typical bit
+			// rate of mono is 24 kbps.
+			duration:    time.Second,
+			sampleRate:  8000,
+			channels:    1,
+			bitRateMode: mp3.VBR(0),
+			expected:    mp3.SizeEstimate{Min: 72 + 1224, Expected: 216 + 3672, Max: 1440 + 24480},
+		},
+		{
+			duration:    time.Second,
+			sampleRate:  44100,
+			channels:    3,
+			bitRateMode: mp3.CBR(128),
+			err:         mp3.ErrInvalidParameter,
+		},
+		{
+			duration:    time.Second,
+			sampleRate:  44100,
+			channels:    2,
+			bitRateMode: mp3.VBR(2),
+			expected:    mp3.SizeEstimate{Min: 104 + 4179, Expected: 620 + 24816, Max: 1044 + 41795},
+		},
+		{
+			duration:    time.Second,
+			sampleRate:  44100,
+			channels:    2,
+			bitRateMode: mp3.CBR(100),
+			err:         mp3.ErrInvalidParameter,
+		},
+		{
+			duration:    -time.Second,
+			sampleRate:  44100,
+			channels:    2,
+			bitRateMode: mp3.CBR(128),
+			err:         mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		estimate, err := mp3.EstimateSize(test.duration, test.sampleRate, test.channels, test.bitRateMode)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%v: expected error: %v got: %v", test.bitRateMode, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.bitRateMode, err)
+		}
+		if estimate != test.expected {
+			t.Errorf("%v: expected estimate: %+v got: %+v", test.bitRateMode, test.expected, estimate)
+		}
+	}
+}
+
+func TestEstimateSizeEncoded(t *testing.T) {
+	tests := []struct {
+		backend     mp3.Backend
+		sampleRate  signal.Frequency
+		channels    int
+		bitRateMode mp3.BitRateMode
+		// tolerance is the allowed difference of CBR size. Shine has
+		// shorter delay and padding than lame, so its output is up to
+		// two frames smaller.
+		tolerance int64
+	}{
+		{backend: mp3.Shine, sampleRate: 44100, channels: 2, bitRateMode: mp3.CBR(128), tolerance: 2 * 418},
+		{backend: mp3.Shine, sampleRate: 22050, channels: 1, bitRateMode: mp3.CBR(64), tolerance: 2 * 209},
+		{backend: mp3.Lame, sampleRate: 44100, channels: 2, bitRateMode: mp3.CBR(192), tolerance: 627},
+		{backend: mp3.Lame, sampleRate: 22050, channels: 2, bitRateMode: mp3.VBR(0)},
+		{backend: mp3.Lame, sampleRate: 8000, channels: 1, bitRateMode: mp3.VBR(2)},
+	}
+	for _, test := range tests {
+		if !test.backend.Available() {
+			continue
+		}
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+
+		source := mock.Source{
+			Channels:   test.channels,
+			SampleRate: test.sampleRate,
+			Limit:      2 * int(test.sampleRate),
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.Sink(f, test.bitRateMode, mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(test.backend),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		f.Close()
+
+		estimate, err := mp3.EstimateSize(2*time.Second, test.sampleRate, test.channels, test.bitRateMode)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		size := stat.Size()
+		if _, ok := test.bitRateMode.(mp3.CBR); ok {
+			if size < estimate.Expected-test.tolerance || size > estimate.Expected+test.tolerance {
+				t.Errorf("%v %v: expected size about %d got: %d", test.backend, test.bitRateMode, estimate.Expected, size)
+			}
+			continue
+		}
+		if size < estimate.Min || size > estimate.Max {
+			t.Errorf("%v %v: size %d is out of estimate: %+v", test.backend, test.bitRateMode, size, estimate)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// filePerm is the permission of files created by FileSink.
+const filePerm = 0644
+
+// FileSink allows to write mp3 file atomically. Stream is encoded into
+// a temporary file in the same directory, that is renamed to path after
+// successful flush. If encoding fails, the temporary file is deleted and
+// existing file at path is kept intact. If the pipe is cancelled, the
+// file is either finalized or deleted according to CancelPolicy. Pipe
+// closes the sink the same way when the source fails as when the input
+// is done, so without FileCommit the output of a failed source is
+// finalized as a shorter stream.
+func FileSink(path string, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		dir, name := filepath.Split(path)
+		if dir == "" {
+			dir = "."
+		}
+		f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+		if err != nil {
+			return pipe.Sink{}, fmt.Errorf("error creating temporary file: %w", err)
+		}
+		sink, err := Sink(f, brm, cm, eq, options...)(mctx, bufferSize, props)
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return pipe.Sink{}, err
+		}
+		var opts sinkOptions
+		for _, option := range options {
+			option(&opts)
+		}
+		af := atomicFile{
+			f:      f,
+			path:   path,
+			policy: opts.cancelPolicy,
+			commit: opts.fileCommit != nil,
+		}
+		if opts.fileCommit != nil {
+			opts.fileCommit.af = &af
+		}
+		return pipe.Sink{
+			Context:   sink.Context,
+			SinkFunc:  af.sinkFunc(sink.SinkFunc),
+			FlushFunc: af.flusher(sink.FlushFunc),
+		}, nil
+	}
+}
+
+// atomicFile is a temporary file that replaces the target file when
+// it's complete.
+type atomicFile struct {
+	f      *os.File
+	path   string
+	policy CancelPolicy
+	failed bool
+	// commit is set if the file is renamed by FileCommit.
+	commit bool
+	// flushed is set when the file is complete and waits for commit.
+	flushed bool
+}
+
+// FileCommit allows to finalize the output of FileSink after the pipe is
+// done. FileSink with commit keeps the complete temporary file after
+// flush and Commit renames it only if the pipe succeeded. FileCommit can
+// be bound only to a single FileSink.
+type FileCommit struct {
+	af *atomicFile
+}
+
+// WithFileCommit binds the commit to the FileSink. Commit must be called
+// after the pipe is done, otherwise the temporary file is left in the
+// directory.
+func WithFileCommit(c *FileCommit) SinkOption {
+	return func(o *sinkOptions) {
+		o.fileCommit = c
+	}
+}
+
+// Commit finalizes the output with the error of the pipe. If err is nil,
+// the temporary file is renamed to the path, otherwise it's deleted and
+// err is returned.
+func (c *FileCommit) Commit(err error) error {
+	af := c.af
+	c.af = nil
+	if af == nil || !af.flushed {
+		return err
+	}
+	if err != nil {
+		os.Remove(af.f.Name())
+		return err
+	}
+	return af.rename()
+}
+
+func (af *atomicFile) sinkFunc(fn pipe.SinkFunc) pipe.SinkFunc {
+	return func(floats signal.Floating) error {
+		if err := fn(floats); err != nil {
+			af.failed = true
+			return err
+		}
+		return nil
+	}
+}
+
+// flusher flushes the encoder and renames the file, unless it waits for
+// commit. Flush is called even if the pipe failed, so the encoder is
+// always released.
+func (af *atomicFile) flusher(fn pipe.FlushFunc) pipe.FlushFunc {
+	return func(ctx context.Context) error {
+		err := fn(ctx)
+		discard := af.failed || (ctx.Err() != nil && af.policy == DiscardOnCancel)
+		if err == nil && !discard {
+			err = af.f.Sync()
+		}
+		if closeErr := af.f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil || discard {
+			os.Remove(af.f.Name())
+			return err
+		}
+		if af.commit {
+			af.flushed = true
+			return nil
+		}
+		return af.rename()
+	}
+}
+
+// rename replaces the target file with the complete temporary file.
+func (af *atomicFile) rename() error {
+	if err := os.Chmod(af.f.Name(), filePerm); err != nil {
+		os.Remove(af.f.Name())
+		return fmt.Errorf("error setting file permissions: %w", err)
+	}
+	if err := os.Rename(af.f.Name(), af.path); err != nil {
+		os.Remove(af.f.Name())
+		return fmt.Errorf("error renaming temporary file: %w", err)
+	}
+	return nil
+}
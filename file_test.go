@@ -0,0 +1,209 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestFileSink(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name    string
+		limit   int
+		cancel  bool
+		options []mp3.SinkOption
+		// output must be deleted.
+		deleted bool
+	}{
+		{name: "complete", limit: 100000},
+		{name: "cancelled finalize", limit: 1 << 30, cancel: true},
+		{name: "cancelled discard", limit: 1 << 30, cancel: true, options: []mp3.SinkOption{mp3.WithCancelPolicy(mp3.DiscardOnCancel)}, deleted: true},
+	}
+	for _, test := range tests {
+		dir, err := os.MkdirTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "out.mp3")
+
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      test.limit,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.FileSink(path, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, test.options...),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := p.Start(ctx)
+		if test.cancel {
+			cancel()
+		}
+		err = pipe.Wait(errc)
+		cancel()
+
+		files, readErr := os.ReadDir(dir)
+		if readErr != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, readErr)
+		}
+		if test.deleted {
+			if len(files) != 0 {
+				t.Errorf("%s: expected no files got: %v", test.name, files[0].Name())
+			}
+			continue
+		}
+		if err != nil && !test.cancel {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(files) != 1 || files[0].Name() != "out.mp3" {
+			t.Fatalf("%s: unexpected files: %v", test.name, files)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if frames := splitFrames(t, data); len(frames) == 0 {
+			t.Errorf("%s: no frames encoded", test.name)
+		}
+	}
+}
+
+func TestFileSinkInvalidParameter(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+	}
+	_, err = pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.FileSink(filepath.Join(dir, "out.mp3"), mp3.CBR(100), mp3.JointStereo, mp3.DefaultEncodingQuality),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("expected no files got: %v", files[0].Name())
+	}
+}
+
+func TestFileSinkCommit(t *testing.T) {
+	errSource := errors.New("source failed")
+	tests := []struct {
+		name string
+		// source fails after calls.
+		failAfter int
+		commit    bool
+		// output must be replaced.
+		replaced bool
+	}{
+		{name: "complete", commit: true, replaced: true},
+		{name: "source failed", failAfter: 10, commit: true},
+		{name: "source failed without commit", failAfter: 10, replaced: true},
+	}
+	for _, test := range tests {
+		dir, err := os.MkdirTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "out.mp3")
+		existing := []byte("existing")
+		if err := os.WriteFile(path, existing, 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var commit mp3.FileCommit
+		options := []mp3.SinkOption{mp3.WithBackend(mp3.Shine)}
+		if test.commit {
+			options = append(options, mp3.WithFileCommit(&commit))
+		}
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      100000,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: failingSource(source.Source(), test.failAfter, errSource),
+				Sink:   mp3.FileSink(path, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		err = pipe.Wait(p.Start(context.Background()))
+		if test.commit {
+			err = commit.Commit(err)
+		}
+		if test.failAfter > 0 && !errors.Is(err, errSource) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, errSource, err)
+		}
+		if test.failAfter == 0 && err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(files) != 1 || files[0].Name() != "out.mp3" {
+			t.Fatalf("%s: unexpected files: %v", test.name, files)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if replaced := string(data) != string(existing); replaced != test.replaced {
+			t.Errorf("%s: expected replaced %v got: %v", test.name, test.replaced, replaced)
+		}
+	}
+}
+
+// failingSource returns the source that fails with err after n calls. It
+// doesn't fail if n is zero.
+func failingSource(fn pipe.SourceAllocatorFunc, n int, err error) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		source, allocErr := fn(mctx, bufferSize)
+		if allocErr != nil || n == 0 {
+			return source, allocErr
+		}
+		sourceFunc := source.SourceFunc
+		calls := 0
+		source.SourceFunc = func(out signal.Floating) (int, error) {
+			if calls == n {
+				return 0, err
+			}
+			calls++
+			return sourceFunc(out)
+		}
+		return source, nil
+	}
+}
@@ -0,0 +1,104 @@
+package mp3
+
+import (
+	"fmt"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+// Fingerprinter computes the acoustic fingerprint of decoded audio. Its
+// methods follow the chromaprint API, so chromaprint bindings implement
+// it with chromaprint_start, chromaprint_feed and chromaprint_finish.
+type Fingerprinter interface {
+	// Start is called before the first samples are fed.
+	Start(sampleRate, channels int) error
+	// Feed is called with interleaved 16-bit samples. The slice is
+	// reused after the call returns.
+	Feed(samples []int16) error
+	// Finish is called after the last samples are fed.
+	Finish() error
+}
+
+// fingerprint is the fingerprinter with the duration of the fed audio.
+type fingerprint struct {
+	Fingerprinter
+	length time.Duration
+}
+
+// WithFingerprint makes the Source feed decoded samples into f, so the
+// fingerprint is computed without the second decode pass. Only the first
+// length of the audio is fed, zero length means the whole stream. Finish
+// is called when the length is fed or at the end of the stream.
+// Chromaprint fpcalc uses the first two minutes by default.
+func WithFingerprint(f Fingerprinter, length time.Duration) SourceOption {
+	return func(o *sourceOptions) {
+		if f == nil {
+			o.fail(fmt.Errorf("%w: fingerprinter is nil", ErrInvalidParameter))
+			return
+		}
+		if length < 0 {
+			o.fail(fmt.Errorf("%w: fingerprint length %v is negative", ErrInvalidParameter, length))
+			return
+		}
+		o.fingerprint = &fingerprint{Fingerprinter: f, length: length}
+	}
+}
+
+// fingerprintFeed feeds samples of a single decoding into fingerprinter.
+type fingerprintFeed struct {
+	Fingerprinter
+	// remaining samples per channel, negative if unlimited.
+	remaining int
+	done      bool
+}
+
+// start starts the fingerprinter for the decoded stream.
+func (f *fingerprint) start(sampleRate signal.Frequency, channels int) (*fingerprintFeed, error) {
+	if err := f.Start(int(sampleRate), channels); err != nil {
+		return nil, fmt.Errorf("error starting fingerprint: %w", err)
+	}
+	remaining := -1
+	if f.length > 0 {
+		remaining = sampleRate.Events(f.length)
+	}
+	return &fingerprintFeed{
+		Fingerprinter: f.Fingerprinter,
+		remaining:     remaining,
+	}, nil
+}
+
+// feed passes interleaved decoded samples to the fingerprinter. It's
+// no-op for nil feed.
+func (f *fingerprintFeed) feed(samples []int16, channels int) error {
+	if f == nil || f.done {
+		return nil
+	}
+	n := signal.ChannelLength(len(samples), channels)
+	if f.remaining >= 0 && n > f.remaining {
+		n = f.remaining
+		samples = samples[:n*channels]
+	}
+	if err := f.Feed(samples); err != nil {
+		return fmt.Errorf("error feeding fingerprint: %w", err)
+	}
+	if f.remaining < 0 {
+		return nil
+	}
+	if f.remaining -= n; f.remaining == 0 {
+		return f.finish()
+	}
+	return nil
+}
+
+// finish finishes the fingerprint once. It's no-op for nil feed.
+func (f *fingerprintFeed) finish() error {
+	if f == nil || f.done {
+		return nil
+	}
+	f.done = true
+	if err := f.Finish(); err != nil {
+		return fmt.Errorf("error finishing fingerprint: %w", err)
+	}
+	return nil
+}
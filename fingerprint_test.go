@@ -0,0 +1,116 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// fingerprinter records calls of the fingerprint hook.
+type fingerprinter struct {
+	sampleRate, channels int
+	samples              int
+	finished             int
+	err                  error
+}
+
+func (f *fingerprinter) Start(sampleRate, channels int) error {
+	f.sampleRate, f.channels = sampleRate, channels
+	return nil
+}
+
+func (f *fingerprinter) Feed(samples []int16) error {
+	f.samples += len(samples)
+	return f.err
+}
+
+func (f *fingerprinter) Finish() error {
+	f.finished++
+	return nil
+}
+
+func TestSourceFingerprint(t *testing.T) {
+	data, _ := encodeShine(t)
+	tests := []struct {
+		name   string
+		length time.Duration
+		// expected samples per channel, zero means all decoded samples.
+		samples int
+	}{
+		{
+			name: "whole stream",
+		},
+		{
+			name:    "length",
+			length:  time.Second,
+			samples: 44100,
+		},
+	}
+	for _, test := range tests {
+		var (
+			f    fingerprinter
+			sink = mock.Sink{Discard: true}
+		)
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: mp3.Source(bytes.NewReader(data), mp3.WithFingerprint(&f, test.length)),
+				Sink:   sink.Sink(),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if f.sampleRate != 44100 || f.channels != 2 {
+			t.Errorf("%s: unexpected start: %d Hz %d channels", test.name, f.sampleRate, f.channels)
+		}
+		expected := test.samples
+		if expected == 0 {
+			expected = sink.Counter.Samples
+		}
+		if f.samples != expected*2 {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, expected*2, f.samples)
+		}
+		if f.finished != 1 {
+			t.Errorf("%s: expected single finish got: %d", test.name, f.finished)
+		}
+	}
+}
+
+func TestSourceFingerprintError(t *testing.T) {
+	data, _ := encodeShine(t)
+	errFeed := errors.New("feed error")
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3.Source(bytes.NewReader(data), mp3.WithFingerprint(&fingerprinter{err: errFeed}, 0)),
+			Sink:   (&mock.Sink{Discard: true}).Sink(),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); !errors.Is(err, errFeed) {
+		t.Errorf("expected error: %v got: %v", errFeed, err)
+	}
+
+	_, err = pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3.Source(bytes.NewReader(data), mp3.WithFingerprint(nil, 0)),
+			Sink:   (&mock.Sink{Discard: true}).Sink(),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,191 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// frameHeaderSize is the size of MPEG audio frame header in bytes.
+const frameHeaderSize = 4
+
+// MPEG audio version bits.
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+)
+
+// errInvalidFrame is returned when bytes don't start with a valid Layer
+// III frame header.
+var errInvalidFrame = errors.New("invalid frame header")
+
+// frameHeader is a parsed header of MPEG Layer III frame.
+type frameHeader struct {
+	version    int
+	protected  bool
+	bitRate    int // kbps
+	sampleRate int
+	padding    bool
+	mode       int
+}
+
+var (
+	frameSampleRates = [...][3]int{
+		mpeg25: {11025, 12000, 8000},
+		mpeg2:  {22050, 24000, 16000},
+		mpeg1:  {44100, 48000, 32000},
+	}
+	frameBitRates = [...][15]int{
+		mpeg25: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		mpeg2:  {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		mpeg1:  {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+)
+
+// parseFrameHeader parses the header at the start of b.
+func parseFrameHeader(b []byte) (frameHeader, error) {
+	if len(b) < frameHeaderSize {
+		return frameHeader{}, errInvalidFrame
+	}
+	if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return frameHeader{}, errInvalidFrame
+	}
+	version := int(b[1] >> 3 & 0x03)
+	layer := b[1] >> 1 & 0x03
+	bitRateIndex := b[2] >> 4
+	sampleRateIndex := b[2] >> 2 & 0x03
+	switch {
+	case version == 1:
+		return frameHeader{}, fmt.Errorf("%w: reserved version", errInvalidFrame)
+	case layer != 1:
+		return frameHeader{}, fmt.Errorf("%w: not Layer III", errInvalidFrame)
+	case bitRateIndex == 0 || bitRateIndex == 15:
+		return frameHeader{}, fmt.Errorf("%w: unsupported bit rate index %d", errInvalidFrame, bitRateIndex)
+	case sampleRateIndex == 3:
+		return frameHeader{}, fmt.Errorf("%w: reserved sample rate", errInvalidFrame)
+	}
+	return frameHeader{
+		version:    version,
+		protected:  b[1]&0x01 == 0,
+		bitRate:    frameBitRates[version][bitRateIndex],
+		sampleRate: frameSampleRates[version][sampleRateIndex],
+		padding:    b[2]>>1&0x01 == 1,
+		mode:       int(b[3] >> 6),
+	}, nil
+}
+
+// size returns the size of the frame in bytes including header.
+func (h frameHeader) size() int {
+	coef := 144
+	if h.version != mpeg1 {
+		coef = 72
+	}
+	size := coef * h.bitRate * 1000 / h.sampleRate
+	if h.padding {
+		size++
+	}
+	return size
+}
+
+// samples returns the number of samples per channel in the frame.
+func (h frameHeader) samples() int {
+	if h.version == mpeg1 {
+		return 1152
+	}
+	return 576
+}
+
+// duration returns the duration of the frame.
+func (h frameHeader) duration() time.Duration {
+	return time.Duration(int64(h.samples()) * int64(time.Second) / int64(h.sampleRate))
+}
+
+// sideInfoSize returns the size of side information in bytes.
+func (h frameHeader) sideInfoSize() int {
+	mono := h.mode == 3
+	switch {
+	case h.version == mpeg1 && mono:
+		return 17
+	case h.version == mpeg1:
+		return 32
+	case mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// granuleLayout returns the number of granules and channels of side
+// information, the bit offset of the first granule and the size of
+// granule of a channel in bits.
+func (h frameHeader) granuleLayout() (granules, channels, offset, size int) {
+	channels, private := 2, 3
+	if h.mode == 3 {
+		channels, private = 1, 5
+	}
+	if h.version != mpeg1 {
+		return 1, channels, 8 + channels, 63
+	}
+	// main_data_begin, private bits and scfsi precede granules.
+	return 2, channels, 9 + private + 4*channels, 59
+}
+
+// splitFrames splits b into complete frames. The remainder that doesn't
+// contain a complete frame is returned separately.
+func splitFrames(b []byte) (frames [][]byte, remainder []byte, err error) {
+	for len(b) >= frameHeaderSize {
+		h, err := parseFrameHeader(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		size := h.size()
+		if size > len(b) {
+			break
+		}
+		frames = append(frames, b[:size])
+		b = b[size:]
+	}
+	return frames, b, nil
+}
+
+// frameScanner finds frames in the stream that is written by parts.
+type frameScanner struct {
+	// partial header of the next frame and bytes left in the current one.
+	next []byte
+	left int
+}
+
+// scan calls fn for every frame header that is completed in p. Offset of
+// the frame is relative to p and is negative if the frame starts in the
+// previous part.
+func (s *frameScanner) scan(p []byte, fn func(offset int, h frameHeader)) error {
+	for b := p; len(b) > 0; {
+		if s.left > 0 {
+			skip := s.left
+			if skip > len(b) {
+				skip = len(b)
+			}
+			s.left -= skip
+			b = b[skip:]
+			continue
+		}
+		missing := frameHeaderSize - len(s.next)
+		if missing > len(b) {
+			missing = len(b)
+		}
+		s.next = append(s.next, b[:missing]...)
+		b = b[missing:]
+		if len(s.next) < frameHeaderSize {
+			break
+		}
+		h, err := parseFrameHeader(s.next)
+		if err != nil {
+			return err
+		}
+		fn(len(p)-len(b)-frameHeaderSize, h)
+		s.left = h.size() - frameHeaderSize
+		s.next = s.next[:0]
+	}
+	return nil
+}
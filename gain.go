@@ -0,0 +1,188 @@
+package mp3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	// GainStep is the change of loudness in dB of a single step of
+	// global gain.
+	GainStep = 1.5
+	// gainUndo is the description of TXXX frame with undo information.
+	// The value has the format of mp3gain.
+	gainUndo = "MP3GAIN_UNDO"
+	// maxGlobalGain is the max value of 8-bit global gain.
+	maxGlobalGain = 255
+)
+
+// AdjustGain copies MP3 stream from r into w and changes global gain of
+// every granule by steps of 1.5 dB without decoding. Positive steps make
+// the audio louder. Gain of granules is clipped to the range of the
+// field, so the change of extreme granules can't be undone exactly. The
+// change that reverts the adjustment is stored in TXXX frame of ID3v2 tag
+// with MP3GAIN_UNDO description in the format of mp3gain and accumulates
+// with the previous adjustments. Other frames of the existing tag and
+// ID3v1 tag are kept. Xing/Info frame is copied as is.
+func AdjustGain(r io.Reader, w io.Writer, steps int) error {
+	return adjustGain(r, w, func([2]int) [2]int { return [2]int{steps, steps} })
+}
+
+// UndoGain copies MP3 stream from r into w and reverts gain adjustments
+// stored in MP3GAIN_UNDO frame of ID3v2 tag. The frame is removed, the
+// tag is removed if it has no other frames. Stream without the frame is
+// copied unchanged.
+func UndoGain(r io.Reader, w io.Writer) error {
+	return adjustGain(r, w, func(undo [2]int) [2]int { return undo })
+}
+
+// adjustGain changes gain of left and right channels returned by change
+// for the undo information of the stream.
+func adjustGain(r io.Reader, w io.Writer, change func(undo [2]int) [2]int) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading MP3 data: %w", err)
+	}
+	var tag, id3v1 []byte
+	if bytes.HasPrefix(data, []byte("ID3")) && len(data) >= id3v2HeaderSize {
+		size := id3v2TagSize(data)
+		if size > len(data) {
+			return fmt.Errorf("%w: tag of %d bytes exceeds the stream", errInvalidID3v2, size)
+		}
+		tag, data = data[:size], data[size:]
+	}
+	if len(data) >= id3v1Size && isID3v1(data[len(data)-id3v1Size:]) {
+		data, id3v1 = data[:len(data)-id3v1Size], data[len(data)-id3v1Size:]
+	}
+	frames, rest, err := splitFrames(data)
+	if err != nil {
+		return fmt.Errorf("error parsing MP3 frame: %w", err)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("error parsing MP3 frame: %w: incomplete frame of %d bytes", errInvalidFrame, len(rest))
+	}
+
+	var (
+		version = ID3v24
+		kept    []id3Frame
+		undo    [2]int
+	)
+	if tag != nil {
+		var tagFrames []id3Frame
+		if version, tagFrames, err = parseID3v2Frames(tag); err != nil {
+			return err
+		}
+		for _, f := range tagFrames {
+			if value, ok := userTextValue(f, gainUndo); ok {
+				if _, err := fmt.Sscanf(value, "%d,%d", &undo[0], &undo[1]); err != nil {
+					return fmt.Errorf("%w: invalid %s value %q", errInvalidID3v2, gainUndo, value)
+				}
+				continue
+			}
+			kept = append(kept, f)
+		}
+	}
+	gain := change(undo)
+	for i, frame := range frames {
+		h, _ := parseFrameHeader(frame)
+		if _, ok := parseXingFrame(frame, h); ok && i == 0 {
+			continue
+		}
+		adjustFrameGain(frame, h, gain)
+	}
+	if undo = [2]int{undo[0] - gain[0], undo[1] - gain[1]}; undo != [2]int{} {
+		value := fmt.Sprintf("%+04d,%+04d,N", undo[0], undo[1])
+		f := UserText{Description: gainUndo, Value: value, Encoding: Latin1}.frame(id3Text{version: version})
+		kept = append([]id3Frame{f}, kept...)
+	}
+	if len(kept) > 0 {
+		// padding of the existing tag is kept if frames fit it.
+		if tag, err = (ID3v2{Version: version}).bytes(kept, len(tag)); err != nil {
+			return err
+		}
+		if _, err := w.Write(tag); err != nil {
+			return fmt.Errorf("error writing ID3v2 tag: %w", err)
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("error copying audio: %w", err)
+	}
+	if _, err := w.Write(id3v1); err != nil {
+		return fmt.Errorf("error writing ID3v1 tag: %w", err)
+	}
+	return nil
+}
+
+// userTextValue returns the value of TXXX frame with description.
+func userTextValue(f id3Frame, description string) (string, bool) {
+	if f.id != "TXXX" || len(f.body) == 0 {
+		return "", false
+	}
+	e := f.body[0]
+	desc := id3Terminated(f.body[1:], e)
+	if decodeString(e, desc) != description {
+		return "", false
+	}
+	// skip terminator of description.
+	terminator := 1
+	if e == 1 || e == 2 {
+		terminator = 2
+	}
+	value := f.body[1+len(desc):]
+	if len(value) < terminator {
+		return "", true
+	}
+	value = value[terminator:]
+	return decodeString(e, id3Terminated(value, e)), true
+}
+
+// adjustFrameGain changes global gain of granules of the frame in place.
+// CRC of protected frame is updated.
+func adjustFrameGain(frame []byte, h frameHeader, gain [2]int) {
+	side := frame[frameHeaderSize:]
+	if h.protected {
+		side = side[2:]
+	}
+	// global gain follows part2_3_length and big_values of every
+	// granule of every channel.
+	granules, channels, offset, size := h.granuleLayout()
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < channels; ch++ {
+			pos := offset + (gr*channels+ch)*size + 21
+			v := uint16(side[pos/8])<<8 | uint16(side[pos/8+1])
+			shift := 8 - uint(pos%8)
+			g := int(v>>shift&0xff) + gain[ch]
+			switch {
+			case g < 0:
+				g = 0
+			case g > maxGlobalGain:
+				g = maxGlobalGain
+			}
+			v = v&^(0xff<<shift) | uint16(g)<<shift
+			side[pos/8], side[pos/8+1] = byte(v>>8), byte(v)
+		}
+	}
+	if h.protected {
+		crc := frameCRC(frame, h)
+		frame[4], frame[5] = byte(crc>>8), byte(crc)
+	}
+}
+
+// frameCRC returns CRC-16 of the last two bytes of frame header and side
+// information as defined by ISO 11172-3.
+func frameCRC(frame []byte, h frameHeader) uint16 {
+	crc := uint16(0xffff)
+	side := frame[frameHeaderSize+2 : frameHeaderSize+2+h.sideInfoSize()]
+	for _, b := range append(frame[2:4:4], side...) {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
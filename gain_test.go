@@ -0,0 +1,121 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// decodedPeak returns the peak of decoded stream.
+func decodedPeak(t *testing.T, data []byte) float64 {
+	t.Helper()
+	sink := mock.Sink{}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3.Source(bytes.NewReader(data)),
+			Sink:   sink.Sink(),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var peak float64
+	for i := 0; i < sink.Values.Len(); i++ {
+		peak = math.Max(peak, math.Abs(sink.Values.Sample(i)))
+	}
+	return peak
+}
+
+func TestAdjustGain(t *testing.T) {
+	input := encodeTracks(t, tracksSource(time.Second))
+	peak := decodedPeak(t, input)
+
+	var louder bytes.Buffer
+	if err := mp3.AdjustGain(bytes.NewReader(input), &louder, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames, audio := parseID3v2(t, louder.Bytes())
+	if len(frames) != 1 || frames[0].id != "TXXX" || string(frames[0].body) != "\x00MP3GAIN_UNDO\x00-002,-002,N" {
+		t.Errorf("unexpected undo frames: %q", frames)
+	}
+	if len(audio) != len(input) {
+		t.Errorf("expected audio of %d bytes got: %d", len(input), len(audio))
+	}
+	// two steps of global gain are 3 dB.
+	if ratio := decodedPeak(t, audio) / peak; math.Abs(ratio-math.Sqrt2) > 0.01 {
+		t.Errorf("expected peak ratio: %v got: %v", math.Sqrt2, ratio)
+	}
+
+	var quieter bytes.Buffer
+	if err := mp3.AdjustGain(bytes.NewReader(louder.Bytes()), &quieter, -5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames, _ = parseID3v2(t, quieter.Bytes())
+	if len(frames) != 1 || !strings.HasSuffix(string(frames[0].body), "+003,+003,N") {
+		t.Errorf("expected accumulated undo got: %q", frames)
+	}
+
+	var undone bytes.Buffer
+	if err := mp3.UndoGain(bytes.NewReader(quieter.Bytes()), &undone); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(undone.Bytes(), input) {
+		t.Errorf("expected original stream after undo")
+	}
+}
+
+func TestAdjustGainKeepsTags(t *testing.T) {
+	input, err := encodeTagged(
+		mp3.WithID3v2(mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}}),
+		mp3.WithID3v1(mp3.ID3v1{Title: "Title"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := mp3.AdjustGain(bytes.NewReader(input), &buf, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames, audio := parseID3v2(t, buf.Bytes())
+	if len(frames) != 2 || frames[0].id != "TXXX" || frames[1].id != "TIT2" {
+		t.Errorf("expected undo and title frames got: %q", frames)
+	}
+	if !bytes.HasPrefix(audio[len(audio)-128:], []byte("TAGTitle")) {
+		t.Errorf("expected ID3v1 tag")
+	}
+
+	var undone bytes.Buffer
+	if err := mp3.UndoGain(bytes.NewReader(buf.Bytes()), &undone); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames, undoneAudio := parseID3v2(t, undone.Bytes())
+	if len(frames) != 1 || frames[0].id != "TIT2" {
+		t.Errorf("expected title frame got: %q", frames)
+	}
+	_, original := parseID3v2(t, input)
+	if !bytes.Equal(undoneAudio, original) {
+		t.Errorf("expected original audio after undo")
+	}
+}
+
+func TestAdjustGainInvalid(t *testing.T) {
+	input := encodeTracks(t, tracksSource(time.Second))
+	err := mp3.AdjustGain(bytes.NewReader(input[:len(input)-1]), &bytes.Buffer{}, 1)
+	if err == nil || !strings.Contains(err.Error(), "incomplete frame") {
+		t.Errorf("expected incomplete frame error got: %v", err)
+	}
+	if err := mp3.AdjustGain(bytes.NewReader(append([]byte("junk"), input...)), &bytes.Buffer{}, 1); err == nil {
+		t.Errorf("expected error")
+	}
+}
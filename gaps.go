@@ -0,0 +1,174 @@
+package mp3
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+const (
+	// gapWindow is the duration of audio at both sides of the join that
+	// is inspected.
+	gapWindow = 10 * time.Second
+	// maxOverlap is the max duration of overlap that is detected.
+	maxOverlap = time.Second
+	// overlapWindow is the number of samples compared to detect the
+	// overlap. It's the min overlap that is detected, because shorter
+	// parts of music are often similar to the previous periods.
+	overlapWindow = 1024
+	// overlapError is max normalized error of the overlapped audio.
+	overlapError = 0.1
+)
+
+type (
+	// GapCheck configures CheckGaps.
+	GapCheck struct {
+		// Threshold is the level in dBFS below which the audio is silent.
+		// Default is -50 dBFS.
+		Threshold float64
+	}
+
+	// Gap is the join of consecutive tracks.
+	Gap struct {
+		// Track is the index of the track that ends at the join.
+		Track int
+		// Gapless is true if both tracks have encoder delay and padding
+		// in Xing/Info frame, so gapless players remove them.
+		Gapless bool
+		// Samples is the number of samples per channel between tracks
+		// played gaplessly. Positive value is the silence at the end of
+		// the track and at the start of the next one, negative value is
+		// the audio that is repeated at the start of the next track.
+		Samples int
+	}
+)
+
+// CheckGaps decodes sequential tracks of the album and reports joins
+// between them. Delay and padding of Xing/Info frame are removed from
+// decoded audio the same way gapless players do and whole decoded frames
+// are used for tracks without them. Gap and overlap are measured within
+// 10 seconds of the join, overlap from 1024 samples up to a second is
+// detected. Albums encoded without gaps have zero samples at every join,
+// unless the music has silence there.
+func CheckGaps(tracks []io.Reader, c GapCheck) ([]Gap, error) {
+	if c.Threshold > 0 || math.IsNaN(c.Threshold) {
+		return nil, fmt.Errorf("%w: silence threshold %v dBFS is above full scale", ErrInvalidParameter, c.Threshold)
+	}
+	if c.Threshold == 0 {
+		c.Threshold = defaultSilenceThreshold
+	}
+	threshold := math.Pow(10, c.Threshold/20) * math.MaxInt16
+	var (
+		gaps []Gap
+		prev gapTrack
+	)
+	for i, r := range tracks {
+		t, err := readGapTrack(r)
+		if err != nil {
+			return nil, fmt.Errorf("track %d: %w", i, err)
+		}
+		if i > 0 {
+			gaps = append(gaps, Gap{
+				Track:   i - 1,
+				Gapless: prev.gapless && t.gapless,
+				Samples: measureGap(prev.tail, t.head, threshold, t.rate.Events(maxOverlap)),
+			})
+		}
+		prev = t
+	}
+	return gaps, nil
+}
+
+// gapTrack holds audible samples at the start and the end of the track,
+// mixed to mono.
+type gapTrack struct {
+	head, tail []float64
+	gapless    bool
+	rate       signal.Frequency
+}
+
+// readGapTrack decodes the track and keeps its head and tail.
+func readGapTrack(r io.Reader) (gapTrack, error) {
+	in, err := readCutInput(r)
+	if err != nil {
+		return gapTrack{}, err
+	}
+	var (
+		window = in.rate.Events(gapWindow)
+		t      = gapTrack{
+			gapless: in.gapless,
+			rate:    in.rate,
+		}
+		ring  = make([]float64, window)
+		total int
+	)
+	err = in.decode(func(left, right int16) {
+		v := (float64(left) + float64(right)) / 2
+		if len(t.head) < window {
+			t.head = append(t.head, v)
+		}
+		ring[total%window] = v
+		total++
+	})
+	if err != nil {
+		return gapTrack{}, err
+	}
+	if total < window {
+		t.tail = ring[:total]
+	} else {
+		start := total % window
+		t.tail = append(ring[start:], ring[:start]...)
+	}
+	return t, nil
+}
+
+// measureGap returns the overlap of the head with the tail as negative
+// value or the length of silence at the join.
+func measureGap(tail, head []float64, threshold float64, maxSamples int) int {
+	if overlap := measureOverlap(tail, head, threshold, maxSamples); overlap > 0 {
+		return -overlap
+	}
+	var silence int
+	for i := len(tail) - 1; i >= 0 && math.Abs(tail[i]) < threshold; i-- {
+		silence++
+	}
+	for i := 0; i < len(head) && math.Abs(head[i]) < threshold; i++ {
+		silence++
+	}
+	return silence
+}
+
+// measureOverlap returns the number of samples at the end of the tail
+// that are repeated at the start of the head or zero. The overlap with
+// the least error is returned.
+func measureOverlap(tail, head []float64, threshold float64, max int) int {
+	var (
+		overlap int
+		best    = overlapError
+	)
+	if max > len(tail) {
+		max = len(tail)
+	}
+	if len(head) < overlapWindow {
+		return 0
+	}
+	for n := overlapWindow; n <= max; n++ {
+		var diff, energy float64
+		for i, v := range tail[len(tail)-n : len(tail)-n+overlapWindow] {
+			d := v - head[i]
+			diff += d * d
+			energy += v*v + head[i]*head[i]
+		}
+		// silence matches any silence.
+		if energy < 2*overlapWindow*threshold*threshold {
+			continue
+		}
+		if e := diff / energy; e < best {
+			best, overlap = e, n
+		}
+	}
+	return overlap
+}
@@ -0,0 +1,144 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// chirpSource returns stereo 44100 Hz source of sine that sweeps from 200
+// to 2000 Hz, so its parts don't repeat.
+func chirpSource(d time.Duration) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		var (
+			pos   int
+			total = signal.Frequency(44100).Events(d)
+			sweep = 1800 / d.Seconds()
+		)
+		return pipe.Source{
+			SourceFunc: func(out signal.Floating) (int, error) {
+				if pos == total {
+					return 0, io.EOF
+				}
+				n := out.Length()
+				if left := total - pos; n > left {
+					n = left
+				}
+				for i := 0; i < n; i++ {
+					t := float64(pos+i) / 44100
+					v := 0.5 * math.Sin(2*math.Pi*(200*t+sweep*t*t/2))
+					for c := 0; c < out.Channels(); c++ {
+						out.SetSample(i*out.Channels()+c, v)
+					}
+				}
+				pos += n
+				return n, nil
+			},
+			SignalProperties: pipe.SignalProperties{
+				Channels:   2,
+				SampleRate: 44100,
+			},
+		}, nil
+	}
+}
+
+func TestCheckGaps(t *testing.T) {
+	album := encodeTracks(t, chirpSource(3*time.Second))
+	// cut writes seekable file, so it has Info frame.
+	cut := func(start, end time.Duration) []byte {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		if err := mp3.Cut(bytes.NewReader(album), f, start, end); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return data
+	}
+	// strip drops Xing/Info frame with delay and padding.
+	strip := func(b []byte) []byte {
+		frames := splitFrames(t, b)
+		return bytes.Join(frames[1:], nil)
+	}
+	track := encodeTracks(t, chirpSource(time.Second))
+	tests := []struct {
+		name     string
+		tracks   [][]byte
+		expected []mp3.Gap
+	}{
+		{
+			name:   "gapless",
+			tracks: [][]byte{cut(0, 1500*time.Millisecond), cut(1500*time.Millisecond, 0)},
+			expected: []mp3.Gap{
+				{Track: 0, Gapless: true, Samples: 0},
+			},
+		},
+		{
+			name:   "overlap",
+			tracks: [][]byte{cut(0, 1500*time.Millisecond), cut(1400*time.Millisecond, 0)},
+			expected: []mp3.Gap{
+				{Track: 0, Gapless: true, Samples: -4410},
+			},
+		},
+		{
+			name: "silence",
+			tracks: [][]byte{
+				track,
+				encodeTracks(t, tracksSource(0, 500*time.Millisecond, time.Second)),
+			},
+			expected: []mp3.Gap{
+				{Track: 0, Gapless: true, Samples: 22050},
+			},
+		},
+		{
+			name:   "without gapless metadata",
+			tracks: [][]byte{strip(track), strip(track)},
+			// padding of 1452 samples is shifted by decoder delay into
+			// the next track that also has shine delay.
+			expected: []mp3.Gap{
+				{Track: 0, Gapless: false, Samples: 1452 - 529 + 528 + 529},
+			},
+		},
+	}
+	// sine samples at zero crossings are below the threshold, that is
+	// raised above pre-echo of the encoder.
+	const tolerance = 8
+	for _, test := range tests {
+		readers := make([]io.Reader, 0, len(test.tracks))
+		for _, track := range test.tracks {
+			readers = append(readers, bytes.NewReader(track))
+		}
+		gaps, err := mp3.CheckGaps(readers, mp3.GapCheck{Threshold: -30})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(gaps) != len(test.expected) {
+			t.Fatalf("%s: expected gaps: %v got: %v", test.name, test.expected, gaps)
+		}
+		for i, gap := range gaps {
+			expected := test.expected[i]
+			if d := gap.Samples - expected.Samples; gap.Track != expected.Track || gap.Gapless != expected.Gapless || d < -tolerance || d > tolerance {
+				t.Errorf("%s: expected gap: %+v got: %+v", test.name, expected, gap)
+			}
+		}
+	}
+
+	if _, err := mp3.CheckGaps(nil, mp3.GapCheck{Threshold: 1}); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,123 @@
+package mp3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NoGenre is ID3v1 genre index that means no genre.
+const NoGenre = 255
+
+// genres are names of ID3v1 genres including Winamp extensions.
+var genres = [...]string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychedelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+	"Folk", "Folk-Rock", "National Folk", "Swing", "Fast Fusion", "Bebob", "Latin", "Revival",
+	"Celtic", "Bluegrass", "Avantgarde", "Gothic Rock", "Progressive Rock", "Psychedelic Rock", "Symphonic Rock", "Slow Rock",
+	"Big Band", "Chorus", "Easy Listening", "Acoustic", "Humour", "Speech", "Chanson", "Opera",
+	"Chamber Music", "Sonata", "Symphony", "Booty Bass", "Primus", "Porn Groove", "Satire", "Slow Jam",
+	"Club", "Tango", "Samba", "Folklore", "Ballad", "Power Ballad", "Rhythmic Soul", "Freestyle",
+	"Duet", "Punk Rock", "Drum Solo", "A capella", "Euro-House", "Dance Hall", "Goa", "Drum & Bass",
+	"Club-House", "Hardcore Techno", "Terror", "Indie", "BritPop", "Negerpunk", "Polsk Punk", "Beat",
+	"Christian Gangsta Rap", "Heavy Metal", "Black Metal", "Crossover", "Contemporary Christian", "Christian Rock", "Merengue", "Salsa",
+	"Thrash Metal", "Anime", "Jpop", "Synthpop", "Abstract", "Art Rock", "Baroque", "Bhangra",
+	"Big Beat", "Breakbeat", "Chillout", "Downtempo", "Dub", "EBM", "Eclectic", "Electro",
+	"Electroclash", "Emo", "Experimental", "Garage", "Global", "IDM", "Illbient", "Industro-Goth",
+	"Jam Band", "Krautrock", "Leftfield", "Lounge", "Math Rock", "New Romantic", "Nu-Breakz", "Post-Punk",
+	"Post-Rock", "Psytrance", "Shoegaze", "Space Rock", "Trop Rock", "World Music", "Neoclassical", "Audiobook",
+	"Audio Theatre", "Neue Deutsche Welle", "Podcast", "Indie Rock", "G-Funk", "Dubstep", "Garage Rock", "Psybient",
+}
+
+// GenreName returns the name of ID3v1 genre.
+func GenreName(id byte) (string, bool) {
+	if int(id) >= len(genres) {
+		return "", false
+	}
+	return genres[id], true
+}
+
+// GenreID returns ID3v1 genre index of the name. Case, spaces and
+// punctuation are ignored.
+func GenreID(name string) (byte, bool) {
+	key := genreKey(name)
+	if key == "" {
+		return NoGenre, false
+	}
+	for i, g := range genres {
+		if genreKey(g) == key {
+			return byte(i), true
+		}
+	}
+	return NoGenre, false
+}
+
+// NormalizeGenre returns the canonical name of genre. It accepts names,
+// numeric genres and ID3v2.3 references like "(17)" or "(17)Rock".
+// Genres that aren't in ID3v1 list are returned trimmed.
+func NormalizeGenre(genre string) string {
+	s := strings.TrimSpace(genre)
+	if strings.HasPrefix(s, "(") {
+		if end := strings.IndexByte(s, ')'); end > 0 {
+			if id, err := strconv.Atoi(s[1:end]); err == nil {
+				if name, ok := genreName(id); ok {
+					return name
+				}
+			}
+			if refinement := strings.TrimSpace(s[end+1:]); refinement != "" {
+				s = refinement
+			}
+		}
+	}
+	if id, err := strconv.Atoi(s); err == nil {
+		if name, ok := genreName(id); ok {
+			return name
+		}
+	}
+	if id, ok := GenreID(s); ok {
+		return genres[id]
+	}
+	return s
+}
+
+// GenreFrame returns TCON frame of the genres. Known genres are written
+// as "(17)Rock" in ID3v2.3 and by name in ID3v2.4.
+func GenreFrame(v ID3Version, genres ...string) TextFrame {
+	values := make([]string, 0, len(genres))
+	for _, genre := range genres {
+		name := NormalizeGenre(genre)
+		if id, ok := GenreID(name); ok && v == ID3v23 {
+			name = "(" + strconv.Itoa(int(id)) + ")" + name
+		}
+		values = append(values, name)
+	}
+	if len(values) == 1 {
+		return TextFrame{ID: "TCON", Value: values[0]}
+	}
+	return TextFrame{ID: "TCON", Values: values}
+}
+
+func genreName(id int) (string, bool) {
+	if id < 0 || id > 0xff {
+		return "", false
+	}
+	return GenreName(byte(id))
+}
+
+// genreKey returns lowercase name without spaces and punctuation.
+func genreKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '&' || r == '+' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
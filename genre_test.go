@@ -0,0 +1,101 @@
+package mp3_test
+
+import (
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestGenre(t *testing.T) {
+	tests := []struct {
+		genre      string
+		normalized string
+		id         byte
+		v23        string
+		v24        string
+	}{
+		{
+			genre:      "Rock",
+			normalized: "Rock",
+			id:         17,
+			v23:        "(17)Rock",
+			v24:        "Rock",
+		},
+		{
+			genre:      " hip hop ",
+			normalized: "Hip-Hop",
+			id:         7,
+			v23:        "(7)Hip-Hop",
+			v24:        "Hip-Hop",
+		},
+		{
+			genre:      "(9)",
+			normalized: "Metal",
+			id:         9,
+			v23:        "(9)Metal",
+			v24:        "Metal",
+		},
+		{
+			genre:      "(17)Rock",
+			normalized: "Rock",
+			id:         17,
+			v23:        "(17)Rock",
+			v24:        "Rock",
+		},
+		{
+			genre:      "186",
+			normalized: "Podcast",
+			id:         186,
+			v23:        "(186)Podcast",
+			v24:        "Podcast",
+		},
+		{
+			genre:      "Drum & Bass",
+			normalized: "Drum & Bass",
+			id:         127,
+			v23:        "(127)Drum & Bass",
+			v24:        "Drum & Bass",
+		},
+		{
+			genre:      " Vaporwave ",
+			normalized: "Vaporwave",
+			id:         mp3.NoGenre,
+			v23:        "Vaporwave",
+			v24:        "Vaporwave",
+		},
+		{
+			genre:      "(250)",
+			normalized: "(250)",
+			id:         mp3.NoGenre,
+			v23:        "(250)",
+			v24:        "(250)",
+		},
+	}
+	for _, test := range tests {
+		if normalized := mp3.NormalizeGenre(test.genre); normalized != test.normalized {
+			t.Fatalf("%q: unexpected normalized genre: %q expected: %q", test.genre, normalized, test.normalized)
+		}
+		id, ok := mp3.GenreID(test.normalized)
+		if id != test.id || ok != (test.id != mp3.NoGenre) {
+			t.Fatalf("%q: unexpected genre id: %d expected: %d", test.genre, id, test.id)
+		}
+		if ok {
+			if name, _ := mp3.GenreName(id); name != test.normalized {
+				t.Fatalf("%q: unexpected genre name: %q expected: %q", test.genre, name, test.normalized)
+			}
+		}
+		if frame := mp3.GenreFrame(mp3.ID3v23, test.genre); frame.ID != "TCON" || frame.Value != test.v23 {
+			t.Fatalf("%q: unexpected v2.3 frame: %+v expected: %q", test.genre, frame, test.v23)
+		}
+		if frame := mp3.GenreFrame(mp3.ID3v24, test.genre); frame.ID != "TCON" || frame.Value != test.v24 {
+			t.Fatalf("%q: unexpected v2.4 frame: %+v expected: %q", test.genre, frame, test.v24)
+		}
+	}
+	frame := mp3.GenreFrame(mp3.ID3v23, "rock", "Vaporwave")
+	if frame.Value != "" || len(frame.Values) != 2 || frame.Values[0] != "(17)Rock" || frame.Values[1] != "Vaporwave" {
+		t.Fatalf("unexpected multiple genres frame: %+v", frame)
+	}
+	if _, ok := mp3.GenreName(192); ok {
+		t.Fatalf("unexpected genre name for 192")
+	}
+}
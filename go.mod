@@ -2,9 +2,8 @@ module pipelined.dev/audio/mp3
 
 require (
 	github.com/hajimehoshi/go-mp3 v0.3.1
-	github.com/viert/lame v0.0.0-20190823071122-49a063e7d5e6
 	pipelined.dev/pipe v0.10.0
 	pipelined.dev/signal v0.10.0
 )
 
-go 1.13
+go 1.21.0
@@ -0,0 +1,347 @@
+package mp3
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+const (
+	defaultSegmentDuration = 6 * time.Second
+	defaultSegmentName     = "segment%05d.mp3"
+	defaultPlaylistName    = "playlist.m3u8"
+	defaultPlaylistSize    = 5
+	// hlsTimestampOwner is the owner of PRIV frame with the timestamp of
+	// packed audio segment.
+	hlsTimestampOwner = "com.apple.streaming.transportStreamTimestamp"
+	// hlsClockRate is the rate of MPEG-2 transport stream clock.
+	hlsClockRate = 90000
+)
+
+// HLS configures the output of HLSSink. Segments and playlist are
+// written into writers returned by Create if it's set or into Dir
+// otherwise.
+type HLS struct {
+	Dir    string
+	Create func(name string) (io.WriteCloser, error)
+	// SegmentDuration is the target duration of segments. Segments are
+	// cut at frame boundaries. Default is 6 seconds.
+	SegmentDuration time.Duration
+	// SegmentName is the format of segment names with the index of the
+	// segment. Default is segment%05d.mp3.
+	SegmentName string
+	// SegmentNames returns the name of the segment with the index and
+	// start time. NameTemplate.Name can be used as it. It overrides
+	// SegmentName if set.
+	SegmentNames func(seq int, start time.Time) string
+	// Playlist is the name of m3u8 playlist. Default is playlist.m3u8.
+	Playlist string
+	// Live makes the playlist updated after every segment with the window
+	// of the last PlaylistSize segments. Otherwise VOD playlist is written
+	// on flush. Segments removed from the window are deleted from Dir
+	// after the next playlist update, so clients that loaded the previous
+	// playlist can still get them. Segments written by Create are never
+	// deleted, Finalized can be used to track and delete them.
+	Live         bool
+	PlaylistSize int
+	// Finalized is called after every segment is closed.
+	Finalized func(Segment)
+}
+
+// HLSSink cuts the stream into segments and writes HLS playlist. Every
+// segment starts with ID3 tag that contains the timestamp of the first
+// sample, as required for packed audio. Timestamps exclude the encoder
+// delay, so the first input sample is at zero and the first segment
+// starts before it. ID3 and ICY metadata options are not supported.
+func HLSSink(h HLS, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		var opts sinkOptions
+		for _, option := range options {
+			option(&opts)
+		}
+		if opts.metadata() {
+			return pipe.Sink{}, fmt.Errorf("%w: HLS segments don't support metadata options", ErrInvalidParameter)
+		}
+		w, err := newHLSWriter(h, opts.encoderDelay())
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		aligned := append([]SinkOption{WithFrameAlignedWrites(0)}, options...)
+		sink, err := Sink(w, brm, cm, eq, aligned...)(mctx, bufferSize, props)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		return pipe.Sink{
+			Context:  sink.Context,
+			SinkFunc: sink.SinkFunc,
+			FlushFunc: func(ctx context.Context) error {
+				err := sink.FlushFunc(ctx)
+				if finishErr := w.finish(); err == nil {
+					err = finishErr
+				}
+				return err
+			},
+		}, nil
+	}
+}
+
+// hlsSegment is the segment listed in the playlist.
+type hlsSegment struct {
+	name     string
+	start    time.Time
+	duration time.Duration
+}
+
+// hlsWriter writes frames into segments.
+type hlsWriter struct {
+	h HLS
+	// dir is the directory of the playlist if Create isn't set.
+	dir     string
+	segment io.WriteCloser
+	// samples of the current segment and before it.
+	samples    int
+	total      int64
+	sampleRate int
+	segments   []hlsSegment
+	// sequence is the media sequence number of the first listed segment.
+	sequence int
+	// delay is the encoder delay in samples.
+	delay int
+	// evicted are segments removed from the live playlist that are
+	// deleted after the next update.
+	evicted []hlsSegment
+}
+
+func newHLSWriter(h HLS, delay int) (*hlsWriter, error) {
+	var dir string
+	if h.Create == nil {
+		if h.Dir == "" {
+			return nil, fmt.Errorf("%w: HLS output directory is empty", ErrInvalidParameter)
+		}
+		dir = h.Dir
+		h.Create = func(name string) (io.WriteCloser, error) {
+			return os.Create(filepath.Join(dir, name))
+		}
+	}
+	if h.SegmentDuration < 0 || h.PlaylistSize < 0 {
+		return nil, fmt.Errorf("%w: HLS segment duration %v and playlist size %d must be positive", ErrInvalidParameter, h.SegmentDuration, h.PlaylistSize)
+	}
+	if h.SegmentDuration == 0 {
+		h.SegmentDuration = defaultSegmentDuration
+	}
+	if h.SegmentName == "" {
+		h.SegmentName = defaultSegmentName
+	}
+	if h.Playlist == "" {
+		h.Playlist = defaultPlaylistName
+	}
+	if h.PlaylistSize == 0 {
+		h.PlaylistSize = defaultPlaylistSize
+	}
+	return &hlsWriter{h: h, dir: dir, delay: delay}, nil
+}
+
+// Write expects whole frames.
+func (w *hlsWriter) Write(p []byte) (int, error) {
+	frames, rest, err := splitFrames(p)
+	if err != nil {
+		return 0, fmt.Errorf("error splitting MP3 frames: %w", err)
+	}
+	if len(rest) > 0 {
+		return 0, fmt.Errorf("incomplete frame of %d bytes", len(rest))
+	}
+	for _, frame := range frames {
+		h, _ := parseFrameHeader(frame)
+		if w.segment == nil || w.duration() >= w.h.SegmentDuration {
+			w.sampleRate = h.sampleRate
+			if err := w.next(); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := w.segment.Write(frame); err != nil {
+			return 0, fmt.Errorf("error writing HLS segment: %w", err)
+		}
+		w.samples += h.samples()
+	}
+	return len(p), nil
+}
+
+// duration returns the duration of the current segment.
+func (w *hlsWriter) duration() time.Duration {
+	return time.Duration(int64(w.samples) * int64(time.Second) / int64(w.sampleRate))
+}
+
+// next closes the current segment and starts the next one.
+func (w *hlsWriter) next() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	start := time.Now()
+	seq := w.sequence + len(w.segments)
+	var name string
+	if w.h.SegmentNames != nil {
+		name = w.h.SegmentNames(seq, start)
+	} else {
+		name = fmt.Sprintf(w.h.SegmentName, seq)
+	}
+	segment, err := w.h.Create(name)
+	if err != nil {
+		return fmt.Errorf("error creating HLS segment: %w", err)
+	}
+	w.segment = segment
+	w.segments = append(w.segments, hlsSegment{name: name, start: start})
+	tag, err := ID3v2{}.bytes([]id3Frame{hlsTimestamp((w.total - int64(w.delay)) * hlsClockRate / int64(w.sampleRate))}, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := w.segment.Write(tag); err != nil {
+		return fmt.Errorf("error writing HLS segment: %w", err)
+	}
+	return nil
+}
+
+// close closes the current segment and updates live playlist.
+func (w *hlsWriter) close() error {
+	if w.segment == nil {
+		return nil
+	}
+	err := w.segment.Close()
+	w.segment = nil
+	if err != nil {
+		return fmt.Errorf("error closing HLS segment: %w", err)
+	}
+	last := &w.segments[len(w.segments)-1]
+	last.duration = w.duration()
+	w.total += int64(w.samples)
+	w.samples = 0
+	if w.h.Finalized != nil {
+		w.h.Finalized(Segment{
+			Seq:      w.sequence + len(w.segments) - 1,
+			Name:     last.name,
+			Start:    last.start,
+			Duration: last.duration,
+		})
+	}
+	if !w.h.Live {
+		return nil
+	}
+	var evicted []hlsSegment
+	if n := len(w.segments) - w.h.PlaylistSize; n > 0 {
+		evicted = w.segments[:n:n]
+		w.segments = w.segments[n:]
+		w.sequence += n
+	}
+	if err := w.writePlaylist(false); err != nil {
+		return err
+	}
+	if err := w.deleteEvicted(); err != nil {
+		return err
+	}
+	w.evicted = evicted
+	return nil
+}
+
+// finish closes the last segment and writes final playlist.
+func (w *hlsWriter) finish() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	if err := w.writePlaylist(true); err != nil {
+		return err
+	}
+	return w.deleteEvicted()
+}
+
+// deleteEvicted deletes the files of evicted segments from Dir.
+func (w *hlsWriter) deleteEvicted() error {
+	if w.dir == "" {
+		return nil
+	}
+	for _, s := range w.evicted {
+		if err := os.Remove(filepath.Join(w.dir, s.name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error deleting HLS segment: %w", err)
+		}
+	}
+	w.evicted = nil
+	return nil
+}
+
+func (w *hlsWriter) writePlaylist(end bool) error {
+	target := int(math.Ceil(w.h.SegmentDuration.Seconds()))
+	for _, s := range w.segments {
+		if d := int(math.Round(s.duration.Seconds())); d > target {
+			target = d
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n", target)
+	if w.h.Live {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", w.sequence)
+	} else {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+	for _, s := range w.segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", s.duration.Seconds(), s.name)
+	}
+	if end {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	if w.dir != "" {
+		return writeFileAtomic(filepath.Join(w.dir, w.h.Playlist), []byte(b.String()))
+	}
+	pw, err := w.h.Create(w.h.Playlist)
+	if err != nil {
+		return fmt.Errorf("error creating HLS playlist: %w", err)
+	}
+	if _, err := io.WriteString(pw, b.String()); err != nil {
+		pw.Close()
+		return fmt.Errorf("error writing HLS playlist: %w", err)
+	}
+	return pw.Close()
+}
+
+// hlsTimestamp returns PRIV frame with 33-bit timestamp.
+func hlsTimestamp(ts int64) id3Frame {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(ts)&(1<<33-1))
+	return privFrame(hlsTimestampOwner, b[:])
+}
+
+// writeFileAtomic replaces the file at path with data, so readers never
+// see partial content.
+func writeFileAtomic(path string, data []byte) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), filePerm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	return nil
+}
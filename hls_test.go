@@ -0,0 +1,214 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+type memoryFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (f *memoryFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func encodeHLS(h mp3.HLS, seconds int, options ...mp3.SinkOption) error {
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      seconds * 44100,
+		Value:      0.5,
+	}
+	options = append([]mp3.SinkOption{mp3.WithBackend(mp3.Shine)}, options...)
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.HLSSink(h, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return pipe.Wait(p.Start(context.Background()))
+}
+
+func TestHLSSink(t *testing.T) {
+	files := make(map[string]*memoryFile)
+	h := mp3.HLS{
+		SegmentDuration: 2 * time.Second,
+		Create: func(name string) (io.WriteCloser, error) {
+			f := &memoryFile{}
+			files[name] = f
+			return f, nil
+		},
+	}
+	if err := encodeHLS(h, 9); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// timestamps start at shine encoder delay before the input.
+	samples := int64(-528)
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("segment%05d.mp3", i)
+		f, ok := files[name]
+		if !ok || !f.closed {
+			t.Fatalf("expected closed segment %s", name)
+		}
+		frames, audio := parseID3v2(t, f.Bytes())
+		if len(frames) != 1 || frames[0].id != "PRIV" {
+			t.Fatalf("%s: expected PRIV frame got: %q", name, frames)
+		}
+		owner := "com.apple.streaming.transportStreamTimestamp\x00"
+		expected := uint64(samples*90000/44100) & (1<<33 - 1)
+		if ts := binary.BigEndian.Uint64(frames[0].body[len(owner):]); !strings.HasPrefix(string(frames[0].body), owner) || ts != expected {
+			t.Errorf("%s: unexpected timestamp: %d expected: %d", name, ts, expected)
+		}
+		samples += int64(len(splitFrames(t, audio)) * 1152)
+	}
+	if _, ok := files["segment00005.mp3"]; ok {
+		t.Errorf("unexpected segment")
+	}
+	playlist := files["playlist.m3u8"].String()
+	expected := []string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:2",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		"#EXTINF:2.011,\nsegment00000.mp3\n",
+		"segment00004.mp3\n#EXT-X-ENDLIST\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(playlist, e) {
+			t.Errorf("expected %q in playlist:\n%s", e, playlist)
+		}
+	}
+}
+
+func TestHLSSinkLive(t *testing.T) {
+	dir, err := os.MkdirTemp("", "hls")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	h := mp3.HLS{
+		Dir:             dir,
+		SegmentDuration: time.Second,
+		Live:            true,
+		PlaylistSize:    3,
+	}
+	// segment evicted by the previous update is kept for clients that
+	// loaded that playlist.
+	h.Finalized = func(s mp3.Segment) {
+		if s.Seq < 4 {
+			return
+		}
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("segment%05d.mp3", s.Seq-4))); err != nil {
+			t.Errorf("segment %d: unexpected error: %v", s.Seq-4, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("segment%05d.mp3", s.Seq-5))); s.Seq > 4 && !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected segment %d deleted got: %v", s.Seq-5, err)
+		}
+	}
+	if err := encodeHLS(h, 6); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	playlist, err := os.ReadFile(filepath.Join(dir, "playlist.m3u8"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(playlist), "#EXT-X-MEDIA-SEQUENCE:3\n") || strings.Count(string(playlist), "#EXTINF") != 3 {
+		t.Errorf("unexpected playlist:\n%s", playlist)
+	}
+	for i := 3; i < 6; i++ {
+		name := fmt.Sprintf("segment%05d.mp3", i)
+		if !strings.Contains(string(playlist), name) {
+			t.Errorf("expected %s in playlist:\n%s", name, playlist)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	// evicted segments are deleted.
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("segment%05d.mp3", i)
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected %s deleted got: %v", name, err)
+		}
+	}
+}
+
+func TestHLSSinkNames(t *testing.T) {
+	files := make(map[string]*memoryFile)
+	var finalized []mp3.Segment
+	h := mp3.HLS{
+		// Create takes precedence over Dir.
+		Dir:             "missing",
+		SegmentDuration: 2 * time.Second,
+		SegmentNames:    mp3.NameTemplate{Template: "{show}-{seq}.mp3", Values: map[string]string{"show": "news"}}.Name,
+		Create: func(name string) (io.WriteCloser, error) {
+			f := &memoryFile{}
+			files[name] = f
+			return f, nil
+		},
+		Finalized: func(s mp3.Segment) {
+			finalized = append(finalized, s)
+		},
+	}
+	if err := encodeHLS(h, 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	playlist, ok := files["playlist.m3u8"]
+	if !ok {
+		t.Fatalf("expected playlist")
+	}
+	if len(finalized) != 3 {
+		t.Fatalf("expected finalized segments: 3 got: %d", len(finalized))
+	}
+	for i, s := range finalized {
+		name := fmt.Sprintf("news-%d.mp3", i)
+		if s.Seq != i || s.Name != name || s.Duration == 0 {
+			t.Errorf("unexpected finalized segment: %+v", s)
+		}
+		if _, ok := files[name]; !ok || !strings.Contains(playlist.String(), name) {
+			t.Errorf("expected segment %s", name)
+		}
+	}
+}
+
+func TestHLSSinkInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       mp3.HLS
+		options []mp3.SinkOption
+	}{
+		{
+			name: "no output",
+		},
+		{
+			name:    "metadata",
+			h:       mp3.HLS{Dir: "."},
+			options: []mp3.SinkOption{mp3.WithID3v1(mp3.ID3v1{})},
+		},
+	}
+	for _, test := range tests {
+		if err := encodeHLS(test.h, 1, test.options...); !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mp3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+// ErrSlowSubscriber is the error of the subscriber that was evicted from
+// the Hub because its buffer was full.
+var ErrSlowSubscriber = errors.New("subscriber doesn't keep up with the stream")
+
+type (
+	// Hub distributes the stream written into it to subscribers that can
+	// be added and removed at any time. Every subscriber has its own
+	// buffer of writes. Subscribers that don't keep up are evicted, so
+	// they never block the stream. Hub is safe for concurrent use.
+	Hub struct {
+		mu          sync.Mutex
+		subscribers map[*Subscriber]struct{}
+	}
+
+	// Subscriber receives the writes of the Hub.
+	Subscriber struct {
+		// C receives copies of writes. It's closed when subscriber is
+		// removed from the Hub. C is nil for attached writers.
+		C <-chan []byte
+
+		hub  *Hub
+		ch   chan []byte
+		done chan struct{}
+		// attached subscribers close done after the writer is finished.
+		attached bool
+		err      error
+	}
+)
+
+// HubSink writes the stream to subscribers of the Hub. Writes are
+// aligned to frames, so subscribers added during the stream receive
+// whole frames. Subscribers are removed on flush.
+func HubSink(h *Hub, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		aligned := append([]SinkOption{WithFrameAlignedWrites(0)}, options...)
+		sink, err := Sink(h, brm, cm, eq, aligned...)(mctx, bufferSize, props)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		return pipe.Sink{
+			Context:  sink.Context,
+			SinkFunc: sink.SinkFunc,
+			FlushFunc: func(ctx context.Context) error {
+				err := sink.FlushFunc(ctx)
+				h.Close()
+				return err
+			},
+		}, nil
+	}
+}
+
+// Subscribe adds the subscriber that buffers up to size writes. Default
+// size is 64.
+func (h *Hub) Subscribe(size int) *Subscriber {
+	s := h.subscribe(size, nil, false)
+	s.C = s.ch
+	return s
+}
+
+// Attach adds the subscriber that copies writes into w in background.
+// Subscriber is removed if w fails. Writes buffered before removal are
+// still copied.
+func (h *Hub) Attach(w io.Writer, size int) *Subscriber {
+	s := h.subscribe(size, nil, true)
+	go func() {
+		var failed bool
+		for p := range s.ch {
+			if failed {
+				continue
+			}
+			if _, err := w.Write(p); err != nil {
+				failed = true
+				// subscriber could be removed before the write.
+				h.mu.Lock()
+				h.remove(s, nil)
+				s.err = err
+				h.mu.Unlock()
+			}
+		}
+		close(s.done)
+	}()
+	return s
+}
+
+// subscribe adds the subscriber with initial data.
+func (h *Hub) subscribe(size int, initial []byte, attached bool) *Subscriber {
+	if size <= 0 {
+		size = defaultSubscriberBuffer
+	}
+	s := &Subscriber{
+		hub:      h,
+		ch:       make(chan []byte, size),
+		done:     make(chan struct{}),
+		attached: attached,
+	}
+	if len(initial) > 0 {
+		s.ch <- initial
+	}
+	h.mu.Lock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Subscribers returns the number of subscribers.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// Write sends the copy of p to all subscribers. Subscribers with full
+// buffers are evicted with ErrSlowSubscriber.
+func (h *Hub) Write(p []byte) (int, error) {
+	data := append([]byte(nil), p...)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		select {
+		case s.ch <- data:
+		default:
+			h.remove(s, ErrSlowSubscriber)
+		}
+	}
+	return len(p), nil
+}
+
+// Close removes all subscribers. Hub can be used after it's closed.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		h.remove(s, nil)
+	}
+	return nil
+}
+
+// remove closes the subscriber channel. It must be called with lock
+// held.
+func (h *Hub) remove(s *Subscriber, err error) {
+	if _, ok := h.subscribers[s]; !ok {
+		return
+	}
+	delete(h.subscribers, s)
+	s.err = err
+	close(s.ch)
+	if !s.attached {
+		close(s.done)
+	}
+}
+
+// Close removes the subscriber from the Hub.
+func (s *Subscriber) Close() {
+	s.hub.mu.Lock()
+	s.hub.remove(s, nil)
+	s.hub.mu.Unlock()
+}
+
+// Done returns the channel that is closed when subscriber is removed.
+// For attached writers it's closed after buffered writes are copied.
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns ErrSlowSubscriber if subscriber was evicted or the error
+// of attached writer. It returns nil if subscriber wasn't removed or was
+// closed.
+func (s *Subscriber) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,95 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestHub(t *testing.T) {
+	var (
+		h      mp3.Hub
+		copied bytes.Buffer
+	)
+	fast := h.Subscribe(10)
+	slow := h.Subscribe(1)
+	attached := h.Attach(&copied, 10)
+	failed := h.Attach(&failingWriter{limit: 1}, 10)
+	for _, p := range []string{"ab", "cd", "ef"} {
+		if _, err := h.Write([]byte(p)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	h.Close()
+	if h.Subscribers() != 0 {
+		t.Errorf("expected subscribers: 0 got: %d", h.Subscribers())
+	}
+
+	var received []byte
+	for p := range fast.C {
+		received = append(received, p...)
+	}
+	<-attached.Done()
+	<-failed.Done()
+	if string(received) != "abcdef" || copied.String() != "abcdef" {
+		t.Errorf("unexpected streams: %q and %q", received, copied.String())
+	}
+	if fast.Err() != nil || attached.Err() != nil {
+		t.Errorf("unexpected errors: %v and %v", fast.Err(), attached.Err())
+	}
+	if !errors.Is(slow.Err(), mp3.ErrSlowSubscriber) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrSlowSubscriber, slow.Err())
+	}
+	if !errors.Is(failed.Err(), errWriter) {
+		t.Errorf("expected error: %v got: %v", errWriter, failed.Err())
+	}
+
+	// hub can be used after it's closed.
+	next := h.Subscribe(0)
+	h.Write([]byte("gh"))
+	next.Close()
+	next.Close()
+	<-next.Done()
+	if p := <-next.C; string(p) != "gh" {
+		t.Errorf("unexpected write: %q", p)
+	}
+}
+
+func TestHubSink(t *testing.T) {
+	var (
+		h   mp3.Hub
+		buf bytes.Buffer
+	)
+	s := h.Attach(&buf, 0)
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.HubSink(&h, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-s.Done()
+	if s.Err() != nil {
+		t.Errorf("unexpected error: %v", s.Err())
+	}
+	if frames := splitFrames(t, buf.Bytes()); len(frames) < 44100/1152 {
+		t.Errorf("expected frames: %d got: %d", 44100/1152, len(frames))
+	}
+}
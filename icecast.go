@@ -0,0 +1,484 @@
+package mp3
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+// defaultIcecastTimeout is the timeout of Icecast connection and writes.
+const defaultIcecastTimeout = 10 * time.Second
+
+// Icecast is the mount point of Icecast or Shoutcast server that
+// IcecastSink streams to as a source client.
+type Icecast struct {
+	// URL of the mount point, like http://localhost:8000/live.mp3. User
+	// and password can be set in URL as well.
+	URL string
+	// User is "source" if empty.
+	User     string
+	Password string
+	// Station metadata sent in ice-* headers.
+	Name        string
+	Description string
+	Genre       string
+	StationURL  string
+	Public      bool
+	// Legacy makes the client use SOURCE method of servers before 2.4.
+	Legacy bool
+	// Shoutcast selects Shoutcast source protocol. Icecast protocol is
+	// used if it's zero.
+	Shoutcast ShoutcastVersion
+	// StreamID is the stream of Shoutcast v2 server. Default is 1.
+	StreamID int
+	// Metadata is sent to the server when its stream title changes.
+	// Interval isn't used. Icecast and Shoutcast v1 metadata is sent with
+	// admin requests in the background, so the stream isn't blocked by
+	// them. Failed requests are retried after ReconnectDelay.
+	Metadata *ICYMetadata
+	// MetadataError receives errors of admin metadata requests. They
+	// don't fail the stream. It's called from the goroutine of the write.
+	MetadataError func(error)
+	// Timeout of connection and writes. Default is 10 seconds.
+	Timeout time.Duration
+	// MaxReconnects is the number of consecutive failed reconnects after
+	// which the Sink fails. Zero disables reconnects and negative value
+	// makes the Sink reconnect forever.
+	MaxReconnects  int
+	ReconnectDelay time.Duration
+	// BufferSize is the max number of bytes buffered while disconnected.
+	// The oldest frames are dropped if it's exceeded.
+	BufferSize int
+}
+
+// IcecastSink streams encoded output to Icecast or Shoutcast server.
+// Connection is established when the Sink is allocated and is closed on
+// flush. Writes are aligned to frames, so frames dropped while
+// disconnected don't break the stream.
+func IcecastSink(ic Icecast, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		w, err := newIcecastWriter(ic, brm, cm, props)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		if ic.Metadata != nil {
+			ic.Metadata.bind(mctx)
+		}
+		aligned := append([]SinkOption{WithFrameAlignedWrites(0)}, options...)
+		sink, err := Sink(w, brm, cm, eq, aligned...)(mctx, bufferSize, props)
+		if err != nil {
+			w.close()
+			return pipe.Sink{}, err
+		}
+		return pipe.Sink{
+			Context:  sink.Context,
+			SinkFunc: sink.SinkFunc,
+			FlushFunc: func(ctx context.Context) error {
+				err := sink.FlushFunc(ctx)
+				if closeErr := w.close(); err == nil {
+					err = closeErr
+				}
+				return err
+			},
+		}, nil
+	}
+}
+
+// icecastWriter writes into source client connection and reconnects if
+// it's broken.
+type icecastWriter struct {
+	ic       Icecast
+	u        *url.URL
+	user     string
+	password string
+	addr     string
+	tls      bool
+	// request is sent after Shoutcast v1 password is accepted.
+	request string
+	// setup messages of Shoutcast v2 broadcast.
+	setup [][]byte
+	conn  net.Conn
+	// session is incremented on every connection.
+	session int
+	// title is the last stream title sent to the server.
+	title string
+	// updated receives the result of the running admin request.
+	updated       chan metadataUpdate
+	updating      bool
+	metadataRetry time.Time
+	// metadataID is the id of the last Shoutcast v2 metadata.
+	metadataID uint16
+	// frames buffered while disconnected.
+	buf      [][]byte
+	buffered int
+	failures int
+	retry    time.Time
+}
+
+func newIcecastWriter(ic Icecast, brm BitRateMode, cm ChannelMode, props pipe.SignalProperties) (*icecastWriter, error) {
+	u, err := url.Parse(ic.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid Icecast URL: %v", ErrInvalidParameter, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: unsupported Icecast URL scheme %q", ErrInvalidParameter, u.Scheme)
+	}
+	if ic.BufferSize < 0 {
+		return nil, fmt.Errorf("%w: Icecast buffer size %d is negative", ErrInvalidParameter, ic.BufferSize)
+	}
+	if ic.Shoutcast < 0 || ic.Shoutcast > Shoutcast2 {
+		return nil, fmt.Errorf("%w: unknown Shoutcast version %d", ErrInvalidParameter, ic.Shoutcast)
+	}
+	if ic.Timeout == 0 {
+		ic.Timeout = defaultIcecastTimeout
+	}
+	if ic.StreamID == 0 {
+		ic.StreamID = 1
+	}
+	user, password := ic.User, ic.Password
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	if user == "" {
+		user = "source"
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	w := icecastWriter{
+		ic:       ic,
+		u:        u,
+		user:     user,
+		password: password,
+		addr:     net.JoinHostPort(u.Hostname(), port),
+		tls:      u.Scheme == "https",
+		updated:  make(chan metadataUpdate, 1),
+	}
+	switch ic.Shoutcast {
+	case Shoutcast1:
+		// source port is the next after the listener port.
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid Shoutcast port %q", ErrInvalidParameter, port)
+		}
+		w.addr = net.JoinHostPort(u.Hostname(), strconv.Itoa(n+1))
+		w.request = shoutcastRequest(ic, brm)
+	case Shoutcast2:
+		w.setup = ultravoxSetup(ic, brm)
+	default:
+		w.request = icecastRequest(ic, u, user, password, brm, cm, props)
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// icecastRequest returns the request of the source client.
+func icecastRequest(ic Icecast, u *url.URL, user, password string, brm BitRateMode, cm ChannelMode, props pipe.SignalProperties) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var b strings.Builder
+	if ic.Legacy {
+		b.WriteString("SOURCE " + path + " HTTP/1.0\r\n")
+	} else {
+		b.WriteString("PUT " + path + " HTTP/1.1\r\n")
+		b.WriteString("Expect: 100-continue\r\n")
+	}
+	header := func(key, value string) {
+		if value != "" {
+			b.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+	header("Host", u.Host)
+	header("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+password)))
+	header("User-Agent", "pipelined.dev/audio/mp3")
+	header("Content-Type", "audio/mpeg")
+	header("Ice-Name", ic.Name)
+	header("Ice-Description", ic.Description)
+	header("Ice-Genre", ic.Genre)
+	header("Ice-Url", ic.StationURL)
+	header("Ice-Public", icyPublic(ic.Public))
+	channels := props.Channels
+	if cm == Mono {
+		channels = 1
+	}
+	info := "ice-samplerate=" + strconv.Itoa(int(props.SampleRate)) + ";ice-channels=" + strconv.Itoa(channels)
+	if cbr, ok := brm.(CBR); ok {
+		info += ";ice-bitrate=" + strconv.Itoa(int(cbr))
+	}
+	header("Ice-Audio-Info", info)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// connect establishes the connection and performs the handshake.
+func (w *icecastWriter) connect() error {
+	dialer := net.Dialer{Timeout: w.ic.Timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if w.tls {
+		conn, err = tls.DialWithDialer(&dialer, "tcp", w.addr, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", w.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("error connecting to Icecast: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(w.ic.Timeout))
+	r := bufio.NewReader(conn)
+	switch w.ic.Shoutcast {
+	case Shoutcast1:
+		err = w.shoutcast1(conn, r)
+	case Shoutcast2:
+		err = w.shoutcast2(conn, r)
+	default:
+		err = w.icecast(conn, r)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	w.conn = conn
+	w.session++
+	// metadata is sent again for the new connection.
+	w.title = ""
+	return nil
+}
+
+// icecast sends the request of the source client.
+func (w *icecastWriter) icecast(conn net.Conn, br *bufio.Reader) error {
+	if _, err := conn.Write([]byte(w.request)); err != nil {
+		return fmt.Errorf("error sending Icecast request: %w", err)
+	}
+	r := textproto.NewReader(br)
+	status, err := r.ReadLine()
+	if err != nil {
+		return fmt.Errorf("error reading Icecast response: %w", err)
+	}
+	if fields := strings.Fields(status); len(fields) < 2 || (fields[1] != "100" && fields[1] != "200") {
+		return fmt.Errorf("source rejected by Icecast: %s", status)
+	}
+	if _, err := r.ReadMIMEHeader(); err != nil {
+		return fmt.Errorf("error reading Icecast response: %w", err)
+	}
+	return nil
+}
+
+func (w *icecastWriter) Write(p []byte) (int, error) {
+	if w.conn == nil {
+		if err := w.reconnect(); err != nil {
+			return 0, err
+		}
+	}
+	if w.conn != nil {
+		w.updateMetadata()
+		err := w.send(p)
+		if err == nil {
+			return len(p), nil
+		}
+		if w.ic.MaxReconnects == 0 {
+			return 0, err
+		}
+	}
+	w.buffer(p)
+	return len(p), nil
+}
+
+// send writes audio p into connection. Shoutcast v2 metadata is sent
+// before audio if stream title has changed.
+func (w *icecastWriter) send(p []byte) error {
+	if w.ic.Shoutcast == Shoutcast2 {
+		data, err := ultravoxData(p)
+		if err != nil {
+			return err
+		}
+		if m := w.ic.Metadata; m != nil && m.StreamTitle != w.title {
+			w.title = m.StreamTitle
+			w.metadataID++
+			data = append(ultravoxMetadata(w.metadataID, w.title), data...)
+		}
+		p = data
+	}
+	return w.write(p)
+}
+
+// reconnect connects if retry is due and sends buffered frames.
+func (w *icecastWriter) reconnect() error {
+	if time.Now().Before(w.retry) {
+		return nil
+	}
+	if err := w.connect(); err != nil {
+		w.failures++
+		if w.ic.MaxReconnects >= 0 && w.failures >= w.ic.MaxReconnects {
+			return err
+		}
+		w.retry = time.Now().Add(w.ic.ReconnectDelay)
+		return nil
+	}
+	w.failures = 0
+	for len(w.buf) > 0 {
+		if err := w.send(w.buf[0]); err != nil {
+			return nil
+		}
+		w.buffered -= len(w.buf[0])
+		w.buf = w.buf[1:]
+	}
+	return nil
+}
+
+// buffer keeps the copy of p and drops the oldest frames if buffer size
+// is exceeded.
+func (w *icecastWriter) buffer(p []byte) {
+	w.buf = append(w.buf, append([]byte(nil), p...))
+	w.buffered += len(p)
+	for w.buffered > w.ic.BufferSize && len(w.buf) > 0 {
+		w.buffered -= len(w.buf[0])
+		w.buf = w.buf[1:]
+	}
+}
+
+// metadataUpdate is the result of admin request.
+type metadataUpdate struct {
+	session int
+	title   string
+	err     error
+}
+
+// updateMetadata starts admin request with the stream title in the
+// background if it has changed and collects the result of the previous
+// one. Shoutcast v2 metadata is sent within the stream instead.
+func (w *icecastWriter) updateMetadata() {
+	select {
+	case u := <-w.updated:
+		w.metadataUpdated(u)
+	default:
+	}
+	m := w.ic.Metadata
+	if m == nil || w.updating || m.StreamTitle == w.title || w.ic.Shoutcast == Shoutcast2 || time.Now().Before(w.metadataRetry) {
+		return
+	}
+	w.updating = true
+	session, title, streamURL := w.session, m.StreamTitle, m.StreamURL
+	go func() {
+		w.updated <- metadataUpdate{
+			session: session,
+			title:   title,
+			err:     w.sendMetadata(title, streamURL),
+		}
+	}()
+}
+
+// metadataUpdated applies the result of admin request. Title is sent
+// again if request failed or connection was reestablished meanwhile.
+func (w *icecastWriter) metadataUpdated(u metadataUpdate) {
+	w.updating = false
+	if u.err != nil {
+		w.metadataRetry = time.Now().Add(w.ic.ReconnectDelay)
+		if w.ic.MetadataError != nil {
+			w.ic.MetadataError(u.err)
+		}
+		return
+	}
+	if u.session == w.session {
+		w.title = u.title
+	}
+}
+
+// sendMetadata sends admin request with the stream title.
+func (w *icecastWriter) sendMetadata(title, streamURL string) error {
+	u := url.URL{Scheme: w.u.Scheme, Host: w.u.Host}
+	query := url.Values{"mode": {"updinfo"}, "song": {title}}
+	if w.ic.Shoutcast == Shoutcast1 {
+		u.Path = "/admin.cgi"
+		query.Set("pass", w.password)
+		if streamURL != "" {
+			query.Set("url", streamURL)
+		}
+	} else {
+		u.Path = "/admin/metadata"
+		query.Set("mount", w.u.Path)
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("error updating metadata: %w", err)
+	}
+	if w.ic.Shoutcast == Shoutcast1 {
+		// Shoutcast v1 rejects admin requests of other user agents.
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; pipelined.dev/audio/mp3)")
+	} else {
+		req.SetBasicAuth(w.user, w.password)
+	}
+	client := http.Client{Timeout: w.ic.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error updating metadata: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("metadata rejected by server: %s", resp.Status)
+	}
+	return nil
+}
+
+// write writes p into connection. Connection is closed if write fails.
+func (w *icecastWriter) write(p []byte) error {
+	w.conn.SetWriteDeadline(time.Now().Add(w.ic.Timeout))
+	if _, err := w.conn.Write(p); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		w.retry = time.Now().Add(w.ic.ReconnectDelay)
+		return fmt.Errorf("error streaming to Icecast: %w", err)
+	}
+	return nil
+}
+
+func (w *icecastWriter) close() error {
+	if w.conn == nil {
+		return nil
+	}
+	// running admin request is completed and the latest title is sent
+	// before the source disconnects.
+	if w.updating {
+		w.metadataUpdated(<-w.updated)
+	}
+	if m := w.ic.Metadata; m != nil && m.StreamTitle != w.title && w.ic.Shoutcast != Shoutcast2 {
+		w.metadataUpdated(metadataUpdate{
+			session: w.session,
+			title:   m.StreamTitle,
+			err:     w.sendMetadata(m.StreamTitle, m.StreamURL),
+		})
+	}
+	if w.ic.Shoutcast == Shoutcast2 {
+		w.write(ultravoxMessage(ultravoxTerminate, nil))
+		if w.conn == nil {
+			return nil
+		}
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
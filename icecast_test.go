@@ -0,0 +1,235 @@
+package mp3_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// icecastServer accepts source connections and sends received
+// requests and streams into the channel. Connection is closed after
+// limit bytes if it's positive. Admin requests are rejected.
+func icecastServer(t *testing.T, status string, limit int64) (net.Listener, <-chan icecastSource) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sources := make(chan icecastSource, 1000)
+	go func() {
+		var wg sync.WaitGroup
+		defer func() {
+			wg.Wait()
+			close(sources)
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				req, err := http.ReadRequest(r)
+				if err != nil {
+					return
+				}
+				if strings.HasPrefix(req.URL.Path, "/admin/") {
+					conn.Write([]byte("HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n"))
+					return
+				}
+				conn.Write([]byte(status + "\r\n\r\n"))
+				var data []byte
+				if limit > 0 {
+					data, _ = io.ReadAll(&io.LimitedReader{R: r, N: limit})
+				} else {
+					data, _ = io.ReadAll(r)
+				}
+				conn.Close()
+				sources <- icecastSource{req: req, data: data}
+			}()
+		}
+	}()
+	return l, sources
+}
+
+type icecastSource struct {
+	req  *http.Request
+	data []byte
+}
+
+func TestIcecastSink(t *testing.T) {
+	l, sources := icecastServer(t, "HTTP/1.1 100 Continue", 0)
+	defer l.Close()
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	ic := mp3.Icecast{
+		URL:      "http://" + l.Addr().String() + "/live.mp3",
+		Password: "hackme",
+		Name:     "Radio",
+		Genre:    "Rock",
+		Public:   true,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.IcecastSink(ic, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := <-sources
+	if s.req.Method != http.MethodPut || s.req.URL.Path != "/live.mp3" {
+		t.Errorf("unexpected request: %s %s", s.req.Method, s.req.URL)
+	}
+	if user, password, _ := s.req.BasicAuth(); user != "source" || password != "hackme" {
+		t.Errorf("unexpected credentials: %s:%s", user, password)
+	}
+	headers := map[string]string{
+		"Content-Type":   "audio/mpeg",
+		"Ice-Name":       "Radio",
+		"Ice-Genre":      "Rock",
+		"Ice-Public":     "1",
+		"Ice-Audio-Info": "ice-samplerate=44100;ice-channels=2;ice-bitrate=128",
+	}
+	for k, v := range headers {
+		if s.req.Header.Get(k) != v {
+			t.Errorf("expected header %s: %q got: %q", k, v, s.req.Header.Get(k))
+		}
+	}
+	frames := splitFrames(t, s.data)
+	if expected := 44100/1152 + 1; len(frames) < expected {
+		t.Errorf("expected frames: %d got: %d", expected, len(frames))
+	}
+}
+
+func TestIcecastSinkMetadataRejected(t *testing.T) {
+	l, sources := icecastServer(t, "HTTP/1.1 100 Continue", 0)
+	defer l.Close()
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	var errs []error
+	ic := mp3.Icecast{
+		URL:      "http://" + l.Addr().String() + "/live.mp3",
+		Password: "hackme",
+		Metadata: &mp3.ICYMetadata{StreamTitle: "Title"},
+		MetadataError: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.IcecastSink(ic, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(errs) == 0 {
+		t.Errorf("expected metadata errors")
+	}
+	s := <-sources
+	if frames := splitFrames(t, s.data); len(frames) < 44100/1152 {
+		t.Errorf("expected frames: %d got: %d", 44100/1152, len(frames))
+	}
+}
+
+func TestIcecastSinkReconnect(t *testing.T) {
+	l, sources := icecastServer(t, "HTTP/1.0 200 OK", 4096)
+	defer l.Close()
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      10 * 44100,
+		Value:      0.5,
+	}
+	ic := mp3.Icecast{
+		URL:           "http://" + l.Addr().String() + "/live.mp3",
+		Legacy:        true,
+		MaxReconnects: -1,
+		BufferSize:    1 << 20,
+		Timeout:       time.Second,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.IcecastSink(ic, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.Close()
+	var connections int
+	for s := range sources {
+		connections++
+		if s.req.Method != "SOURCE" {
+			t.Errorf("unexpected method: %s", s.req.Method)
+		}
+	}
+	if connections < 2 {
+		t.Errorf("expected reconnects, got connections: %d", connections)
+	}
+}
+
+func TestIcecastSinkRejected(t *testing.T) {
+	l, _ := icecastServer(t, "HTTP/1.1 401 Unauthorized", 0)
+	defer l.Close()
+	source := mock.Source{Channels: 2, SampleRate: 44100, Limit: 44100}
+	ic := mp3.Icecast{URL: "http://" + l.Addr().String() + "/live.mp3"}
+	_, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.IcecastSink(ic, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err == nil {
+		t.Errorf("expected error")
+	}
+	_, err = pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.IcecastSink(mp3.Icecast{URL: "ftp://localhost"}, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,131 @@
+package mp3
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"pipelined.dev/pipe/mutable"
+)
+
+const (
+	// icyBlockSize is the unit of ICY metadata length.
+	icyBlockSize = 16
+	// icyMaxSize is the max size of ICY metadata.
+	icyMaxSize = 255 * icyBlockSize
+)
+
+// ICYMetadata is Shoutcast metadata injected into the stream for
+// listeners that requested it with Icy-MetaData header. Interval must be
+// sent to the listener as icy-metaint header. Metadata block is written
+// after every Interval bytes of audio. It contains the metadata only if
+// it has changed since the previous block. Metadata values can't contain
+// quotes, so they are replaced with right single quotation mark in
+// StreamTitle and percent-encoded in StreamURL. ICYMetadata can be bound
+// only to a single Sink.
+type ICYMetadata struct {
+	Interval    int
+	StreamTitle string
+	StreamURL   string
+
+	mctx mutable.Context
+	// last written metadata.
+	sent string
+}
+
+// WithICYMetadata makes the Sink inject ICY metadata blocks into the
+// stream. Stream isn't seekable then, so Xing header isn't written.
+func WithICYMetadata(m *ICYMetadata) SinkOption {
+	return func(o *sinkOptions) {
+		if m.Interval <= 0 {
+			o.fail(fmt.Errorf("%w: ICY metadata interval %d is not positive", ErrInvalidParameter, m.Interval))
+			return
+		}
+		o.icy = m
+	}
+}
+
+// SetStreamTitle returns the mutation that changes the title sent with
+// the next metadata block. It panics if metadata isn't bound to the Sink
+// yet.
+func (m *ICYMetadata) SetStreamTitle(title string) mutable.Mutation {
+	return m.mctx.Mutate(func() error {
+		m.StreamTitle = title
+		return nil
+	})
+}
+
+// SetStreamURL returns the mutation that changes the URL sent with the
+// next metadata block. It panics if metadata isn't bound to the Sink yet.
+func (m *ICYMetadata) SetStreamURL(url string) mutable.Mutation {
+	return m.mctx.Mutate(func() error {
+		m.StreamURL = url
+		return nil
+	})
+}
+
+func (m *ICYMetadata) bind(mctx mutable.Context) {
+	m.mctx = mctx
+}
+
+// icyTitleReplacer and icyURLReplacer replace quotes in metadata values.
+// ICY metadata has no escaping and clients end the value at the quote.
+var (
+	icyTitleReplacer = strings.NewReplacer("'", "\u2019")
+	icyURLReplacer   = strings.NewReplacer("'", "%27")
+)
+
+// block returns the metadata block. Block is empty if metadata hasn't
+// changed.
+func (m *ICYMetadata) block() []byte {
+	var s strings.Builder
+	s.WriteString("StreamTitle='" + icyTitleReplacer.Replace(m.StreamTitle) + "';")
+	if m.StreamURL != "" {
+		s.WriteString("StreamUrl='" + icyURLReplacer.Replace(m.StreamURL) + "';")
+	}
+	meta := s.String()
+	if meta == m.sent {
+		return []byte{0}
+	}
+	m.sent = meta
+	if len(meta) > icyMaxSize {
+		meta = meta[:icyMaxSize]
+	}
+	blocks := (len(meta) + icyBlockSize - 1) / icyBlockSize
+	b := make([]byte, 1+blocks*icyBlockSize)
+	b[0] = byte(blocks)
+	copy(b[1:], meta)
+	return b
+}
+
+// icyWriter writes metadata blocks between audio bytes.
+type icyWriter struct {
+	w    io.Writer
+	meta *ICYMetadata
+	// audio bytes written since the last block.
+	written int
+}
+
+func (i *icyWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		size := i.meta.Interval - i.written
+		if size > len(p) {
+			size = len(p)
+		}
+		written, err := i.w.Write(p[:size])
+		n += written
+		if err != nil {
+			return n, err
+		}
+		i.written += size
+		p = p[size:]
+		if i.written == i.meta.Interval {
+			if _, err := i.w.Write(i.meta.block()); err != nil {
+				return n, err
+			}
+			i.written = 0
+		}
+	}
+	return n, nil
+}
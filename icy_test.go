@@ -0,0 +1,103 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkICYMetadata(t *testing.T) {
+	const interval = 1000
+	meta := mp3.ICYMetadata{
+		Interval:    interval,
+		StreamTitle: "First",
+		StreamURL:   "https://example.com/?q='",
+	}
+	var (
+		buf bytes.Buffer
+		p   *pipe.Pipe
+		set bool
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      1 << 30,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink: mp3.Sink(&buf, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithICYMetadata(&meta),
+				mp3.WithProgress(func(pr mp3.Progress) {
+					switch {
+					case pr.Frames > 10 && !set:
+						set = true
+						go p.Push(meta.SetStreamTitle("It's second"))
+					case pr.Frames > 100:
+						cancel()
+					}
+				}),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(ctx)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var (
+		audio    []byte
+		blocks   []string
+		empty    int
+		data     = buf.Bytes()
+		expected = []string{
+			"StreamTitle='First';StreamUrl='https://example.com/?q=%27';",
+			"StreamTitle='It\u2019s second';StreamUrl='https://example.com/?q=%27';",
+		}
+	)
+	for len(data) > interval {
+		audio = append(audio, data[:interval]...)
+		size := int(data[interval]) * 16
+		if size == 0 {
+			empty++
+		} else {
+			blocks = append(blocks, string(bytes.TrimRight(data[interval+1:interval+1+size], "\x00")))
+		}
+		data = data[interval+1+size:]
+	}
+	audio = append(audio, data...)
+	if len(blocks) != len(expected) {
+		t.Fatalf("expected metadata blocks: %q got: %q", expected, blocks)
+	}
+	for i := range blocks {
+		if blocks[i] != expected[i] {
+			t.Errorf("expected metadata block %d: %q got: %q", i, expected[i], blocks[i])
+		}
+	}
+	if empty == 0 {
+		t.Errorf("expected empty metadata blocks")
+	}
+	frames := splitFrames(t, audio)
+	if string(frames[0][4+32:4+36]) == "Info" {
+		t.Errorf("unexpected Info tag in ICY stream")
+	}
+}
+
+func TestSinkICYMetadataInterval(t *testing.T) {
+	_, err := encodeTagged(mp3.WithICYMetadata(&mp3.ICYMetadata{}))
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
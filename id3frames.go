@@ -0,0 +1,149 @@
+package mp3
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxUniqueIDSize is the max size of UFID identifier.
+	maxUniqueIDSize = 64
+	// id3v23Separator separates values of text frames in ID3v2.3 that
+	// doesn't support multiple values.
+	id3v23Separator = "/"
+)
+
+type (
+	// TextFrame is the text information frame, like TIT2 or TLEN. User
+	// defined text is written with UserText.
+	TextFrame struct {
+		ID    string
+		Value string
+		// Values are written instead of Value for frames with multiple
+		// values, like several artists. They're null-separated in
+		// ID3v2.4 and joined with slash in ID3v2.3.
+		Values   []string
+		Encoding TextEncoding
+	}
+
+	// UserText is written as TXXX frame. Values are written the same
+	// way as values of TextFrame.
+	UserText struct {
+		Description string
+		Value       string
+		Values      []string
+		Encoding    TextEncoding
+	}
+
+	// Comment is written as COMM frame.
+	Comment struct {
+		// Language is ISO-639-2 code. XXX is used if it's empty.
+		Language    string
+		Description string
+		Text        string
+		Encoding    TextEncoding
+	}
+
+	// UserURL is written as WXXX frame.
+	UserURL struct {
+		Description string
+		// URL is encoded in ISO-8859-1.
+		URL      string
+		Encoding TextEncoding
+	}
+
+	// UniqueID is written as UFID frame. Owner is the URL or email of
+	// the organization responsible for the database of identifiers.
+	UniqueID struct {
+		Owner string
+		ID    []byte
+	}
+)
+
+func (f TextFrame) validate() error {
+	if len(f.ID) != 4 || f.ID[0] != 'T' || f.ID == "TXXX" {
+		return fmt.Errorf("%w: %q is not text information frame", ErrInvalidParameter, f.ID)
+	}
+	for _, c := range f.ID {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return fmt.Errorf("%w: invalid frame identifier %q", ErrInvalidParameter, f.ID)
+		}
+	}
+	if f.Value != "" && len(f.Values) > 0 {
+		return fmt.Errorf("%w: %s frame has both value and values", ErrInvalidParameter, f.ID)
+	}
+	return nil
+}
+
+func (u UserText) validate() error {
+	if u.Value != "" && len(u.Values) > 0 {
+		return fmt.Errorf("%w: %q user text has both value and values", ErrInvalidParameter, u.Description)
+	}
+	return nil
+}
+
+func (c Comment) validate() error {
+	if c.Language != "" && len(c.Language) != 3 {
+		return fmt.Errorf("%w: comment language %q is not ISO-639-2 code", ErrInvalidParameter, c.Language)
+	}
+	return nil
+}
+
+func (u UniqueID) validate() error {
+	if u.Owner == "" {
+		return fmt.Errorf("%w: unique identifier owner is empty", ErrInvalidParameter)
+	}
+	if len(u.ID) == 0 || len(u.ID) > maxUniqueIDSize {
+		return fmt.Errorf("%w: unique identifier size %d is out of range 1-%d", ErrInvalidParameter, len(u.ID), maxUniqueIDSize)
+	}
+	return nil
+}
+
+func (f TextFrame) frame(t id3Text) id3Frame {
+	t = t.with(f.Encoding)
+	if len(f.Values) == 0 {
+		return textFrame(t, f.ID, f.Value)
+	}
+	return id3Frame{id: f.ID, body: t.appendValues([]byte{t.encodingByte()}, f.Values)}
+}
+
+func (u UserText) frame(t id3Text) id3Frame {
+	t = t.with(u.Encoding)
+	body := t.appendTerminated([]byte{t.encodingByte()}, u.Description)
+	if len(u.Values) == 0 {
+		return id3Frame{id: "TXXX", body: t.appendString(body, u.Value)}
+	}
+	return id3Frame{id: "TXXX", body: t.appendValues(body, u.Values)}
+}
+
+// appendValues appends multiple values of text frame.
+func (t id3Text) appendValues(b []byte, values []string) []byte {
+	if t.version == ID3v23 {
+		return t.appendString(b, strings.Join(values, id3v23Separator))
+	}
+	last := len(values) - 1
+	for _, v := range values[:last] {
+		b = t.appendTerminated(b, v)
+	}
+	return t.appendString(b, values[last])
+}
+
+func (c Comment) frame(t id3Text) id3Frame {
+	language := c.Language
+	if language == "" {
+		language = "XXX"
+	}
+	t = t.with(c.Encoding)
+	body := appendLatin1([]byte{t.encodingByte()}, language)
+	body = t.appendTerminated(body, c.Description)
+	return id3Frame{id: "COMM", body: t.appendString(body, c.Text)}
+}
+
+func (u UserURL) frame(t id3Text) id3Frame {
+	return userURLFrame(t.with(u.Encoding), u.Description, u.URL)
+}
+
+func (u UniqueID) frame() id3Frame {
+	body := append(appendLatin1(nil, u.Owner), 0)
+	return id3Frame{id: "UFID", body: append(body, u.ID...)}
+}
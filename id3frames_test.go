@@ -0,0 +1,106 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestSinkCustomFrames(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      mp3.ID3v2
+		expected []id3Frame
+		err      error
+	}{
+		{
+			name: "frames",
+			tag: mp3.ID3v2{
+				Text:      []mp3.TextFrame{{ID: "TIT2", Value: "Title"}},
+				UserText:  []mp3.UserText{{Description: "ASSET", Value: "42"}},
+				Comments:  []mp3.Comment{{Language: "eng", Description: "note", Text: "Ünïcode"}, {Text: "any"}},
+				UserURLs:  []mp3.UserURL{{Description: "notes", URL: "https://example.com"}},
+				UniqueIDs: []mp3.UniqueID{{Owner: "http://example.com", ID: []byte("id-1")}},
+			},
+			expected: []id3Frame{
+				{id: "TIT2", body: []byte("\x03Title")},
+				{id: "UFID", body: []byte("http://example.com\x00id-1")},
+				{id: "TXXX", body: []byte("\x03ASSET\x0042")},
+				{id: "COMM", body: []byte("\x03engnote\x00Ünïcode")},
+				{id: "COMM", body: []byte("\x03XXX\x00any")},
+				{id: "WXXX", body: []byte("\x03notes\x00https://example.com")},
+			},
+		},
+		{
+			name: "multiple values",
+			tag: mp3.ID3v2{
+				Text: []mp3.TextFrame{
+					{ID: "TPE1", Values: []string{"A", "B"}},
+					{ID: "TCON", Values: []string{"Rock", "Jazz"}, Encoding: mp3.UTF16},
+				},
+			},
+			expected: []id3Frame{
+				{id: "TPE1", body: []byte("\x03A\x00B")},
+				{id: "TCON", body: []byte("\x01\xff\xfeR\x00o\x00c\x00k\x00\x00\x00\xff\xfeJ\x00a\x00z\x00z\x00")},
+			},
+		},
+		{
+			name: "multiple values ID3v2.3",
+			tag: mp3.ID3v2{
+				Version: mp3.ID3v23,
+				Text:    []mp3.TextFrame{{ID: "TPE1", Values: []string{"A", "B"}, Encoding: mp3.Latin1}},
+			},
+			expected: []id3Frame{
+				{id: "TPE1", body: []byte("\x00A/B")},
+			},
+		},
+		{
+			name: "value and values",
+			tag:  mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TPE1", Value: "A", Values: []string{"B"}}}},
+			err:  mp3.ErrInvalidParameter,
+		},
+		{
+			name: "user text frame",
+			tag:  mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TXXX"}}},
+			err:  mp3.ErrInvalidParameter,
+		},
+		{
+			name: "invalid language",
+			tag:  mp3.ID3v2{Comments: []mp3.Comment{{Language: "en"}}},
+			err:  mp3.ErrInvalidParameter,
+		},
+		{
+			name: "empty owner",
+			tag:  mp3.ID3v2{UniqueIDs: []mp3.UniqueID{{ID: []byte{1}}}},
+			err:  mp3.ErrInvalidParameter,
+		},
+		{
+			name: "long identifier",
+			tag:  mp3.ID3v2{UniqueIDs: []mp3.UniqueID{{Owner: "owner", ID: make([]byte, 65)}}},
+			err:  mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(mp3.WithID3v2(test.tag))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames, _ := parseID3v2(t, data)
+		if len(frames) != len(test.expected) {
+			t.Fatalf("%s: expected frames: %d got: %d", test.name, len(test.expected), len(frames))
+		}
+		for i, f := range frames {
+			if f.id != test.expected[i].id || !bytes.Equal(f.body, test.expected[i].body) {
+				t.Errorf("%s: expected frame %s %q got: %s %q", test.name, test.expected[i].id, test.expected[i].body, f.id, f.body)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package mp3
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// TextEncoding is the encoding of ID3v2 text fields.
+type TextEncoding int
+
+const (
+	// DefaultEncoding is UTF-8 for ID3v2.4 and UTF-16 for ID3v2.3.
+	// Frames with default encoding use the encoding of the tag.
+	DefaultEncoding TextEncoding = iota
+	// Latin1 is ISO-8859-1. Characters that aren't in ISO-8859-1 are
+	// replaced with question mark.
+	Latin1
+	// UTF16 is UTF-16 with byte order mark.
+	UTF16
+	// UTF16BE is UTF-16 big-endian without byte order mark. It's
+	// supported only by ID3v2.4.
+	UTF16BE
+	// UTF8 is supported only by ID3v2.4.
+	UTF8
+)
+
+func (e TextEncoding) validate(v ID3Version) error {
+	switch e {
+	case DefaultEncoding, Latin1, UTF16:
+		return nil
+	case UTF16BE, UTF8:
+		if v == ID3v24 {
+			return nil
+		}
+		return fmt.Errorf("%w: %v encoding isn't supported by %v", ErrInvalidParameter, e, v)
+	}
+	return fmt.Errorf("%w: unknown text encoding %d", ErrInvalidParameter, int(e))
+}
+
+func (e TextEncoding) String() string {
+	switch e {
+	case DefaultEncoding:
+		return "default"
+	case Latin1:
+		return "ISO-8859-1"
+	case UTF16:
+		return "UTF-16"
+	case UTF16BE:
+		return "UTF-16BE"
+	case UTF8:
+		return "UTF-8"
+	}
+	return "unknown"
+}
+
+// id3Text encodes text fields of the frames.
+type id3Text struct {
+	version  ID3Version
+	encoding TextEncoding
+}
+
+// with returns text encoder with overridden encoding.
+func (t id3Text) with(e TextEncoding) id3Text {
+	if e != DefaultEncoding {
+		t.encoding = e
+	}
+	return t
+}
+
+// resolved returns the encoding that is used for text.
+func (t id3Text) resolved() TextEncoding {
+	if t.encoding != DefaultEncoding {
+		return t.encoding
+	}
+	if t.version == ID3v23 {
+		return UTF16
+	}
+	return UTF8
+}
+
+// encodingByte returns the encoding byte of the frame.
+func (t id3Text) encodingByte() byte {
+	return byte(t.resolved() - Latin1)
+}
+
+// appendString appends encoded string without terminator.
+func (t id3Text) appendString(b []byte, s string) []byte {
+	switch t.resolved() {
+	case Latin1:
+		return appendLatin1(b, s)
+	case UTF16:
+		b = append(b, 0xff, 0xfe)
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(u), byte(u>>8))
+		}
+		return b
+	case UTF16BE:
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(u>>8), byte(u))
+		}
+		return b
+	default:
+		return append(b, s...)
+	}
+}
+
+// appendTerminated appends encoded string with terminator.
+func (t id3Text) appendTerminated(b []byte, s string) []byte {
+	b = t.appendString(b, s)
+	switch t.resolved() {
+	case UTF16, UTF16BE:
+		return append(b, 0, 0)
+	default:
+		return append(b, 0)
+	}
+}
+
+// appendLatin1 appends ISO-8859-1 string. Characters that aren't in
+// ISO-8859-1 are replaced with question mark.
+func appendLatin1(b []byte, s string) []byte {
+	for _, r := range s {
+		if r > 0xff {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+// decodeString decodes the string of encoding byte e without terminator.
+func decodeString(e byte, b []byte) string {
+	switch e {
+	case 0:
+		runes := make([]rune, 0, len(b))
+		for _, v := range b {
+			runes = append(runes, rune(v))
+		}
+		return string(runes)
+	case 1, 2:
+		bigEndian := e == 2
+		if len(b) >= 2 && (b[0] == 0xfe && b[1] == 0xff || b[0] == 0xff && b[1] == 0xfe) {
+			bigEndian, b = b[0] == 0xfe, b[2:]
+		}
+		units := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			if bigEndian {
+				units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+			} else {
+				units = append(units, uint16(b[i+1])<<8|uint16(b[i]))
+			}
+		}
+		return string(utf16.Decode(units))
+	default:
+		return string(b)
+	}
+}
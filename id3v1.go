@@ -0,0 +1,66 @@
+package mp3
+
+import "strconv"
+
+// ID3v1 is the ID3v1.1 tag appended at the end of the stream. Text
+// fields are encoded in ISO-8859-1 and truncated to the field size.
+type ID3v1 struct {
+	Title   string
+	Artist  string
+	Album   string
+	Year    int
+	Comment string
+	// Track is the track number in range 1-255. Zero means no track.
+	Track int
+	// Genre is the index in ID3v1 genre list, see GenreID. Nil means no
+	// genre, because zero index is Blues.
+	Genre *byte
+}
+
+// WithID3v1 makes the Sink append ID3v1.1 tag after the stream. It's
+// recognized by old players that don't support ID3v2.
+func WithID3v1(tag ID3v1) SinkOption {
+	return func(o *sinkOptions) {
+		o.id3v1 = &tag
+	}
+}
+
+// bytes returns encoded tag.
+func (t ID3v1) bytes() []byte {
+	b := make([]byte, id3v1Size)
+	copy(b, "TAG")
+	putLatin1(b[3:33], t.Title)
+	putLatin1(b[33:63], t.Artist)
+	putLatin1(b[63:93], t.Album)
+	if t.Year > 0 && t.Year < 10000 {
+		putLatin1(b[93:97], strconv.Itoa(t.Year))
+	}
+	comment := b[97:127]
+	if t.Track > 0 && t.Track < 256 {
+		// ID3v1.1 takes the last two bytes of comment for track.
+		comment = comment[:28]
+		b[126] = byte(t.Track)
+	}
+	putLatin1(comment, t.Comment)
+	b[127] = NoGenre
+	if t.Genre != nil {
+		b[127] = *t.Genre
+	}
+	return b
+}
+
+// putLatin1 writes s into the field. Characters that aren't in
+// ISO-8859-1 are replaced with question mark.
+func putLatin1(field []byte, s string) {
+	i := 0
+	for _, r := range s {
+		if i == len(field) {
+			return
+		}
+		if r > 0xff {
+			r = '?'
+		}
+		field[i] = byte(r)
+		i++
+	}
+}
@@ -0,0 +1,109 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkID3v1(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      mp3.ID3v1
+		expected func() []byte
+	}{
+		{
+			name: "track",
+			tag: mp3.ID3v1{
+				Title:   "Title",
+				Artist:  "Artist",
+				Album:   "Album",
+				Year:    1999,
+				Comment: "Comment",
+				Track:   7,
+				Genre:   genre(17),
+			},
+			expected: func() []byte {
+				b := id3v1Tag("Title", "Artist", "Album", "1999", "Comment")
+				b[126], b[127] = 7, 17
+				return b
+			},
+		},
+		{
+			name: "no track",
+			tag: mp3.ID3v1{
+				Title:   "Ünïcode ☃",
+				Comment: "Comment that is longer than twenty eight bytes",
+			},
+			expected: func() []byte {
+				b := id3v1Tag("\xdcn\xefcode ?", "", "", "", "Comment that is longer than tw")
+				b[127] = 255
+				return b
+			},
+		},
+		{
+			name: "blues",
+			tag: mp3.ID3v1{
+				Title: "Title",
+				Genre: genre(0),
+			},
+			expected: func() []byte {
+				b := id3v1Tag("Title", "", "", "", "")
+				b[127] = 0
+				return b
+			},
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      10000,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.Sink(&buf, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithID3v1(test.tag),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		data := buf.Bytes()
+		tag := data[len(data)-128:]
+		if expected := test.expected(); !bytes.Equal(tag, expected) {
+			t.Errorf("%s: expected tag:\n%q\ngot:\n%q", test.name, expected, tag)
+		}
+		splitFrames(t, data[:len(data)-128])
+	}
+}
+
+// id3v1Tag returns the tag with text fields set.
+func id3v1Tag(title, artist, album, year, comment string) []byte {
+	b := make([]byte, 128)
+	copy(b, "TAG")
+	copy(b[3:33], title)
+	copy(b[33:63], artist)
+	copy(b[63:93], album)
+	copy(b[93:97], year)
+	copy(b[97:127], comment)
+	return b
+}
+
+// genre returns the pointer to genre index.
+func genre(id byte) *byte {
+	return &id
+}
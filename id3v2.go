@@ -0,0 +1,371 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// id3v2HeaderSize is the size of ID3v2 tag header.
+	id3v2HeaderSize = 10
+	// id3v2MaxSize is the max size of ID3v2 tag body that fits syncsafe
+	// integer.
+	id3v2MaxSize = 1<<28 - 1
+)
+
+// ID3Version is the version of written ID3v2 tag.
+type ID3Version int
+
+const (
+	// ID3v24 is the default version.
+	ID3v24 ID3Version = iota
+	// ID3v23 is supported by more legacy players.
+	ID3v23
+)
+
+func (v ID3Version) String() string {
+	switch v {
+	case ID3v24:
+		return "ID3v2.4"
+	case ID3v23:
+		return "ID3v2.3"
+	}
+	return "unknown"
+}
+
+// ID3v2 is the ID3v2 tag written before the stream.
+type ID3v2 struct {
+	Version ID3Version
+	// Encoding is the text encoding of frames that use default one.
+	Encoding TextEncoding
+	// Text are text information frames.
+	Text []TextFrame
+	// Pictures are embedded as APIC frames. Only one FileIcon, which
+	// must be 32x32 PNG, and one OtherFileIcon are allowed.
+	Pictures []Picture
+	// Chapters are written as CHAP frames with table of contents.
+	Chapters  []Chapter
+	UserText  []UserText
+	Comments  []Comment
+	UserURLs  []UserURL
+	UniqueIDs []UniqueID
+	// MusicBrainz are identifiers of MusicBrainz database.
+	MusicBrainz *MusicBrainz
+	// Padding is the number of zero bytes after the frames. It allows
+	// to edit the tag later without rewriting the whole file.
+	Padding int
+}
+
+// WithID3v2 makes the Sink write ID3v2 tag before the stream.
+func WithID3v2(tag ID3v2) SinkOption {
+	return func(o *sinkOptions) {
+		if err := tag.validate(); err != nil {
+			o.fail(err)
+			return
+		}
+		o.id3v2 = &tag
+	}
+}
+
+func (t ID3v2) validate() error {
+	if t.Version != ID3v24 && t.Version != ID3v23 {
+		return fmt.Errorf("%w: unknown ID3v2 version %d", ErrInvalidParameter, int(t.Version))
+	}
+	if t.Padding < 0 || t.Padding > id3v2MaxSize {
+		return fmt.Errorf("%w: ID3v2 padding %d is out of range", ErrInvalidParameter, t.Padding)
+	}
+	encodings := []TextEncoding{t.Encoding}
+	for i, f := range t.Text {
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("text frame %d: %w", i, err)
+		}
+		encodings = append(encodings, f.Encoding)
+	}
+	if err := validatePictures(t.Pictures); err != nil {
+		return err
+	}
+	for _, p := range t.Pictures {
+		encodings = append(encodings, p.Encoding)
+	}
+	for i, u := range t.UserText {
+		if err := u.validate(); err != nil {
+			return fmt.Errorf("user text %d: %w", i, err)
+		}
+		encodings = append(encodings, u.Encoding)
+	}
+	for i, c := range t.Comments {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("comment %d: %w", i, err)
+		}
+		encodings = append(encodings, c.Encoding)
+	}
+	for _, u := range t.UserURLs {
+		encodings = append(encodings, u.Encoding)
+	}
+	for i, u := range t.UniqueIDs {
+		if err := u.validate(); err != nil {
+			return fmt.Errorf("unique identifier %d: %w", i, err)
+		}
+	}
+	if t.MusicBrainz != nil {
+		if err := t.MusicBrainz.validate(); err != nil {
+			return err
+		}
+	}
+	if len(t.Chapters) > maxChapters {
+		return fmt.Errorf("%w: %d chapters exceed %d", ErrInvalidParameter, len(t.Chapters), maxChapters)
+	}
+	for i, c := range t.Chapters {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("chapter %d: %w", i, err)
+		}
+		for _, l := range c.Links {
+			encodings = append(encodings, l.Encoding)
+		}
+		for _, p := range c.pictures() {
+			encodings = append(encodings, p.Encoding)
+		}
+	}
+	for _, e := range encodings {
+		if err := e.validate(t.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// id3Frame is the encoded frame of ID3v2 tag.
+type id3Frame struct {
+	id   string
+	body []byte
+}
+
+// frames returns frames of the tag.
+func (t ID3v2) frames() []id3Frame {
+	text := id3Text{version: t.Version, encoding: t.Encoding}
+	var frames []id3Frame
+	for _, f := range t.Text {
+		frames = append(frames, f.frame(text))
+	}
+	uniqueIDs, userText := t.UniqueIDs, t.UserText
+	if t.MusicBrainz != nil {
+		uniqueIDs = append(append([]UniqueID(nil), uniqueIDs...), t.MusicBrainz.uniqueIDs()...)
+		userText = append(append([]UserText(nil), userText...), t.MusicBrainz.userText()...)
+	}
+	for _, u := range uniqueIDs {
+		frames = append(frames, u.frame())
+	}
+	for _, u := range userText {
+		frames = append(frames, u.frame(text))
+	}
+	for _, c := range t.Comments {
+		frames = append(frames, c.frame(text))
+	}
+	for _, u := range t.UserURLs {
+		frames = append(frames, u.frame(text))
+	}
+	for _, p := range t.Pictures {
+		frames = append(frames, p.frame(text))
+	}
+	return append(frames, chapterFrames(text, t.Chapters)...)
+}
+
+// bytes returns encoded tag with extra frames. Tag is padded to the
+// size if it's smaller.
+func (t ID3v2) bytes(extra []id3Frame, size int) ([]byte, error) {
+	body := appendFrames(nil, t.Version, append(t.frames(), extra...))
+	if padding := size - id3v2HeaderSize - len(body); padding > 0 {
+		body = append(body, make([]byte, padding)...)
+	}
+	if len(body) > id3v2MaxSize {
+		return nil, fmt.Errorf("%w: ID3v2 tag size %d exceeds %d", ErrInvalidParameter, len(body), id3v2MaxSize)
+	}
+	header := []byte{'I', 'D', '3', 4, 0, 0}
+	if t.Version == ID3v23 {
+		header[3] = 3
+	}
+	header = append(header, syncsafeBytes(len(body))...)
+	return append(header, body...), nil
+}
+
+// appendFrames appends encoded frames to b. Frame sizes are syncsafe
+// only in ID3v2.4.
+func appendFrames(b []byte, v ID3Version, frames []id3Frame) []byte {
+	for _, f := range frames {
+		b = append(b, f.id...)
+		if v == ID3v23 {
+			var size [4]byte
+			binary.BigEndian.PutUint32(size[:], uint32(len(f.body)))
+			b = append(b, size[:]...)
+		} else {
+			b = append(b, syncsafeBytes(len(f.body))...)
+		}
+		b = append(b, 0, 0) // flags.
+		b = append(b, f.body...)
+	}
+	return b
+}
+
+// syncsafeBytes encodes 28-bit syncsafe integer.
+func syncsafeBytes(v int) []byte {
+	return []byte{byte(v >> 21 & 0x7f), byte(v >> 14 & 0x7f), byte(v >> 7 & 0x7f), byte(v & 0x7f)}
+}
+
+// syncsafe decodes 28-bit syncsafe integer of ID3v2 header.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// id3v2TagSize returns the size of ID3v2 tag with header and footer. It
+// returns zero if header isn't ID3v2 tag header.
+func id3v2TagSize(header []byte) int {
+	if len(header) < id3v2HeaderSize || string(header[:3]) != "ID3" {
+		return 0
+	}
+	size := id3v2HeaderSize + syncsafe(header[6:10])
+	// footer flag.
+	if header[5]&0x10 != 0 {
+		size += id3v2HeaderSize
+	}
+	return size
+}
+
+// textFrame returns text information frame.
+func textFrame(t id3Text, id, s string) id3Frame {
+	return id3Frame{id: id, body: t.appendString([]byte{t.encodingByte()}, s)}
+}
+
+// userURLFrame returns WXXX frame. URL is always encoded in ISO-8859-1.
+func userURLFrame(t id3Text, description, url string) id3Frame {
+	body := t.appendTerminated([]byte{t.encodingByte()}, description)
+	return id3Frame{id: "WXXX", body: appendLatin1(body, url)}
+}
+
+// deferredFrames are frames that are known only after the stream is
+// encoded. Reserve is the max size of encoded frames.
+type deferredFrames struct {
+	reserve int
+	frames  func(EncodingInfo) ([]id3Frame, error)
+}
+
+// WithDeferredID3v2 makes the Sink write frames that are known only
+// after encoding, like the length of the stream. Reserve bytes are
+// padded in ID3v2 tag and the tag is rewritten on flush with frames of
+// the tag returned by fn. Version and encoding of the returned tag are
+// ignored. Writer must be seekable.
+func WithDeferredID3v2(reserve int, fn func(EncodingInfo) ID3v2) SinkOption {
+	return func(o *sinkOptions) {
+		if reserve <= 0 {
+			o.fail(fmt.Errorf("%w: deferred ID3v2 reserve %d is not positive", ErrInvalidParameter, reserve))
+			return
+		}
+		o.deferredTags = append(o.deferredTags, deferredTag{reserve: reserve, fn: fn})
+	}
+}
+
+// deferredTag is the tag that is known after encoding.
+type deferredTag struct {
+	reserve int
+	fn      func(EncodingInfo) ID3v2
+}
+
+// frames returns deferred frames of the tag. The tag is validated
+// against version of the stream tag.
+func (d deferredTag) frames(tag ID3v2) deferredFrames {
+	return deferredFrames{
+		reserve: d.reserve,
+		frames: func(info EncodingInfo) ([]id3Frame, error) {
+			deferred := d.fn(info)
+			deferred.Version, deferred.Encoding = tag.Version, tag.Encoding
+			if err := deferred.validate(); err != nil {
+				return nil, err
+			}
+			return deferred.frames(), nil
+		},
+	}
+}
+
+// tagWriter writes ID3v2 tag at the start of the stream. If tag has
+// deferred frames, space is reserved for them and the tag is rewritten
+// on finish.
+type tagWriter struct {
+	ws       io.WriteSeeker
+	tag      ID3v2
+	deferred []deferredFrames
+	start    int64
+	size     int
+}
+
+// writeTag writes the tag into w. Writer must be seekable if there are
+// deferred frames.
+func writeTag(w io.Writer, tag ID3v2, deferred []deferredFrames) (*tagWriter, error) {
+	b, err := tag.bytes(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	tw := tagWriter{tag: tag, deferred: deferred}
+	if tag.Padding > 0 && len(deferred) == 0 {
+		if b, err = tag.bytes(nil, len(b)+tag.Padding); err != nil {
+			return nil, err
+		}
+	}
+	if len(deferred) > 0 {
+		ws, ok := w.(io.WriteSeeker)
+		if !ok {
+			return nil, fmt.Errorf("%w: deferred ID3v2 frames require seekable writer", ErrInvalidParameter)
+		}
+		if tw.start, err = ws.Seek(0, io.SeekCurrent); err != nil {
+			return nil, fmt.Errorf("%w: deferred ID3v2 frames require seekable writer: %v", ErrInvalidParameter, err)
+		}
+		tw.ws = ws
+		size := len(b) + tag.Padding
+		for _, d := range deferred {
+			size += d.reserve
+		}
+		if b, err = tag.bytes(nil, size); err != nil {
+			return nil, err
+		}
+	}
+	tw.size = len(b)
+	if _, err := w.Write(b); err != nil {
+		return nil, fmt.Errorf("error writing ID3v2 tag: %w", err)
+	}
+	return &tw, nil
+}
+
+// finish rewrites the tag with deferred frames.
+func (tw *tagWriter) finish(info EncodingInfo) error {
+	if tw.ws == nil {
+		return nil
+	}
+	var frames []id3Frame
+	for _, d := range tw.deferred {
+		f, err := d.frames(info)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, f...)
+	}
+	b, err := tw.tag.bytes(frames, tw.size)
+	if err != nil {
+		return err
+	}
+	if len(b) > tw.size {
+		return fmt.Errorf("error writing ID3v2 tag: deferred frames exceed reserved size %d", tw.size)
+	}
+	end, err := tw.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error writing ID3v2 tag: %w", err)
+	}
+	if _, err := tw.ws.Seek(tw.start, io.SeekStart); err != nil {
+		return fmt.Errorf("error writing ID3v2 tag: %w", err)
+	}
+	if _, err := tw.ws.Write(b); err != nil {
+		return fmt.Errorf("error writing ID3v2 tag: %w", err)
+	}
+	if _, err := tw.ws.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("error writing ID3v2 tag: %w", err)
+	}
+	return nil
+}
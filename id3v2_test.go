@@ -0,0 +1,410 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkID3v2Pictures(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), 1, 2, 3)
+	icon := pngImage(32, 32)
+	tests := []struct {
+		name     string
+		pictures []mp3.Picture
+		// expected APIC frame bodies.
+		expected [][]byte
+		err      error
+	}{
+		{
+			name:     "detected mime",
+			pictures: []mp3.Picture{{Type: mp3.FrontCover, Description: "Cover", Data: png}},
+			expected: [][]byte{append([]byte("\x03image/png\x00\x03Cover\x00"), png...)},
+		},
+		{
+			name: "explicit mime",
+			pictures: []mp3.Picture{
+				{Type: mp3.FrontCover, Data: png},
+				{Type: mp3.BackCover, MIME: "image/x-custom", Data: []byte{1}},
+			},
+			expected: [][]byte{
+				append([]byte("\x03image/png\x00\x03\x00"), png...),
+				[]byte("\x03image/x-custom\x00\x04\x00\x01"),
+			},
+		},
+		{
+			name: "several types",
+			pictures: []mp3.Picture{
+				{Type: mp3.FileIcon, Data: icon},
+				{Type: mp3.OtherFileIcon, Data: png},
+				{Type: mp3.FrontCover, Description: "front", Data: png},
+				{Type: mp3.BackCover, Description: "back", Data: png},
+			},
+			expected: [][]byte{
+				append([]byte("\x03image/png\x00\x01\x00"), icon...),
+				append([]byte("\x03image/png\x00\x02\x00"), png...),
+				append([]byte("\x03image/png\x00\x03front\x00"), png...),
+				append([]byte("\x03image/png\x00\x04back\x00"), png...),
+			},
+		},
+		{
+			name: "duplicate file icon",
+			pictures: []mp3.Picture{
+				{Type: mp3.FileIcon, Data: icon},
+				{Type: mp3.FileIcon, Description: "other", Data: icon},
+			},
+			err: mp3.ErrInvalidParameter,
+		},
+		{
+			name: "duplicate other file icon",
+			pictures: []mp3.Picture{
+				{Type: mp3.OtherFileIcon, Data: png},
+				{Type: mp3.OtherFileIcon, Description: "other", Data: png},
+			},
+			err: mp3.ErrInvalidParameter,
+		},
+		{
+			name:     "file icon size",
+			pictures: []mp3.Picture{{Type: mp3.FileIcon, Data: pngImage(64, 64)}},
+			err:      mp3.ErrInvalidParameter,
+		},
+		{
+			name:     "file icon format",
+			pictures: []mp3.Picture{{Type: mp3.FileIcon, Data: []byte{0xff, 0xd8, 0xff, 0}}},
+			err:      mp3.ErrInvalidParameter,
+		},
+		{
+			name:     "unknown format",
+			pictures: []mp3.Picture{{Type: mp3.FrontCover, Data: []byte{1, 2, 3}}},
+			err:      mp3.ErrInvalidParameter,
+		},
+		{
+			name:     "empty",
+			pictures: []mp3.Picture{{Type: mp3.FrontCover}},
+			err:      mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(mp3.WithID3v2(mp3.ID3v2{Pictures: test.pictures}))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames, audio := parseID3v2(t, data)
+		var pictures [][]byte
+		for _, f := range frames {
+			if f.id == "APIC" {
+				pictures = append(pictures, f.body)
+			}
+		}
+		if len(pictures) != len(test.expected) {
+			t.Fatalf("%s: expected pictures: %d got: %d", test.name, len(test.expected), len(pictures))
+		}
+		for i := range pictures {
+			if !bytes.Equal(pictures[i], test.expected[i]) {
+				t.Errorf("%s: expected picture %d: %q got: %q", test.name, i, test.expected[i], pictures[i])
+			}
+		}
+		if string(splitFrames(t, audio)[0][4+32:4+36]) != "Info" {
+			t.Errorf("%s: expected Info tag after ID3v2 tag", test.name)
+		}
+	}
+}
+
+// pngImage returns PNG signature and IHDR chunk of the image size.
+func pngImage(width, height uint32) []byte {
+	b := append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 0, 13)
+	b = append(b, "IHDR"...)
+	b = append(b, make([]byte, 8)...)
+	binary.BigEndian.PutUint32(b[16:], width)
+	binary.BigEndian.PutUint32(b[20:], height)
+	return append(b, 8, 6, 0, 0, 0)
+}
+
+func TestSinkID3v2Version(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      mp3.ID3v2
+		version  byte
+		expected []id3Frame
+		err      error
+	}{
+		{
+			name: "v2.4 default",
+			tag: mp3.ID3v2{
+				UserText: []mp3.UserText{{Description: "a", Value: "é"}},
+			},
+			version:  4,
+			expected: []id3Frame{{id: "TXXX", body: []byte("\x03a\x00é")}},
+		},
+		{
+			name: "v2.3 default",
+			tag: mp3.ID3v2{
+				Version:  mp3.ID3v23,
+				UserText: []mp3.UserText{{Description: "a", Value: "é"}},
+			},
+			version:  3,
+			expected: []id3Frame{{id: "TXXX", body: []byte("\x01\xff\xfea\x00\x00\x00\xff\xfe\xe9\x00")}},
+		},
+		{
+			name: "per-frame encoding",
+			tag: mp3.ID3v2{
+				Encoding: mp3.UTF16BE,
+				UserText: []mp3.UserText{{Description: "a", Value: "é☃", Encoding: mp3.Latin1}},
+				Comments: []mp3.Comment{{Language: "eng", Text: "é"}},
+			},
+			version: 4,
+			expected: []id3Frame{
+				{id: "TXXX", body: []byte("\x00a\x00\xe9?")},
+				{id: "COMM", body: []byte("\x02eng\x00\x00\x00\xe9")},
+			},
+		},
+		{
+			name: "v2.3 UTF-8",
+			tag:  mp3.ID3v2{Version: mp3.ID3v23, Encoding: mp3.UTF8},
+			err:  mp3.ErrInvalidParameter,
+		},
+		{
+			name: "v2.3 UTF-8 frame",
+			tag: mp3.ID3v2{
+				Version:  mp3.ID3v23,
+				Comments: []mp3.Comment{{Encoding: mp3.UTF8}},
+			},
+			err: mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(mp3.WithID3v2(test.tag))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if data[3] != test.version {
+			t.Errorf("%s: expected version: %d got: %d", test.name, test.version, data[3])
+		}
+		frames, _ := parseID3v2(t, data)
+		if len(frames) != len(test.expected) {
+			t.Fatalf("%s: expected frames: %d got: %d", test.name, len(test.expected), len(frames))
+		}
+		for i, f := range frames {
+			if f.id != test.expected[i].id || !bytes.Equal(f.body, test.expected[i].body) {
+				t.Errorf("%s: expected frame %s %q got: %s %q", test.name, test.expected[i].id, test.expected[i].body, f.id, f.body)
+			}
+		}
+	}
+}
+
+type id3Frame struct {
+	id   string
+	body []byte
+}
+
+// parseID3v2 returns frames of ID3v2 tag at the start of data and the
+// data after the tag.
+func parseID3v2(t *testing.T, data []byte) ([]id3Frame, []byte) {
+	t.Helper()
+	if len(data) < 10 || string(data[:3]) != "ID3" || (data[3] != 4 && data[3] != 3) {
+		t.Fatalf("invalid ID3v2 header: % x", data[:10])
+	}
+	version := data[3]
+	size := syncsafe(data[6:10])
+	body, rest := data[10:10+size], data[10+size:]
+	var frames []id3Frame
+	for len(body) >= 10 && body[0] != 0 {
+		frameSize := syncsafe(body[4:8])
+		if version == 3 {
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		frames = append(frames, id3Frame{id: string(body[:4]), body: body[10 : 10+frameSize]})
+		body = body[10+frameSize:]
+	}
+	return frames, rest
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// encodeTagged encodes a second of signal into temporary file with
+// provided options and returns the file content.
+func encodeTagged(options ...mp3.SinkOption) ([]byte, error) {
+	f, err := os.CreateTemp("", "mp3")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	options = append([]mp3.SinkOption{mp3.WithBackend(mp3.Shine)}, options...)
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(f.Name())
+}
+
+func TestSinkDeferredID3v2(t *testing.T) {
+	length := func(info mp3.EncodingInfo) mp3.ID3v2 {
+		return mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TLEN", Value: strconv.Itoa(info.Samples * 1000 / 44100)}}}
+	}
+	tests := []struct {
+		name     string
+		tag      mp3.ID3v2
+		reserve  int
+		fn       func(mp3.EncodingInfo) mp3.ID3v2
+		expected []id3Frame
+		// fails reports that the tag can't be written.
+		fails bool
+		err   error
+	}{
+		{
+			name:    "length",
+			tag:     mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}},
+			reserve: 64,
+			fn:      length,
+			expected: []id3Frame{
+				{id: "TIT2", body: []byte("\x03Title")},
+				{id: "TLEN", body: []byte("\x031000")},
+			},
+		},
+		{
+			name:     "v2.3",
+			tag:      mp3.ID3v2{Version: mp3.ID3v23},
+			reserve:  64,
+			fn:       length,
+			expected: []id3Frame{{id: "TLEN", body: []byte("\x01\xff\xfe1\x000\x000\x000\x00")}},
+		},
+		{
+			name:    "small reserve",
+			reserve: 4,
+			fn:      length,
+			fails:   true,
+		},
+		{
+			name:    "invalid frame",
+			reserve: 64,
+			fn: func(mp3.EncodingInfo) mp3.ID3v2 {
+				return mp3.ID3v2{Text: []mp3.TextFrame{{ID: "XXXX"}}}
+			},
+			err: mp3.ErrInvalidParameter,
+		},
+		{
+			name: "no reserve",
+			fn:   length,
+			err:  mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(mp3.WithID3v2(test.tag), mp3.WithDeferredID3v2(test.reserve, test.fn))
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames, audio := parseID3v2(t, data)
+		if len(frames) != len(test.expected) {
+			t.Fatalf("%s: expected frames: %d got: %d", test.name, len(test.expected), len(frames))
+		}
+		for i, f := range frames {
+			if f.id != test.expected[i].id || !bytes.Equal(f.body, test.expected[i].body) {
+				t.Errorf("%s: expected frame %s %q got: %s %q", test.name, test.expected[i].id, test.expected[i].body, f.id, f.body)
+			}
+		}
+		if string(splitFrames(t, audio)[0][4+32:4+36]) != "Info" {
+			t.Errorf("%s: expected Info tag after ID3v2 tag", test.name)
+		}
+	}
+}
+
+func TestSinkID3v2Padding(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []mp3.SinkOption
+		expected int
+		err      error
+	}{
+		{
+			name:     "padding",
+			options:  []mp3.SinkOption{mp3.WithID3v2(mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}, Padding: 2048})},
+			expected: 16 + 2048,
+		},
+		{
+			name: "padding with deferred frames",
+			options: []mp3.SinkOption{
+				mp3.WithID3v2(mp3.ID3v2{Padding: 1024}),
+				mp3.WithDeferredID3v2(100, func(mp3.EncodingInfo) mp3.ID3v2 {
+					return mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}}
+				}),
+			},
+			expected: 1024 + 100,
+		},
+		{
+			name:    "negative",
+			options: []mp3.SinkOption{mp3.WithID3v2(mp3.ID3v2{Padding: -1})},
+			err:     mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(test.options...)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if size := syncsafe(data[6:10]); size != test.expected {
+			t.Errorf("%s: expected tag size: %d got: %d", test.name, test.expected, size)
+		}
+		frames, audio := parseID3v2(t, data)
+		if len(frames) != 1 || frames[0].id != "TIT2" {
+			t.Errorf("%s: unexpected frames: %v", test.name, frames)
+		}
+		splitFrames(t, audio)
+	}
+}
@@ -0,0 +1,59 @@
+package mp3
+
+import "time"
+
+type (
+	// EncodingInfo describes the encoded stream. It's available after the
+	// encoder is flushed. Delay and Padding are in samples per channel
+	// of the encoded stream and are needed for gapless playback: the
+	// decoded stream contains Delay samples before the input and Padding
+	// samples after it.
+	EncodingInfo struct {
+		// Samples is the number of input samples per channel.
+		Samples int
+		// Frames is the number of encoded audio frames, not including
+		// VBR header frame.
+		Frames int
+		// Delay is the number of samples added by the encoder before the
+		// input.
+		Delay int
+		// Padding is the number of samples added by the encoder after the
+		// input to complete the last frame.
+		Padding int
+		// Trimmed is the duration of leading silence dropped before
+		// encoding.
+		Trimmed time.Duration
+	}
+
+	// InfoEncoder is implemented by encoders that report EncodingInfo.
+	// If custom encoder doesn't implement it, only the number of samples
+	// is reported.
+	InfoEncoder interface {
+		Encoder
+		// EncodingInfo returns the info. It's called after Flush.
+		EncodingInfo() EncodingInfo
+	}
+)
+
+// WithEncodingInfo sets the function that receives EncodingInfo after
+// the encoder is flushed successfully.
+func WithEncodingInfo(fn func(EncodingInfo)) SinkOption {
+	return func(o *sinkOptions) {
+		o.info = fn
+	}
+}
+
+// encodingInfo returns the info of flushed encoder with provided number
+// of samples consumed by the sink.
+func encodingInfo(encoder Encoder, samples int) EncodingInfo {
+	if se, ok := encoder.(*switchEncoder); ok {
+		return se.encodingInfo(samples)
+	}
+	ie, ok := encoder.(InfoEncoder)
+	if !ok {
+		return EncodingInfo{Samples: samples}
+	}
+	info := ie.EncodingInfo()
+	info.Samples = samples
+	return info
+}
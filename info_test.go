@@ -0,0 +1,88 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestEncodingInfo(t *testing.T) {
+	skipWithoutLame(t)
+	type sinkFunc func(*bytes.Buffer, ...mp3.SinkOption) pipe.SinkAllocatorFunc
+	tests := []struct {
+		name string
+		sink sinkFunc
+		// number of header frames in the output.
+		headerFrames int
+	}{
+		{
+			name: "lame",
+			sink: func(out *bytes.Buffer, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				return mp3.Sink(out, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+			headerFrames: 1,
+		},
+		{
+			name: "shine",
+			sink: func(out *bytes.Buffer, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				options = append(options, mp3.WithBackend(mp3.Shine))
+				return mp3.Sink(out, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+		},
+		{
+			name: "parallel",
+			sink: func(out *bytes.Buffer, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				return mp3.ParallelSink(out, 2, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+		},
+	}
+	const frameSamples = 1152
+
+	for _, test := range tests {
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      700000,
+			Value:      0.5,
+		}
+		var (
+			out      bytes.Buffer
+			info     mp3.EncodingInfo
+			reported bool
+		)
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: test.sink(&out, mp3.WithEncodingInfo(func(i mp3.EncodingInfo) {
+					info, reported = i, true
+				})),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !reported {
+			t.Fatalf("%s: info wasn't reported", test.name)
+		}
+		if info.Samples != source.Limit {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, source.Limit, info.Samples)
+		}
+		if frames := len(splitFrames(t, out.Bytes())) - test.headerFrames; info.Frames != frames {
+			t.Errorf("%s: expected frames: %d got: %d", test.name, frames, info.Frames)
+		}
+		if info.Delay <= 0 || info.Padding < 0 {
+			t.Errorf("%s: unexpected delay: %d padding: %d", test.name, info.Delay, info.Padding)
+		}
+		if total := info.Delay + info.Samples + info.Padding; total != info.Frames*frameSamples {
+			t.Errorf("%s: expected %d samples in frames got: %d", test.name, info.Frames*frameSamples, total)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Package shine is a fixed-point MPEG Layer III encoder. It's a copy of
+// github.com/braheezy/shine-mp3/pkg/mp3 v0.2.0, a Go port of the shine
+// encoder, distributed under the terms of LGPL in LICENSE.
+//
+// Local changes:
+//   - NewEncoder takes the bitrate and validates the configuration,
+//     upstream always encodes at 128 kbps;
+//   - side information of MPEG-1 mono frames is 17 bytes, upstream
+//     reserves 9 bytes and produces oversized frames.
+package shine
@@ -0,0 +1,53 @@
+package mp3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// decoderDelay is the delay of mp3 decoder in samples, that Apple players
+// expect to be included in iTunSMPB delay.
+const decoderDelay = 529
+
+// WithITunesGapless makes the Sink write iTunSMPB comment with encoder delay,
+// padding and the number of samples into ID3v2 tag. It allows gapless
+// playback in Apple players that ignore LAME tag. Comment is written on
+// flush, so the writer must be seekable.
+func WithITunesGapless() SinkOption {
+	return func(o *sinkOptions) {
+		o.itunSMPB = true
+	}
+}
+
+// itunSMPBDeferred returns COMM frame that is written on flush.
+func itunSMPBDeferred(tag ID3v2) deferredFrames {
+	return deferredFrames{
+		reserve: len(appendFrames(nil, tag.Version, []id3Frame{itunSMPBFrame(tag, EncodingInfo{})})),
+		frames: func(info EncodingInfo) ([]id3Frame, error) {
+			return []id3Frame{itunSMPBFrame(tag, info)}, nil
+		},
+	}
+}
+
+// itunSMPBFrame returns the comment. It has the fixed size for any info.
+func itunSMPBFrame(tag ID3v2, info EncodingInfo) id3Frame {
+	padding := info.Padding - decoderDelay
+	if padding < 0 {
+		padding = 0
+	}
+	fields := []string{
+		"00000000",
+		fmt.Sprintf("%08X", uint32(info.Delay+decoderDelay)),
+		fmt.Sprintf("%08X", uint32(padding)),
+		fmt.Sprintf("%016X", uint64(info.Samples)),
+	}
+	for len(fields) < 12 {
+		fields = append(fields, "00000000")
+	}
+	text := id3Text{version: tag.Version, encoding: tag.Encoding}
+	return Comment{
+		Language:    "eng",
+		Description: "iTunSMPB",
+		Text:        " " + strings.Join(fields, " "),
+	}.frame(text)
+}
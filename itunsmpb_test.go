@@ -0,0 +1,31 @@
+package mp3_test
+
+import (
+	"fmt"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestSinkITunesGapless(t *testing.T) {
+	var info mp3.EncodingInfo
+	data, err := encodeTagged(
+		mp3.WithITunesGapless(),
+		mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames, _ := parseID3v2(t, data)
+	if len(frames) != 1 || frames[0].id != "COMM" {
+		t.Fatalf("expected single COMM frame got: %v", frames)
+	}
+	expected := fmt.Sprintf("\x03engiTunSMPB\x00 00000000 %08X %08X %016X 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000",
+		info.Delay+529, info.Padding-529, info.Samples)
+	if string(frames[0].body) != expected {
+		t.Errorf("expected comment: %q got: %q", expected, frames[0].body)
+	}
+	if info.Samples != 44100 {
+		t.Errorf("expected samples: 44100 got: %d", info.Samples)
+	}
+}
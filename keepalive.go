@@ -0,0 +1,112 @@
+package mp3
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// WithKeepalive makes the Sink write silent frames if no frames are
+// written within interval, so listeners of live stream don't disconnect
+// when the input underruns. Silent frames are written at the pace of
+// the stream until the input resumes. They have the format of the last
+// written frame and don't use bit reservoir, but the first frame after
+// them may reference the reservoir, so WithoutBitReservoir is
+// recommended. Writes are aligned to frames and stream isn't seekable,
+// so Xing header isn't written. Silent frames are written from the
+// background, the writer is never called concurrently though.
+func WithKeepalive(interval time.Duration) SinkOption {
+	return func(o *sinkOptions) {
+		if interval <= 0 {
+			o.fail(fmt.Errorf("%w: keepalive interval %v is not positive", ErrInvalidParameter, interval))
+			return
+		}
+		o.keepalive = interval
+		o.alignFrames = true
+	}
+}
+
+// keepaliveWriter writes silent frames when writes stall.
+type keepaliveWriter struct {
+	w        io.Writer
+	interval time.Duration
+
+	mu    sync.Mutex
+	timer *time.Timer
+	// header of the last written frame and the silent frame for it.
+	header   [frameHeaderSize]byte
+	silence  []byte
+	duration time.Duration
+	// last is the time of the last write of the Sink.
+	last    time.Time
+	stopped bool
+	// err is the error of the background write.
+	err error
+}
+
+func (k *keepaliveWriter) Write(p []byte) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.err != nil {
+		return 0, k.err
+	}
+	n, err := k.w.Write(p)
+	if err != nil || k.stopped {
+		return n, err
+	}
+	// tags are passed through, format is updated only by frames.
+	if h, err := parseFrameHeader(p); err == nil {
+		k.update(h, p)
+	}
+	if k.silence == nil {
+		return n, nil
+	}
+	k.last = time.Now()
+	if k.timer == nil {
+		k.timer = time.AfterFunc(k.interval, k.keepalive)
+	} else {
+		k.timer.Reset(k.interval)
+	}
+	return n, nil
+}
+
+// update prepares silent frame if format of the stream has changed.
+func (k *keepaliveWriter) update(h frameHeader, p []byte) {
+	// padding and CRC bits don't affect the format.
+	header := [frameHeaderSize]byte{p[0], p[1] | 0x01, p[2] &^ 0x02, p[3]}
+	if k.silence != nil && header == k.header {
+		return
+	}
+	h.padding = false
+	k.header = header
+	k.silence = make([]byte, h.size())
+	copy(k.silence, header[:])
+	k.duration = h.duration()
+}
+
+// keepalive writes silent frame and schedules the next one. Frame with
+// zero side information decodes into silence.
+func (k *keepaliveWriter) keepalive() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// timer has been reset by the write that happened meanwhile.
+	if k.stopped || k.err != nil || time.Since(k.last) < k.interval {
+		return
+	}
+	if _, err := k.w.Write(k.silence); err != nil {
+		k.err = fmt.Errorf("error writing keepalive frame: %w", err)
+		return
+	}
+	k.timer.Reset(k.duration)
+}
+
+// stop disables keepalive at the end of the stream.
+func (k *keepaliveWriter) stop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stopped = true
+	if k.timer != nil {
+		k.timer.Stop()
+	}
+}
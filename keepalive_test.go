@@ -0,0 +1,113 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// stallingSource stalls for the duration after the number of reads.
+func stallingSource(source pipe.SourceAllocatorFunc, reads int, stall time.Duration) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		s, err := source(mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		fn := s.SourceFunc
+		var n int
+		s.SourceFunc = func(out signal.Floating) (int, error) {
+			if n++; n == reads {
+				time.Sleep(stall)
+			}
+			return fn(out)
+		}
+		return s, nil
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	tests := []struct {
+		name     string
+		stall    time.Duration
+		silent   bool
+		interval time.Duration
+	}{
+		{
+			name:     "stalled",
+			stall:    500 * time.Millisecond,
+			interval: 50 * time.Millisecond,
+			silent:   true,
+		},
+		{
+			name:     "not stalled",
+			interval: time.Second,
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      44100,
+			Value:      0.5,
+		}
+		var info mp3.EncodingInfo
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: stallingSource(source.Source(), 10, test.stall),
+				Sink: mp3.Sink(&buf, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithKeepalive(test.interval),
+					mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		var silent int
+		for _, frame := range splitFrames(t, buf.Bytes()) {
+			if frameBitRate(frame) != 128 {
+				t.Fatalf("%s: unexpected bit rate of frame: %d", test.name, frameBitRate(frame))
+			}
+			if len(bytes.Trim(frame[4:], "\x00")) == 0 {
+				silent++
+			}
+		}
+		if test.silent && silent == 0 {
+			t.Errorf("%s: expected silent frames", test.name)
+		}
+		if !test.silent && silent != 0 {
+			t.Errorf("%s: unexpected silent frames: %d", test.name, silent)
+		}
+		if info.Frames == 0 {
+			t.Errorf("%s: expected encoded frames", test.name)
+		}
+	}
+}
+
+func TestKeepaliveInvalid(t *testing.T) {
+	source := mock.Source{Channels: 2, SampleRate: 44100, Limit: 44100}
+	_, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.Sink(&bytes.Buffer{}, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithKeepalive(0)),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,347 @@
+//go:build cgo && !nolame
+// +build cgo,!nolame
+
+package mp3
+
+/*
+#cgo LDFLAGS: -lmp3lame
+#include "lame/lame.h"
+
+// exported by libmp3lame, but declared only in internal set_get.h.
+int lame_set_substep(lame_global_flags *, int);
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// lameAvailable reports whether the package is built with libmp3lame.
+const lameAvailable = true
+
+const (
+	vbrOff  = C.vbr_off
+	vbrABR  = C.vbr_abr
+	vbrMTRH = C.vbr_mtrh
+
+	modeStereo      = C.STEREO
+	modeJointStereo = C.JOINT_STEREO
+	modeMono        = C.MONO
+)
+
+// lameEncoder binds libmp3lame encoder and writes encoded data into the
+// underlying writer.
+type lameEncoder struct {
+	opts      sinkOptions
+	gfp       *C.lame_global_flags
+	w         io.Writer
+	remainder []byte
+	// out is reused for encoded frames of every write.
+	out    []byte
+	closed bool
+	info   EncodingInfo
+	// seekable output and the offset of the stream start. Lame tag is
+	// backfilled only if output is seekable.
+	ws    io.WriteSeeker
+	start int64
+}
+
+// Init implements Encoder.
+func (e *lameEncoder) Init(w io.Writer, p EncoderParams) error {
+	gfp := C.lame_init()
+	if gfp == nil {
+		return errors.New("error initializing lame")
+	}
+	e.gfp = gfp
+	e.setWriter(w)
+
+	p.BitRateMode.apply(e)
+	setQuality(e, p.Quality)
+	setChannelMode(e, p.ChannelMode)
+	e.opts.apply(e)
+	if p.OutSampleRate != 0 {
+		e.setOutSampleRate(int(p.OutSampleRate))
+	}
+	e.setInSampleRate(int(p.SampleRate))
+	e.setNumChannels(p.Channels)
+	if err := e.initParams(); err != nil {
+		e.free()
+		return err
+	}
+	return nil
+}
+
+// setWriter sets the output. Lame tag is backfilled only if output is
+// seekable.
+func (e *lameEncoder) setWriter(w io.Writer) {
+	e.w = w
+	e.ws, e.start = nil, 0
+	if ws, ok := w.(io.WriteSeeker); ok {
+		// pipes and terminals implement Seek, but fail.
+		if start, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			e.ws, e.start = ws, start
+		}
+	}
+}
+
+func (e *lameEncoder) setVBR(mode C.vbr_mode) {
+	C.lame_set_VBR(e.gfp, mode)
+}
+
+func (e *lameEncoder) setVBRQuality(quality int) {
+	C.lame_set_VBR_q(e.gfp, C.int(quality))
+}
+
+func (e *lameEncoder) setVBRMeanBitRate(kbps int) {
+	C.lame_set_VBR_mean_bitrate_kbps(e.gfp, C.int(kbps))
+}
+
+func (e *lameEncoder) setBitRate(kbps int) {
+	C.lame_set_brate(e.gfp, C.int(kbps))
+}
+
+func (e *lameEncoder) setMode(mode C.MPEG_mode) {
+	C.lame_set_mode(e.gfp, mode)
+}
+
+func (e *lameEncoder) setQuality(quality int) {
+	C.lame_set_quality(e.gfp, C.int(quality))
+}
+
+func (e *lameEncoder) setInSampleRate(sampleRate int) {
+	C.lame_set_in_samplerate(e.gfp, C.int(sampleRate))
+}
+
+func (e *lameEncoder) setOutSampleRate(sampleRate int) {
+	C.lame_set_out_samplerate(e.gfp, C.int(sampleRate))
+}
+
+func (e *lameEncoder) setNumChannels(channels int) {
+	C.lame_set_num_channels(e.gfp, C.int(channels))
+}
+
+func (e *lameEncoder) setErrorProtection(enabled bool) {
+	C.lame_set_error_protection(e.gfp, cbool(enabled))
+}
+
+func (e *lameEncoder) setDisableReservoir(disabled bool) {
+	C.lame_set_disable_reservoir(e.gfp, cbool(disabled))
+}
+
+func (e *lameEncoder) setStrictISO(strict bool) {
+	C.lame_set_strict_ISO(e.gfp, cbool(strict))
+}
+
+func (e *lameEncoder) setForceMS(force bool) {
+	C.lame_set_force_ms(e.gfp, cbool(force))
+}
+
+func (e *lameEncoder) setATHAASensitivity(db float64) {
+	C.lame_set_athaa_sensitivity(e.gfp, C.float(db))
+}
+
+func (e *lameEncoder) setInterChannelRatio(ratio float64) {
+	C.lame_set_interChRatio(e.gfp, C.float(ratio))
+}
+
+// bit offsets of noise shaping adjustments in nspsytune value.
+const (
+	nsBass   = 2
+	nsAlto   = 8
+	nsTreble = 14
+	nsSFB21  = 20
+)
+
+// setNoiseShaping sets noise shaping adjustment bits of nspsytune value
+// in quarters of dB, the same way lame frontend does.
+func (e *lameEncoder) setNoiseShaping(offset uint, db float64) {
+	k := int(db * 4)
+	nspsytune := int(C.lame_get_exp_nspsytune(e.gfp)) | (k&63)<<offset
+	C.lame_set_exp_nspsytune(e.gfp, C.int(nspsytune))
+}
+
+func (e *lameEncoder) setATHType(t int) {
+	C.lame_set_ATHtype(e.gfp, C.int(t))
+}
+
+func (e *lameEncoder) setATHLower(db float64) {
+	C.lame_set_ATHlower(e.gfp, C.float(db))
+}
+
+func (e *lameEncoder) setSubstep(mode int) {
+	C.lame_set_substep(e.gfp, C.int(mode))
+}
+
+func (e *lameEncoder) setLowpass(hz int) {
+	C.lame_set_lowpassfreq(e.gfp, C.int(hz))
+}
+
+func (e *lameEncoder) setHighpass(hz int) {
+	C.lame_set_highpassfreq(e.gfp, C.int(hz))
+}
+
+func (e *lameEncoder) setWriteVBRTag(enabled bool) {
+	C.lame_set_bWriteVbrTag(e.gfp, cbool(enabled))
+}
+
+func (e *lameEncoder) numChannels() int {
+	return int(C.lame_get_num_channels(e.gfp))
+}
+
+// initParams must be called after all parameters are set and before
+// encoding.
+func (e *lameEncoder) initParams() error {
+	if ret := C.lame_init_params(e.gfp); ret < 0 {
+		return fmt.Errorf("error initializing lame parameters: %d", int(ret))
+	}
+	return nil
+}
+
+// Write implements Encoder.
+func (e *lameEncoder) Write(p []byte) (int, error) {
+	buf := p
+	if len(e.remainder) > 0 {
+		buf = append(e.remainder, buf...)
+	}
+	blockAlign := bytesPerSample * e.numChannels()
+	if remainBytes := len(buf) % blockAlign; remainBytes > 0 {
+		e.remainder = append([]byte(nil), buf[len(buf)-remainBytes:]...)
+		buf = buf[:len(buf)-remainBytes]
+	} else {
+		e.remainder = e.remainder[:0]
+	}
+	if len(buf) == 0 {
+		return len(p), nil
+	}
+
+	numSamples := len(buf) / blockAlign
+	// worst case estimate recommended by lame.
+	size := int(1.25*float64(numSamples) + 7200)
+	if cap(e.out) < size {
+		e.out = make([]byte, size)
+	}
+	out := e.out[:size]
+	pcm := (*C.short)(unsafe.Pointer(&buf[0]))
+	mp3buf := (*C.uchar)(unsafe.Pointer(&out[0]))
+	var n C.int
+	if e.numChannels() == 1 {
+		n = C.lame_encode_buffer(e.gfp, pcm, nil, C.int(numSamples), mp3buf, C.int(len(out)))
+	} else {
+		n = C.lame_encode_buffer_interleaved(e.gfp, pcm, C.int(numSamples), mp3buf, C.int(len(out)))
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("error encoding: %d", int(n))
+	}
+	if n > 0 {
+		if _, err := e.w.Write(out[:n]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush implements Encoder.
+func (e *lameEncoder) Flush() error {
+	if e.closed {
+		return nil
+	}
+	defer e.free()
+	return e.flush(false)
+}
+
+// flushNogap flushes the bitstream of the current track. PCM buffered by
+// the encoder is kept for the next track.
+func (e *lameEncoder) flushNogap() error {
+	if e.closed {
+		return nil
+	}
+	return e.flush(true)
+}
+
+// nextTrack starts the bitstream of the next track that is written into
+// w.
+func (e *lameEncoder) nextTrack(w io.Writer) error {
+	if ret := C.lame_init_bitstream(e.gfp); ret < 0 {
+		return fmt.Errorf("error initializing lame bitstream: %d", int(ret))
+	}
+	e.setWriter(w)
+	return nil
+}
+
+func (e *lameEncoder) flush(nogap bool) error {
+	out := make([]byte, 7200)
+	mp3buf := (*C.uchar)(unsafe.Pointer(&out[0]))
+	var n C.int
+	if nogap {
+		n = C.lame_encode_flush_nogap(e.gfp, mp3buf, C.int(len(out)))
+	} else {
+		n = C.lame_encode_flush(e.gfp, mp3buf, C.int(len(out)))
+	}
+	if n < 0 {
+		return fmt.Errorf("error flushing: %d", int(n))
+	}
+	// delay and padding are known only after flush.
+	e.info = EncodingInfo{
+		Frames:  int(C.lame_get_frameNum(e.gfp)),
+		Delay:   int(C.lame_get_encoder_delay(e.gfp)),
+		Padding: int(C.lame_get_encoder_padding(e.gfp)),
+	}
+	if n > 0 {
+		if _, err := e.w.Write(out[:n]); err != nil {
+			return err
+		}
+	}
+	return e.writeLameTag()
+}
+
+// writeLameTag overwrites the placeholder frame at the stream start with
+// Xing/Info frame. It contains LAME extension with encoder delay and
+// padding required for gapless playback.
+func (e *lameEncoder) writeLameTag() error {
+	if e.ws == nil || C.lame_get_bWriteVbrTag(e.gfp) == 0 {
+		return nil
+	}
+	tag := make([]byte, 2880) // max frame size.
+	n := C.lame_get_lametag_frame(e.gfp, (*C.uchar)(unsafe.Pointer(&tag[0])), C.size_t(len(tag)))
+	if n == 0 || int(n) > len(tag) {
+		return nil
+	}
+	end, err := e.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error writing lame tag: %w", err)
+	}
+	if _, err := e.ws.Seek(e.start, io.SeekStart); err != nil {
+		return fmt.Errorf("error writing lame tag: %w", err)
+	}
+	if _, err := e.ws.Write(tag[:n]); err != nil {
+		return fmt.Errorf("error writing lame tag: %w", err)
+	}
+	if _, err := e.ws.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("error writing lame tag: %w", err)
+	}
+	return nil
+}
+
+// EncodingInfo implements InfoEncoder.
+func (e *lameEncoder) EncodingInfo() EncodingInfo {
+	return e.info
+}
+
+// free releases encoder resources without flushing.
+func (e *lameEncoder) free() {
+	if e.closed {
+		return
+	}
+	e.closed = true
+	C.lame_close(e.gfp)
+}
+
+func cbool(v bool) C.int {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+//go:build !cgo || nolame
+// +build !cgo nolame
+
+package mp3
+
+import "io"
+
+// lameAvailable reports whether the package is built with libmp3lame.
+const lameAvailable = false
+
+const (
+	vbrOff = iota
+	vbrABR
+	vbrMTRH
+)
+
+const (
+	modeStereo = iota
+	modeJointStereo
+	modeMono
+)
+
+// bit offsets of noise shaping adjustments in nspsytune value.
+const (
+	nsBass   = 2
+	nsAlto   = 8
+	nsTreble = 14
+	nsSFB21  = 20
+)
+
+// lameEncoder is a placeholder for builds without libmp3lame. Sink
+// rejects Lame backend before the encoder is created.
+type lameEncoder struct {
+	opts sinkOptions
+}
+
+// Init implements Encoder.
+func (e *lameEncoder) Init(io.Writer, EncoderParams) error {
+	return ErrBackendUnavailable
+}
+
+// Write implements Encoder.
+func (e *lameEncoder) Write([]byte) (int, error) {
+	return 0, ErrBackendUnavailable
+}
+
+// Flush implements Encoder.
+func (e *lameEncoder) Flush() error {
+	return ErrBackendUnavailable
+}
+
+func (e *lameEncoder) flushNogap() error {
+	return ErrBackendUnavailable
+}
+
+func (e *lameEncoder) nextTrack(io.Writer) error {
+	return ErrBackendUnavailable
+}
+
+func (e *lameEncoder) setVBR(int)                              {}
+func (e *lameEncoder) setVBRQuality(int)                       {}
+func (e *lameEncoder) setVBRMeanBitRate(int)                   {}
+func (e *lameEncoder) setBitRate(int)                          {}
+func (e *lameEncoder) setMode(int)                             {}
+func (e *lameEncoder) setQuality(int)                          {}
+func (e *lameEncoder) setErrorProtection(bool)                 {}
+func (e *lameEncoder) setDisableReservoir(bool)                {}
+func (e *lameEncoder) setStrictISO(bool)                       {}
+func (e *lameEncoder) setForceMS(bool)                         {}
+func (e *lameEncoder) setATHAASensitivity(float64)             {}
+func (e *lameEncoder) setInterChannelRatio(float64)            {}
+func (e *lameEncoder) setNoiseShaping(offset uint, db float64) {}
+func (e *lameEncoder) setATHType(int)                          {}
+func (e *lameEncoder) setATHLower(float64)                     {}
+func (e *lameEncoder) setSubstep(int)                          {}
+func (e *lameEncoder) setLowpass(int)                          {}
+func (e *lameEncoder) setHighpass(int)                         {}
+func (e *lameEncoder) setWriteVBRTag(bool)                     {}
@@ -0,0 +1,105 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkLameTag(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name        string
+		bitRateMode mp3.BitRateMode
+		tag         string
+		stats       bool
+		options     []mp3.SinkOption
+	}{
+		{name: "cbr", bitRateMode: mp3.CBR(128), tag: "Info"},
+		{name: "vbr", bitRateMode: mp3.VBR(2), tag: "Xing"},
+		{name: "frame aligned", bitRateMode: mp3.CBR(128), tag: "Info", options: []mp3.SinkOption{mp3.WithFrameAlignedWrites(0)}},
+		{name: "stats", bitRateMode: mp3.VBR(2), tag: "Xing", stats: true},
+		{name: "tag template", bitRateMode: mp3.VBR(2), tag: "Xing", options: []mp3.SinkOption{mp3.WithTagTemplate(&mp3.TagTemplate{
+			Tag:     mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TLEN", Value: "{length}"}}},
+			Reserve: 64,
+		})}},
+		// low latency mode doesn't write the tag, so placeholder isn't left.
+		{name: "low latency", bitRateMode: mp3.VBR(2), options: []mp3.SinkOption{mp3.WithLowLatency(func(mp3.Latency) {})}},
+		{name: "progress", bitRateMode: mp3.VBR(2), tag: "Xing", options: []mp3.SinkOption{mp3.WithProgress(func(mp3.Progress) {})}},
+	}
+	for _, test := range tests {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      100000,
+			Value:      0.5,
+		}
+		var (
+			info  mp3.EncodingInfo
+			stats mp3.Stats
+		)
+		options := append(test.options, mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }))
+		if test.stats {
+			options = append(options, mp3.WithStats(func(s mp3.Stats) { stats = s }))
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(f, test.bitRateMode, mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		f.Close()
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if bytes.HasPrefix(data, []byte("ID3")) {
+			_, data = parseID3v2(t, data)
+		}
+		// tag follows MPEG-1 stereo side info.
+		frames := splitFrames(t, data)
+		frame := frames[0]
+		xing := frame[4+32:]
+		if test.tag == "" {
+			if tag := string(xing[:4]); tag == "Xing" || tag == "Info" || bytes.Count(frame[4:], []byte{0}) == len(frame)-4 {
+				t.Errorf("%s: expected audio frame got: %q", test.name, tag)
+			}
+			continue
+		}
+		if tag := string(xing[:4]); tag != test.tag {
+			t.Fatalf("%s: expected tag: %q got: %q", test.name, test.tag, tag)
+		}
+		lame := xing[120:]
+		if !bytes.HasPrefix(lame, []byte("LAME")) {
+			t.Fatalf("%s: LAME extension is missing", test.name)
+		}
+		delay := int(lame[21])<<4 | int(lame[22])>>4
+		padding := int(lame[22]&0x0f)<<8 | int(lame[23])
+		if delay != info.Delay || padding != info.Padding {
+			t.Errorf("%s: expected delay: %d padding: %d got delay: %d padding: %d", test.name, info.Delay, info.Padding, delay, padding)
+		}
+		// rewritten tag frame isn't counted twice.
+		if test.stats && (stats.Frames != len(frames) || stats.Bytes != int64(len(data))) {
+			t.Errorf("%s: expected frames: %d bytes: %d got: %+v", test.name, len(frames), len(data), stats)
+		}
+	}
+}
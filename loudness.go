@@ -0,0 +1,214 @@
+package mp3
+
+import (
+	"math"
+	"sort"
+
+	"pipelined.dev/signal"
+)
+
+const (
+	// absoluteGate is the absolute gating threshold of EBU R128 in LUFS.
+	absoluteGate = -70
+	// relativeGate is the relative gating threshold in LU.
+	relativeGate = -10
+	// gatingSubBlocks is the number of 100ms sub-blocks in 400ms gating
+	// block.
+	gatingSubBlocks = 4
+	// rangeRelativeGate is the relative gating threshold of loudness
+	// range in LU, as defined by EBU Tech 3342.
+	rangeRelativeGate = -20
+	// shortTermSubBlocks is the number of 100ms sub-blocks in 3s
+	// short-term block.
+	shortTermSubBlocks = 30
+	// shortTermHop is the number of sub-blocks between starts of
+	// short-term blocks.
+	shortTermHop = 10
+)
+
+// biquad is the second order IIR filter in direct form II.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+// kWeighting returns high shelf and high pass filters of K-weighting for
+// the sample rate, as defined by ITU-R BS.1770.
+func kWeighting(sampleRate float64) (shelf, highPass biquad) {
+	f0, g, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / sampleRate)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf = biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / sampleRate)
+	a0 = 1 + k/q + k*k
+	highPass = biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// loudnessMeter measures integrated loudness, loudness range and sample
+// peak of the signal according to EBU R128. All channels are weighted
+// equally.
+type loudnessMeter struct {
+	shelf, highPass biquad
+	// filter state per channel.
+	state [][4]float64
+	// samples per 100ms sub-block.
+	subBlockLength int
+	subBlock       float64
+	subBlockPos    int
+	subBlocks      []float64
+	// mean square of gating blocks.
+	blocks []float64
+	// last sub-blocks of short-term block and the number of sub-blocks
+	// since the start of the last one.
+	shortTermSubBlocks []float64
+	shortTermPos       int
+	// mean square of short-term blocks.
+	shortTermBlocks []float64
+	peak            float64
+}
+
+func newLoudnessMeter(sampleRate signal.Frequency) *loudnessMeter {
+	shelf, highPass := kWeighting(float64(sampleRate))
+	return &loudnessMeter{
+		shelf:          shelf,
+		highPass:       highPass,
+		subBlockLength: int(sampleRate) / 10,
+	}
+}
+
+// write measures the signal.
+func (m *loudnessMeter) write(floats signal.Floating) {
+	channels := floats.Channels()
+	if m.state == nil {
+		m.state = make([][4]float64, channels)
+	}
+	for i := 0; i < floats.Length(); i++ {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			v := floats.Sample(i*channels + c)
+			if abs := math.Abs(v); abs > m.peak {
+				m.peak = abs
+			}
+			s := &m.state[c]
+			// shelf state is s[0:2], high pass state is s[2:4].
+			w := v - m.shelf.a1*s[0] - m.shelf.a2*s[1]
+			v = m.shelf.b0*w + m.shelf.b1*s[0] + m.shelf.b2*s[1]
+			s[1], s[0] = s[0], w
+			w = v - m.highPass.a1*s[2] - m.highPass.a2*s[3]
+			v = m.highPass.b0*w + m.highPass.b1*s[2] + m.highPass.b2*s[3]
+			s[3], s[2] = s[2], w
+			sum += v * v
+		}
+		m.subBlock += sum
+		if m.subBlockPos++; m.subBlockPos == m.subBlockLength {
+			m.addSubBlock()
+		}
+	}
+}
+
+// addSubBlock completes 100ms sub-block and the gating block that ends
+// with it.
+func (m *loudnessMeter) addSubBlock() {
+	m.subBlocks = append(m.subBlocks, m.subBlock/float64(m.subBlockLength))
+	m.addShortTermSubBlock(m.subBlock / float64(m.subBlockLength))
+	m.subBlock, m.subBlockPos = 0, 0
+	if len(m.subBlocks) < gatingSubBlocks {
+		return
+	}
+	var sum float64
+	for _, v := range m.subBlocks[len(m.subBlocks)-gatingSubBlocks:] {
+		sum += v
+	}
+	m.blocks = append(m.blocks, sum/gatingSubBlocks)
+	m.subBlocks = m.subBlocks[len(m.subBlocks)-gatingSubBlocks+1:]
+}
+
+// addShortTermSubBlock completes the short-term block every second.
+func (m *loudnessMeter) addShortTermSubBlock(v float64) {
+	m.shortTermSubBlocks = append(m.shortTermSubBlocks, v)
+	m.shortTermPos++
+	if len(m.shortTermSubBlocks) < shortTermSubBlocks || m.shortTermPos < shortTermHop {
+		return
+	}
+	m.shortTermBlocks = append(m.shortTermBlocks, mean(m.shortTermSubBlocks))
+	m.shortTermSubBlocks = m.shortTermSubBlocks[shortTermHop:]
+	m.shortTermPos = 0
+}
+
+// loudnessRange returns loudness range in LU as defined by EBU Tech
+// 3342. It's zero if the signal is shorter than short-term block or
+// below absolute gate.
+func (m *loudnessMeter) loudnessRange() float64 {
+	gated := gate(m.shortTermBlocks, absoluteGate)
+	if len(gated) == 0 {
+		return 0
+	}
+	gated = gate(gated, blockLoudness(mean(gated))+rangeRelativeGate)
+	values := make([]float64, len(gated))
+	for i, v := range gated {
+		values[i] = blockLoudness(v)
+	}
+	sort.Float64s(values)
+	percentile := func(p float64) float64 {
+		return values[int(math.Round(p*float64(len(values)-1)))]
+	}
+	return percentile(0.95) - percentile(0.10)
+}
+
+// loudness returns integrated loudness in LUFS. It's negative infinity
+// if the signal is below absolute gate.
+func (m *loudnessMeter) loudness() float64 {
+	blocks := m.blocks
+	if len(blocks) == 0 && m.subBlockPos+len(m.subBlocks) > 0 {
+		// signal is shorter than gating block.
+		var sum float64
+		for _, v := range m.subBlocks {
+			sum += v * float64(m.subBlockLength)
+		}
+		sum += m.subBlock
+		blocks = []float64{sum / float64(len(m.subBlocks)*m.subBlockLength+m.subBlockPos)}
+	}
+	gated := gate(blocks, absoluteGate)
+	if len(gated) == 0 {
+		return math.Inf(-1)
+	}
+	return blockLoudness(mean(gate(gated, blockLoudness(mean(gated))+relativeGate)))
+}
+
+// gate returns blocks with loudness above threshold.
+func gate(blocks []float64, threshold float64) []float64 {
+	var gated []float64
+	for _, v := range blocks {
+		if blockLoudness(v) > threshold {
+			gated = append(gated, v)
+		}
+	}
+	return gated
+}
+
+func blockLoudness(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+func mean(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
@@ -0,0 +1,119 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// truePeakTaps is the number of taps of every phase of true peak
+// interpolation filter.
+const truePeakTaps = 12
+
+// truePeakFilter is the polyphase FIR filter of 4x oversampling as
+// defined by ITU-R BS.1770.
+var truePeakFilter = [4][truePeakTaps]float64{
+	{
+		0.0017089843750, 0.0109863281250, -0.0196533203125, 0.0332031250000,
+		-0.0594482421875, 0.1373291015625, 0.9721679687500, -0.1022949218750,
+		0.0476074218750, -0.0266113281250, 0.0148925781250, -0.0083007812500,
+	},
+	{
+		-0.0291748046875, 0.0292968750000, -0.0517578125000, 0.0891113281250,
+		-0.1665039062500, 0.4650878906250, 0.7797851562500, -0.2003173828125,
+		0.1015625000000, -0.0582275390625, 0.0330810546875, -0.0189208984375,
+	},
+	{
+		-0.0189208984375, 0.0330810546875, -0.0582275390625, 0.1015625000000,
+		-0.2003173828125, 0.7797851562500, 0.4650878906250, -0.1665039062500,
+		0.0891113281250, -0.0517578125000, 0.0292968750000, -0.0291748046875,
+	},
+	{
+		-0.0083007812500, 0.0148925781250, -0.0266113281250, 0.0476074218750,
+		-0.1022949218750, 0.9721679687500, 0.1373291015625, -0.0594482421875,
+		0.0332031250000, -0.0196533203125, 0.0109863281250, 0.0017089843750,
+	},
+}
+
+// Loudness is the result of loudness scan.
+type Loudness struct {
+	// Integrated loudness in LUFS. It's negative infinity for silence.
+	Integrated float64
+	// Range is the loudness range in LU.
+	Range float64
+	// TruePeak is the maximum of 4x oversampled signal in dBTP. It's
+	// negative infinity for silence.
+	TruePeak float64
+}
+
+// LoudnessProcessor returns the processor that passes the signal through
+// unchanged and measures its integrated loudness and loudness range
+// according to EBU R128 and true peak according to ITU-R BS.1770. The
+// result is passed to fn when the pipe is flushed, so compliance with
+// the target loudness can be checked in the same pipe that encodes the
+// signal.
+func LoudnessProcessor(fn func(Loudness)) pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		if fn == nil {
+			return pipe.Processor{}, fmt.Errorf("%w: loudness function is nil", ErrInvalidParameter)
+		}
+		var (
+			m  = newLoudnessMeter(props.SampleRate)
+			tp = newTruePeakMeter(props.Channels)
+		)
+		return pipe.Processor{
+			SignalProperties: props,
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				m.write(in)
+				tp.write(in)
+				return signal.FloatingAsFloating(in, out), nil
+			},
+			FlushFunc: func(context.Context) error {
+				fn(Loudness{
+					Integrated: m.loudness(),
+					Range:      m.loudnessRange(),
+					TruePeak:   20 * math.Log10(math.Max(tp.peak, m.peak)),
+				})
+				return nil
+			},
+		}, nil
+	}
+}
+
+// truePeakMeter measures the peak of 4x oversampled signal.
+type truePeakMeter struct {
+	// last samples per channel, the most recent one is the last.
+	history [][truePeakTaps]float64
+	peak    float64
+}
+
+func newTruePeakMeter(channels int) *truePeakMeter {
+	return &truePeakMeter{
+		history: make([][truePeakTaps]float64, channels),
+	}
+}
+
+// write measures the signal.
+func (m *truePeakMeter) write(floats signal.Floating) {
+	channels := floats.Channels()
+	for i := 0; i < floats.Length(); i++ {
+		for c := 0; c < channels; c++ {
+			h := &m.history[c]
+			copy(h[:], h[1:])
+			h[truePeakTaps-1] = floats.Sample(i*channels + c)
+			for _, phase := range truePeakFilter {
+				var v float64
+				for j, coef := range phase {
+					v += coef * h[truePeakTaps-1-j]
+				}
+				if abs := math.Abs(v); abs > m.peak {
+					m.peak = abs
+				}
+			}
+		}
+	}
+}
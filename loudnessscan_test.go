@@ -0,0 +1,91 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestLoudnessProcessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   pipe.SourceAllocatorFunc
+		samples  int
+		expected mp3.Loudness
+	}{
+		{
+			name:    "sine",
+			source:  sineSource(997, 0.1, 10*44100),
+			samples: 10 * 44100,
+			// stereo 997 Hz sine of -20 dBFS is -20 LUFS.
+			expected: mp3.Loudness{Integrated: -20, Range: 0, TruePeak: -20},
+		},
+		{
+			name:     "silence",
+			source:   sineSource(997, 0, 44100),
+			samples:  44100,
+			expected: mp3.Loudness{Integrated: math.Inf(-1), Range: 0, TruePeak: math.Inf(-1)},
+		},
+	}
+	const tolerance = 0.1
+	for _, test := range tests {
+		var (
+			loudness mp3.Loudness
+			called   bool
+			sink     = mock.Sink{Discard: true}
+		)
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: test.source,
+				Processors: pipe.Processors(mp3.LoudnessProcessor(func(l mp3.Loudness) {
+					loudness, called = l, true
+				})),
+				Sink: sink.Sink(),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !called {
+			t.Fatalf("%s: expected loudness to be reported", test.name)
+		}
+		if sink.Counter.Samples != test.samples {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, test.samples, sink.Counter.Samples)
+		}
+		for _, v := range []struct {
+			name             string
+			expected, actual float64
+		}{
+			{"integrated", test.expected.Integrated, loudness.Integrated},
+			{"range", test.expected.Range, loudness.Range},
+			{"true peak", test.expected.TruePeak, loudness.TruePeak},
+		} {
+			if v.expected != v.actual && !(math.Abs(v.expected-v.actual) < tolerance) {
+				t.Errorf("%s: expected %s: %v got: %v", test.name, v.name, v.expected, v.actual)
+			}
+		}
+	}
+}
+
+func TestLoudnessProcessorNil(t *testing.T) {
+	_, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source:     sineSource(997, 0.1, 44100),
+			Processors: pipe.Processors(mp3.LoudnessProcessor(nil)),
+			Sink:       (&mock.Sink{Discard: true}).Sink(),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
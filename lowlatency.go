@@ -0,0 +1,59 @@
+package mp3
+
+import (
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+// Latency is the delay added by the Sink in low latency mode.
+type Latency struct {
+	// Buffer is the duration of the last consumed buffer. Buffer is
+	// accumulated by the pipe before it's passed to the Sink.
+	Buffer time.Duration
+	// Encoder is the duration of the input consumed by the encoder that
+	// isn't emitted as frames yet.
+	Encoder time.Duration
+}
+
+// Total returns the sum of latencies.
+func (l Latency) Total() time.Duration {
+	return l.Buffer + l.Encoder
+}
+
+// WithLowLatency configures the Sink for interactive streams, like
+// intercom or monitoring. Bit reservoir is disabled, so decoders can
+// play every frame as soon as it's received. Every frame is written into
+// the writer separately as soon as it's encoded and Xing header isn't
+// written. If fn isn't nil, it receives the latency after every buffer.
+// The pipe should use small buffers, like 256 samples, to keep the
+// end-to-end latency low.
+func WithLowLatency(fn func(Latency)) SinkOption {
+	return func(o *sinkOptions) {
+		o.disableReservoir = true
+		o.disableVBRTag = true
+		o.alignFrames = true
+		// frames are never grouped into a single write.
+		o.maxWriteBytes = 1
+		o.latency = fn
+	}
+}
+
+// reportLatency calls the latency function after every buffer is
+// consumed.
+func reportLatency(fn pipe.SinkFunc, samples func() int, stats *statsWriter, sampleRate signal.Frequency, report func(Latency)) pipe.SinkFunc {
+	return func(floats signal.Floating) error {
+		if err := fn(floats); err != nil {
+			return err
+		}
+		consumed := sampleRate.Duration(samples())
+		emitted := time.Duration(stats.seconds * float64(time.Second))
+		l := Latency{Buffer: sampleRate.Duration(floats.Length())}
+		if consumed > emitted {
+			l.Encoder = consumed - emitted
+		}
+		report(l)
+		return nil
+	}
+}
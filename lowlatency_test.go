@@ -0,0 +1,60 @@
+package mp3_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/signal"
+)
+
+func TestLowLatency(t *testing.T) {
+	var (
+		w         writesRecorder
+		latencies []mp3.Latency
+	)
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      44100,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		256,
+		pipe.Line{
+			Source: source.Source(),
+			Sink: mp3.Sink(&w, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithLowLatency(func(l mp3.Latency) {
+					latencies = append(latencies, l)
+				}),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(w.writes) < 44100/1152 {
+		t.Fatalf("expected writes: %d got: %d", 44100/1152, len(w.writes))
+	}
+	for i, write := range w.writes {
+		if frames := splitFrames(t, write); len(frames) != 1 {
+			t.Fatalf("write %d: expected single frame got: %d", i, len(frames))
+		}
+	}
+	if len(latencies) != (44100+255)/256 {
+		t.Fatalf("expected latency reports: %d got: %d", (44100+255)/256, len(latencies))
+	}
+	buffer := signal.Frequency(44100).Duration(256)
+	for i, l := range latencies[:len(latencies)-1] {
+		if l.Buffer != buffer || l.Encoder > 100*time.Millisecond || l.Total() != l.Buffer+l.Encoder {
+			t.Errorf("report %d: unexpected latency: %+v", i, l)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"pipelined.dev/pipe"
+)
+
+// MappedFile is the MP3 file mapped into memory. Sources of the file
+// decode the mapping without read syscalls and ReadAt is a copy, so
+// random access of seek-heavy workloads like audio editors is cheap. On
+// platforms without mmap support or if the package is built with nommap
+// tag, the file is read into memory instead.
+type MappedFile struct {
+	data   []byte
+	unmap  func() error
+	closed bool
+}
+
+// OpenMapped maps the file at path into memory. The file must not be
+// truncated while it's mapped.
+func OpenMapped(path string) (*MappedFile, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MappedFile{data: data, unmap: unmap}, nil
+}
+
+// Size returns the size of the file.
+func (f *MappedFile) Size() int64 {
+	return int64(len(f.data))
+}
+
+// Bytes returns content of the file. It must not be modified and isn't
+// valid after the file is closed.
+func (f *MappedFile) Bytes() []byte {
+	return f.data
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *MappedFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, errMappedFileClosed
+	}
+	if off < 0 {
+		return 0, errors.New("mp3: negative offset")
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Source returns the Source that decodes the file from the start. Any
+// number of sources of the same file can be used concurrently.
+func (f *MappedFile) Source(options ...SourceOption) pipe.SourceAllocatorFunc {
+	return Source(bytes.NewReader(f.data), options...)
+}
+
+// Close unmaps the file. Sources of the file must not be used after
+// that.
+func (f *MappedFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	f.data = nil
+	if f.unmap == nil {
+		return nil
+	}
+	return f.unmap()
+}
+
+var errMappedFileClosed = errors.New("mp3: mapped file is closed")
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly) || nommap
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly nommap
+
+package mp3
+
+import "os"
+
+// mapFile reads the file into memory.
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	return data, nil, err
+}
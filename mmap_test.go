@@ -0,0 +1,88 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestMappedFile(t *testing.T) {
+	data, err := os.ReadFile(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f, err := mp3.OpenMapped(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	if f.Size() != int64(len(data)) || !bytes.Equal(f.Bytes(), data) {
+		t.Fatalf("expected content of %d bytes got: %d", len(data), f.Size())
+	}
+
+	// random access.
+	p := make([]byte, 100)
+	if n, err := f.ReadAt(p, 1000); err != nil || n != len(p) || !bytes.Equal(p, data[1000:1100]) {
+		t.Errorf("unexpected read at 1000: %d %v", n, err)
+	}
+	if n, err := f.ReadAt(p, int64(len(data)-10)); err != io.EOF || n != 10 {
+		t.Errorf("expected %d bytes with EOF got: %d %v", 10, n, err)
+	}
+
+	// sources of the same file decode concurrently.
+	sinks := make([]mock.Sink, 2)
+	lines := make([]pipe.Line, len(sinks))
+	for i := range lines {
+		lines[i] = pipe.Line{Source: f.Source(), Sink: sinks[i].Sink()}
+	}
+	pp, err := pipe.New(bufferSize, lines...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(pp.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := range sinks {
+		if sinks[i].Counter.Samples != mp3Samples {
+			t.Errorf("sink %d: expected %d samples got: %d", i, mp3Samples, sinks[i].Counter.Samples)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := f.ReadAt(p, 0); err == nil {
+		t.Errorf("expected error of closed file")
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("unexpected error of second close: %v", err)
+	}
+}
+
+func TestMappedFileEmpty(t *testing.T) {
+	tmp, err := os.CreateTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	f, err := mp3.OpenMapped(tmp.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Size() != 0 {
+		t.Errorf("expected empty file got: %d bytes", f.Size())
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := mp3.OpenMapped(tmp.Name() + ".missing"); err == nil {
+		t.Errorf("expected error of missing file")
+	}
+}
@@ -0,0 +1,43 @@
+//go:build (linux || darwin || freebsd || netbsd || openbsd || dragonfly) && !nommap
+// +build linux darwin freebsd netbsd openbsd dragonfly
+// +build !nommap
+
+package mp3
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file read-only and returns the function that unmaps
+// it. Empty file isn't mapped.
+func mapFile(path string) ([]byte, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// mapping stays valid after the file is closed.
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		return nil, nil, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("error mapping file: size %d is too large", size)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error mapping file: %w", err)
+	}
+	return data, func() error {
+		if err := syscall.Munmap(data); err != nil {
+			return fmt.Errorf("error unmapping file: %w", err)
+		}
+		return nil
+	}, nil
+}
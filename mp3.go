@@ -3,14 +3,12 @@
 package mp3
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 
 	mp3 "github.com/hajimehoshi/go-mp3"
-	"github.com/viert/lame"
 
 	"pipelined.dev/pipe"
 	"pipelined.dev/pipe/mutable"
@@ -18,22 +16,37 @@ import (
 )
 
 // Source allows to read mp3 data.
-func Source(r io.Reader) pipe.SourceAllocatorFunc {
+func Source(r io.Reader, options ...SourceOption) pipe.SourceAllocatorFunc {
+	var o sourceOptions
+	for _, option := range options {
+		option(&o)
+	}
 	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
-		decoder, err := mp3.NewDecoder(r)
+		if o.err != nil {
+			return pipe.Source{}, o.err
+		}
+		// tags aren't needed to decode audio.
+		audio, err := skipID3v2(r)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		decoder, err := mp3.NewDecoder(audio)
 		if err != nil {
 			return pipe.Source{}, fmt.Errorf("error creating MP3 decoder: %w", err)
 		}
 
 		// current decoder always provides stereo, so constant.
 		channels := 2
-		ints := signal.Allocator{
-			Channels: channels,
-			Capacity: bufferSize,
-			Length:   bufferSize,
-		}.Int16(signal.BitDepth16)
+		var feed *fingerprintFeed
+		if o.fingerprint != nil {
+			if feed, err = o.fingerprint.start(signal.Frequency(decoder.SampleRate()), channels); err != nil {
+				return pipe.Source{}, err
+			}
+		}
+		pcm := newPooledPCM(channels, o.chunkSize(bufferSize))
 		return pipe.Source{
-				SourceFunc: source(decoder, ints),
+				SourceFunc: source(decoder, pcm, feed),
+				FlushFunc:  pcm.flusher(nil),
 				SignalProperties: pipe.SignalProperties{
 					Channels:   channels,
 					SampleRate: signal.Frequency(decoder.SampleRate()),
@@ -43,36 +56,53 @@ func Source(r io.Reader) pipe.SourceAllocatorFunc {
 	}
 }
 
-func source(decoder *mp3.Decoder, ints signal.Signed) pipe.SourceFunc {
+func source(decoder *mp3.Decoder, pcm *pooledPCM, feed *fingerprintFeed) pipe.SourceFunc {
 	return func(floats signal.Floating) (int, error) {
-		var (
-			sample int16
-			read   int // total number of read samples
-		)
-		for read < ints.Len() {
-			if err := binary.Read(decoder, binary.LittleEndian, &sample); err != nil {
-				// because EOF returns only when nothing was read.
-				if err == io.EOF {
-					break // no more bytes available
+		buf := pcm.get()
+		// decoder is read in chunks of the PCM buffer until floats are
+		// full or the stream ends.
+		var read int // total number of read samples
+		for read < floats.Len() {
+			data := buf.bytes
+			if left := (floats.Len() - read) * bytesPerSample; left < len(data) {
+				data = data[:left]
+			}
+			n, err := io.ReadFull(decoder, data)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return 0, fmt.Errorf("error reading MP3 data: %w", err)
+			}
+			chunk := n / bytesPerSample
+			samples := buf.floats[:chunk]
+			if feed == nil {
+				decodeFloats(samples, data[:n])
+			} else {
+				ints := buf.ints[:chunk]
+				decodeInts(ints, data[:n])
+				if err := feed.feed(ints, floats.Channels()); err != nil {
+					return 0, err
 				}
-				return read, fmt.Errorf("error reading MP3 data: %w", err)
+				intsToFloats(samples, ints)
+			}
+			writeFloats(floats, read, samples)
+			read += chunk
+			if n < len(data) {
+				break
 			}
-			ints.SetSample(read, int64(sample))
-			read++
 		}
 
 		// nothing was read, source is done.
 		if read == 0 {
+			if err := feed.finish(); err != nil {
+				return 0, err
+			}
 			return 0, io.EOF
 		}
-		if read != ints.Len() {
-			return signal.SignedAsFloating(ints.Slice(0, signal.ChannelLength(read, ints.Channels())), floats), nil
-		}
-		return signal.SignedAsFloating(ints, floats), nil
+		return signal.ChannelLength(read, floats.Channels()), nil
 	}
 }
 
-// ChannelMode determines how channel data will be encoded.
+// ChannelMode determines how channel data will be encoded. Single-channel
+// input is always encoded as Mono.
 type ChannelMode int
 
 const (
@@ -96,11 +126,12 @@ const (
 type (
 	// BitRateMode determines which VBR setting is going to be used.
 	BitRateMode interface {
-		apply(*lame.LameWriter)
+		apply(*lameEncoder)
+		validate(sampleRate signal.Frequency) error
 		fmt.Stringer
 	}
 
-	// VBR uses variable bit rate. Values: [0..10]
+	// VBR uses variable bit rate. Values: [0..9]
 	VBR int
 
 	// ABR uses average bit rate. Values: [8..320]
@@ -118,98 +149,314 @@ type EncodingQuality int
 // encoding algorithm.
 const DefaultEncodingQuality EncodingQuality = -1
 
-func setQuality(encoder *lame.LameWriter, q EncodingQuality) {
+// bytes per sample of 16 bit PCM.
+const bytesPerSample = 2
+
+// ErrInvalidParameter is returned when the encoder parameters are not
+// valid.
+var ErrInvalidParameter = errors.New("invalid encoder parameter")
+
+func setQuality(encoder *lameEncoder, q EncodingQuality) {
 	if q == DefaultEncodingQuality {
 		return
 	}
+	encoder.setQuality(int(q))
+}
 
-	switch {
-	case q < 0:
-		encoder.Encoder.SetQuality(0)
-	case q > 9:
-		encoder.Encoder.SetQuality(9)
-	default:
-		encoder.Encoder.SetQuality(int(q))
+func (q EncodingQuality) validate() error {
+	if q == DefaultEncodingQuality || (q >= 0 && q <= 9) {
+		return nil
 	}
+	return fmt.Errorf("%w: encoding quality %d is out of range [0..9]", ErrInvalidParameter, q)
 }
 
 // Sink allows to write mp3 files. Lame uses
-// 5 as default value if not provided.
-func Sink(w io.Writer, brm BitRateMode, cm ChannelMode, eq EncodingQuality) pipe.SinkAllocatorFunc {
+// 5 as default value if not provided. If w implements io.WriteSeeker,
+// Xing/Info frame is written at the start of the stream on flush.
+func Sink(w io.Writer, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
 	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
-		encoder := lame.NewWriter(w)
-		brm.apply(encoder)
-		setQuality(encoder, eq)
-		setChannelMode(encoder, cm)
-
-		encoder.Encoder.SetInSamplerate(int(props.SampleRate))
-		encoder.Encoder.SetNumChannels(int(props.Channels))
-		encoder.Encoder.InitParams()
-		ints := signal.Allocator{
-			Channels: props.Channels,
-			Capacity: bufferSize,
-			Length:   bufferSize,
-		}.Int16(signal.BitDepth16)
+		cfg, err := newEncoderConfig(brm, cm, eq, props, options)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		// lame writes its own tag unless the stream is deterministic and
+		// custom encoders are responsible for it.
+		out, err := newOutput(w, cfg, cfg.opts.newEncoder == nil && (cfg.opts.backend != Lame || cfg.opts.deterministic))
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		se, err := newSwitchEncoder(cfg, out.w)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		counter := pcmCounter{Writer: se}
+		reconfigure := se.reconfigure
+		agg := newAggregator(se, cfg.opts.aggregation, cfg.channels)
+		if agg != nil {
+			counter.Writer = agg
+			reconfigure = agg.reconfigurer(reconfigure)
+		}
+		samples := func() int { return counter.samples(cfg.channels) }
+		if c := cfg.opts.controller; c != nil {
+			c.bind(mctx, reconfigure)
+		}
+		cfg.opts.bind(mctx)
+		pad := newPadder(&counter, cfg)
+		trim := newTrimmer(&cfg)
+		flush := encoderFlusher(se, &counter, out, cfg)
+		if agg != nil {
+			flush = agg.flusher(flush, cfg.opts)
+		}
+		pcm := newPooledPCM(cfg.channels, cfg.opts.chunkSize(bufferSize))
 		return pipe.Sink{
-			SinkFunc:  sink(encoder, ints),
-			FlushFunc: encoderFlusher(encoder),
+			Context:   mctx,
+			SinkFunc:  out.sinkFunc(pad.sinkFunc(trim.sinkFunc(cfg.sinkFunc(&counter, pcm, bufferSize))), samples),
+			FlushFunc: pcm.flusher(pad.flusher(flush, cfg.opts)),
 		}, nil
 	}
 }
 
-func sink(encoder *lame.LameWriter, ints signal.Signed) pipe.SinkFunc {
-	bytesBuf := bytes.NewBuffer(make([]byte, 0, ints.Len()))
+// encoderConfig contains validated parameters of the encoder.
+type encoderConfig struct {
+	brm        BitRateMode
+	cm         ChannelMode
+	eq         EncodingQuality
+	opts       sinkOptions
+	sampleRate signal.Frequency
+	// number of encoded channels.
+	channels int
+	downmix  DownmixMatrix
+}
+
+func newEncoderConfig(brm BitRateMode, cm ChannelMode, eq EncodingQuality, props pipe.SignalProperties, options []SinkOption) (encoderConfig, error) {
+	var opts sinkOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := opts.validate(); err != nil {
+		return encoderConfig{}, err
+	}
+	opts.resolveTelephony(props.Channels)
+	if err := opts.resolveSampleRate(props.SampleRate); err != nil {
+		return encoderConfig{}, err
+	}
+	if opts.deterministic && opts.outSampleRate == 0 {
+		opts.outSampleRate = props.SampleRate
+	}
+	// controller splices encoders at the frame grid of the input.
+	if opts.controller != nil && opts.newEncoder == nil && opts.outSampleRate == 0 {
+		opts.outSampleRate = props.SampleRate
+	}
+	matrix, err := opts.resolveDownmix(props.Channels)
+	if err != nil {
+		return encoderConfig{}, err
+	}
+	channels := props.Channels
+	if matrix != nil {
+		channels = len(matrix)
+	}
+	if err := validate(brm, cm, eq, opts.outSampleRate, channels); err != nil {
+		return encoderConfig{}, err
+	}
+	if opts.telephony {
+		if err := validateTelephony(brm, cm, opts.outSampleRate); err != nil {
+			return encoderConfig{}, err
+		}
+	}
+	cfg := encoderConfig{
+		brm:        brm,
+		cm:         cm,
+		eq:         eq,
+		opts:       opts,
+		sampleRate: props.SampleRate,
+		channels:   channels,
+		downmix:    matrix,
+	}
+	if err := cfg.validateBackend(); err != nil {
+		return encoderConfig{}, err
+	}
+	return cfg, nil
+}
+
+// validateBackend checks that configuration is supported by the backend.
+func (c encoderConfig) validateBackend() error {
+	if c.opts.newEncoder != nil {
+		return nil
+	}
+	switch c.opts.backend {
+	case Lame:
+		if !Lame.Available() {
+			return fmt.Errorf("%w: %v", ErrBackendUnavailable, Lame)
+		}
+	case Shine:
+		if !Shine.Available() {
+			return fmt.Errorf("%w: %v", ErrBackendUnavailable, Shine)
+		}
+		return validateShine(c)
+	default:
+		return fmt.Errorf("%w: unknown backend %d", ErrInvalidParameter, c.opts.backend)
+	}
+	return nil
+}
+
+// newEncoder returns initialized encoder that writes into w.
+func (c encoderConfig) newEncoder(w io.Writer) (Encoder, error) {
+	encoder := c.opts.encoder()
+	if err := encoder.Init(w, c.params()); err != nil {
+		return nil, fmt.Errorf("error creating MP3 encoder: %w", err)
+	}
+	return encoder, nil
+}
+
+// params returns parameters to initialize the encoder.
+func (c encoderConfig) params() EncoderParams {
+	return EncoderParams{
+		BitRateMode:   c.brm,
+		ChannelMode:   c.cm.forChannels(c.channels),
+		Quality:       c.eq,
+		SampleRate:    c.sampleRate,
+		OutSampleRate: c.opts.outSampleRate,
+		Channels:      c.channels,
+	}
+}
+
+// sinkFunc returns the function that converts the signal into PCM and
+// writes it to w. PCM buffer must be released on flush.
+func (c encoderConfig) sinkFunc(w io.Writer, pcm *pooledPCM, bufferSize int) pipe.SinkFunc {
+	fn := sink(w, pcm)
+	if c.downmix != nil {
+		return downmix(c.downmix, bufferSize, fn)
+	}
+	return fn
+}
+
+// validate checks the encoder parameters before encoder is initialized.
+// Output sample rate is zero if lame picks it.
+func validate(brm BitRateMode, cm ChannelMode, eq EncodingQuality, outSampleRate signal.Frequency, channels int) error {
+	if brm == nil {
+		return fmt.Errorf("%w: bit rate mode is not set", ErrInvalidParameter)
+	}
+	if err := brm.validate(outSampleRate); err != nil {
+		return err
+	}
+	if err := eq.validate(); err != nil {
+		return err
+	}
+	return cm.validate(channels)
+}
+
+func sink(encoder io.Writer, pcm *pooledPCM) pipe.SinkFunc {
 	return func(floats signal.Floating) error {
-		if n := signal.FloatingAsSigned(floats, ints); n != ints.Length() {
-			ints = ints.Slice(0, n)
-			// defer because it must be done after write
-			defer func() {
-				ints = ints.Slice(0, ints.Capacity())
-			}()
-		}
-		bytesBuf.Reset()
-		for i := 0; i < ints.Len(); i++ {
-			if err := binary.Write(bytesBuf, binary.LittleEndian, int16(ints.Sample(i))); err != nil {
-				return fmt.Errorf("error writing binary data: %w", err)
+		buf := pcm.get()
+		// floats are converted and written in chunks of the PCM buffer.
+		for written := 0; written < floats.Len(); {
+			samples := buf.floats
+			if left := floats.Len() - written; left < len(samples) {
+				samples = samples[:left]
 			}
-		}
-		if _, err := encoder.Write(bytesBuf.Bytes()); err != nil {
-			return fmt.Errorf("error writing MP3 buffer: %w", err)
+			readFloats(floats, written, samples)
+			data := buf.bytes[:len(samples)*bytesPerSample]
+			encodeFloats(data, samples)
+			if _, err := encoder.Write(data); err != nil {
+				return fmt.Errorf("error writing MP3 buffer: %w", err)
+			}
+			written += len(samples)
 		}
 		return nil
 	}
 }
 
-func encoderFlusher(encoder *lame.LameWriter) pipe.FlushFunc {
-	return func(context.Context) error {
-		if err := encoder.Close(); err != nil {
+func encoderFlusher(encoder Encoder, counter *pcmCounter, out output, cfg encoderConfig) pipe.FlushFunc {
+	return func(ctx context.Context) error {
+		out.stop()
+		if cfg.opts.discard(ctx) {
+			// encoder is flushed only to release resources.
+			out.discard()
+			encoder.Flush()
+			return nil
+		}
+		if err := encoder.Flush(); err != nil {
 			return fmt.Errorf("error flushing WAV encoder: %w", err)
 		}
+		info := encodingInfo(encoder, counter.samples(cfg.channels))
+		if err := out.finish(info); err != nil {
+			return err
+		}
+		if cfg.opts.info != nil {
+			cfg.opts.info(info)
+		}
 		return nil
 	}
 }
 
-func (vbr VBR) apply(writer *lame.LameWriter) {
-	writer.Encoder.SetVBR(lame.VBR_MTRH)
-	writer.Encoder.SetVBRQuality(int(vbr))
+// pcmCounter counts bytes of PCM written into the encoder.
+type pcmCounter struct {
+	io.Writer
+	n int
+}
+
+func (c *pcmCounter) Write(p []byte) (int, error) {
+	n, err := c.Writer.Write(p)
+	c.n += n
+	return n, err
+}
+
+// samples returns the number of written samples per channel.
+func (c *pcmCounter) samples(channels int) int {
+	return c.n / (bytesPerSample * channels)
+}
+
+func (vbr VBR) apply(encoder *lameEncoder) {
+	encoder.setVBR(vbrMTRH)
+	encoder.setVBRQuality(int(vbr))
+}
+
+func (vbr VBR) validate(signal.Frequency) error {
+	if vbr < 0 || vbr > 9 {
+		return fmt.Errorf("%w: VBR quality %d is out of range [0..9]", ErrInvalidParameter, vbr)
+	}
+	return nil
 }
 
 func (vbr VBR) String() string {
 	return fmt.Sprintf("vbr-%d", vbr)
 }
 
-func (abr ABR) apply(writer *lame.LameWriter) {
-	writer.Encoder.SetVBR(lame.VBR_ABR)
-	writer.Encoder.SetVBRAverageBitRate(int(abr))
+func (abr ABR) apply(encoder *lameEncoder) {
+	encoder.setVBR(vbrABR)
+	encoder.setVBRMeanBitRate(int(abr))
+}
+
+func (abr ABR) validate(signal.Frequency) error {
+	if abr < 8 || abr > 320 {
+		return fmt.Errorf("%w: ABR %d kbps is out of range [8..320]", ErrInvalidParameter, abr)
+	}
+	return nil
 }
 
 func (abr ABR) String() string {
 	return fmt.Sprintf("abr-%d", abr)
 }
 
-func (cbr CBR) apply(writer *lame.LameWriter) {
-	writer.Encoder.SetVBR(lame.VBR_OFF)
-	writer.Encoder.SetBitrate(int(cbr))
+func (cbr CBR) apply(encoder *lameEncoder) {
+	encoder.setVBR(vbrOff)
+	encoder.setBitRate(int(cbr))
+}
+
+func (cbr CBR) validate(sampleRate signal.Frequency) error {
+	if cbr < 8 || cbr > 320 {
+		return fmt.Errorf("%w: CBR %d kbps is out of range [8..320]", ErrInvalidParameter, cbr)
+	}
+	if sampleRate == 0 {
+		if !contains(mpeg1BitRates[:], int(cbr)) && !contains(mpeg2BitRates[:], int(cbr)) {
+			return fmt.Errorf("%w: CBR %d kbps is not a valid MP3 bit rate", ErrInvalidParameter, cbr)
+		}
+		return nil
+	}
+	if !contains(bitRates(sampleRate), int(cbr)) {
+		return fmt.Errorf("%w: CBR %d kbps is not allowed for %v Hz", ErrInvalidParameter, cbr, sampleRate)
+	}
+	return nil
 }
 
 func (cbr CBR) String() string {
@@ -217,15 +464,36 @@ func (cbr CBR) String() string {
 }
 
 // setMode assigns mode to the sink.
-func setChannelMode(e *lame.LameWriter, cm ChannelMode) {
+func setChannelMode(e *lameEncoder, cm ChannelMode) {
 	switch cm {
 	case JointStereo:
-		e.Encoder.SetMode(lame.JOINT_STEREO)
+		e.setMode(modeJointStereo)
 	case Stereo:
-		e.Encoder.SetMode(lame.STEREO)
+		e.setMode(modeStereo)
 	case Mono:
-		e.Encoder.SetMode(lame.MONO)
+		e.setMode(modeMono)
+	}
+}
+
+func (cm ChannelMode) validate(channels int) error {
+	switch cm {
+	case Mono, Stereo, JointStereo:
+	default:
+		return fmt.Errorf("%w: unknown channel mode %d", ErrInvalidParameter, cm)
 	}
+	if channels < 1 || channels > 2 {
+		return fmt.Errorf("%w: %d channels are not supported", ErrInvalidParameter, channels)
+	}
+	return nil
+}
+
+// forChannels returns the mode that is used to encode the provided number
+// of channels. Single channel is always encoded as genuine mono.
+func (cm ChannelMode) forChannels(channels int) ChannelMode {
+	if channels == 1 {
+		return Mono
+	}
+	return cm
 }
 
 func (cm ChannelMode) String() string {
@@ -239,3 +507,26 @@ func (cm ChannelMode) String() string {
 	}
 	return "Unknown"
 }
+
+// bit rates in kbps allowed by MPEG-1 and by MPEG-2/MPEG-2.5 Layer III.
+var (
+	mpeg1BitRates = [...]int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mpeg2BitRates = [...]int{8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+)
+
+// bitRates returns allowed bit rates for the output sample rate.
+func bitRates(sampleRate signal.Frequency) []int {
+	if sampleRate >= 32000 {
+		return mpeg1BitRates[:]
+	}
+	return mpeg2BitRates[:]
+}
+
+func contains(values []int, v int) bool {
+	for i := range values {
+		if values[i] == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,20 @@
 package mp3_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"testing"
 
 	"pipelined.dev/audio/mp3"
 	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
 )
 
 const (
@@ -17,12 +24,23 @@ const (
 	out        = "_testdata/out"
 )
 
+// skipWithoutLame skips the test when the package is built without
+// libmp3lame.
+func skipWithoutLame(t testing.TB) {
+	t.Helper()
+	if !mp3.Lame.Available() {
+		t.Skip("lame backend is not available")
+	}
+}
+
 func TestMp3(t *testing.T) {
+	skipWithoutLame(t)
 	tests := []struct {
 		inFile      string
 		bitRateMode mp3.BitRateMode
 		channelMode mp3.ChannelMode
 		quality     mp3.EncodingQuality
+		options     []mp3.SinkOption
 	}{
 		{
 			inFile:      sample,
@@ -90,6 +108,13 @@ func TestMp3(t *testing.T) {
 			bitRateMode: mp3.VBR(0),
 			quality:     3,
 		},
+		{
+			inFile:      sample,
+			channelMode: mp3.JointStereo,
+			bitRateMode: mp3.CBR(192),
+			quality:     mp3.DefaultEncodingQuality,
+			options:     []mp3.SinkOption{mp3.WithStrictISO()},
+		},
 	}
 
 	for i, test := range tests {
@@ -107,11 +132,12 @@ func TestMp3(t *testing.T) {
 					test.bitRateMode,
 					test.channelMode,
 					test.quality,
+					test.options...,
 				),
 			},
 		)
 		if err != nil {
-			t.Errorf("unexpected error: %v", err)
+			t.Fatalf("unexpected error: %v", err)
 		}
 
 		err = pipe.Wait(p.Start(context.Background()))
@@ -123,3 +149,344 @@ func TestMp3(t *testing.T) {
 		_ = outFile.Close()
 	}
 }
+
+func TestSource(t *testing.T) {
+	var expected signal.Floating
+	for _, size := range []int{1, 333, bufferSize, 4096} {
+		inFile, err := os.Open(sample)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sink := mock.Sink{}
+		p, err := pipe.New(
+			size,
+			pipe.Line{
+				Source: mp3.Source(inFile),
+				Sink:   sink.Sink(),
+			},
+		)
+		if err != nil {
+			t.Fatalf("buffer %d: unexpected error: %v", size, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("buffer %d: unexpected error: %v", size, err)
+		}
+		_ = inFile.Close()
+		if sink.Counter.Samples != mp3Samples {
+			t.Errorf("buffer %d: expected samples: %d got: %d", size, mp3Samples, sink.Counter.Samples)
+		}
+		// decoded signal doesn't depend on the buffer size.
+		if expected == nil {
+			expected = sink.Values
+			continue
+		}
+		for i := 0; i < expected.Len(); i++ {
+			if expected.Sample(i) != sink.Values.Sample(i) {
+				t.Fatalf("buffer %d: expected sample %d: %v got: %v", size, i, expected.Sample(i), sink.Values.Sample(i))
+			}
+		}
+	}
+}
+
+func TestSinkCRC(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		options   []mp3.SinkOption
+		protected bool
+	}{
+		{
+			protected: false,
+		},
+		{
+			options:   []mp3.SinkOption{mp3.WithCRC()},
+			protected: true,
+		},
+	}
+
+	for _, test := range tests {
+		encoded := encode(t, test.options...)
+		if len(encoded) < 4 || encoded[0] != 0xff {
+			t.Fatalf("output doesn't start with frame header")
+		}
+		// protection bit is zero when frame is protected by CRC.
+		if protected := encoded[1]&0x01 == 0; protected != test.protected {
+			t.Errorf("unexpected protection: %v expected: %v", protected, test.protected)
+		}
+	}
+}
+
+func TestSinkWithoutBitReservoir(t *testing.T) {
+	skipWithoutLame(t)
+	encoded := encode(t, mp3.WithoutBitReservoir())
+	frames := splitFrames(t, encoded)
+	if len(frames) == 0 {
+		t.Fatalf("no frames encoded")
+	}
+	for i, frame := range frames {
+		// MPEG-1 main_data_begin is the first 9 bits of side info.
+		sideInfo := frame[4:]
+		if frame[1]&0x01 == 0 {
+			sideInfo = frame[6:]
+		}
+		if mainDataBegin := int(sideInfo[0])<<1 | int(sideInfo[1]>>7); mainDataBegin != 0 {
+			t.Fatalf("frame %d references bit reservoir: %d", i, mainDataBegin)
+		}
+	}
+}
+
+func TestSinkOutputSampleRate(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		sampleRate signal.Frequency
+		index      byte
+		err        bool
+	}{
+		{sampleRate: 32000, index: 2},
+		{sampleRate: 48000, index: 1},
+		{sampleRate: 44100, index: 0},
+		{sampleRate: 40000, err: true},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		_, err := mp3.Sink(
+			&out,
+			mp3.CBR(128),
+			mp3.JointStereo,
+			mp3.DefaultEncodingQuality,
+			mp3.WithOutputSampleRate(test.sampleRate),
+		)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+		if test.err {
+			if err == nil {
+				t.Errorf("expected error for sample rate: %v", test.sampleRate)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		encoded := encode(t, mp3.WithOutputSampleRate(test.sampleRate))
+		if index := encoded[2] >> 2 & 0x03; index != test.index {
+			t.Errorf("unexpected sample rate index: %d expected: %d", index, test.index)
+		}
+	}
+}
+
+func TestSinkSampleRatePolicy(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		sampleRate signal.Frequency
+		options    []mp3.SinkOption
+		err        bool
+	}{
+		{sampleRate: 44100},
+		{sampleRate: 96000, err: true},
+		{
+			sampleRate: 96000,
+			options:    []mp3.SinkOption{mp3.WithSampleRatePolicy(mp3.ResampleToNearest)},
+		},
+		{
+			sampleRate: 96000,
+			options:    []mp3.SinkOption{mp3.WithOutputSampleRate(48000)},
+		},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		_, err := mp3.Sink(
+			&out,
+			mp3.CBR(128),
+			mp3.JointStereo,
+			mp3.DefaultEncodingQuality,
+			test.options...,
+		)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: test.sampleRate, Channels: 2})
+		if test.err {
+			var srErr *mp3.UnsupportedSampleRateError
+			if !errors.As(err, &srErr) {
+				t.Errorf("expected sample rate error, got: %v", err)
+			} else if srErr.SampleRate != test.sampleRate {
+				t.Errorf("unexpected error sample rate: %v", srErr.SampleRate)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestSinkValidation(t *testing.T) {
+	tests := []struct {
+		bitRateMode mp3.BitRateMode
+		channelMode mp3.ChannelMode
+		quality     mp3.EncodingQuality
+		channels    int
+		options     []mp3.SinkOption
+		err         bool
+	}{
+		{bitRateMode: mp3.CBR(320), channelMode: mp3.JointStereo, channels: 2},
+		{bitRateMode: mp3.CBR(144), channelMode: mp3.JointStereo, channels: 2},
+		{bitRateMode: mp3.CBR(144), channelMode: mp3.JointStereo, channels: 2, options: []mp3.SinkOption{mp3.WithOutputSampleRate(44100)}, err: true},
+		{bitRateMode: mp3.CBR(144), channelMode: mp3.JointStereo, channels: 2, options: []mp3.SinkOption{mp3.WithOutputSampleRate(22050)}},
+		{bitRateMode: mp3.CBR(320), channelMode: mp3.JointStereo, channels: 2, options: []mp3.SinkOption{mp3.WithOutputSampleRate(22050)}, err: true},
+		{bitRateMode: mp3.CBR(100), channelMode: mp3.JointStereo, channels: 2, err: true},
+		{bitRateMode: mp3.CBR(400), channelMode: mp3.JointStereo, channels: 2, err: true},
+		{bitRateMode: mp3.ABR(100), channelMode: mp3.JointStereo, channels: 2},
+		{bitRateMode: mp3.ABR(4), channelMode: mp3.JointStereo, channels: 2, err: true},
+		{bitRateMode: mp3.VBR(10), channelMode: mp3.JointStereo, channels: 2, err: true},
+		{bitRateMode: mp3.VBR(-1), channelMode: mp3.JointStereo, channels: 2, err: true},
+		{bitRateMode: mp3.VBR(2), quality: 10, channelMode: mp3.JointStereo, channels: 2, err: true},
+		{bitRateMode: mp3.VBR(2), quality: -2, channelMode: mp3.JointStereo, channels: 2, err: true},
+		{bitRateMode: mp3.VBR(2), channelMode: mp3.ChannelMode(10), channels: 2, err: true},
+		{bitRateMode: mp3.VBR(2), channelMode: mp3.JointStereo, channels: 3, err: true},
+		{bitRateMode: mp3.VBR(2), channelMode: mp3.Mono, channels: 1},
+		{bitRateMode: mp3.VBR(2), channelMode: mp3.Stereo, channels: 1},
+		{bitRateMode: mp3.VBR(2), channelMode: mp3.Mono, channels: 2},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		_, err := mp3.Sink(
+			&out,
+			test.bitRateMode,
+			test.channelMode,
+			test.quality,
+			test.options...,
+		)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: test.channels})
+		if test.err != errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("unexpected error for %v: %v", test.bitRateMode, err)
+		}
+	}
+}
+
+func TestSinkMonoInput(t *testing.T) {
+	skipWithoutLame(t)
+	for _, cm := range []mp3.ChannelMode{mp3.Mono, mp3.Stereo, mp3.JointStereo} {
+		source := mock.Source{
+			Channels:   1,
+			SampleRate: 44100,
+			Limit:      44100,
+			Value:      0.5,
+		}
+		var out bytes.Buffer
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(&out, mp3.CBR(128), cm, mp3.DefaultEncodingQuality),
+			},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i, frame := range splitFrames(t, out.Bytes()) {
+			// channel mode bits 11 stand for single channel.
+			if mode := frame[3] >> 6; mode != 0x03 {
+				t.Fatalf("%v: frame %d is not mono: %d", cm, i, mode)
+			}
+		}
+	}
+}
+
+// splitFrames splits MPEG-1 Layer III stream into frames.
+func splitFrames(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	bitRates := [...]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	sampleRates := [...]int{44100, 48000, 32000}
+	var frames [][]byte
+	for len(data) >= 4 {
+		if data[0] != 0xff || data[1]&0xfe != 0xfa {
+			t.Fatalf("invalid frame header: % x", data[:4])
+		}
+		size := 144*bitRates[data[2]>>4]*1000/sampleRates[data[2]>>2&0x03] + int(data[2]>>1&0x01)
+		if size > len(data) {
+			t.Fatalf("truncated frame")
+		}
+		frames = append(frames, data[:size])
+		data = data[size:]
+	}
+	return frames
+}
+
+// encode sample file with provided options.
+func encode(t *testing.T, options ...mp3.SinkOption) []byte {
+	t.Helper()
+	inFile, err := os.Open(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer inFile.Close()
+
+	var out bytes.Buffer
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3.Source(inFile),
+			Sink: mp3.Sink(
+				&out,
+				mp3.CBR(128),
+				mp3.JointStereo,
+				mp3.DefaultEncodingQuality,
+				options...,
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestSourceSkipsID3v2(t *testing.T) {
+	artwork := append(pngImage(1, 1), make([]byte, 4<<20)...)
+	tagged, err := encodeTagged(mp3.WithID3v2(mp3.ID3v2{
+		Pictures: []mp3.Picture{{Type: mp3.FrontCover, Data: artwork}},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name   string
+		reader func() io.Reader
+	}{
+		{
+			name:   "seeker",
+			reader: func() io.Reader { return bytes.NewReader(tagged) },
+		},
+		{
+			name:   "reader",
+			reader: func() io.Reader { return struct{ io.Reader }{bytes.NewReader(tagged)} },
+		},
+	}
+	for _, test := range tests {
+		r := test.reader()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		source, err := mp3.Source(r)(mutable.Mutable(), bufferSize)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(len(artwork)) {
+			t.Errorf("%s: expected tag to be skipped got: %d bytes allocated", test.name, allocated)
+		}
+		floats := signal.Allocator{Channels: 2, Length: bufferSize, Capacity: bufferSize}.Float64()
+		var samples int
+		for {
+			n, err := source.SourceFunc(floats)
+			if err != nil {
+				break
+			}
+			samples += n
+		}
+		if samples < 44100 {
+			t.Errorf("%s: expected at least %d samples got: %d", test.name, 44100, samples)
+		}
+	}
+}
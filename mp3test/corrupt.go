@@ -0,0 +1,99 @@
+package mp3test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+type (
+	// Corruption configures Corrupt. Offsets are in bytes of the original
+	// stream, so corruptions don't affect each other.
+	Corruption struct {
+		// Seed is the seed of positions of flipped bits. The same seed
+		// provides the same corruption.
+		Seed int64
+		// BitFlips is the number of random bits flipped.
+		BitFlips int
+		// Drop are ranges removed from the stream.
+		Drop []Range
+		// Duplicate are ranges repeated right after themselves.
+		Duplicate []Range
+		// Truncate is the offset where the stream ends. Zero keeps the
+		// whole stream.
+		Truncate int64
+	}
+
+	// Range is the range of bytes of the stream.
+	Range struct {
+		Offset int64
+		Length int64
+	}
+)
+
+func (r Range) contains(pos int64) bool {
+	return pos >= r.Offset && pos < r.Offset+r.Length
+}
+
+// Corrupt returns the reader of r with injected corruption. The stream is
+// read entirely on the first call of Read.
+func Corrupt(r io.Reader, c Corruption) io.Reader {
+	return &corruptReader{
+		source:     r,
+		Corruption: c,
+	}
+}
+
+type corruptReader struct {
+	source io.Reader
+	Corruption
+	corrupted *bytes.Reader
+}
+
+func (r *corruptReader) Read(p []byte) (int, error) {
+	if r.corrupted == nil {
+		data, err := io.ReadAll(r.source)
+		if err != nil {
+			return 0, fmt.Errorf("error reading stream: %w", err)
+		}
+		r.corrupted = bytes.NewReader(r.corrupt(data))
+	}
+	return r.corrupted.Read(p)
+}
+
+// corrupt returns corrupted copy of data.
+func (c Corruption) corrupt(data []byte) []byte {
+	data = append([]byte(nil), data...)
+	if len(data) > 0 {
+		rnd := rand.New(rand.NewSource(c.Seed))
+		for i := 0; i < c.BitFlips; i++ {
+			data[rnd.Intn(len(data))] ^= 1 << uint(rnd.Intn(8))
+		}
+	}
+	end := int64(len(data))
+	if c.Truncate > 0 && c.Truncate < end {
+		end = c.Truncate
+	}
+	out := make([]byte, 0, end)
+	for pos := int64(0); pos < end; pos++ {
+		if !c.dropped(pos) {
+			out = append(out, data[pos])
+		}
+		for _, d := range c.Duplicate {
+			if d.Offset >= 0 && d.Length > 0 && pos == d.Offset+d.Length-1 {
+				out = append(out, data[d.Offset:pos+1]...)
+			}
+		}
+	}
+	return out
+}
+
+func (c Corruption) dropped(pos int64) bool {
+	for _, d := range c.Drop {
+		if d.contains(pos) {
+			return true
+		}
+	}
+	return false
+}
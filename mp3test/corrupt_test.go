@@ -0,0 +1,90 @@
+package mp3test_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/audio/mp3/mp3test"
+	"pipelined.dev/pipe"
+)
+
+func TestCorrupt(t *testing.T) {
+	data := []byte("0123456789")
+	tests := []struct {
+		name       string
+		corruption mp3test.Corruption
+		expected   string
+	}{
+		{
+			name:     "none",
+			expected: "0123456789",
+		},
+		{
+			name:       "truncate",
+			corruption: mp3test.Corruption{Truncate: 4},
+			expected:   "0123",
+		},
+		{
+			name:       "drop",
+			corruption: mp3test.Corruption{Drop: []mp3test.Range{{Offset: 2, Length: 3}, {Offset: 8, Length: 5}}},
+			expected:   "01567",
+		},
+		{
+			name:       "duplicate",
+			corruption: mp3test.Corruption{Duplicate: []mp3test.Range{{Offset: 1, Length: 2}}},
+			expected:   "012123456789",
+		},
+		{
+			name: "combined",
+			corruption: mp3test.Corruption{
+				Drop:      []mp3test.Range{{Offset: 0, Length: 2}},
+				Duplicate: []mp3test.Range{{Offset: 0, Length: 3}},
+				Truncate:  6,
+			},
+			expected: "2012345",
+		},
+	}
+	for _, test := range tests {
+		out, err := io.ReadAll(mp3test.Corrupt(bytes.NewReader(data), test.corruption))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if string(out) != test.expected {
+			t.Errorf("%s: expected: %q got: %q", test.name, test.expected, out)
+		}
+	}
+}
+
+func TestCorruptBitFlips(t *testing.T) {
+	data := mp3test.Encode(t, mp3test.Tone(pipe.SignalProperties{Channels: 2, SampleRate: 44100}, 440, 0.5, time.Second),
+		mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine))
+	corrupt := func(seed int64) []byte {
+		out, err := io.ReadAll(mp3test.Corrupt(bytes.NewReader(data), mp3test.Corruption{Seed: seed, BitFlips: 10}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return out
+	}
+	first := corrupt(1)
+	if len(first) != len(data) || bytes.Equal(first, data) {
+		t.Errorf("expected flipped bits of the same stream")
+	}
+	if !bytes.Equal(first, corrupt(1)) {
+		t.Errorf("expected the same corruption for the same seed")
+	}
+	if bytes.Equal(first, corrupt(2)) {
+		t.Errorf("expected different corruption for different seeds")
+	}
+	var flipped int
+	for i := range data {
+		for b := data[i] ^ first[i]; b != 0; b &= b - 1 {
+			flipped++
+		}
+	}
+	if flipped == 0 || flipped > 10 {
+		t.Errorf("expected up to 10 flipped bits got: %d", flipped)
+	}
+}
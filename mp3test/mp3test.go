@@ -0,0 +1,196 @@
+// Package mp3test provides helpers to test pipes that read and write MP3
+// without sample files. Signals are generated by sources, encoded into
+// temporary files and decoded back with mp3 package.
+package mp3test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/signal"
+)
+
+// bufferSize is the buffer size of encoding and decoding pipes.
+const bufferSize = 1152
+
+// Stream is the decoded MP3 stream.
+type Stream struct {
+	// Data is the encoded stream.
+	Data []byte
+	// Info is the info of the encoder. It's set only by RoundTrip.
+	Info mp3.EncodingInfo
+	// SampleRate and Channels are read from the first frame header.
+	SampleRate signal.Frequency
+	Channels   int
+	// Decoded is the decoded audio. Current decoder always provides
+	// stereo.
+	Decoded signal.Floating
+	// xingSamples is the number of samples decoded from Xing/Info frame.
+	xingSamples int
+}
+
+// Duration returns the duration of decoded audio without the silent
+// Xing/Info frame. Encoder delay and padding are excluded if Info is set.
+func (s Stream) Duration() time.Duration {
+	samples := s.Decoded.Length() - s.xingSamples - s.Info.Delay - s.Info.Padding
+	return s.SampleRate.Duration(samples)
+}
+
+// Encode encodes the source into temporary file, so the stream has
+// Xing/Info frame, and returns its data. Options are applied to the sink.
+func Encode(tb testing.TB, source pipe.SourceAllocatorFunc, brm mp3.BitRateMode, cm mp3.ChannelMode, eq mp3.EncodingQuality, options ...mp3.SinkOption) []byte {
+	tb.Helper()
+	f, err := os.CreateTemp("", "mp3test")
+	if err != nil {
+		tb.Fatalf("error creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	run(tb, pipe.Line{
+		Source: source,
+		Sink:   mp3.Sink(f, brm, cm, eq, options...),
+	})
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		tb.Fatalf("error reading encoded file: %v", err)
+	}
+	return data
+}
+
+// Decode decodes MP3 data.
+func Decode(tb testing.TB, data []byte) Stream {
+	tb.Helper()
+	h, err := parseHeader(data)
+	if err != nil {
+		tb.Fatalf("error parsing MP3 stream: %v", err)
+	}
+	sink := mock.Sink{}
+	run(tb, pipe.Line{
+		Source: mp3.Source(bytes.NewReader(data)),
+		Sink:   sink.Sink(),
+	})
+	return Stream{
+		Data:        data,
+		SampleRate:  h.sampleRate,
+		Channels:    h.channels,
+		Decoded:     sink.Values,
+		xingSamples: h.xingSamples,
+	}
+}
+
+// RoundTrip encodes the source and decodes the result. Stream has the
+// encoding info.
+func RoundTrip(tb testing.TB, source pipe.SourceAllocatorFunc, brm mp3.BitRateMode, cm mp3.ChannelMode, eq mp3.EncodingQuality, options ...mp3.SinkOption) Stream {
+	tb.Helper()
+	var info mp3.EncodingInfo
+	options = append(options[:len(options):len(options)], mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }))
+	s := Decode(tb, Encode(tb, source, brm, cm, eq, options...))
+	s.Info = info
+	return s
+}
+
+// AssertDuration reports error if duration of the stream differs from
+// expected by more than tolerance.
+func AssertDuration(tb testing.TB, s Stream, expected, tolerance time.Duration) {
+	tb.Helper()
+	if d := s.Duration() - expected; d < -tolerance || d > tolerance {
+		tb.Errorf("expected duration: %v got: %v", expected, s.Duration())
+	}
+}
+
+// AssertChannels reports error if the stream has unexpected number of
+// channels.
+func AssertChannels(tb testing.TB, s Stream, expected int) {
+	tb.Helper()
+	if s.Channels != expected {
+		tb.Errorf("expected channels: %d got: %d", expected, s.Channels)
+	}
+}
+
+// AssertSampleRate reports error if the stream has unexpected sample
+// rate.
+func AssertSampleRate(tb testing.TB, s Stream, expected signal.Frequency) {
+	tb.Helper()
+	if s.SampleRate != expected {
+		tb.Errorf("expected sample rate: %v got: %v", expected, s.SampleRate)
+	}
+}
+
+func run(tb testing.TB, line pipe.Line) {
+	tb.Helper()
+	p, err := pipe.New(bufferSize, line)
+	if err != nil {
+		tb.Fatalf("error creating pipe: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		tb.Fatalf("error running pipe: %v", err)
+	}
+}
+
+// sampleRates are indexed by version and sample rate bits of the frame
+// header.
+var sampleRates = [4][3]signal.Frequency{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{},                    // reserved
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+// header is the info of the first frame.
+type header struct {
+	sampleRate  signal.Frequency
+	channels    int
+	xingSamples int
+}
+
+// parseHeader parses the first frame after ID3v2 tag.
+func parseHeader(data []byte) (header, error) {
+	if bytes.HasPrefix(data, []byte("ID3")) && len(data) >= 10 {
+		size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+		if data[5]&0x10 != 0 {
+			// footer is present.
+			size += 10
+		}
+		if size+10 > len(data) {
+			return header{}, fmt.Errorf("ID3v2 tag of %d bytes exceeds the stream", size+10)
+		}
+		data = data[size+10:]
+	}
+	if len(data) < 4 || data[0] != 0xff || data[1]&0xe0 != 0xe0 {
+		return header{}, fmt.Errorf("frame sync not found")
+	}
+	version, rate := data[1]>>3&0x3, data[2]>>2&0x3
+	if version == 1 || rate == 3 {
+		return header{}, fmt.Errorf("invalid frame header %x", data[:4])
+	}
+	h := header{
+		sampleRate: sampleRates[version][rate],
+		channels:   2,
+	}
+	if data[3]>>6 == 3 {
+		h.channels = 1
+	}
+	// Xing/Info tag follows the side information.
+	side, samples := 32, 1152
+	switch {
+	case version != 3 && h.channels == 1:
+		side, samples = 9, 576
+	case version != 3:
+		side, samples = 17, 576
+	case h.channels == 1:
+		side = 17
+	}
+	if offset := 4 + side; len(data) >= offset+4 {
+		if tag := string(data[offset : offset+4]); tag == "Xing" || tag == "Info" {
+			h.xingSamples = samples
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,70 @@
+package mp3test_test
+
+import (
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/audio/mp3/mp3test"
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   pipe.SourceAllocatorFunc
+		mode     mp3.ChannelMode
+		rate     signal.Frequency
+		channels int
+	}{
+		{
+			name:     "stereo tone",
+			source:   mp3test.Tone(pipe.SignalProperties{Channels: 2, SampleRate: 44100}, 440, 0.5, time.Second),
+			mode:     mp3.JointStereo,
+			rate:     44100,
+			channels: 2,
+		},
+		{
+			name:     "mono noise",
+			source:   mp3test.Noise(pipe.SignalProperties{Channels: 1, SampleRate: 22050}, 0.5, 500*time.Millisecond, 1),
+			mode:     mp3.Mono,
+			rate:     22050,
+			channels: 1,
+		},
+	}
+	for _, test := range tests {
+		s := mp3test.RoundTrip(t, test.source, mp3.CBR(64), test.mode, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine))
+		mp3test.AssertSampleRate(t, s, test.rate)
+		mp3test.AssertChannels(t, s, test.channels)
+		mp3test.AssertDuration(t, s, test.rate.Duration(s.Info.Samples), 0)
+		if s.Info.Samples == 0 {
+			t.Errorf("%s: expected encoded samples", test.name)
+		}
+	}
+}
+
+func TestNoise(t *testing.T) {
+	encode := func(seed int64) []byte {
+		source := mp3test.Noise(pipe.SignalProperties{Channels: 2, SampleRate: 44100}, 0.5, 100*time.Millisecond, seed)
+		return mp3test.Encode(t, source, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine))
+	}
+	if string(encode(1)) != string(encode(1)) {
+		t.Errorf("expected the same stream for the same seed")
+	}
+	if string(encode(1)) == string(encode(2)) {
+		t.Errorf("expected different streams for different seeds")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := mp3test.Encode(t, mp3test.Tone(pipe.SignalProperties{Channels: 2, SampleRate: 48000}, 440, 0.5, time.Second),
+		mp3.CBR(128), mp3.Stereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine),
+		mp3.WithID3v2(mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Tone"}}}),
+	)
+	s := mp3test.Decode(t, data)
+	mp3test.AssertSampleRate(t, s, 48000)
+	mp3test.AssertChannels(t, s, 2)
+	// without encoding info, whole frames are decoded.
+	mp3test.AssertDuration(t, s, time.Second, 2*48000/1152*time.Millisecond)
+}
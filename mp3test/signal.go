@@ -0,0 +1,63 @@
+package mp3test
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Tone returns the source of sine wave with provided frequency in Hz and
+// amplitude. All channels have the same signal.
+func Tone(props pipe.SignalProperties, freq, amplitude float64, d time.Duration) pipe.SourceAllocatorFunc {
+	return generator(props, d, func(pos int) float64 {
+		return amplitude * math.Sin(2*math.Pi*freq*float64(pos)/float64(props.SampleRate))
+	})
+}
+
+// Noise returns the source of uniform white noise with provided
+// amplitude. The same seed provides the same signal. All channels have
+// the same signal.
+func Noise(props pipe.SignalProperties, amplitude float64, d time.Duration, seed int64) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		rnd := rand.New(rand.NewSource(seed))
+		return generator(props, d, func(int) float64 {
+			return amplitude * (2*rnd.Float64() - 1)
+		})(mctx, bufferSize)
+	}
+}
+
+// generator returns the source of d duration with sample values
+// provided by fn for every position.
+func generator(props pipe.SignalProperties, d time.Duration, fn func(pos int) float64) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		var (
+			pos   int
+			total = props.SampleRate.Events(d)
+		)
+		return pipe.Source{
+			SourceFunc: func(out signal.Floating) (int, error) {
+				if pos == total {
+					return 0, io.EOF
+				}
+				n := out.Length()
+				if left := total - pos; n > left {
+					n = left
+				}
+				for i := 0; i < n; i++ {
+					v := fn(pos + i)
+					for c := 0; c < out.Channels(); c++ {
+						out.SetSample(i*out.Channels()+c, v)
+					}
+				}
+				pos += n
+				return n, nil
+			},
+			SignalProperties: props,
+		}, nil
+	}
+}
@@ -0,0 +1,82 @@
+package mp3
+
+import "fmt"
+
+// musicBrainzOwner is the owner of UFID frame with MusicBrainz recording
+// identifier.
+const musicBrainzOwner = "http://musicbrainz.org"
+
+// MusicBrainz are MusicBrainz identifiers written with the conventions
+// of MusicBrainz Picard. The recording identifier is written as UFID
+// frame and others as TXXX frames. All identifiers are optional.
+type MusicBrainz struct {
+	RecordingID    string
+	ReleaseID      string
+	ReleaseGroupID string
+	ReleaseTrackID string
+	ArtistIDs      []string
+	AlbumArtistIDs []string
+	WorkID         string
+}
+
+func (m MusicBrainz) validate() error {
+	ids := []string{m.RecordingID, m.ReleaseID, m.ReleaseGroupID, m.ReleaseTrackID, m.WorkID}
+	ids = append(ids, m.ArtistIDs...)
+	for _, id := range append(ids, m.AlbumArtistIDs...) {
+		if id != "" && !isUUID(id) {
+			return fmt.Errorf("%w: MusicBrainz identifier %q is not UUID", ErrInvalidParameter, id)
+		}
+	}
+	return nil
+}
+
+// uniqueIDs returns UFID frame of the recording.
+func (m MusicBrainz) uniqueIDs() []UniqueID {
+	if m.RecordingID == "" {
+		return nil
+	}
+	return []UniqueID{{Owner: musicBrainzOwner, ID: []byte(m.RecordingID)}}
+}
+
+// userText returns TXXX frames of identifiers.
+func (m MusicBrainz) userText() []UserText {
+	var text []UserText
+	add := func(description string, values ...string) {
+		var ids []string
+		for _, v := range values {
+			if v != "" {
+				ids = append(ids, v)
+			}
+		}
+		if len(ids) > 0 {
+			text = append(text, UserText{Description: description, Values: ids, Encoding: Latin1})
+		}
+	}
+	add("MusicBrainz Album Id", m.ReleaseID)
+	add("MusicBrainz Release Group Id", m.ReleaseGroupID)
+	add("MusicBrainz Release Track Id", m.ReleaseTrackID)
+	add("MusicBrainz Artist Id", m.ArtistIDs...)
+	add("MusicBrainz Album Artist Id", m.AlbumArtistIDs...)
+	add("MusicBrainz Work Id", m.WorkID)
+	return text
+}
+
+// isUUID reports whether s is UUID in canonical textual form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestSinkMusicBrainz(t *testing.T) {
+	const (
+		recording = "0b5ce5d5-2f5b-4b5f-9c1d-6e0f3a4b8c01"
+		release   = "1c6df6e6-3a6c-4c6a-8d2e-7f1a4b5c9d02"
+		artist1   = "2d7ea7f7-4b7d-4d7b-9e3f-8a2b5c6d0e03"
+		artist2   = "3e8fb8a8-5c8e-4e8c-af4a-9b3c6d7e1f04"
+	)
+	tests := []struct {
+		name     string
+		tag      mp3.ID3v2
+		expected []id3Frame
+		err      error
+	}{
+		{
+			name: "identifiers",
+			tag: mp3.ID3v2{
+				MusicBrainz: &mp3.MusicBrainz{
+					RecordingID: recording,
+					ReleaseID:   release,
+					ArtistIDs:   []string{artist1, artist2},
+				},
+			},
+			expected: []id3Frame{
+				{id: "UFID", body: []byte("http://musicbrainz.org\x00" + recording)},
+				{id: "TXXX", body: []byte("\x00MusicBrainz Album Id\x00" + release)},
+				{id: "TXXX", body: []byte("\x00MusicBrainz Artist Id\x00" + artist1 + "\x00" + artist2)},
+			},
+		},
+		{
+			name: "identifiers ID3v2.3",
+			tag: mp3.ID3v2{
+				Version:     mp3.ID3v23,
+				MusicBrainz: &mp3.MusicBrainz{ArtistIDs: []string{artist1, artist2}},
+			},
+			expected: []id3Frame{
+				{id: "TXXX", body: []byte("\x00MusicBrainz Artist Id\x00" + artist1 + "/" + artist2)},
+			},
+		},
+		{
+			name: "invalid identifier",
+			tag:  mp3.ID3v2{MusicBrainz: &mp3.MusicBrainz{RecordingID: "recording"}},
+			err:  mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(mp3.WithID3v2(test.tag))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames, _ := parseID3v2(t, data)
+		if len(frames) != len(test.expected) {
+			t.Fatalf("%s: expected frames: %d got: %d", test.name, len(test.expected), len(frames))
+		}
+		for i, f := range frames {
+			if f.id != test.expected[i].id || !bytes.Equal(f.body, test.expected[i].body) {
+				t.Errorf("%s: expected frame %s %q got: %s %q", test.name, test.expected[i].id, test.expected[i].body, f.id, f.body)
+			}
+		}
+	}
+}
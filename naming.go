@@ -0,0 +1,68 @@
+package mp3
+
+import (
+	"strconv"
+	"time"
+)
+
+// NameTemplate is the template of file names with variables in {name}
+// form, like show-{date}-{seq}.mp3. Its Name method can be used as
+// Rotation.Path and HLS.SegmentNames. Built-in variables are:
+//
+//	{seq}  sequence number of the file that starts from zero
+//	{date} start date of the file in YYYY-MM-DD format
+//	{time} start time of the file in HH-MM-SS format
+//	{unix} start time of the file as unix timestamp
+//
+// Other variables are taken from Values. Path separators of values and
+// dots that start their path elements are replaced with underscores, so
+// values like stream titles can't make the path leave the directory of
+// the template.
+// Unknown variables are kept as is.
+type NameTemplate struct {
+	Template string
+	Values   map[string]string
+}
+
+// Segment is the file finalized by RotatingFileSink or HLSSink.
+type Segment struct {
+	Seq int
+	// Name is the path of rotated file or the name of HLS segment.
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Name returns the name of the file with sequence number seq started at
+// start.
+func (n NameTemplate) Name(seq int, start time.Time) string {
+	values := map[string]string{
+		"seq":  strconv.Itoa(seq),
+		"date": start.Format("2006-01-02"),
+		"time": start.Format("15-04-05"),
+		"unix": strconv.FormatInt(start.Unix(), 10),
+	}
+	for k, v := range n.Values {
+		values[k] = sanitizeNameValue(v)
+	}
+	return expandTemplate(n.Template, values)
+}
+
+// sanitizeNameValue replaces path separators and dots that start the
+// value or follow the separator with underscores.
+func sanitizeNameValue(v string) string {
+	b := []byte(v)
+	leading := true
+	for i, c := range b {
+		switch {
+		case c == '/' || c == '\\':
+			b[i] = '_'
+			leading = true
+		case c == '.' && leading:
+			b[i] = '_'
+		default:
+			leading = false
+		}
+	}
+	return string(b)
+}
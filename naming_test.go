@@ -0,0 +1,47 @@
+package mp3_test
+
+import (
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestNameTemplate(t *testing.T) {
+	start := time.Date(2020, time.March, 5, 18, 30, 15, 0, time.UTC)
+	tests := []struct {
+		template string
+		values   map[string]string
+		expected string
+	}{
+		{
+			template: "show-{date}-{seq}.mp3",
+			expected: "show-2020-03-05-7.mp3",
+		},
+		{
+			template: "{show}/{date}_{time}.mp3",
+			values:   map[string]string{"show": "news"},
+			expected: "news/2020-03-05_18-30-15.mp3",
+		},
+		{
+			template: "{show}/{title}.mp3",
+			values:   map[string]string{"show": "../..", "title": "AC/DC - Back In Black"},
+			expected: "_____/AC_DC - Back In Black.mp3",
+		},
+		{
+			template: "{title}-{seq}.mp3",
+			values:   map[string]string{"title": "..\\secret"},
+			expected: "___secret-7.mp3",
+		},
+		{
+			template: "{unix}-{unknown}.mp3",
+			expected: "1583433015-{unknown}.mp3",
+		},
+	}
+	for _, test := range tests {
+		n := mp3.NameTemplate{Template: test.template, Values: test.values}
+		if name := n.Name(7, start); name != test.expected {
+			t.Errorf("%s: expected name: %q got: %q", test.template, test.expected, name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+// NogapSinks returns sinks that encode consecutive tracks of an album
+// with a single lame encoder. Encoder state is shared between tracks and
+// PCM buffered at the end of the track is encoded into the next one, so
+// tracks don't have gaps when played back-to-back. Sink is returned per
+// writer. Tracks must be encoded one after another in the order of
+// writers and must have the same signal properties. Only Lame backend is
+// supported.
+func NogapSinks(writers []io.Writer, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) []pipe.SinkAllocatorFunc {
+	album := nogapAlbum{
+		brm:     brm,
+		cm:      cm,
+		eq:      eq,
+		options: options,
+		tracks:  len(writers),
+	}
+	sinks := make([]pipe.SinkAllocatorFunc, 0, len(writers))
+	for i, w := range writers {
+		sinks = append(sinks, album.sink(i, w))
+	}
+	return sinks
+}
+
+// nogapAlbum holds the encoder shared by tracks.
+type nogapAlbum struct {
+	brm     BitRateMode
+	cm      ChannelMode
+	eq      EncodingQuality
+	options []SinkOption
+	tracks  int
+
+	cfg     encoderConfig
+	props   pipe.SignalProperties
+	encoder *lameEncoder
+	// index of the track that can be started.
+	next int
+}
+
+func (a *nogapAlbum) sink(track int, w io.Writer) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		if track != a.next {
+			return pipe.Sink{}, fmt.Errorf("%w: track %d is started before track %d", ErrInvalidParameter, track, a.next)
+		}
+		var out output
+		if track == 0 {
+			var err error
+			if out, err = a.init(w, props); err != nil {
+				return pipe.Sink{}, err
+			}
+		} else {
+			if props != a.props {
+				return pipe.Sink{}, fmt.Errorf("%w: track %d signal properties %+v don't match album %+v", ErrInvalidParameter, track, props, a.props)
+			}
+			var err error
+			if out, err = newOutput(w, a.cfg, false); err != nil {
+				return pipe.Sink{}, err
+			}
+			if err := a.encoder.nextTrack(out.w); err != nil {
+				return pipe.Sink{}, err
+			}
+		}
+		a.cfg.opts.bind(mctx)
+		counter := pcmCounter{Writer: a.encoder}
+		samples := func() int { return counter.samples(a.cfg.channels) }
+		pcm := newPooledPCM(a.cfg.channels, a.cfg.opts.chunkSize(bufferSize))
+		return pipe.Sink{
+			Context:   mctx,
+			SinkFunc:  out.sinkFunc(a.cfg.sinkFunc(&counter, pcm, bufferSize), samples),
+			FlushFunc: pcm.flusher(a.flusher(track, &counter, out)),
+		}, nil
+	}
+}
+
+// init creates the encoder for the first track.
+func (a *nogapAlbum) init(w io.Writer, props pipe.SignalProperties) (output, error) {
+	cfg, err := newEncoderConfig(a.brm, a.cm, a.eq, props, a.options)
+	if err != nil {
+		return output{}, err
+	}
+	if cfg.opts.newEncoder != nil || cfg.opts.backend != Lame {
+		return output{}, fmt.Errorf("%w: nogap encoding requires %v backend", ErrInvalidParameter, Lame)
+	}
+	if cfg.opts.controller != nil {
+		return output{}, fmt.Errorf("%w: nogap encoding doesn't support controller", ErrInvalidParameter)
+	}
+	if cfg.opts.leadSilence != (silence{}) || cfg.opts.trailSilence != (silence{}) || cfg.opts.trimSilence {
+		return output{}, fmt.Errorf("%w: nogap encoding doesn't support silence padding and trim", ErrInvalidParameter)
+	}
+	out, err := newOutput(w, cfg, false)
+	if err != nil {
+		return output{}, err
+	}
+	encoder, err := cfg.newEncoder(out.w)
+	if err != nil {
+		return output{}, err
+	}
+	a.cfg, a.props, a.encoder = cfg, props, encoder.(*lameEncoder)
+	return out, nil
+}
+
+// flusher flushes the bitstream of the track. The last track flushes
+// the encoder and releases it.
+func (a *nogapAlbum) flusher(track int, counter *pcmCounter, out output) pipe.FlushFunc {
+	return func(ctx context.Context) error {
+		if a.cfg.opts.discard(ctx) {
+			// album is aborted, encoder is flushed only to release
+			// resources.
+			out.discard()
+			a.encoder.Flush()
+			return nil
+		}
+		flush := a.encoder.flushNogap
+		if track == a.tracks-1 {
+			flush = a.encoder.Flush
+		}
+		if err := flush(); err != nil {
+			return fmt.Errorf("error flushing MP3 encoder: %w", err)
+		}
+		a.next++
+		info := encodingInfo(a.encoder, counter.samples(a.cfg.channels))
+		if err := out.finish(info); err != nil {
+			return err
+		}
+		if a.cfg.opts.info != nil {
+			a.cfg.opts.info(info)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/signal"
+)
+
+func TestNogapSinks(t *testing.T) {
+	skipWithoutLame(t)
+	tracks := make([]bytes.Buffer, 3)
+	writers := make([]io.Writer, 0, len(tracks))
+	for i := range tracks {
+		writers = append(writers, &tracks[i])
+	}
+	var samples []int
+	sinks := mp3.NogapSinks(writers, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+		mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { samples = append(samples, i.Samples) }),
+	)
+	limits := []int{50000, 10000, 70000}
+	for i, sink := range sinks {
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      limits[i],
+			Value:      0.5,
+		}
+		p, err := pipe.New(bufferSize, pipe.Line{Source: source.Source(), Sink: sink})
+		if err != nil {
+			t.Fatalf("track %d: unexpected error: %v", i, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("track %d: unexpected error: %v", i, err)
+		}
+	}
+
+	for i := range tracks {
+		if samples[i] != limits[i] {
+			t.Errorf("track %d: expected samples: %d got: %d", i, limits[i], samples[i])
+		}
+		if frames := splitFrames(t, tracks[i].Bytes()); len(frames) == 0 {
+			t.Errorf("track %d: no frames encoded", i)
+		}
+	}
+}
+
+func TestNogapSinksValidation(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name    string
+		options []mp3.SinkOption
+		// sample rate of the second track.
+		sampleRate signal.Frequency
+		// index of the track that is started first.
+		first int
+	}{
+		{name: "shine", options: []mp3.SinkOption{mp3.WithBackend(mp3.Shine)}, sampleRate: 44100},
+		{name: "sample rate", sampleRate: 48000},
+		{name: "order", sampleRate: 44100, first: 1},
+	}
+	for _, test := range tests {
+		writers := []io.Writer{&bytes.Buffer{}, &bytes.Buffer{}}
+		sinks := mp3.NogapSinks(writers, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, test.options...)
+		var err error
+		for i, sampleRate := range []signal.Frequency{44100, test.sampleRate}[test.first:] {
+			source := mock.Source{
+				Channels:   2,
+				SampleRate: sampleRate,
+				Limit:      10000,
+			}
+			var p *pipe.Pipe
+			if p, err = pipe.New(bufferSize, pipe.Line{Source: source.Source(), Sink: sinks[i+test.first]}); err != nil {
+				break
+			}
+			if err = pipe.Wait(p.Start(context.Background())); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package mp3
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+type (
+	// SinkOption configures optional encoder settings of the Sink.
+	SinkOption func(*sinkOptions)
+
+	sinkOptions struct {
+		crc              bool
+		disableReservoir bool
+		strictISO        bool
+		outSampleRate    signal.Frequency
+		sampleRatePolicy SampleRatePolicy
+		downmix          DownmixMatrix
+		tuning           []func(*lameEncoder)
+		disableVBRTag    bool
+		backend          Backend
+		newEncoder       func() Encoder
+		info             func(EncodingInfo)
+		alignFrames      bool
+		maxWriteBytes    int
+		progress         func(Progress)
+		stats            func(Stats)
+		writeTimeout     time.Duration
+		fallback         io.Writer
+		cancelPolicy     CancelPolicy
+		fileCommit       *FileCommit
+		controller       *Controller
+		leadSilence      silence
+		trailSilence     silence
+		trimSilence      bool
+		trimThreshold    float64
+		id3v1            *ID3v1
+		id3v2            *ID3v2
+		replayGain       bool
+		itunSMPB         bool
+		deferredTags     []deferredTag
+		template         *TagTemplate
+		icy              *ICYMetadata
+		keepalive        time.Duration
+		latency          func(Latency)
+		cues             *Cues
+		backpressure     *Backpressure
+		deterministic    bool
+		stutter          *stutterDetector
+		telephony        bool
+		aggregation      *aggregation
+		chunk            int
+		err              error
+	}
+
+	// SourceOption configures optional decoder settings of the Source.
+	SourceOption func(*sourceOptions)
+
+	sourceOptions struct {
+		fingerprint *fingerprint
+		chunk       int
+		err         error
+	}
+
+	// SampleRatePolicy determines how the Sink handles input sample rates
+	// that aren't supported by MP3.
+	SampleRatePolicy int
+
+	// UnsupportedSampleRateError is returned when the sample rate isn't
+	// supported by MP3.
+	UnsupportedSampleRateError struct {
+		SampleRate signal.Frequency
+	}
+)
+
+const (
+	// RejectSampleRate makes the Sink return UnsupportedSampleRateError.
+	// This is the default policy.
+	RejectSampleRate SampleRatePolicy = iota
+	// ResampleToNearest makes the Sink resample the input to the nearest
+	// supported sample rate.
+	ResampleToNearest
+)
+
+// WithCRC enables CRC protection of every encoded frame. It adds two bytes
+// per frame and allows receivers to detect corrupted frame headers and
+// side information.
+func WithCRC() SinkOption {
+	return func(o *sinkOptions) {
+		o.crc = true
+	}
+}
+
+// WithoutBitReservoir disables the bit reservoir, so every encoded frame
+// is self-contained and doesn't reference data of previous frames. It's
+// useful for packetized streaming where frames may be lost independently,
+// but reduces quality at the same bit rate.
+func WithoutBitReservoir() SinkOption {
+	return func(o *sinkOptions) {
+		o.disableReservoir = true
+	}
+}
+
+// WithStrictISO enforces strict ISO compliance of the encoded stream. It
+// guarantees compatibility with picky hardware decoders at the cost of
+// slightly less efficient encoding.
+func WithStrictISO() SinkOption {
+	return func(o *sinkOptions) {
+		o.strictISO = true
+	}
+}
+
+// WithOutputSampleRate sets the sample rate of encoded stream. If it differs
+// from the input sample rate, the signal is resampled by lame. Without this
+// option lame picks the output sample rate on its own.
+func WithOutputSampleRate(sampleRate signal.Frequency) SinkOption {
+	return func(o *sinkOptions) {
+		o.outSampleRate = sampleRate
+	}
+}
+
+// WithoutVBRTag disables Xing/Info frame that is written at the start of
+// seekable outputs.
+func WithoutVBRTag() SinkOption {
+	return func(o *sinkOptions) {
+		o.disableVBRTag = true
+	}
+}
+
+// WithSampleRatePolicy sets the policy for input sample rates that aren't
+// supported by MP3. It has no effect if output sample rate is set
+// explicitly.
+func WithSampleRatePolicy(p SampleRatePolicy) SinkOption {
+	return func(o *sinkOptions) {
+		o.sampleRatePolicy = p
+	}
+}
+
+// validate checks that options are consistent.
+func (o sinkOptions) validate() error {
+	if o.err != nil {
+		return o.err
+	}
+	if o.outSampleRate != 0 && !isSupportedSampleRate(o.outSampleRate) {
+		return &UnsupportedSampleRateError{SampleRate: o.outSampleRate}
+	}
+	if o.fallback != nil && o.writeTimeout == 0 {
+		return fmt.Errorf("%w: fallback writer requires write timeout", ErrInvalidParameter)
+	}
+	return o.validateDeterministic()
+}
+
+// resolveSampleRate applies sample rate policy for the input sample rate.
+func (o *sinkOptions) resolveSampleRate(in signal.Frequency) error {
+	if o.outSampleRate != 0 || isSupportedSampleRate(in) {
+		return nil
+	}
+	switch o.sampleRatePolicy {
+	case ResampleToNearest:
+		o.outSampleRate = nearestSampleRate(in)
+		return nil
+	default:
+		return &UnsupportedSampleRateError{SampleRate: in}
+	}
+}
+
+// apply sets options to the encoder.
+func (o sinkOptions) apply(e *lameEncoder) {
+	e.setErrorProtection(o.crc)
+	e.setDisableReservoir(o.disableReservoir)
+	e.setStrictISO(o.strictISO)
+	// Sink writes the tag of deterministic stream.
+	e.setWriteVBRTag(!o.disableVBRTag && !o.deterministic)
+	for _, fn := range o.tuning {
+		fn(e)
+	}
+}
+
+// tune adds encoder setting that is applied after all other options.
+func (o *sinkOptions) tune(fn func(*lameEncoder)) {
+	o.tuning = append(o.tuning, fn)
+}
+
+// fail records the first invalid option.
+func (o *sinkOptions) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// fail records the first invalid option.
+func (o *sourceOptions) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// metadata reports whether options add tags, cues or ICY metadata into
+// the stream.
+func (o sinkOptions) metadata() bool {
+	return o.id3v1 != nil || o.id3v2 != nil || o.replayGain || o.itunSMPB || len(o.deferredTags) > 0 || o.template != nil || o.icy != nil || o.cues != nil
+}
+
+// bind binds options that are updated with mutations to the Sink.
+func (o sinkOptions) bind(mctx mutable.Context) {
+	if o.template != nil {
+		o.template.bind(mctx)
+	}
+	if o.icy != nil {
+		o.icy.bind(mctx)
+	}
+	if o.cues != nil {
+		o.cues.bind(mctx)
+	}
+}
+
+// sampleRates supported by MPEG-1, MPEG-2 and MPEG-2.5.
+var sampleRates = [...]signal.Frequency{
+	48000, 44100, 32000,
+	24000, 22050, 16000,
+	12000, 11025, 8000,
+}
+
+func isSupportedSampleRate(sampleRate signal.Frequency) bool {
+	for _, sr := range sampleRates {
+		if sr == sampleRate {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestSampleRate returns the supported sample rate that is the closest
+// to the provided one.
+func nearestSampleRate(sampleRate signal.Frequency) signal.Frequency {
+	nearest := sampleRates[0]
+	for _, sr := range sampleRates[1:] {
+		if math.Abs(float64(sr-sampleRate)) < math.Abs(float64(nearest-sampleRate)) {
+			nearest = sr
+		}
+	}
+	return nearest
+}
+
+func (e *UnsupportedSampleRateError) Error() string {
+	return fmt.Sprintf("unsupported sample rate: %v", e.SampleRate)
+}
@@ -0,0 +1,274 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+// output is the chain of writers between the encoder and the sink
+// writer.
+type output struct {
+	// w is the writer for the encoder.
+	w io.Writer
+	// base is the writer for tags, they bypass frame handling.
+	base   io.Writer
+	xing   *xingWriter
+	frames *frameWriter
+	stats  *statsWriter
+	gate   *gateWriter
+	// progress and stats callbacks.
+	progress  func(Progress)
+	report    func(Stats)
+	id3v1     *ID3v1
+	tag       *tagWriter
+	meter     *loudnessMeter
+	keepalive *keepaliveWriter
+	// backpressure buffer is closed when the output is done.
+	backpressure *backpressureWriter
+	// latency function and input sample rate to measure it.
+	latency    func(Latency)
+	sampleRate signal.Frequency
+}
+
+// newOutput wraps w with writers required by the options. Xing header is
+// written by the output only if requested. ID3v2 tag is written before
+// the output is returned.
+func newOutput(w io.Writer, c encoderConfig, xingHeader bool) (output, error) {
+	if c.opts.icy != nil {
+		w = &icyWriter{w: w, meta: c.opts.icy}
+	}
+	var backpressure *backpressureWriter
+	if c.opts.backpressure != nil {
+		backpressure = newBackpressureWriter(w, *c.opts.backpressure)
+		w = backpressure
+	}
+	if c.opts.cues != nil {
+		w = &cueWriter{w: w, cues: c.opts.cues, icy: c.opts.icy}
+	}
+	var keepalive *keepaliveWriter
+	if c.opts.keepalive > 0 {
+		keepalive = &keepaliveWriter{w: w, interval: c.opts.keepalive}
+		w = keepalive
+	}
+	var gate *gateWriter
+	if c.opts.cancelPolicy == DiscardOnCancel {
+		gate = &gateWriter{w: w}
+		w = gate
+	}
+	if c.opts.writeTimeout > 0 {
+		w = &timeoutWriter{
+			w:        w,
+			timeout:  c.opts.writeTimeout,
+			fallback: c.opts.fallback,
+		}
+	}
+	o := output{
+		w:            w,
+		base:         w,
+		gate:         gate,
+		keepalive:    keepalive,
+		backpressure: backpressure,
+		latency:      c.opts.latency,
+		sampleRate:   c.sampleRate,
+		progress:     c.opts.progress,
+		report:       c.opts.stats,
+		id3v1:        c.opts.id3v1,
+	}
+	var tag ID3v2
+	if c.opts.id3v2 != nil {
+		tag = *c.opts.id3v2
+	}
+	var deferred []deferredFrames
+	if c.opts.replayGain {
+		o.meter = newLoudnessMeter(c.sampleRate)
+		deferred = append(deferred, replayGainDeferred(tag, o.meter))
+	}
+	if c.opts.itunSMPB {
+		deferred = append(deferred, itunSMPBDeferred(tag))
+	}
+	for _, d := range c.opts.deferredTags {
+		deferred = append(deferred, d.frames(tag))
+	}
+	if c.opts.template != nil {
+		// average bit rate is needed to resolve the template.
+		o.stats = &statsWriter{}
+		deferred = append(deferred, c.opts.template.deferred(tag, c.sampleRate, o.stats))
+	}
+	if c.opts.id3v2 != nil || len(deferred) > 0 {
+		var err error
+		if o.tag, err = writeTag(w, tag, deferred); err != nil {
+			return output{}, err
+		}
+	}
+	if xingHeader {
+		if o.xing = newXingWriter(w, c); o.xing != nil {
+			o.w = o.xing
+		}
+	}
+	if c.opts.alignFrames {
+		o.frames = &frameWriter{w: o.w, max: c.opts.maxWriteBytes}
+		o.w = o.frames
+	}
+	if o.stats != nil || o.progress != nil || o.report != nil || o.latency != nil {
+		if o.stats == nil {
+			o.stats = &statsWriter{}
+		}
+		o.stats.w = o.w
+		o.w = o.stats
+	}
+	return o, nil
+}
+
+// sinkFunc wraps the sink function to report progress and latency and
+// measure loudness if needed.
+func (o output) sinkFunc(fn pipe.SinkFunc, samples func() int) pipe.SinkFunc {
+	if o.meter != nil {
+		fn = measure(fn, o.meter)
+	}
+	if o.stats == nil {
+		return fn
+	}
+	fn = progress(fn, samples, o.stats, o.progress)
+	if o.latency != nil {
+		fn = reportLatency(fn, samples, o.stats, o.sampleRate, o.latency)
+	}
+	return fn
+}
+
+// stop stops keepalive frames when the input has ended.
+func (o output) stop() {
+	if o.keepalive != nil {
+		o.keepalive.stop()
+	}
+}
+
+// discard drops all following writes.
+func (o output) discard() {
+	o.stop()
+	if o.gate != nil {
+		o.gate.closed = true
+	}
+	if o.backpressure != nil {
+		o.backpressure.close(false)
+	}
+}
+
+// finish must be called after the encoder is flushed.
+func (o output) finish(info EncodingInfo) error {
+	err := o.finishStream(info)
+	if o.backpressure != nil {
+		if closeErr := o.backpressure.close(err == nil); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// finishStream writes the end of the stream.
+func (o output) finishStream(info EncodingInfo) error {
+	o.stop()
+	if o.frames != nil {
+		if err := o.frames.flush(); err != nil {
+			return err
+		}
+	}
+	if o.xing != nil {
+		if err := o.xing.finish(info); err != nil {
+			return err
+		}
+	}
+	if o.tag != nil {
+		if err := o.tag.finish(info); err != nil {
+			return err
+		}
+	}
+	if o.id3v1 != nil {
+		if _, err := o.base.Write(o.id3v1.bytes()); err != nil {
+			return fmt.Errorf("error writing ID3v1 tag: %w", err)
+		}
+	}
+	if o.report != nil {
+		o.report(o.stats.result())
+	}
+	return nil
+}
+
+// WithFrameAlignedWrites makes the Sink write only whole frames into the
+// writer. If max is positive, frames are grouped into writes of at most
+// max bytes. Frame that is larger than max is written alone.
+func WithFrameAlignedWrites(max int) SinkOption {
+	return func(o *sinkOptions) {
+		if max < 0 {
+			o.fail(fmt.Errorf("%w: max bytes per write %d is negative", ErrInvalidParameter, max))
+			return
+		}
+		o.alignFrames = true
+		o.maxWriteBytes = max
+	}
+}
+
+// errSeekBuffered is returned when frame writer is seeked with
+// incomplete frame buffered.
+var errSeekBuffered = errors.New("seek with incomplete frame buffered")
+
+// frameWriter buffers encoded data and writes it by whole frames. It
+// allows to seek the underlying writer if it's seekable, so encoders can
+// rewrite the header.
+type frameWriter struct {
+	w   io.Writer
+	max int
+	buf []byte
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	f.buf = append(f.buf, p...)
+	frames, rest, err := splitFrames(f.buf)
+	if err != nil {
+		return 0, fmt.Errorf("error splitting MP3 frames: %w", err)
+	}
+	var size int
+	for _, frame := range frames {
+		size += len(frame)
+	}
+	written := f.buf[:size]
+	for len(written) > 0 {
+		n := len(frames[0])
+		frames = frames[1:]
+		for len(frames) > 0 && (f.max == 0 || n+len(frames[0]) <= f.max) {
+			n += len(frames[0])
+			frames = frames[1:]
+		}
+		if _, err := f.w.Write(written[:n]); err != nil {
+			return 0, err
+		}
+		written = written[n:]
+	}
+	f.buf = append(f.buf[:0], rest...)
+	return len(p), nil
+}
+
+// Seek implements io.Seeker.
+func (f *frameWriter) Seek(offset int64, whence int) (int64, error) {
+	ws, ok := f.w.(io.WriteSeeker)
+	if !ok {
+		return 0, errors.New("writer is not seekable")
+	}
+	if len(f.buf) > 0 {
+		return 0, errSeekBuffered
+	}
+	return ws.Seek(offset, whence)
+}
+
+// flush writes the remaining data.
+func (f *frameWriter) flush() error {
+	if len(f.buf) == 0 {
+		return nil
+	}
+	_, err := f.w.Write(f.buf)
+	f.buf = f.buf[:0]
+	return err
+}
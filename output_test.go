@@ -0,0 +1,84 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// writesRecorder keeps every write separately.
+type writesRecorder struct {
+	writes [][]byte
+}
+
+func (r *writesRecorder) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestSinkFrameAlignedWrites(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name    string
+		max     int
+		options []mp3.SinkOption
+	}{
+		{name: "lame"},
+		{name: "lame max", max: 1000},
+		{name: "lame max less than frame", max: 100},
+		{name: "shine max", max: 1000, options: []mp3.SinkOption{mp3.WithBackend(mp3.Shine)}},
+	}
+	for _, test := range tests {
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      100000,
+			Value:      0.5,
+		}
+		var out writesRecorder
+		options := append(test.options, mp3.WithFrameAlignedWrites(test.max))
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(&out, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(out.writes) == 0 {
+			t.Fatalf("%s: nothing was written", test.name)
+		}
+		for i, write := range out.writes {
+			frames := splitFrames(t, write)
+			if test.max > 0 && len(write) > test.max && len(frames) > 1 {
+				t.Errorf("%s: write %d has %d bytes in %d frames", test.name, i, len(write), len(frames))
+			}
+		}
+	}
+}
+
+func TestSinkFrameAlignedWritesValidation(t *testing.T) {
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+	}
+	_, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.Sink(&writesRecorder{}, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithFrameAlignedWrites(-1)),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,272 @@
+package mp3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+const (
+	// parallelChunkFrames is the number of frames produced by a single
+	// encoding job.
+	parallelChunkFrames = 256
+	// parallelOverlapFrames is the number of frames encoded before and
+	// after the chunk to prime and drain encoder state. Output of overlap
+	// is discarded.
+	parallelOverlapFrames = 4
+)
+
+// ParallelSink allows to write mp3 files using multiple cores. Input is
+// split into chunks that are encoded concurrently by independent encoders
+// and concatenated at frame boundaries. Encoders don't use bit reservoir,
+// so every frame is self-contained and can be stitched safely. Xing/Info
+// frame is written only for the whole stream if w implements
+// io.WriteSeeker. Resampling is not supported. If workers is
+// not positive, the number of CPUs is used.
+func ParallelSink(w io.Writer, workers int, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		cfg, err := newEncoderConfig(brm, cm, eq, props, options)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		if cfg.opts.outSampleRate != 0 && cfg.opts.outSampleRate != props.SampleRate {
+			return pipe.Sink{}, fmt.Errorf("%w: parallel encoding doesn't support resampling", ErrInvalidParameter)
+		}
+		if cfg.opts.controller != nil {
+			return pipe.Sink{}, fmt.Errorf("%w: parallel encoding doesn't support controller", ErrInvalidParameter)
+		}
+		// pin output sample rate to keep frame grid aligned with input.
+		cfg.opts.outSampleRate = props.SampleRate
+		cfg.opts.disableReservoir = true
+		// header is written for the whole stream.
+		out, err := newOutput(w, cfg, true)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		cfg.opts.disableVBRTag = true
+		if err := cfg.brm.validate(props.SampleRate); err != nil {
+			return pipe.Sink{}, err
+		}
+
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		frameSamples := frameHeader{version: mpeg1}.samples()
+		if props.SampleRate < 32000 {
+			frameSamples = frameHeader{version: mpeg2}.samples()
+		}
+		trim := newTrimmer(&cfg)
+		e := parallelEncoder{
+			cfg:            cfg,
+			w:              out.w,
+			out:            out,
+			workers:        workers,
+			frameSamples:   frameSamples,
+			blockAlign:     bytesPerSample * cfg.channels,
+			chunkSamples:   parallelChunkFrames * frameSamples,
+			overlapSamples: parallelOverlapFrames * frameSamples,
+		}
+		cfg.opts.bind(mctx)
+		pad := newPadder(&e, cfg)
+		pcm := newPooledPCM(cfg.channels, cfg.opts.chunkSize(bufferSize))
+		return pipe.Sink{
+			Context:   mctx,
+			SinkFunc:  out.sinkFunc(pad.sinkFunc(trim.sinkFunc(cfg.sinkFunc(&e, pcm, bufferSize))), e.samples),
+			FlushFunc: pcm.flusher(pad.flusher(e.flush, cfg.opts)),
+		}, nil
+	}
+}
+
+type (
+	// parallelEncoder accumulates PCM and dispatches encoding jobs.
+	parallelEncoder struct {
+		cfg            encoderConfig
+		w              io.Writer
+		out            output
+		workers        int
+		frameSamples   int
+		blockAlign     int
+		chunkSamples   int
+		overlapSamples int
+
+		pcm     []byte
+		offset  int // sample offset of the first buffered sample
+		chunk   int // index of the next chunk
+		pending []chan parallelResult
+		info    EncodingInfo
+		// reports whether encoders provide the info.
+		hasInfo bool
+	}
+
+	parallelResult struct {
+		encoded []byte
+		frames  int
+		info    EncodingInfo
+		hasInfo bool
+		err     error
+	}
+)
+
+// Write buffers PCM and dispatches every chunk as soon as its overlap is
+// available.
+func (e *parallelEncoder) Write(p []byte) (int, error) {
+	e.pcm = append(e.pcm, p...)
+	for {
+		end := (e.chunk+1)*e.chunkSamples + e.overlapSamples
+		if e.offset+len(e.pcm)/e.blockAlign < end {
+			return len(p), nil
+		}
+		if err := e.dispatch(false); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// dispatch starts the job for the next chunk. Final chunk contains all
+// remaining samples and keeps encoder flush output.
+func (e *parallelEncoder) dispatch(final bool) error {
+	chunkStart := e.chunk * e.chunkSamples
+	start := chunkStart - e.overlapSamples
+	if start < 0 {
+		start = 0
+	}
+	end := e.offset + len(e.pcm)/e.blockAlign
+	if !final {
+		end = chunkStart + e.chunkSamples + e.overlapSamples
+	}
+	pcm := e.pcm[(start-e.offset)*e.blockAlign : (end-e.offset)*e.blockAlign]
+
+	// frames are numbered from the start of the stream.
+	first := chunkStart / e.frameSamples
+	last := first + parallelChunkFrames
+	if final {
+		last = -1
+	}
+	result := make(chan parallelResult, 1)
+	go func(base int) {
+		result <- e.encode(pcm, first-base, last-base)
+	}(start / e.frameSamples)
+	e.pending = append(e.pending, result)
+	e.chunk++
+
+	// keep only overlap of the next chunk.
+	next := e.chunk*e.chunkSamples - e.overlapSamples
+	if !final && next > e.offset {
+		e.pcm = append([]byte(nil), e.pcm[(next-e.offset)*e.blockAlign:]...)
+		e.offset = next
+	}
+	if len(e.pending) < e.workers {
+		return nil
+	}
+	return e.writeNext()
+}
+
+// encode encodes PCM with a new encoder and returns frames in range
+// [first, last). If last is negative, all frames after first are returned.
+func (e *parallelEncoder) encode(pcm []byte, first, last int) parallelResult {
+	var buf bytes.Buffer
+	encoder, err := e.cfg.newEncoder(&buf)
+	if err != nil {
+		return parallelResult{err: err}
+	}
+	if _, err := encoder.Write(pcm); err != nil {
+		encoder.Flush()
+		return parallelResult{err: fmt.Errorf("error writing MP3 buffer: %w", err)}
+	}
+	if err := encoder.Flush(); err != nil {
+		return parallelResult{err: fmt.Errorf("error flushing MP3 encoder: %w", err)}
+	}
+	var r parallelResult
+	if ie, ok := encoder.(InfoEncoder); ok {
+		r.info, r.hasInfo = ie.EncodingInfo(), true
+	}
+	frames, _, err := splitFrames(buf.Bytes())
+	if err != nil {
+		return parallelResult{err: fmt.Errorf("error splitting MP3 frames: %w", err)}
+	}
+	if first > len(frames) {
+		first = len(frames)
+	}
+	if last < 0 || last > len(frames) {
+		last = len(frames)
+	}
+	for _, frame := range frames[first:last] {
+		r.encoded = append(r.encoded, frame...)
+	}
+	r.frames = last - first
+	return r
+}
+
+// writeNext waits for the oldest job and writes its result.
+func (e *parallelEncoder) writeNext() error {
+	r := <-e.pending[0]
+	e.pending = e.pending[1:]
+	if r.err != nil {
+		return r.err
+	}
+	// stream delay is the delay of the first encoder.
+	if e.info.Frames == 0 {
+		e.info.Delay, e.hasInfo = r.info.Delay, r.hasInfo
+	}
+	e.info.Frames += r.frames
+	if _, err := e.w.Write(r.encoded); err != nil {
+		return fmt.Errorf("error writing MP3 data: %w", err)
+	}
+	return nil
+}
+
+func (e *parallelEncoder) flush(ctx context.Context) error {
+	if e.cfg.opts.discard(ctx) {
+		e.drain()
+		return nil
+	}
+	if err := e.dispatch(true); err != nil {
+		return err
+	}
+	for len(e.pending) > 0 {
+		if err := e.writeNext(); err != nil {
+			e.drain()
+			return err
+		}
+	}
+	info := e.encodingInfo()
+	if err := e.out.finish(info); err != nil {
+		return err
+	}
+	if e.cfg.opts.info != nil {
+		e.cfg.opts.info(info)
+	}
+	return nil
+}
+
+// drain waits for the rest of jobs and discards their results.
+func (e *parallelEncoder) drain() {
+	for _, r := range e.pending {
+		<-r
+	}
+	e.pending = nil
+}
+
+// samples returns the number of consumed samples per channel.
+func (e *parallelEncoder) samples() int {
+	return e.offset + len(e.pcm)/e.blockAlign
+}
+
+// encodingInfo returns the info of stitched stream.
+func (e *parallelEncoder) encodingInfo() EncodingInfo {
+	samples := e.samples()
+	if !e.hasInfo {
+		return EncodingInfo{Samples: samples}
+	}
+	return EncodingInfo{
+		Samples: samples,
+		Frames:  e.info.Frames,
+		Delay:   e.info.Delay,
+		Padding: e.info.Frames*e.frameSamples - e.info.Delay - samples,
+	}
+}
@@ -0,0 +1,71 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestParallelSink(t *testing.T) {
+	skipWithoutLame(t)
+	inFile, err := os.Open(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer inFile.Close()
+	inputFile, err := os.Open(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer inputFile.Close()
+
+	input := mock.Sink{Discard: true}
+	var out bytes.Buffer
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3.Source(inFile),
+			Sink:   mp3.ParallelSink(&out, 4, mp3.CBR(192), mp3.JointStereo, mp3.DefaultEncodingQuality),
+		},
+		pipe.Line{
+			Source: mp3.Source(inputFile),
+			Sink:   input.Sink(),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, frame := range splitFrames(t, out.Bytes()) {
+		if mainDataBegin := int(frame[4])<<1 | int(frame[5]>>7); mainDataBegin != 0 {
+			t.Fatalf("frame %d references bit reservoir: %d", i, mainDataBegin)
+		}
+	}
+
+	decoded := mock.Sink{Discard: true}
+	p, err = pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: mp3.Source(bytes.NewReader(out.Bytes())),
+			Sink:   decoded.Sink(),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// output is longer because of encoder delay and padding.
+	if decoded.Samples < input.Samples || decoded.Samples > input.Samples+3*1152 {
+		t.Errorf("unexpected number of samples: %d input: %d", decoded.Samples, input.Samples)
+	}
+}
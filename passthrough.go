@@ -0,0 +1,270 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+// id3v1Size is the size of ID3v1 tag at the end of the stream.
+const id3v1Size = 128
+
+// FrameSink writes already encoded mp3 stream without re-encoding. It
+// allows to remux frames produced by other encoders or recorders. Input
+// can start with ID3v2 tag and end with ID3v1 tag, both are dropped.
+// Xing/Info frame of the input is replaced with the new one, but delay
+// and padding from its LAME extension are kept. Options that configure
+// the encoder and ReplayGain are ignored.
+type FrameSink struct {
+	w    io.Writer
+	opts sinkOptions
+	out  output
+	info EncodingInfo
+
+	started bool
+	buf     []byte
+	// bit rate of the first frame and whether it varies.
+	bitRate  int
+	variable bool
+	samples  int
+}
+
+// NewFrameSink returns FrameSink that writes into w.
+func NewFrameSink(w io.Writer, options ...SinkOption) (*FrameSink, error) {
+	var opts sinkOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	// loudness can't be measured without decoding.
+	opts.replayGain = false
+	return &FrameSink{
+		w:    w,
+		opts: opts,
+	}, nil
+}
+
+// Write implements io.Writer. Data can be split at any position.
+func (s *FrameSink) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	if err := s.process(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadFrom implements io.ReaderFrom. Data is read directly into the
+// buffer of the sink in chunks of remuxChunkSize, so frames are copied
+// only once on their way from r to the output.
+func (s *FrameSink) ReadFrom(r io.Reader) (int64, error) {
+	var read int64
+	for {
+		if cap(s.buf)-len(s.buf) < remuxChunkSize {
+			buf := make([]byte, len(s.buf), len(s.buf)+remuxChunkSize)
+			s.buf = buf[:copy(buf, s.buf)]
+		}
+		n, err := r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		read += int64(n)
+		if n > 0 {
+			if err := s.process(); err != nil {
+				return read, err
+			}
+		}
+		if err == io.EOF {
+			return read, nil
+		}
+		if err != nil {
+			return read, fmt.Errorf("error reading MP3 data: %w", err)
+		}
+	}
+}
+
+// process starts the stream and writes complete frames of the buffer.
+func (s *FrameSink) process() error {
+	if !s.started {
+		ok, err := s.start()
+		if err != nil || !ok {
+			return err
+		}
+	}
+	return s.writeFrames()
+}
+
+// start skips ID3v2 tag and replaces Xing/Info frame of the input. It
+// returns false if more data is needed.
+func (s *FrameSink) start() (bool, error) {
+	if bytes.HasPrefix(s.buf, []byte("ID3")) {
+		if len(s.buf) < id3v2HeaderSize {
+			return false, nil
+		}
+		size := id3v2TagSize(s.buf)
+		if len(s.buf) < size {
+			return false, nil
+		}
+		s.buf = s.buf[size:]
+	}
+	if len(s.buf) < frameHeaderSize {
+		return false, nil
+	}
+	h, err := parseFrameHeader(s.buf)
+	if err != nil {
+		return false, fmt.Errorf("error parsing MP3 frame: %w", err)
+	}
+	if len(s.buf) < h.size() {
+		return false, nil
+	}
+	if info, ok := parseXingFrame(s.buf[:h.size()], h); ok {
+		s.info = info
+		s.buf = s.buf[h.size():]
+	}
+
+	s.bitRate = h.bitRate
+	c := encoderConfig{
+		brm:        CBR(h.bitRate),
+		cm:         Stereo,
+		opts:       s.opts,
+		sampleRate: signal.Frequency(h.sampleRate),
+		channels:   2,
+	}
+	switch h.mode {
+	case 1:
+		c.cm = JointStereo
+	case 3:
+		c.cm, c.channels = Mono, 1
+	}
+	if s.out, err = newOutput(s.w, c, true); err != nil {
+		return false, err
+	}
+	s.started = true
+	if s.out.stats != nil {
+		s.out.stats.start = time.Now()
+	}
+	return true, nil
+}
+
+// writeFrames writes complete frames of the buffer. Repeated frames are
+// dropped if stutter repair is enabled.
+func (s *FrameSink) writeFrames() error {
+	var start, size int
+	for b := s.buf; len(b) >= frameHeaderSize && !isID3v1(b); {
+		h, err := parseFrameHeader(b)
+		if err != nil {
+			return fmt.Errorf("error parsing MP3 frame: %w", err)
+		}
+		if h.size() > len(b) {
+			break
+		}
+		if s.opts.stutter != nil && s.opts.stutter.repeated(b[:h.size()], h) {
+			if _, err := s.out.w.Write(s.buf[start:size]); err != nil {
+				return err
+			}
+			size += h.size()
+			start = size
+			b = b[h.size():]
+			continue
+		}
+		if h.bitRate != s.bitRate {
+			s.variable = true
+		}
+		s.samples += h.samples()
+		size += h.size()
+		b = b[h.size():]
+	}
+	if size == 0 {
+		return nil
+	}
+	if start < size {
+		if _, err := s.out.w.Write(s.buf[start:size]); err != nil {
+			return err
+		}
+	}
+	s.buf = append(s.buf[:0], s.buf[size:]...)
+	if s.out.progress != nil {
+		s.out.progress(Progress{
+			Samples: s.samples,
+			Frames:  s.out.stats.frames,
+			Bytes:   s.out.stats.bytes,
+		})
+	}
+	return nil
+}
+
+// Flush writes Xing/Info frame if the writer is seekable. Stream must
+// not end with incomplete frame.
+func (s *FrameSink) Flush() error {
+	if !s.started {
+		if len(s.buf) == 0 {
+			return nil
+		}
+		return fmt.Errorf("error parsing MP3 frame: %w", errInvalidFrame)
+	}
+	if len(s.buf) > 0 && !(len(s.buf) == id3v1Size && isID3v1(s.buf)) {
+		return fmt.Errorf("error parsing MP3 frame: %w: incomplete frame of %d bytes", errInvalidFrame, len(s.buf))
+	}
+	s.buf = s.buf[:0]
+	if s.opts.stutter != nil {
+		s.opts.stutter.finish()
+	}
+	if s.out.xing != nil && s.variable {
+		s.out.xing.brm = nil
+	}
+	s.info.Samples = s.samples - s.info.Delay - s.info.Padding
+	if err := s.out.finish(s.info); err != nil {
+		return err
+	}
+	if s.opts.info != nil {
+		s.opts.info(s.info)
+	}
+	return nil
+}
+
+// parseXingFrame returns encoder delay and padding if frame contains
+// Xing/Info header.
+func parseXingFrame(frame []byte, h frameHeader) (EncodingInfo, bool) {
+	ext, ok := xingExtension(frame, h)
+	var info EncodingInfo
+	if ext != nil {
+		info.Delay = int(ext[21])<<4 | int(ext[22])>>4
+		info.Padding = int(ext[22]&0x0f)<<8 | int(ext[23])
+	}
+	return info, ok
+}
+
+// xingExtension returns the extension of Xing/Info header that holds
+// encoder delay and padding. It's nil if frame doesn't contain the
+// header or the header has no extension.
+func xingExtension(frame []byte, h frameHeader) ([]byte, bool) {
+	b := frame[h.xingOffset():]
+	if len(b) < 8 || (string(b[:4]) != "Xing" && string(b[:4]) != "Info") {
+		return nil, false
+	}
+	flags := binary.BigEndian.Uint32(b[4:])
+	ext := 8
+	for _, field := range []struct {
+		flag uint32
+		size int
+	}{{0x01, 4}, {0x02, 4}, {0x04, 100}, {0x08, 4}} {
+		if flags&field.flag != 0 {
+			ext += field.size
+		}
+	}
+	if len(b) < ext {
+		return nil, true
+	}
+	// extension is written by LAME, FFmpeg and the sink.
+	if e := b[ext:]; len(e) >= 24 && (bytes.HasPrefix(e, []byte("LAME")) || bytes.HasPrefix(e, []byte("Lav")) || bytes.HasPrefix(e, []byte(xingExtEncoder))) {
+		return e, true
+	}
+	return nil, true
+}
+
+func isID3v1(b []byte) bool {
+	return bytes.HasPrefix(b, []byte("TAG"))
+}
@@ -0,0 +1,132 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestFrameSink(t *testing.T) {
+	input, inputInfo := encodeShine(t)
+	id3v2 := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 5, 1, 2, 3, 4, 5}
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	data := append(append(append([]byte(nil), id3v2...), input...), id3v1...)
+
+	tests := []struct {
+		name     string
+		seekable bool
+		chunk    int
+	}{
+		{name: "seekable", seekable: true, chunk: 1000},
+		{name: "not seekable", chunk: 7},
+	}
+	for _, test := range tests {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+		var w io.Writer = f
+		if !test.seekable {
+			w = struct{ io.Writer }{f}
+		}
+
+		var info mp3.EncodingInfo
+		fs, err := mp3.NewFrameSink(w, mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		for b := data; len(b) > 0; {
+			n := test.chunk
+			if n > len(b) {
+				n = len(b)
+			}
+			if _, err := fs.Write(b[:n]); err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+			b = b[n:]
+		}
+		if err := fs.Flush(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		f.Close()
+
+		output, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		inFrames, outFrames := splitFrames(t, input), splitFrames(t, output)
+		if test.seekable {
+			if string(outFrames[0][4+32:4+36]) != "Info" {
+				t.Errorf("%s: expected Info tag", test.name)
+			}
+			outFrames = outFrames[1:]
+		}
+		if !bytes.Equal(bytes.Join(inFrames[1:], nil), bytes.Join(outFrames, nil)) {
+			t.Errorf("%s: audio frames don't match", test.name)
+		}
+		if info.Delay != inputInfo.Delay || info.Padding != inputInfo.Padding {
+			t.Errorf("%s: expected delay and padding: %d %d got: %d %d", test.name, inputInfo.Delay, inputInfo.Padding, info.Delay, info.Padding)
+		}
+	}
+}
+
+func TestFrameSinkTruncated(t *testing.T) {
+	input, _ := encodeShine(t)
+	fs, err := mp3.NewFrameSink(io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := fs.Write(input[:len(input)-10]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fs.Flush(); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// encodeShine returns tagged stream encoded by Shine backend.
+func encodeShine(t *testing.T) ([]byte, mp3.EncodingInfo) {
+	t.Helper()
+	f, err := os.CreateTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var info mp3.EncodingInfo
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      100000,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink: mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return data, info
+}
@@ -0,0 +1,91 @@
+package mp3
+
+import (
+	"math"
+
+	"pipelined.dev/signal"
+)
+
+// Conversions between little-endian 16-bit PCM and floating point
+// samples are called for every buffer of Source and Sink. Loops advance
+// PCM data instead of indexing it, so the compiler elides bound checks.
+
+// decodeFloats converts PCM data into floating point samples.
+func decodeFloats(floats []float64, data []byte) {
+	for i := range floats {
+		if len(data) < bytesPerSample {
+			return
+		}
+		floats[i] = floatSample(int16(uint16(data[0]) | uint16(data[1])<<8))
+		data = data[bytesPerSample:]
+	}
+}
+
+// decodeInts converts PCM data into 16-bit samples.
+func decodeInts(ints []int16, data []byte) {
+	for i := range ints {
+		if len(data) < bytesPerSample {
+			return
+		}
+		ints[i] = int16(uint16(data[0]) | uint16(data[1])<<8)
+		data = data[bytesPerSample:]
+	}
+}
+
+// intsToFloats converts 16-bit samples into floating point ones.
+func intsToFloats(floats []float64, ints []int16) {
+	for i := 0; i < len(floats) && i < len(ints); i++ {
+		floats[i] = floatSample(ints[i])
+	}
+}
+
+// encodeFloats converts floating point samples into PCM data.
+func encodeFloats(data []byte, floats []float64) {
+	for _, v := range floats {
+		if len(data) < bytesPerSample {
+			return
+		}
+		s := uint16(int16Sample(v))
+		data[0], data[1] = byte(s), byte(s>>8)
+		data = data[bytesPerSample:]
+	}
+}
+
+// readFloats reads samples of src starting at offset into dst. Buffer
+// types of the signal package are unexported, so the interface is the
+// only access to their samples.
+func readFloats(src signal.Floating, offset int, dst []float64) {
+	for i := range dst {
+		dst[i] = src.Sample(offset + i)
+	}
+}
+
+// writeFloats writes src into samples of dst starting at offset.
+func writeFloats(dst signal.Floating, offset int, src []float64) {
+	for i, v := range src {
+		dst.SetSample(offset+i, v)
+	}
+}
+
+// floatSample converts 16-bit sample into floating point the same way
+// signal.SignedAsFloating does.
+func floatSample(v int16) float64 {
+	if v > 0 {
+		return float64(v) / math.MaxInt16
+	}
+	return float64(v) / -math.MinInt16
+}
+
+// int16Sample converts floating point sample into 16-bit one the same way
+// signal.FloatingAsSigned does. Values beyond [-1, 1] are clipped.
+func int16Sample(v float64) int16 {
+	switch {
+	case v >= 1:
+		return math.MaxInt16
+	case v > 0:
+		return int16(v * math.MaxInt16)
+	case v <= -1:
+		return math.MinInt16
+	}
+	return int16(v * -math.MinInt16)
+}
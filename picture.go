@@ -0,0 +1,251 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileIconSize is the width and height of FileIcon picture.
+const fileIconSize = 32
+
+// PictureType is the type of picture embedded into ID3v2 tag.
+type PictureType byte
+
+// Picture types defined by ID3v2.
+const (
+	OtherPicture PictureType = iota
+	FileIcon
+	OtherFileIcon
+	FrontCover
+	BackCover
+	LeafletPage
+	MediaPicture
+	LeadArtist
+	Artist
+	Conductor
+	Band
+	Composer
+	Lyricist
+	RecordingLocation
+	DuringRecording
+	DuringPerformance
+	ScreenCapture
+	BrightFish
+	Illustration
+	BandLogo
+	PublisherLogo
+)
+
+// Picture is the image embedded into ID3v2 tag.
+type Picture struct {
+	Type PictureType
+	// MIME is the type of image data. It's detected from data if empty.
+	MIME        string
+	Description string
+	// Encoding of the description.
+	Encoding TextEncoding
+	Data     []byte
+}
+
+func (p Picture) validate() error {
+	if len(p.Data) == 0 {
+		return fmt.Errorf("%w: picture data is empty", ErrInvalidParameter)
+	}
+	if p.Type > PublisherLogo {
+		return fmt.Errorf("%w: unknown picture type %d", ErrInvalidParameter, p.Type)
+	}
+	if p.MIME == "" && detectImageMIME(p.Data) == "" {
+		return fmt.Errorf("%w: unknown picture format", ErrInvalidParameter)
+	}
+	if p.Type == FileIcon {
+		if width, height, ok := pngSize(p.Data); !ok || width != fileIconSize || height != fileIconSize {
+			return fmt.Errorf("%w: file icon must be %dx%d PNG", ErrInvalidParameter, fileIconSize, fileIconSize)
+		}
+	}
+	return nil
+}
+
+// validatePictures validates pictures of the tag. Only one picture of
+// FileIcon and OtherFileIcon types is allowed.
+func validatePictures(pictures []Picture) error {
+	var icon, otherIcon bool
+	for i, p := range pictures {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("picture %d: %w", i, err)
+		}
+		switch {
+		case p.Type == FileIcon && icon, p.Type == OtherFileIcon && otherIcon:
+			return fmt.Errorf("%w: picture %d: only one picture of type %d is allowed", ErrInvalidParameter, i, p.Type)
+		case p.Type == FileIcon:
+			icon = true
+		case p.Type == OtherFileIcon:
+			otherIcon = true
+		}
+	}
+	return nil
+}
+
+// frame returns APIC frame.
+func (p Picture) frame(t id3Text) id3Frame {
+	mime := p.MIME
+	if mime == "" {
+		mime = detectImageMIME(p.Data)
+	}
+	t = t.with(p.Encoding)
+	body := []byte{t.encodingByte()}
+	body = append(appendLatin1(body, mime), 0)
+	body = append(body, byte(p.Type))
+	body = t.appendTerminated(body, p.Description)
+	body = append(body, p.Data...)
+	return id3Frame{id: "APIC", body: body}
+}
+
+// Extension returns the suggested file extension of the picture with the
+// leading dot. It's detected from data if MIME is empty or unknown. Empty
+// string is returned for unknown formats.
+func (p Picture) Extension() string {
+	if ext, ok := imageExtensions[p.MIME]; ok {
+		return ext
+	}
+	return imageExtensions[detectImageMIME(p.Data)]
+}
+
+// imageExtensions are file extensions of image MIME types.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/jpg":  ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/bmp":  ".bmp",
+	"image/webp": ".webp",
+}
+
+// ReadPictures returns pictures of ID3v2 tag at the start of r without
+// decoding audio. Only the tag is read. Pictures of chapters follow the
+// pictures of the tag.
+func ReadPictures(r io.Reader) ([]Picture, error) {
+	tag, err := readID3v2(r)
+	if err != nil || tag == nil {
+		return nil, err
+	}
+	v, frames, err := parseID3v2Frames(tag)
+	if err != nil {
+		return nil, err
+	}
+	var pictures, chapters []Picture
+	for _, f := range frames {
+		switch f.id {
+		case "APIC":
+			if p, ok := parsePicture(f.body); ok {
+				pictures = append(pictures, p)
+			}
+		case "CHAP":
+			id := id3Terminated(f.body, 0)
+			if len(f.body) < len(id)+1+16 {
+				return nil, fmt.Errorf("%w: CHAP frame of %d bytes", errInvalidID3v2, len(f.body))
+			}
+			sub, err := parseFrames(f.body[len(id)+1+16:], v)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range sub {
+				if p, ok := parsePicture(f.body); ok && f.id == "APIC" {
+					chapters = append(chapters, p)
+				}
+			}
+		}
+	}
+	return append(pictures, chapters...), nil
+}
+
+// ExtractPictures writes pictures of ID3v2 tag at the start of r into
+// files and returns their paths. Path returns the path of the picture
+// with its index, Extension of the picture can be used to name the file.
+func ExtractPictures(r io.Reader, path func(i int, p Picture) string) ([]string, error) {
+	if path == nil {
+		return nil, fmt.Errorf("%w: picture path function is nil", ErrInvalidParameter)
+	}
+	pictures, err := ReadPictures(r)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(pictures))
+	for i, p := range pictures {
+		name := path(i, p)
+		if err := os.WriteFile(name, p.Data, filePerm); err != nil {
+			return paths, fmt.Errorf("error writing picture %d: %w", i, err)
+		}
+		paths = append(paths, name)
+	}
+	return paths, nil
+}
+
+// parsePicture returns the picture of APIC frame body.
+func parsePicture(body []byte) (Picture, bool) {
+	if len(body) < 1 {
+		return Picture{}, false
+	}
+	e := body[0]
+	mime := id3Terminated(body[1:], 0)
+	rest := body[1+len(mime):]
+	if len(rest) < 2 {
+		return Picture{}, false
+	}
+	p := Picture{
+		MIME:     string(mime),
+		Type:     PictureType(rest[1]),
+		Encoding: Latin1 + TextEncoding(e),
+	}
+	rest = rest[2:]
+	desc := id3Terminated(rest, e)
+	p.Description = decodeString(e, desc)
+	terminator := 1
+	if e == 1 || e == 2 {
+		terminator = 2
+	}
+	if len(rest) < len(desc)+terminator {
+		return Picture{}, false
+	}
+	p.Data = rest[len(desc)+terminator:]
+	return p, true
+}
+
+// pngSignature is the magic bytes of PNG image.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// imageSignatures are magic bytes of image formats supported by players.
+var imageSignatures = []struct {
+	mime      string
+	signature []byte
+}{
+	{"image/jpeg", []byte{0xff, 0xd8, 0xff}},
+	{"image/png", pngSignature},
+	{"image/gif", []byte("GIF87a")},
+	{"image/gif", []byte("GIF89a")},
+	{"image/bmp", []byte("BM")},
+}
+
+// pngSize returns dimensions of PNG image from its IHDR chunk.
+func pngSize(data []byte) (width, height int, ok bool) {
+	if len(data) < 24 || !bytes.HasPrefix(data, pngSignature) || string(data[12:16]) != "IHDR" {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint32(data[16:])), int(binary.BigEndian.Uint32(data[20:])), true
+}
+
+// detectImageMIME returns MIME type of image data or empty string if the
+// format is unknown.
+func detectImageMIME(data []byte) string {
+	for _, s := range imageSignatures {
+		if bytes.HasPrefix(data, s.signature) {
+			return s.mime
+		}
+	}
+	if len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return "image/webp"
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestReadPictures(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), 1, 2, 3)
+	jpeg := []byte{0xff, 0xd8, 0xff, 1}
+	tests := []struct {
+		name     string
+		tag      mp3.ID3v2
+		expected []mp3.Picture
+	}{
+		{
+			name: "pictures",
+			tag: mp3.ID3v2{
+				Pictures: []mp3.Picture{
+					{Type: mp3.FrontCover, Description: "Обложка", Encoding: mp3.UTF16, Data: png},
+					{Type: mp3.BackCover, MIME: "image/jpeg", Encoding: mp3.Latin1, Data: jpeg},
+				},
+			},
+			expected: []mp3.Picture{
+				{Type: mp3.FrontCover, MIME: "image/png", Description: "Обложка", Encoding: mp3.UTF16, Data: png},
+				{Type: mp3.BackCover, MIME: "image/jpeg", Encoding: mp3.Latin1, Data: jpeg},
+			},
+		},
+		{
+			name: "chapter pictures",
+			tag: mp3.ID3v2{
+				Version:  mp3.ID3v23,
+				Pictures: []mp3.Picture{{Type: mp3.FrontCover, Encoding: mp3.Latin1, Data: jpeg}},
+				Chapters: []mp3.Chapter{
+					{Title: "Chapter", End: time.Second, Image: &mp3.Picture{Type: mp3.OtherPicture, Encoding: mp3.Latin1, Data: png}},
+				},
+			},
+			expected: []mp3.Picture{
+				{Type: mp3.FrontCover, MIME: "image/jpeg", Encoding: mp3.Latin1, Data: jpeg},
+				{Type: mp3.OtherPicture, MIME: "image/png", Encoding: mp3.Latin1, Data: png},
+			},
+		},
+		{
+			name: "without pictures",
+			tag:  mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}},
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(mp3.WithID3v2(test.tag))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		pictures, err := mp3.ReadPictures(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(pictures, test.expected) {
+			t.Errorf("%s: expected pictures: %+v got: %+v", test.name, test.expected, pictures)
+		}
+	}
+}
+
+func TestExtractPictures(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), 1, 2, 3)
+	data, err := encodeTagged(mp3.WithID3v2(mp3.ID3v2{
+		Pictures: []mp3.Picture{
+			{Type: mp3.FrontCover, Data: png},
+			{Type: mp3.BackCover, MIME: "image/x-custom", Data: []byte{1}},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir, err := os.MkdirTemp("", "pictures")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	paths, err := mp3.ExtractPictures(bytes.NewReader(data), func(i int, p mp3.Picture) string {
+		return filepath.Join(dir, strconv.Itoa(i)+p.Extension())
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "0.png"), filepath.Join(dir, "1")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected paths: %v got: %v", expected, paths)
+	}
+	for i, expected := range [][]byte{png, {1}} {
+		data, err := os.ReadFile(paths[i])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("picture %d: expected data: %v got: %v", i, expected, data)
+		}
+	}
+
+	if _, err := mp3.ExtractPictures(bytes.NewReader(data), nil); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
+
+func TestPictureExtension(t *testing.T) {
+	tests := []struct {
+		picture  mp3.Picture
+		expected string
+	}{
+		{picture: mp3.Picture{MIME: "image/jpeg"}, expected: ".jpg"},
+		{picture: mp3.Picture{Data: []byte("GIF89a")}, expected: ".gif"},
+		{picture: mp3.Picture{MIME: "image/unknown", Data: []byte("RIFF\x00\x00\x00\x00WEBP")}, expected: ".webp"},
+		{picture: mp3.Picture{MIME: "-->", Data: []byte("https://example.com/cover.jpg")}, expected: ""},
+	}
+	for _, test := range tests {
+		if ext := test.picture.Extension(); ext != test.expected {
+			t.Errorf("%+v: expected extension: %q got: %q", test.picture.MIME, test.expected, ext)
+		}
+	}
+}
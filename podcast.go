@@ -0,0 +1,146 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+// podcastBufferSize is the buffer size of the podcast pipe.
+const podcastBufferSize = 1152
+
+// Podcast is the episode that is mastered into distributable file.
+type Podcast struct {
+	// Preset is the encoder settings. PodcastPreset is used if bit rate
+	// mode isn't set.
+	Preset Preset
+	// Version and Encoding of the ID3v2 tag.
+	Version  ID3Version
+	Encoding TextEncoding
+	// Title is the title of the episode written as TIT2 frame.
+	Title string
+	// Show is the name of the podcast written as TALB frame.
+	Show string
+	// Author is written as TPE1 frame.
+	Author string
+	// Episode is the number of the episode written as TRCK frame. Zero
+	// number isn't written.
+	Episode int
+	// Date is the release date like 2006-01-02 written as TDRC frame or
+	// year of TYER frame in ID3v2.3.
+	Date string
+	// Description is the show notes written as COMM frame.
+	Description string
+	// Artwork is written as front cover APIC frame.
+	Artwork *Picture
+	// Chapters of the episode. Chapters with zero end last until the
+	// end of the episode.
+	Chapters []Chapter
+	// Options are passed to the Sink after options of the preset.
+	Options []SinkOption
+}
+
+// Master encodes the episode from source into file at path. File is
+// written atomically with ID3v2 tag of the episode metadata, chapters,
+// artwork and ReplayGain loudness.
+func (p Podcast) Master(ctx context.Context, source pipe.SourceAllocatorFunc, path string) error {
+	line, err := pipe.New(
+		podcastBufferSize,
+		pipe.Line{
+			Source: source,
+			Sink:   p.sink(path),
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return pipe.Wait(line.Start(ctx))
+}
+
+// sink returns the FileSink of the episode. Chapters are written on
+// flush, when the duration of the episode is known.
+func (p Podcast) sink(path string) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		preset := p.Preset
+		if preset.BitRateMode == nil {
+			preset = PodcastPreset
+		}
+		options := append(p.Options[:len(p.Options):len(p.Options)], WithID3v2(p.tag()), WithReplayGain())
+		if len(p.Chapters) > 0 {
+			reserve, err := p.chaptersReserve()
+			if err != nil {
+				return pipe.Sink{}, err
+			}
+			options = append(options, WithDeferredID3v2(reserve, func(info EncodingInfo) ID3v2 {
+				return ID3v2{Chapters: endChapters(p.Chapters, props.SampleRate.Duration(info.Samples))}
+			}))
+		}
+		return preset.FileSink(path, options...)(mctx, bufferSize, props)
+	}
+}
+
+// tag returns ID3v2 tag of the episode metadata.
+func (p Podcast) tag() ID3v2 {
+	tag := ID3v2{Version: p.Version, Encoding: p.Encoding}
+	text := func(id, value string) {
+		if value != "" {
+			tag.Text = append(tag.Text, TextFrame{ID: id, Value: value})
+		}
+	}
+	text("TIT2", p.Title)
+	text("TALB", p.Show)
+	text("TPE1", p.Author)
+	if p.Episode > 0 {
+		text("TRCK", strconv.Itoa(p.Episode))
+	}
+	if p.Version == ID3v23 {
+		if len(p.Date) >= 4 {
+			text("TYER", p.Date[:4])
+		}
+	} else {
+		text("TDRC", p.Date)
+	}
+	tag.Text = append(tag.Text, GenreFrame(p.Version, "Podcast"))
+	if p.Description != "" {
+		tag.Comments = []Comment{{Text: p.Description}}
+	}
+	if p.Artwork != nil {
+		artwork := *p.Artwork
+		artwork.Type = FrontCover
+		tag.Pictures = []Picture{artwork}
+	}
+	return tag
+}
+
+// chaptersReserve validates chapters and returns the size of their
+// frames. Size doesn't depend on chapter times.
+func (p Podcast) chaptersReserve() (int, error) {
+	chapters := ID3v2{Version: p.Version, Encoding: p.Encoding}
+	for _, c := range p.Chapters {
+		if c.End == 0 {
+			c.End = c.Start
+		}
+		chapters.Chapters = append(chapters.Chapters, c)
+	}
+	if err := chapters.validate(); err != nil {
+		return 0, fmt.Errorf("podcast chapters: %w", err)
+	}
+	return len(appendFrames(nil, p.Version, chapters.frames())), nil
+}
+
+// endChapters returns the copy of chapters with zero ends set to the
+// duration.
+func endChapters(chapters []Chapter, duration time.Duration) []Chapter {
+	ended := make([]Chapter, len(chapters))
+	for i, c := range chapters {
+		if c.End == 0 {
+			c.End = duration
+		}
+		ended[i] = c
+	}
+	return ended
+}
@@ -0,0 +1,90 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestPodcast(t *testing.T) {
+	dir, err := os.MkdirTemp("", "podcast")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "episode.mp3")
+	png := append([]byte("\x89PNG\r\n\x1a\n"), 1, 2, 3)
+	podcast := mp3.Podcast{
+		Preset: mp3.Preset{
+			BitRateMode:     mp3.CBR(128),
+			ChannelMode:     mp3.JointStereo,
+			EncodingQuality: mp3.DefaultEncodingQuality,
+			Options:         []mp3.SinkOption{mp3.WithBackend(mp3.Shine)},
+		},
+		Title:       "Pilot",
+		Show:        "The Show",
+		Author:      "The Host",
+		Episode:     1,
+		Date:        "2020-01-02",
+		Description: "Show notes",
+		Artwork:     &mp3.Picture{Data: png},
+		Chapters: []mp3.Chapter{
+			{Title: "Intro", End: time.Second},
+			{Title: "Interview", Start: time.Second},
+		},
+	}
+	if err := podcast.Master(context.Background(), tracksSource(2*time.Second), path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	frames, _ := parseID3v2(t, data)
+	var ids []string
+	for _, f := range frames {
+		ids = append(ids, f.id)
+	}
+	expected := []string{"TIT2", "TALB", "TPE1", "TRCK", "TDRC", "TCON", "COMM", "APIC", "TXXX", "TXXX", "CTOC", "CHAP", "CHAP"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected frames: %v got: %v", expected, ids)
+	}
+	if gain := string(frames[8].body); !strings.Contains(gain, "REPLAYGAIN_TRACK_GAIN") {
+		t.Errorf("expected track gain got: %q", gain)
+	}
+
+	chapters, err := mp3.ReadChapters(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedChapters := []mp3.Chapter{
+		{Title: "Intro", End: time.Second},
+		// the last chapter ends at the end of the episode.
+		{Title: "Interview", Start: time.Second, End: 2 * time.Second},
+	}
+	if !reflect.DeepEqual(chapters, expectedChapters) {
+		t.Errorf("expected chapters: %+v got: %+v", expectedChapters, chapters)
+	}
+
+	pictures, err := mp3.ReadPictures(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pictures) != 1 || pictures[0].Type != mp3.FrontCover || !bytes.Equal(pictures[0].Data, png) {
+		t.Errorf("expected front cover got: %+v", pictures)
+	}
+
+	podcast.Chapters = []mp3.Chapter{{Title: "Invalid", Start: time.Second, End: time.Millisecond}}
+	if err := podcast.Master(context.Background(), tracksSource(time.Second), filepath.Join(dir, "invalid.mp3")); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
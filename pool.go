@@ -0,0 +1,85 @@
+package mp3
+
+import (
+	"context"
+	"sync"
+
+	"pipelined.dev/pipe"
+)
+
+// pcmPools contain PCM buffers of Source and Sink per buffer shape, so
+// services that run many short pipes don't allocate them every time.
+var pcmPools sync.Map
+
+type (
+	// pcmShape is the number of channels and the length of PCM buffer.
+	pcmShape struct {
+		channels int
+		length   int
+	}
+
+	// pcmBuffer contains interleaved samples of the buffer as floats,
+	// 16-bit integers and bytes of little-endian PCM.
+	pcmBuffer struct {
+		floats []float64
+		ints   []int16
+		bytes  []byte
+	}
+
+	// pooledPCM takes the buffer from the pool on the first use and puts
+	// it back on flush. Buffer is taken again if the pipe is restarted.
+	pooledPCM struct {
+		shape pcmShape
+		buf   *pcmBuffer
+	}
+)
+
+func newPooledPCM(channels, length int) *pooledPCM {
+	return &pooledPCM{shape: pcmShape{channels: channels, length: length}}
+}
+
+// pool returns the pool of buffers of the shape.
+func (s pcmShape) pool() *sync.Pool {
+	if p, ok := pcmPools.Load(s); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := pcmPools.LoadOrStore(s, &sync.Pool{
+		New: func() interface{} {
+			size := s.channels * s.length
+			return &pcmBuffer{
+				floats: make([]float64, size),
+				ints:   make([]int16, size),
+				bytes:  make([]byte, size*bytesPerSample),
+			}
+		},
+	})
+	return p.(*sync.Pool)
+}
+
+// get returns the buffer of the component.
+func (p *pooledPCM) get() *pcmBuffer {
+	if p.buf == nil {
+		p.buf = p.shape.pool().Get().(*pcmBuffer)
+	}
+	return p.buf
+}
+
+// release puts the buffer back into the pool.
+func (p *pooledPCM) release() {
+	if p.buf == nil {
+		return
+	}
+	p.shape.pool().Put(p.buf)
+	p.buf = nil
+}
+
+// flusher releases the buffer after fn is done. Fn can be nil.
+func (p *pooledPCM) flusher(fn pipe.FlushFunc) pipe.FlushFunc {
+	return func(ctx context.Context) error {
+		defer p.release()
+		if fn == nil {
+			return nil
+		}
+		return fn(ctx)
+	}
+}
@@ -0,0 +1,64 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+)
+
+func TestPooledBuffers(t *testing.T) {
+	data, err := os.ReadFile(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	transcode := func() ([]byte, error) {
+		var out bytes.Buffer
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: mp3.Source(bytes.NewReader(data)),
+				Sink:   mp3.Sink(&out, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	expected, err := transcode()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// concurrent pipes don't share buffers.
+	const pipes = 4
+	var (
+		wg      sync.WaitGroup
+		results [pipes][]byte
+		errs    [pipes]error
+	)
+	for i := 0; i < pipes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = transcode()
+		}(i)
+	}
+	wg.Wait()
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("pipe %d: unexpected error: %v", i, errs[i])
+		}
+		if !bytes.Equal(results[i], expected) {
+			t.Errorf("pipe %d: expected the same output", i)
+		}
+	}
+}
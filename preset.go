@@ -0,0 +1,74 @@
+package mp3
+
+import (
+	"io"
+
+	"pipelined.dev/pipe"
+)
+
+// Preset is the set of encoder settings tuned for the kind of content.
+type Preset struct {
+	BitRateMode     BitRateMode
+	ChannelMode     ChannelMode
+	EncodingQuality EncodingQuality
+	// Options are applied before the options passed to the sink, so
+	// they can be overridden.
+	Options []SinkOption
+}
+
+// PodcastPreset encodes spoken word with music at 128 kbps CBR joint
+// stereo, that is accepted by all podcast directories.
+var PodcastPreset = Preset{
+	BitRateMode:     CBR(128),
+	ChannelMode:     JointStereo,
+	EncodingQuality: 2,
+}
+
+// VoicePreset encodes speech, like audiobooks, into mono 64 kbps ABR.
+// Rumble below 80 Hz and hiss above the voice range are filtered out,
+// so bits are spent on intelligibility. Use With and fields of the copy
+// to tweak it.
+var VoicePreset = Preset{
+	BitRateMode:     ABR(64),
+	ChannelMode:     Mono,
+	EncodingQuality: 2,
+	Options: []SinkOption{
+		WithHighpass(80),
+		WithLowpass(11000),
+	},
+}
+
+// TelephonyPreset encodes telephony into 8 kHz mono 16 kbps CBR
+// MPEG-2.5. Frequencies outside of the telephone band are filtered out.
+var TelephonyPreset = Preset{
+	BitRateMode:     CBR(16),
+	ChannelMode:     Mono,
+	EncodingQuality: 2,
+	Options: []SinkOption{
+		WithTelephony(),
+		WithHighpass(300),
+		WithLowpass(3400),
+	},
+}
+
+// With returns the copy of the preset with options added after its own
+// ones, so they override the preset settings.
+func (p Preset) With(options ...SinkOption) Preset {
+	p.Options = p.options(options)
+	return p
+}
+
+// Sink returns the Sink with settings of the preset.
+func (p Preset) Sink(w io.Writer, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return Sink(w, p.BitRateMode, p.ChannelMode, p.EncodingQuality, p.options(options)...)
+}
+
+// FileSink returns the FileSink with settings of the preset.
+func (p Preset) FileSink(path string, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return FileSink(path, p.BitRateMode, p.ChannelMode, p.EncodingQuality, p.options(options)...)
+}
+
+// options returns options of the preset followed by the provided ones.
+func (p Preset) options(options []SinkOption) []SinkOption {
+	return append(p.Options[:len(p.Options):len(p.Options)], options...)
+}
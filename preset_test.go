@@ -0,0 +1,65 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+func TestPreset(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name     string
+		preset   mp3.Preset
+		channels int
+		err      error
+	}{
+		{
+			name:     "podcast",
+			preset:   mp3.PodcastPreset,
+			channels: 2,
+		},
+		{
+			name:     "voice mono",
+			preset:   mp3.VoicePreset,
+			channels: 1,
+		},
+		{
+			name:     "voice stereo",
+			preset:   mp3.VoicePreset,
+			channels: 2,
+		},
+		{
+			name:     "tweaked voice",
+			preset:   mp3.VoicePreset.With(mp3.WithLowpass(8000)),
+			channels: 1,
+		},
+		{
+			name:     "invalid tweak",
+			preset:   mp3.VoicePreset.With(mp3.WithHighpass(0)),
+			channels: 1,
+			err:      mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		_, err := test.preset.Sink(&out)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: test.channels})
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+	// tweaks don't change the preset.
+	if n := len(mp3.VoicePreset.Options); n != 2 {
+		t.Errorf("expected 2 voice preset options got: %d", n)
+	}
+}
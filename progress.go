@@ -0,0 +1,50 @@
+package mp3
+
+import (
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+// Progress reports the state of encoding. Values are totals since the
+// start of encoding.
+type Progress struct {
+	// Samples is the number of consumed input samples per channel.
+	Samples int
+	// Frames is the number of frames emitted by the encoder.
+	Frames int
+	// Bytes is the number of bytes emitted by the encoder.
+	Bytes int64
+}
+
+// WithProgress sets the function that is called after every buffer is
+// consumed by the Sink. Encoder buffers the input, so emitted frames lag
+// behind consumed samples.
+func WithProgress(fn func(Progress)) SinkOption {
+	return func(o *sinkOptions) {
+		o.progress = fn
+	}
+}
+
+// progress calls the progress function after every buffer is consumed.
+// Start time of encoding is recorded on the first call.
+func progress(fn pipe.SinkFunc, samples func() int, stats *statsWriter, report func(Progress)) pipe.SinkFunc {
+	return func(floats signal.Floating) error {
+		if stats.start.IsZero() {
+			stats.start = time.Now()
+		}
+		if err := fn(floats); err != nil {
+			return err
+		}
+		if report == nil {
+			return nil
+		}
+		report(Progress{
+			Samples: samples(),
+			Frames:  stats.frames,
+			Bytes:   stats.bytes,
+		})
+		return nil
+	}
+}
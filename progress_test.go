@@ -0,0 +1,77 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkProgress(t *testing.T) {
+	skipWithoutLame(t)
+	type sinkFunc func(*bytes.Buffer, ...mp3.SinkOption) pipe.SinkAllocatorFunc
+	tests := []struct {
+		name string
+		sink sinkFunc
+	}{
+		{
+			name: "sink",
+			sink: func(out *bytes.Buffer, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				return mp3.Sink(out, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+		},
+		{
+			name: "parallel",
+			sink: func(out *bytes.Buffer, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				return mp3.ParallelSink(out, 2, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+		},
+	}
+	for _, test := range tests {
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      700000,
+			Value:      0.5,
+		}
+		var (
+			out     bytes.Buffer
+			reports []mp3.Progress
+		)
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: test.sink(&out, mp3.WithProgress(func(p mp3.Progress) {
+					reports = append(reports, p)
+				})),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if expected := (source.Limit + bufferSize - 1) / bufferSize; len(reports) != expected {
+			t.Fatalf("%s: expected %d reports got: %d", test.name, expected, len(reports))
+		}
+		for i := 1; i < len(reports); i++ {
+			prev, next := reports[i-1], reports[i]
+			if next.Samples <= prev.Samples || next.Frames < prev.Frames || next.Bytes < prev.Bytes {
+				t.Fatalf("%s: progress isn't increasing: %+v %+v", test.name, prev, next)
+			}
+		}
+		last := reports[len(reports)-1]
+		if last.Samples != source.Limit {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, source.Limit, last.Samples)
+		}
+		if last.Frames == 0 || last.Bytes == 0 || last.Bytes > int64(out.Len()) {
+			t.Errorf("%s: unexpected progress: %+v output bytes: %d", test.name, last, out.Len())
+		}
+	}
+}
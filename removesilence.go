@@ -0,0 +1,154 @@
+package mp3
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// SilenceRemoval configures RemoveSilence.
+type SilenceRemoval struct {
+	// Threshold is the level in dBFS below which the input is silent.
+	// Default is -50 dBFS.
+	Threshold float64
+	// MinSilence is the min duration of silence that is removed.
+	// Default is 2 seconds.
+	MinSilence time.Duration
+	// Padding is the duration of silence kept at both ends of removed
+	// stretch.
+	Padding time.Duration
+}
+
+// RemoveSilence returns the source that reads the signal of the source
+// and removes silent stretches that last at least min silence, except
+// padding at both ends. Silence at the start and the end of the signal
+// is removed the same way. It wraps the source instead of being the
+// processor, because silence is held until its duration is known and
+// processors can't output held samples after the input ends.
+func RemoveSilence(source pipe.SourceAllocatorFunc, s SilenceRemoval) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		if s.Threshold > 0 || math.IsNaN(s.Threshold) {
+			return pipe.Source{}, fmt.Errorf("%w: silence threshold %v dBFS is above full scale", ErrInvalidParameter, s.Threshold)
+		}
+		if s.MinSilence < 0 {
+			return pipe.Source{}, fmt.Errorf("%w: min silence %v is negative", ErrInvalidParameter, s.MinSilence)
+		}
+		if s.Padding < 0 {
+			return pipe.Source{}, fmt.Errorf("%w: silence padding %v is negative", ErrInvalidParameter, s.Padding)
+		}
+		if s.Threshold == 0 {
+			s.Threshold = defaultSilenceThreshold
+		}
+		if s.MinSilence == 0 {
+			s.MinSilence = defaultMinSilence
+		}
+		src, err := source(mctx, bufferSize)
+		if err != nil {
+			return pipe.Source{}, err
+		}
+		r := silenceRemover{
+			fn:         src.SourceFunc,
+			threshold:  math.Pow(10, s.Threshold/20),
+			minSilence: src.SampleRate.Events(s.MinSilence),
+			padding:    src.SampleRate.Events(s.Padding),
+			channels:   src.Channels,
+			in: signal.Allocator{
+				Channels: src.Channels,
+				Capacity: bufferSize,
+				Length:   bufferSize,
+			}.Float64(),
+		}
+		src.SourceFunc = r.sourceFunc
+		return src, nil
+	}
+}
+
+// silenceRemover reads the source and holds silent samples until it's
+// known if the silence is removed.
+type silenceRemover struct {
+	fn         pipe.SourceFunc
+	threshold  float64
+	minSilence int
+	padding    int
+	channels   int
+	in         signal.Floating
+	frame      []float64
+	// interleaved samples ready to output.
+	pending []float64
+	// interleaved silent samples after leading padding.
+	held []float64
+	// length of current silent stretch.
+	silence int
+	eof     bool
+}
+
+func (r *silenceRemover) sourceFunc(out signal.Floating) (int, error) {
+	for len(r.pending) < out.Len() && !r.eof {
+		n, err := r.fn(r.in)
+		if err == io.EOF {
+			r.release()
+			r.eof = true
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.write(r.in.Slice(0, n))
+	}
+	if len(r.pending) == 0 {
+		return 0, io.EOF
+	}
+	n := len(r.pending)
+	if n > out.Len() {
+		n = out.Len()
+	}
+	for i := 0; i < n; i++ {
+		out.SetSample(i, r.pending[i])
+	}
+	r.pending = r.pending[:copy(r.pending, r.pending[n:])]
+	return signal.ChannelLength(n, r.channels), nil
+}
+
+// write removes silence from the read samples.
+func (r *silenceRemover) write(floats signal.Floating) {
+	keep := r.padding * r.channels
+	for i := 0; i < floats.Length(); i++ {
+		frame := r.frame[:0]
+		silent := true
+		for c := 0; c < r.channels; c++ {
+			v := floats.Sample(i*r.channels + c)
+			silent = silent && math.Abs(v) < r.threshold
+			frame = append(frame, v)
+		}
+		r.frame = frame
+		if !silent {
+			r.release()
+			r.pending = append(r.pending, frame...)
+			continue
+		}
+		if r.silence++; r.silence <= r.padding {
+			r.pending = append(r.pending, frame...)
+			continue
+		}
+		r.held = append(r.held, frame...)
+		// stretch is removed, only trailing padding is held.
+		if r.silence >= r.minSilence && len(r.held) > 2*keep {
+			r.held = r.held[:copy(r.held, r.held[len(r.held)-keep:])]
+		}
+	}
+}
+
+// release outputs held samples when silent stretch ends.
+func (r *silenceRemover) release() {
+	held := r.held
+	if keep := r.padding * r.channels; r.silence >= r.minSilence && len(held) > keep {
+		held = held[len(held)-keep:]
+	}
+	r.pending = append(r.pending, held...)
+	r.held, r.silence = r.held[:0], 0
+}
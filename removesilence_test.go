@@ -0,0 +1,93 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/signal"
+)
+
+func TestRemoveSilence(t *testing.T) {
+	tests := []struct {
+		name     string
+		removal  mp3.SilenceRemoval
+		expected time.Duration
+	}{
+		{
+			name:     "without padding",
+			expected: 3500 * time.Millisecond,
+		},
+		{
+			name:     "padding",
+			removal:  mp3.SilenceRemoval{Padding: 250 * time.Millisecond},
+			expected: 4500 * time.Millisecond,
+		},
+		{
+			name:     "min silence",
+			removal:  mp3.SilenceRemoval{MinSilence: 500 * time.Millisecond},
+			expected: 3 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		sink := mock.Sink{Discard: true}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				// the second silence is shorter than default min silence.
+				Source: mp3.RemoveSilence(tracksSource(
+					time.Second, 3*time.Second, time.Second, 500*time.Millisecond, time.Second, 2*time.Second,
+				), test.removal),
+				Sink: sink.Sink(),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		// sine samples at zero crossings are below the threshold.
+		const tolerance = 4
+		expected := signal.Frequency(44100).Events(test.expected)
+		if d := sink.Counter.Samples - expected; d < -tolerance || d > tolerance {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, expected, sink.Counter.Samples)
+		}
+	}
+}
+
+func TestRemoveSilenceInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		removal mp3.SilenceRemoval
+	}{
+		{
+			name:    "threshold",
+			removal: mp3.SilenceRemoval{Threshold: 1},
+		},
+		{
+			name:    "min silence",
+			removal: mp3.SilenceRemoval{MinSilence: -time.Second},
+		},
+		{
+			name:    "padding",
+			removal: mp3.SilenceRemoval{Padding: -time.Second},
+		},
+	}
+	for _, test := range tests {
+		_, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: mp3.RemoveSilence(tracksSource(time.Second), test.removal),
+				Sink:   (&mock.Sink{Discard: true}).Sink(),
+			},
+		)
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
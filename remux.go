@@ -0,0 +1,23 @@
+package mp3
+
+import "io"
+
+// remuxChunkSize is the size of reads of FrameSink.ReadFrom.
+const remuxChunkSize = 64 * 1024
+
+// Remux copies MP3 stream from r into w frame by frame without decoding
+// it, so large archives are copied and retagged at the speed of the
+// disk. Options are applied as with NewFrameSink: tags of the input are
+// replaced with the ones of options and Xing/Info frame is rewritten if w
+// is seekable. Memory is bound by the size of the largest frame or input
+// ID3v2 tag. Use Cut to trim the stream without decoding.
+func Remux(r io.Reader, w io.Writer, options ...SinkOption) error {
+	s, err := NewFrameSink(w, options...)
+	if err != nil {
+		return err
+	}
+	if _, err := s.ReadFrom(r); err != nil {
+		return err
+	}
+	return s.Flush()
+}
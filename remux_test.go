@@ -0,0 +1,69 @@
+package mp3_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"testing/iotest"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestRemux(t *testing.T) {
+	input, inputInfo := encodeShine(t)
+	id3v2 := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 5, 1, 2, 3, 4, 5}
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	data := append(append(append([]byte(nil), id3v2...), input...), id3v1...)
+
+	f, err := os.CreateTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	var info mp3.EncodingInfo
+	err = mp3.Remux(iotest.HalfReader(bytes.NewReader(data)), f,
+		mp3.WithID3v2(mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}}),
+		mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	output, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	frames, audio := parseID3v2(t, output)
+	if len(frames) != 1 || frames[0].id != "TIT2" {
+		t.Errorf("expected TIT2 frame got: %v", frames)
+	}
+	inFrames, outFrames := splitFrames(t, input), splitFrames(t, audio)
+	if string(outFrames[0][4+32:4+36]) != "Info" {
+		t.Errorf("expected Info tag")
+	}
+	if !bytes.Equal(bytes.Join(inFrames[1:], nil), bytes.Join(outFrames[1:], nil)) {
+		t.Errorf("audio frames don't match")
+	}
+	if info.Delay != inputInfo.Delay || info.Padding != inputInfo.Padding {
+		t.Errorf("expected delay and padding: %d %d got: %d %d", inputInfo.Delay, inputInfo.Padding, info.Delay, info.Padding)
+	}
+}
+
+func TestRemuxErrors(t *testing.T) {
+	input, _ := encodeShine(t)
+	tests := []struct {
+		name string
+		r    io.Reader
+	}{
+		{name: "not mp3", r: bytes.NewReader([]byte("not mp3 data"))},
+		{name: "truncated", r: bytes.NewReader(input[:len(input)-10])},
+		{name: "read error", r: iotest.TimeoutReader(bytes.NewReader(input))},
+	}
+	for _, test := range tests {
+		if err := mp3.Remux(test.r, io.Discard); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
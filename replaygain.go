@@ -0,0 +1,55 @@
+package mp3
+
+import (
+	"fmt"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+// replayGainReference is the reference loudness of ReplayGain 2.0 in
+// LUFS.
+const replayGainReference = -18
+
+// WithReplayGain makes the Sink measure loudness of the input according
+// to EBU R128 and write ReplayGain 2.0 track gain and peak into ID3v2
+// tag. Space for the values is reserved in the tag, that is rewritten on
+// flush, so the writer must be seekable. Tags are not written for
+// silence.
+func WithReplayGain() SinkOption {
+	return func(o *sinkOptions) {
+		o.replayGain = true
+	}
+}
+
+// measure feeds the meter with the signal before it's encoded.
+func measure(fn pipe.SinkFunc, m *loudnessMeter) pipe.SinkFunc {
+	return func(floats signal.Floating) error {
+		m.write(floats)
+		return fn(floats)
+	}
+}
+
+// replayGainDeferred returns TXXX frames with measured gain and peak.
+// Gated loudness keeps the gain within two integer digits.
+func replayGainDeferred(tag ID3v2, m *loudnessMeter) deferredFrames {
+	return deferredFrames{
+		reserve: len(appendFrames(nil, tag.Version, replayGainText(tag, -99.99, 99.999999))),
+		frames: func(EncodingInfo) ([]id3Frame, error) {
+			loudness := m.loudness()
+			if math.IsInf(loudness, -1) {
+				return nil, nil
+			}
+			return replayGainText(tag, replayGainReference-loudness, m.peak), nil
+		},
+	}
+}
+
+func replayGainText(tag ID3v2, gain, peak float64) []id3Frame {
+	text := id3Text{version: tag.Version, encoding: tag.Encoding}
+	return []id3Frame{
+		UserText{Description: "REPLAYGAIN_TRACK_GAIN", Value: fmt.Sprintf("%.2f dB", gain)}.frame(text),
+		UserText{Description: "REPLAYGAIN_TRACK_PEAK", Value: fmt.Sprintf("%.6f", peak)}.frame(text),
+	}
+}
@@ -0,0 +1,140 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestSinkReplayGain(t *testing.T) {
+	tests := []struct {
+		name      string
+		amplitude float64
+		gain      float64
+		peak      float64
+		tagged    bool
+	}{
+		// 1 kHz sine is amplified by 0.69 dB with K-weighting.
+		{name: "sine", amplitude: 0.5, gain: -18 + 6.02, peak: 0.5, tagged: true},
+		{name: "quiet sine", amplitude: 0.05, gain: -18 + 26.02, peak: 0.05, tagged: true},
+		{name: "silence"},
+	}
+	for _, test := range tests {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: sineSource(1000, test.amplitude, 3*44100),
+				Sink: mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithReplayGain(),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		f.Close()
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		frames, audio := parseID3v2(t, data)
+		values := map[string]string{}
+		for _, f := range frames {
+			if f.id != "TXXX" {
+				continue
+			}
+			fields := bytes.SplitN(f.body[1:], []byte{0}, 2)
+			values[string(fields[0])] = string(fields[1])
+		}
+		if string(splitFrames(t, audio)[0][4+32:4+36]) != "Info" {
+			t.Errorf("%s: expected Info tag after ID3v2 tag", test.name)
+		}
+		if !test.tagged {
+			if len(values) != 0 {
+				t.Errorf("%s: unexpected tags: %v", test.name, values)
+			}
+			continue
+		}
+		gain, err := strconv.ParseFloat(strings.TrimSuffix(values["REPLAYGAIN_TRACK_GAIN"], " dB"), 64)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if math.Abs(gain-test.gain) > 0.1 {
+			t.Errorf("%s: expected gain: %.2f got: %.2f", test.name, test.gain, gain)
+		}
+		peak, err := strconv.ParseFloat(values["REPLAYGAIN_TRACK_PEAK"], 64)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if math.Abs(peak-test.peak) > 0.001 {
+			t.Errorf("%s: expected peak: %.6f got: %.6f", test.name, test.peak, peak)
+		}
+	}
+}
+
+func TestSinkReplayGainNotSeekable(t *testing.T) {
+	_, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: sineSource(1000, 0.5, 44100),
+			Sink: mp3.Sink(&bytes.Buffer{}, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithReplayGain(),
+			),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
+
+// sineSource returns stereo 44100 Hz source of sine wave.
+func sineSource(frequency, amplitude float64, samples int) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		var pos int
+		return pipe.Source{
+			SourceFunc: func(out signal.Floating) (int, error) {
+				if pos == samples {
+					return 0, io.EOF
+				}
+				n := out.Length()
+				if left := samples - pos; n > left {
+					n = left
+				}
+				for i := 0; i < n; i++ {
+					v := amplitude * math.Sin(2*math.Pi*frequency*float64(pos+i)/44100)
+					for c := 0; c < out.Channels(); c++ {
+						out.SetSample(i*out.Channels()+c, v)
+					}
+				}
+				pos += n
+				return n, nil
+			},
+			SignalProperties: pipe.SignalProperties{
+				Channels:   2,
+				SampleRate: 44100,
+			},
+		}, nil
+	}
+}
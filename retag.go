@@ -0,0 +1,383 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RewriteID3v2 replaces ID3v2 tag of existing MP3 file without decoding
+// audio. If the new tag fits the existing one, it's overwritten in place
+// and the rest of the existing tag becomes padding. Otherwise the file is
+// rewritten atomically with the tag padded by Padding bytes. Audio and
+// ID3v1 tag are kept as is.
+func RewriteID3v2(path string, tag ID3v2) error {
+	if err := tag.validate(); err != nil {
+		return err
+	}
+	b, err := tag.bytes(nil, 0)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, id3v2HeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("error reading ID3v2 tag: %w", err)
+	}
+	size := id3v2TagSize(header)
+	if size >= len(b) {
+		if b, err = tag.bytes(nil, size); err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(b, 0); err != nil {
+			return fmt.Errorf("error writing ID3v2 tag: %w", err)
+		}
+		return f.Sync()
+	}
+	if b, err = tag.bytes(nil, len(b)+tag.Padding); err != nil {
+		return err
+	}
+	return rewriteFile(f, path, b, int64(size))
+}
+
+// rewriteFile replaces the file with the tag followed by the content of f
+// after offset.
+func rewriteFile(f *os.File, path string, tag []byte, offset int64) error {
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	err = func() error {
+		if _, err := tmp.Write(tag); err != nil {
+			return fmt.Errorf("error writing ID3v2 tag: %w", err)
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tmp, f); err != nil {
+			return fmt.Errorf("error copying audio: %w", err)
+		}
+		if err := tmp.Sync(); err != nil {
+			return err
+		}
+		return os.Chmod(tmp.Name(), stat.Mode().Perm())
+	}()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error renaming temporary file: %w", err)
+	}
+	return nil
+}
+
+// errInvalidID3v2 is returned when existing ID3v2 tag can't be parsed.
+var errInvalidID3v2 = errors.New("invalid ID3v2 tag")
+
+// Retag describes how RetagStream changes tags of the stream.
+type Retag struct {
+	// ID3v2 replaces the existing ID3v2 tag. Nil keeps the existing
+	// tag as is.
+	ID3v2 *ID3v2
+	// Merge keeps frames of the existing ID3v2 tag that aren't in the
+	// new one. Frames with the same identifier, or the same identifier
+	// and description or owner for frames like TXXX and PRIV, are
+	// replaced. Merged tag is written in the version of the existing
+	// tag. Compressed and encrypted frames are dropped.
+	Merge bool
+	// ID3v1 replaces or adds ID3v1 tag at the end of the stream. Nil
+	// keeps the existing tag.
+	ID3v1 *ID3v1
+}
+
+// RetagStream copies MP3 stream from r into w with changed tags. Audio
+// bytes are copied as is without parsing, so it's suitable for fast
+// retagging of large files.
+func RetagStream(r io.Reader, w io.Writer, t Retag) error {
+	if t.ID3v2 != nil {
+		if err := t.ID3v2.validate(); err != nil {
+			return err
+		}
+	}
+	var existing []byte
+	if t.ID3v2 != nil && !t.Merge {
+		// replaced tag isn't needed, so it's skipped without reading.
+		var err error
+		if r, err = skipID3v2(r); err != nil {
+			return err
+		}
+	} else {
+		header := make([]byte, id3v2HeaderSize)
+		n, err := io.ReadFull(r, header)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return fmt.Errorf("error reading ID3v2 tag: %w", err)
+		}
+		header = header[:n]
+		if size := id3v2TagSize(header); size > 0 {
+			existing = make([]byte, size)
+			copy(existing, header)
+			if _, err := io.ReadFull(r, existing[len(header):]); err != nil {
+				return fmt.Errorf("error reading ID3v2 tag: %w", err)
+			}
+			header = nil
+		}
+		// bytes read after the tag belong to the stream.
+		r = io.MultiReader(bytes.NewReader(header), r)
+	}
+	tag, err := t.tag(existing)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(tag); err != nil {
+		return fmt.Errorf("error writing ID3v2 tag: %w", err)
+	}
+	if t.ID3v1 == nil {
+		if _, err := io.Copy(w, r); err != nil {
+			return fmt.Errorf("error copying audio: %w", err)
+		}
+		return nil
+	}
+	return copyReplacingID3v1(w, r, t.ID3v1.bytes())
+}
+
+// tag returns the ID3v2 tag that replaces the existing one.
+func (t Retag) tag(existing []byte) ([]byte, error) {
+	if t.ID3v2 == nil {
+		return existing, nil
+	}
+	tag := *t.ID3v2
+	if !t.Merge || existing == nil {
+		b, err := tag.bytes(nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		return tag.bytes(nil, len(b)+tag.Padding)
+	}
+	v, frames, err := parseID3v2Frames(existing)
+	if err != nil {
+		return nil, err
+	}
+	tag.Version = v
+	if err := tag.validate(); err != nil {
+		return nil, err
+	}
+	replaced := make(map[string]bool)
+	for _, f := range tag.frames() {
+		replaced[id3FrameKey(f)] = true
+	}
+	var kept []id3Frame
+	for _, f := range frames {
+		if !replaced[id3FrameKey(f)] {
+			kept = append(kept, f)
+		}
+	}
+	b, err := tag.bytes(kept, 0)
+	if err != nil {
+		return nil, err
+	}
+	return tag.bytes(kept, len(b)+tag.Padding)
+}
+
+// copyReplacingID3v1 copies r into w and replaces ID3v1 tag at the end
+// of the stream. The tag is appended if the stream doesn't have it.
+func copyReplacingID3v1(w io.Writer, r io.Reader, tag []byte) error {
+	var (
+		buf  = make([]byte, 32*1024+id3v1Size)
+		tail int
+	)
+	for {
+		n, err := r.Read(buf[tail:])
+		tail += n
+		// last bytes are held until the end of the stream.
+		if flush := tail - id3v1Size; flush > 0 {
+			if _, err := w.Write(buf[:flush]); err != nil {
+				return fmt.Errorf("error copying audio: %w", err)
+			}
+			tail = copy(buf, buf[flush:tail])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error copying audio: %w", err)
+		}
+	}
+	rest := buf[:tail]
+	if len(rest) == id3v1Size && isID3v1(rest) {
+		rest = nil
+	}
+	if _, err := w.Write(rest); err != nil {
+		return fmt.Errorf("error copying audio: %w", err)
+	}
+	if _, err := w.Write(tag); err != nil {
+		return fmt.Errorf("error writing ID3v1 tag: %w", err)
+	}
+	return nil
+}
+
+// readID3v2 reads ID3v2 tag at the start of r. Nil is returned if the
+// stream doesn't start with the tag.
+func readID3v2(r io.Reader) ([]byte, error) {
+	header := make([]byte, id3v2HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading ID3v2 tag: %w", err)
+	}
+	size := id3v2TagSize(header)
+	if size == 0 {
+		return nil, nil
+	}
+	tag := make([]byte, size)
+	copy(tag, header)
+	if _, err := io.ReadFull(r, tag[id3v2HeaderSize:]); err != nil {
+		return nil, fmt.Errorf("error reading ID3v2 tag: %w", err)
+	}
+	return tag, nil
+}
+
+// skipID3v2 skips ID3v2 tag at the start of r. Only the header of the tag
+// is read, the rest is discarded or seeked over without allocating it.
+// Returned reader continues after the tag and isn't io.Seeker.
+func skipID3v2(r io.Reader) (io.Reader, error) {
+	header := make([]byte, id3v2HeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("error reading ID3v2 tag: %w", err)
+	}
+	header = header[:n]
+	if size := id3v2TagSize(header); size > 0 {
+		rest := int64(size - len(header))
+		if s, ok := r.(io.Seeker); ok {
+			_, err = s.Seek(rest, io.SeekCurrent)
+		} else {
+			_, err = io.CopyN(io.Discard, r, rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error skipping ID3v2 tag: %w", err)
+		}
+		header = nil
+	}
+	return io.MultiReader(bytes.NewReader(header), r), nil
+}
+
+// parseID3v2Frames returns the version and frames of ID3v2.3 or ID3v2.4
+// tag. Frames with format flags, like compression, are dropped.
+func parseID3v2Frames(tag []byte) (ID3Version, []id3Frame, error) {
+	var v ID3Version
+	switch tag[3] {
+	case 3:
+		v = ID3v23
+	case 4:
+		v = ID3v24
+	default:
+		return 0, nil, fmt.Errorf("%w: unsupported version 2.%d", errInvalidID3v2, tag[3])
+	}
+	flags := tag[5]
+	body := tag[id3v2HeaderSize : id3v2HeaderSize+syncsafe(tag[6:10])]
+	// tag-level unsynchronisation is used only by ID3v2.3.
+	if v == ID3v23 && flags&0x80 != 0 {
+		body = bytes.ReplaceAll(body, []byte{0xff, 0}, []byte{0xff})
+	}
+	if flags&0x40 != 0 && len(body) >= 4 {
+		size := syncsafe(body)
+		if v == ID3v23 {
+			size = int(binary.BigEndian.Uint32(body)) + 4
+		}
+		if size > len(body) {
+			return 0, nil, fmt.Errorf("%w: extended header of %d bytes", errInvalidID3v2, size)
+		}
+		body = body[size:]
+	}
+	frames, err := parseFrames(body, v)
+	return v, frames, err
+}
+
+// parseFrames returns frames of the tag body or embedded frames of CHAP
+// and CTOC frames. Frames with format flags are dropped.
+func parseFrames(body []byte, v ID3Version) ([]id3Frame, error) {
+	var frames []id3Frame
+	for len(body) >= id3v2HeaderSize && body[0] != 0 {
+		size := syncsafe(body[4:8])
+		if v == ID3v23 {
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		if size > len(body)-id3v2HeaderSize {
+			return nil, fmt.Errorf("%w: frame %q of %d bytes", errInvalidID3v2, body[:4], size)
+		}
+		if body[9] == 0 {
+			frames = append(frames, id3Frame{
+				id:   string(body[:4]),
+				body: body[id3v2HeaderSize : id3v2HeaderSize+size],
+			})
+		}
+		body = body[id3v2HeaderSize+size:]
+	}
+	return frames, nil
+}
+
+// id3FrameKey returns the key that identifies the frame in the tag.
+// Frames that can occur several times are identified by description,
+// owner or type.
+func id3FrameKey(f id3Frame) string {
+	b := f.body
+	switch f.id {
+	case "TXXX", "WXXX":
+		if len(b) > 0 {
+			return f.id + string(id3Terminated(b[1:], b[0]))
+		}
+	case "COMM", "USLT":
+		if len(b) > 4 {
+			return f.id + string(b[1:4]) + string(id3Terminated(b[4:], b[0]))
+		}
+	case "UFID", "PRIV", "CHAP", "CTOC":
+		return f.id + string(id3Terminated(b, 0))
+	case "APIC":
+		if len(b) > 0 {
+			if mime := id3Terminated(b[1:], 0); len(b) > len(mime)+2 {
+				return f.id + string(b[len(mime)+2])
+			}
+		}
+	}
+	return f.id
+}
+
+// id3Terminated returns the string of encoding e up to its terminator.
+func id3Terminated(b []byte, e byte) []byte {
+	// UTF-16 strings are terminated by two aligned null bytes.
+	if e == 1 || e == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return b[:i]
+			}
+		}
+		return b
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return b[:i]
+	}
+	return b
+}
@@ -0,0 +1,221 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestRewriteID3v2(t *testing.T) {
+	title := func(s string) mp3.ID3v2 {
+		return mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: s}}}
+	}
+	long := make([]byte, 600)
+	for i := range long {
+		long[i] = 'a'
+	}
+	tests := []struct {
+		name    string
+		options []mp3.SinkOption
+		tag     mp3.ID3v2
+		// expected size of the tag, zero if any size is expected.
+		size int
+		err  error
+	}{
+		{
+			name:    "in place",
+			options: []mp3.SinkOption{mp3.WithID3v2(mp3.ID3v2{Text: title("Old").Text, Padding: 512})},
+			tag:     title("New"),
+			size:    10 + 14 + 512,
+		},
+		{
+			name: "without tag",
+			tag:  mp3.ID3v2{Text: title("New").Text, Padding: 100},
+			size: 10 + 14 + 100,
+		},
+		{
+			name:    "larger tag",
+			options: []mp3.SinkOption{mp3.WithID3v2(mp3.ID3v2{Text: title("Old").Text, Padding: 512})},
+			tag:     mp3.ID3v2{Text: title(string(long)).Text, Padding: 32},
+			size:    10 + 10 + 1 + 600 + 32,
+		},
+		{
+			name: "invalid tag",
+			tag:  mp3.ID3v2{Text: []mp3.TextFrame{{ID: "XXXX"}}},
+			err:  mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(append(test.options, mp3.WithID3v1(mp3.ID3v1{Title: "v1"}))...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		audio := data
+		if len(test.options) > 0 {
+			_, audio = parseID3v2(t, data)
+		}
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.Write(data); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		f.Close()
+
+		err = mp3.RewriteID3v2(f.Name(), test.tag)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		rewritten, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames, rest := parseID3v2(t, rewritten)
+		if size := len(rewritten) - len(rest); size != test.size {
+			t.Errorf("%s: expected tag size: %d got: %d", test.name, test.size, size)
+		}
+		if len(frames) != 1 || frames[0].id != "TIT2" || string(frames[0].body[1:]) != test.tag.Text[0].Value {
+			t.Errorf("%s: unexpected frames: %q", test.name, frames)
+		}
+		if !bytes.Equal(rest, audio) {
+			t.Errorf("%s: audio is changed", test.name)
+		}
+	}
+}
+
+func TestRetagStream(t *testing.T) {
+	existing := mp3.ID3v2{
+		Text:     []mp3.TextFrame{{ID: "TIT2", Value: "Old"}, {ID: "TPE1", Value: "Artist"}},
+		UserText: []mp3.UserText{{Description: "a", Value: "1"}, {Description: "b", Value: "2"}},
+	}
+	tag := mp3.ID3v2{
+		Text:     []mp3.TextFrame{{ID: "TIT2", Value: "New"}},
+		UserText: []mp3.UserText{{Description: "a", Value: "3"}},
+	}
+	v23 := existing
+	v23.Version = mp3.ID3v23
+	tests := []struct {
+		name    string
+		options []mp3.SinkOption
+		retag   mp3.Retag
+		// expected frame identifiers with values of UTF-8 frames.
+		frames  []string
+		version byte
+		id3v1   string
+	}{
+		{
+			name:    "replace",
+			options: []mp3.SinkOption{mp3.WithID3v2(existing)},
+			retag:   mp3.Retag{ID3v2: &tag},
+			frames:  []string{"TIT2 New", "TXXX a\x003"},
+			version: 4,
+			id3v1:   "v1",
+		},
+		{
+			name:    "merge",
+			options: []mp3.SinkOption{mp3.WithID3v2(existing)},
+			retag:   mp3.Retag{ID3v2: &tag, Merge: true},
+			frames:  []string{"TIT2 New", "TXXX a\x003", "TPE1 Artist", "TXXX b\x002"},
+			version: 4,
+			id3v1:   "v1",
+		},
+		{
+			name:    "merge into ID3v2.3",
+			options: []mp3.SinkOption{mp3.WithID3v2(v23)},
+			retag:   mp3.Retag{ID3v2: &tag, Merge: true},
+			frames:  []string{"TIT2", "TXXX", "TPE1", "TXXX"},
+			version: 3,
+			id3v1:   "v1",
+		},
+		{
+			name:    "merge without tag",
+			retag:   mp3.Retag{ID3v2: &tag, Merge: true},
+			frames:  []string{"TIT2 New", "TXXX a\x003"},
+			version: 4,
+			id3v1:   "v1",
+		},
+		{
+			name:    "keep ID3v2",
+			options: []mp3.SinkOption{mp3.WithID3v2(existing)},
+			retag:   mp3.Retag{ID3v1: &mp3.ID3v1{Title: "new v1"}},
+			frames:  []string{"TIT2 Old", "TPE1 Artist", "TXXX a\x001", "TXXX b\x002"},
+			version: 4,
+			id3v1:   "new v1",
+		},
+	}
+	for _, test := range tests {
+		data, err := encodeTagged(append(test.options, mp3.WithID3v1(mp3.ID3v1{Title: "v1"}))...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		audio := data
+		if len(test.options) > 0 {
+			_, audio = parseID3v2(t, data)
+		}
+		audio = audio[:len(audio)-128]
+
+		var buf bytes.Buffer
+		if err := mp3.RetagStream(bytes.NewReader(data), &buf, test.retag); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		retagged := buf.Bytes()
+		if retagged[3] != test.version {
+			t.Errorf("%s: expected version: %d got: %d", test.name, test.version, retagged[3])
+		}
+		frames, rest := parseID3v2(t, retagged)
+		var ids []string
+		for _, f := range frames {
+			if test.version == 4 {
+				ids = append(ids, f.id+" "+string(f.body[1:]))
+			} else {
+				ids = append(ids, f.id)
+			}
+		}
+		if !reflect.DeepEqual(ids, test.frames) {
+			t.Errorf("%s: expected frames: %q got: %q", test.name, test.frames, ids)
+		}
+		if !bytes.Equal(rest[:len(rest)-128], audio) {
+			t.Errorf("%s: audio is changed", test.name)
+		}
+		if v1 := string(bytes.TrimRight(rest[len(rest)-125:len(rest)-95], "\x00")); v1 != test.id3v1 {
+			t.Errorf("%s: expected ID3v1 title: %q got: %q", test.name, test.id3v1, v1)
+		}
+	}
+}
+
+func TestRetagStreamWithoutTags(t *testing.T) {
+	audio, err := encodeTagged()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	err = mp3.RetagStream(bytes.NewReader(audio), &buf, mp3.Retag{
+		ID3v2: &mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "New"}}},
+		ID3v1: &mp3.ID3v1{Title: "New"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, rest := parseID3v2(t, buf.Bytes())
+	if !bytes.Equal(rest[:len(rest)-128], audio) || string(rest[len(rest)-128:len(rest)-125]) != "TAG" {
+		t.Errorf("unexpected stream")
+	}
+
+	err = mp3.RetagStream(bytes.NewReader(audio), io.Discard, mp3.Retag{ID3v2: &mp3.ID3v2{Version: 5}})
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
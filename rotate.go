@@ -0,0 +1,144 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Rotation limits the files written by RotatingFileSink. The file is
+// rotated when any of non-zero limits is reached.
+type Rotation struct {
+	// Duration is the max duration of the input per file.
+	Duration time.Duration
+	// Size is the max size of the file. Encoder buffers the input, so
+	// files exceed it by up to a buffer of encoded data and tags.
+	Size int64
+	// Path returns the path of the file with the sequence number that
+	// starts from zero and the time when the file is started.
+	// NameTemplate.Name can be used to format it.
+	Path func(seq int, start time.Time) string
+	// Finalized is called after every file is flushed.
+	Finalized func(Segment)
+}
+
+// RotatingFileSink writes the stream into files that are rotated by
+// duration or size. Every file is encoded separately and written the
+// same way as FileSink writes it, so it has its own Xing header and
+// tags. Progress is reported per file. FileCommit can't be bound to
+// rotated files.
+func RotatingFileSink(r Rotation, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		if r.Path == nil {
+			return pipe.Sink{}, fmt.Errorf("%w: rotation path function is nil", ErrInvalidParameter)
+		}
+		if r.Duration < 0 || r.Size < 0 {
+			return pipe.Sink{}, fmt.Errorf("%w: rotation limits %v and %d must not be negative", ErrInvalidParameter, r.Duration, r.Size)
+		}
+		var opts sinkOptions
+		for _, option := range options {
+			option(&opts)
+		}
+		if opts.fileCommit != nil {
+			return pipe.Sink{}, fmt.Errorf("%w: file commit can't be bound to rotated files", ErrInvalidParameter)
+		}
+		rs := rotatingSink{
+			r:          r,
+			brm:        brm,
+			cm:         cm,
+			eq:         eq,
+			mctx:       mctx,
+			bufferSize: bufferSize,
+			props:      props,
+		}
+		rs.options = append(append([]SinkOption(nil), options...), WithProgress(func(p Progress) {
+			rs.progress = p
+			if opts.progress != nil {
+				opts.progress(p)
+			}
+		}))
+		if err := rs.next(); err != nil {
+			return pipe.Sink{}, err
+		}
+		return pipe.Sink{
+			Context:   rs.sink.Context,
+			SinkFunc:  rs.sinkFunc,
+			FlushFunc: rs.flush,
+		}, nil
+	}
+}
+
+// rotatingSink allocates a new FileSink for every file.
+type rotatingSink struct {
+	r          Rotation
+	brm        BitRateMode
+	cm         ChannelMode
+	eq         EncodingQuality
+	options    []SinkOption
+	mctx       mutable.Context
+	bufferSize int
+	props      pipe.SignalProperties
+
+	seq      int
+	sink     pipe.Sink
+	progress Progress
+	// path and start time of the current file.
+	path  string
+	start time.Time
+}
+
+// next allocates the sink of the next file.
+func (rs *rotatingSink) next() error {
+	start := time.Now()
+	path := rs.r.Path(rs.seq, start)
+	sink, err := FileSink(path, rs.brm, rs.cm, rs.eq, rs.options...)(rs.mctx, rs.bufferSize, rs.props)
+	if err != nil {
+		return err
+	}
+	rs.seq++
+	rs.sink = sink
+	rs.progress = Progress{}
+	rs.path = path
+	rs.start = start
+	return nil
+}
+
+// full reports whether the current file has reached the limits.
+func (rs *rotatingSink) full() bool {
+	if rs.r.Duration > 0 && rs.progress.Samples >= rs.props.SampleRate.Events(rs.r.Duration) {
+		return true
+	}
+	return rs.r.Size > 0 && rs.progress.Bytes >= rs.r.Size
+}
+
+func (rs *rotatingSink) sinkFunc(floats signal.Floating) error {
+	if rs.full() {
+		if err := rs.flush(context.Background()); err != nil {
+			return err
+		}
+		if err := rs.next(); err != nil {
+			return err
+		}
+	}
+	return rs.sink.SinkFunc(floats)
+}
+
+// flush flushes the current file and reports it finalized.
+func (rs *rotatingSink) flush(ctx context.Context) error {
+	if err := rs.sink.FlushFunc(ctx); err != nil {
+		return err
+	}
+	if rs.r.Finalized != nil {
+		rs.r.Finalized(Segment{
+			Seq:      rs.seq - 1,
+			Name:     rs.path,
+			Start:    rs.start,
+			Duration: rs.props.SampleRate.Duration(rs.progress.Samples),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestRotatingFileSink(t *testing.T) {
+	tests := []struct {
+		name     string
+		backend  mp3.Backend
+		rotation mp3.Rotation
+		options  []mp3.SinkOption
+		files    int
+		err      error
+	}{
+		{
+			name:     "duration",
+			backend:  mp3.Shine,
+			rotation: mp3.Rotation{Duration: 3 * time.Second},
+			files:    4,
+		},
+		{
+			name:     "lame duration",
+			backend:  mp3.Lame,
+			rotation: mp3.Rotation{Duration: 3 * time.Second},
+			files:    4,
+		},
+		{
+			name:     "size",
+			backend:  mp3.Shine,
+			rotation: mp3.Rotation{Size: 50000},
+			files:    4,
+		},
+		{
+			name:     "negative duration",
+			backend:  mp3.Shine,
+			rotation: mp3.Rotation{Duration: -time.Second},
+			err:      mp3.ErrInvalidParameter,
+		},
+		{
+			name:     "file commit",
+			backend:  mp3.Shine,
+			rotation: mp3.Rotation{Duration: 3 * time.Second},
+			options:  []mp3.SinkOption{mp3.WithFileCommit(&mp3.FileCommit{})},
+			err:      mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		if !test.backend.Available() {
+			continue
+		}
+		dir, err := os.MkdirTemp("", "rotate")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		defer os.RemoveAll(dir)
+		test.rotation.Path = mp3.NameTemplate{Template: filepath.Join(dir, "{seq}.mp3")}.Name
+		var finalized []mp3.Segment
+		test.rotation.Finalized = func(s mp3.Segment) {
+			finalized = append(finalized, s)
+		}
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      10 * 44100,
+			Value:      0.5,
+		}
+		var progress int
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.RotatingFileSink(test.rotation, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					append([]mp3.SinkOption{
+						mp3.WithBackend(test.backend),
+						mp3.WithID3v1(mp3.ID3v1{Title: "Archive"}),
+						mp3.WithProgress(func(mp3.Progress) { progress++ }),
+					}, test.options...)...,
+				),
+			},
+		)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if progress == 0 {
+			t.Errorf("%s: expected progress", test.name)
+		}
+		var duration time.Duration
+		for i, s := range finalized {
+			if s.Seq != i || s.Name != filepath.Join(dir, fmt.Sprintf("%d.mp3", i)) || s.Start.IsZero() {
+				t.Errorf("%s: unexpected finalized segment: %+v", test.name, s)
+			}
+			duration += s.Duration
+		}
+		if len(finalized) != test.files || duration.Round(time.Millisecond) != 10*time.Second {
+			t.Errorf("%s: expected finalized files: %d of 10s got: %d of %v", test.name, test.files, len(finalized), duration)
+		}
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(files) != test.files {
+			t.Fatalf("%s: expected files: %d got: %d", test.name, test.files, len(files))
+		}
+		for i := range files {
+			data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.mp3", i)))
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+			if string(data[4+32:4+36]) != "Info" {
+				t.Errorf("%s: file %d: expected Info tag", test.name, i)
+			}
+			if test.backend == mp3.Lame && string(data[4+32+120:4+32+124]) != "LAME" {
+				t.Errorf("%s: file %d: expected LAME extension", test.name, i)
+			}
+			if tag := data[len(data)-128:]; string(tag[:3]) != "TAG" || string(tag[3:10]) != "Archive" {
+				t.Errorf("%s: file %d: expected ID3v1 tag", test.name, i)
+			}
+		}
+	}
+}
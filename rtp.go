@@ -0,0 +1,161 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+const (
+	// rtpHeaderSize is the size of RTP header without CSRC list.
+	rtpHeaderSize = 12
+	// mpaHeaderSize is the size of MPEG audio specific header.
+	mpaHeaderSize = 4
+	// rtpPayloadMPA is the static payload type of MPEG audio.
+	rtpPayloadMPA = 14
+	// rtpClockRate is the clock rate of MPEG audio timestamps.
+	rtpClockRate = 90000
+	defaultMTU   = 1400
+)
+
+// RTP configures packetization of RTPSink.
+type RTP struct {
+	// MTU is the max size of the packet including RTP header. Default is
+	// 1400 bytes.
+	MTU int
+	// PayloadType is 14 if zero.
+	PayloadType byte
+	SSRC        uint32
+	// Initial sequence number and timestamp.
+	Sequence  uint16
+	Timestamp uint32
+	// FEC is called for every packet. Returned packets are written after
+	// it, so forward error correction can be added to the stream.
+	FEC func(packet []byte) ([][]byte, error)
+}
+
+// RTPSink writes encoded stream as RTP packets of MPEG audio payload
+// defined by RFC 2250. Every packet is passed to w in a single write,
+// so w can be a UDP connection. Frames that fit MTU are packed together
+// and larger frames are fragmented. Tags and ICY metadata aren't
+// supported.
+func RTPSink(w io.Writer, r RTP, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		var opts sinkOptions
+		for _, option := range options {
+			option(&opts)
+		}
+		if opts.metadata() {
+			return pipe.Sink{}, fmt.Errorf("%w: RTP stream doesn't support metadata options", ErrInvalidParameter)
+		}
+		if r.MTU == 0 {
+			r.MTU = defaultMTU
+		}
+		if r.MTU <= rtpHeaderSize+mpaHeaderSize || r.PayloadType > 0x7f {
+			return pipe.Sink{}, fmt.Errorf("%w: invalid RTP MTU %d or payload type %d", ErrInvalidParameter, r.MTU, r.PayloadType)
+		}
+		if r.PayloadType == 0 {
+			r.PayloadType = rtpPayloadMPA
+		}
+		rw := rtpWriter{w: w, r: r, sequence: r.Sequence}
+		aligned := append([]SinkOption{WithFrameAlignedWrites(0)}, options...)
+		return Sink(&rw, brm, cm, eq, aligned...)(mctx, bufferSize, props)
+	}
+}
+
+// rtpWriter packetizes frame-aligned writes.
+type rtpWriter struct {
+	w        io.Writer
+	r        RTP
+	sequence uint16
+	// samples written before the packet in sample rate of the stream.
+	samples int64
+	started bool
+	// packet is the frames waiting to be sent and timestamp of the first.
+	packet    []byte
+	timestamp uint32
+}
+
+func (rw *rtpWriter) Write(p []byte) (int, error) {
+	frames, rest, err := splitFrames(p)
+	if err != nil {
+		return 0, fmt.Errorf("error splitting MP3 frames: %w", err)
+	}
+	if len(rest) > 0 {
+		return 0, fmt.Errorf("incomplete frame of %d bytes", len(rest))
+	}
+	payload := rw.r.MTU - rtpHeaderSize - mpaHeaderSize
+	for _, frame := range frames {
+		h, _ := parseFrameHeader(frame)
+		timestamp := rw.r.Timestamp + uint32(rw.samples*rtpClockRate/int64(h.sampleRate))
+		rw.samples += int64(h.samples())
+		if len(rw.packet)+len(frame) > payload {
+			if err := rw.send(rw.packet, 0, rw.timestamp); err != nil {
+				return 0, err
+			}
+			rw.packet = rw.packet[:0]
+		}
+		if len(frame) > payload {
+			for offset := 0; offset < len(frame); offset += payload {
+				end := offset + payload
+				if end > len(frame) {
+					end = len(frame)
+				}
+				if err := rw.send(frame[offset:end], offset, timestamp); err != nil {
+					return 0, err
+				}
+			}
+			continue
+		}
+		if len(rw.packet) == 0 {
+			rw.timestamp = timestamp
+		}
+		rw.packet = append(rw.packet, frame...)
+	}
+	// packets aren't held between writes to keep the latency.
+	if err := rw.send(rw.packet, 0, rw.timestamp); err != nil {
+		return 0, err
+	}
+	rw.packet = rw.packet[:0]
+	return len(p), nil
+}
+
+// send writes the packet with payload and FEC packets.
+func (rw *rtpWriter) send(payload []byte, offset int, timestamp uint32) error {
+	if len(payload) == 0 {
+		return nil
+	}
+	packet := make([]byte, rtpHeaderSize+mpaHeaderSize, rtpHeaderSize+mpaHeaderSize+len(payload))
+	packet[0] = 2 << 6
+	packet[1] = rw.r.PayloadType
+	// marker is set on the first packet of the stream.
+	if !rw.started {
+		packet[1] |= 0x80
+		rw.started = true
+	}
+	binary.BigEndian.PutUint16(packet[2:], rw.sequence)
+	binary.BigEndian.PutUint32(packet[4:], timestamp)
+	binary.BigEndian.PutUint32(packet[8:], rw.r.SSRC)
+	binary.BigEndian.PutUint16(packet[rtpHeaderSize+2:], uint16(offset))
+	packet = append(packet, payload...)
+	rw.sequence++
+	if _, err := rw.w.Write(packet); err != nil {
+		return fmt.Errorf("error writing RTP packet: %w", err)
+	}
+	if rw.r.FEC == nil {
+		return nil
+	}
+	fec, err := rw.r.FEC(packet)
+	if err != nil {
+		return fmt.Errorf("error generating FEC packets: %w", err)
+	}
+	for _, p := range fec {
+		if _, err := rw.w.Write(p); err != nil {
+			return fmt.Errorf("error writing FEC packet: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package mp3_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// packetWriter keeps every write as a packet.
+type packetWriter struct {
+	packets [][]byte
+}
+
+func (w *packetWriter) Write(p []byte) (int, error) {
+	w.packets = append(w.packets, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestRTPSink(t *testing.T) {
+	tests := []struct {
+		name string
+		rtp  mp3.RTP
+		fec  bool
+		// fragmented frames are expected.
+		fragmented bool
+		err        error
+	}{
+		{
+			name: "packed",
+			rtp:  mp3.RTP{SSRC: 0xdeadbeef, Sequence: 0xfffe, Timestamp: 1000},
+		},
+		{
+			name:       "fragmented",
+			rtp:        mp3.RTP{MTU: 200, SSRC: 1},
+			fragmented: true,
+		},
+		{
+			name: "fec",
+			rtp:  mp3.RTP{SSRC: 1},
+			fec:  true,
+		},
+		{
+			name: "small MTU",
+			rtp:  mp3.RTP{MTU: 16},
+			err:  mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		var w packetWriter
+		fec := 0
+		if test.fec {
+			test.rtp.FEC = func(packet []byte) ([][]byte, error) {
+				fec++
+				return [][]byte{[]byte("fec")}, nil
+			}
+		}
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      44100,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.RTPSink(&w, test.rtp, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+			},
+		)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		var (
+			audio      []byte
+			fragmented bool
+			sequence   = test.rtp.Sequence
+			timestamp  = test.rtp.Timestamp
+		)
+		for i, packet := range w.packets {
+			if test.fec && i%2 == 1 {
+				if string(packet) != "fec" {
+					t.Fatalf("%s: expected FEC packet got: %q", test.name, packet)
+				}
+				continue
+			}
+			mtu := test.rtp.MTU
+			if mtu == 0 {
+				mtu = 1400
+			}
+			if len(packet) > mtu {
+				t.Fatalf("%s: packet size %d exceeds MTU %d", test.name, len(packet), mtu)
+			}
+			if packet[0] != 0x80 || packet[1]&0x7f != 14 || (packet[1]&0x80 != 0) != (i == 0) {
+				t.Fatalf("%s: unexpected packet header: % x", test.name, packet[:2])
+			}
+			if s := binary.BigEndian.Uint16(packet[2:]); s != sequence {
+				t.Fatalf("%s: expected sequence: %d got: %d", test.name, sequence, s)
+			}
+			sequence++
+			ts := binary.BigEndian.Uint32(packet[4:])
+			if ts < timestamp {
+				t.Fatalf("%s: timestamp %d is less than previous %d", test.name, ts, timestamp)
+			}
+			timestamp = ts
+			if ssrc := binary.BigEndian.Uint32(packet[8:]); ssrc != test.rtp.SSRC {
+				t.Fatalf("%s: expected SSRC: %x got: %x", test.name, test.rtp.SSRC, ssrc)
+			}
+			if binary.BigEndian.Uint16(packet[14:]) != 0 {
+				fragmented = true
+			}
+			audio = append(audio, packet[16:]...)
+		}
+		frames := splitFrames(t, audio)
+		if expected := 44100/1152 + 1; len(frames) < expected {
+			t.Errorf("%s: expected frames: %d got: %d", test.name, expected, len(frames))
+		}
+		// timestamp of the last packet in 90 kHz clock.
+		duration := int64(timestamp-test.rtp.Timestamp) * 44100 / 90000
+		if max := int64(len(frames) * 1152); duration >= max {
+			t.Errorf("%s: last timestamp %d exceeds stream duration %d", test.name, duration, max)
+		}
+		if fragmented != test.fragmented {
+			t.Errorf("%s: expected fragmented: %v", test.name, test.fragmented)
+		}
+		if test.fec && fec != len(w.packets)/2 {
+			t.Errorf("%s: expected FEC calls: %d got: %d", test.name, len(w.packets)/2, fec)
+		}
+	}
+}
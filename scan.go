@@ -0,0 +1,77 @@
+package mp3
+
+import (
+	"io"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+// frameIndexSize is the maximum number of offsets in frameIndex.
+const frameIndexSize = 1024
+
+// StreamScan is the result of ScanStream.
+type StreamScan struct {
+	// Frames is the number of audio frames including Xing/Info frame.
+	Frames int
+	// Bytes is the size of audio frames.
+	Bytes int64
+	// Samples is the number of samples per channel in audio frames.
+	Samples int64
+	// SampleRate is the sample rate of the first frame.
+	SampleRate signal.Frequency
+	// Duration is the duration of audio frames.
+	Duration time.Duration
+}
+
+// ScanStream walks audio frames of MP3 stream read from r without
+// decoding them. Frames are found the same way StripTags does, so tags
+// and unknown data are skipped. Stream is read by parts and frames are
+// not kept, so multi-GB files are scanned with bounded memory.
+func ScanStream(r io.Reader) (StreamScan, error) {
+	var s StreamScan
+	_, err := walkFrames(r, func(frame []byte, h frameHeader) error {
+		if s.Frames == 0 {
+			s.SampleRate = signal.Frequency(h.sampleRate)
+		}
+		s.Frames++
+		s.Bytes += int64(len(frame))
+		s.Samples += int64(h.samples())
+		s.Duration += h.duration()
+		return nil
+	})
+	return s, err
+}
+
+// frameIndex keeps offsets of every stride-th frame. When it's full,
+// every other offset is dropped and the stride is doubled, so the index
+// of the stream of any length takes at most frameIndexSize offsets.
+type frameIndex struct {
+	frames  int
+	stride  int
+	offsets []int64
+}
+
+// add appends the offset of the next frame.
+func (x *frameIndex) add(offset int64) {
+	if x.stride == 0 {
+		x.stride = 1
+	}
+	if x.frames%x.stride == 0 {
+		if len(x.offsets) == frameIndexSize {
+			for i := 0; i < frameIndexSize/2; i++ {
+				x.offsets[i] = x.offsets[2*i]
+			}
+			x.offsets = x.offsets[:frameIndexSize/2]
+			x.stride *= 2
+		}
+		x.offsets = append(x.offsets, offset)
+	}
+	x.frames++
+}
+
+// offset returns the offset of the indexed frame that is the closest
+// one at or before the frame.
+func (x *frameIndex) offset(frame int) int64 {
+	return x.offsets[frame/x.stride]
+}
@@ -0,0 +1,82 @@
+package mp3_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestScanStream(t *testing.T) {
+	audio, err := encodeTagged()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tagged, err := encodeTagged(
+		mp3.WithID3v2(mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}, Padding: 100}),
+		mp3.WithID3v1(mp3.ID3v1{Title: "Title"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames := len(splitFrames(t, audio))
+	s, err := mp3.ScanStream(bytes.NewReader(tagged))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := mp3.StreamScan{
+		Frames:     frames,
+		Bytes:      int64(len(audio)),
+		Samples:    int64(frames * 1152),
+		SampleRate: 44100,
+		Duration:   time.Duration(frames) * (1152 * time.Second / 44100),
+	}
+	if s != expected {
+		t.Errorf("expected scan: %+v got: %+v", expected, s)
+	}
+}
+
+// repeatReader reads the same frame n times.
+type repeatReader struct {
+	frame []byte
+	n     int
+	pos   int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.frame[r.pos:])
+	if r.pos += n; r.pos == len(r.frame) {
+		r.pos = 0
+		r.n--
+	}
+	return n, nil
+}
+
+func TestScanStreamBoundedMemory(t *testing.T) {
+	audio, err := encodeTagged()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frame := splitFrames(t, audio)[1]
+	// about 40 MB of frames.
+	r := &repeatReader{frame: frame, n: 100000}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	s, err := mp3.ScanStream(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if s.Frames != 100000 || s.Bytes != int64(100000*len(frame)) {
+		t.Errorf("unexpected scan: %+v", s)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("expected bounded memory got: %d bytes allocated", allocated)
+	}
+}
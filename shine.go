@@ -0,0 +1,147 @@
+//go:build !noshine
+// +build !noshine
+
+package mp3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"pipelined.dev/audio/mp3/internal/shine"
+)
+
+// shineEncoder encodes PCM with shine. Shine encodes exactly one frame at
+// a time, so PCM is buffered until a full frame is available. Shine
+// bitstream keeps the tail of a frame until the next frame is encoded,
+// so only complete frames are written.
+type shineEncoder struct {
+	enc       *shine.Encoder
+	w         io.Writer
+	pcm       []int16
+	frameLen  int // samples for all channels in a frame
+	remainder []byte
+	encoded   []byte
+	samples   int // samples for all channels written
+	frames    int
+	channels  int
+}
+
+// shineAvailable reports whether the package is built with shine.
+const shineAvailable = true
+
+// Init implements Encoder.
+func (e *shineEncoder) Init(w io.Writer, p EncoderParams) error {
+	cbr, ok := p.BitRateMode.(CBR)
+	if !ok {
+		return fmt.Errorf("%w: %v backend supports only CBR", ErrInvalidParameter, Shine)
+	}
+	enc, err := shine.NewEncoder(int(p.SampleRate), p.Channels, int(cbr))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidParameter, err)
+	}
+	e.enc = enc
+	e.w = w
+	e.channels = p.Channels
+	e.frameLen = int(enc.Mpeg.GranulesPerFrame) * shine.GRANULE_SIZE * p.Channels
+	e.pcm = make([]int16, 0, e.frameLen)
+	return nil
+}
+
+// Write implements Encoder.
+func (e *shineEncoder) Write(p []byte) (int, error) {
+	buf := p
+	if len(e.remainder) > 0 {
+		buf = append(e.remainder, buf...)
+		e.remainder = nil
+	}
+	for ; len(buf) >= bytesPerSample; buf = buf[bytesPerSample:] {
+		e.pcm = append(e.pcm, int16(binary.LittleEndian.Uint16(buf)))
+		e.samples++
+		if len(e.pcm) < e.frameLen {
+			continue
+		}
+		if err := e.encode(); err != nil {
+			return 0, err
+		}
+	}
+	if len(buf) > 0 {
+		e.remainder = append(e.remainder, buf...)
+	}
+	return len(p), nil
+}
+
+// encode encodes buffered frame. Incomplete frame is padded with silence.
+func (e *shineEncoder) encode() error {
+	for len(e.pcm) < e.frameLen {
+		e.pcm = append(e.pcm, 0)
+	}
+	out, n := e.enc.EncodeBufferInterleaved(e.pcm)
+	e.pcm = e.pcm[:0]
+	e.encoded = append(e.encoded, out[:n]...)
+	// frames are parsed in place, so steady state doesn't allocate.
+	buf := e.encoded
+	for len(buf) >= frameHeaderSize {
+		h, err := parseFrameHeader(buf)
+		if err != nil {
+			return fmt.Errorf("error encoding: %w", err)
+		}
+		size := h.size()
+		if size > len(buf) {
+			break
+		}
+		if _, err := e.w.Write(buf[:size]); err != nil {
+			return err
+		}
+		e.frames++
+		buf = buf[size:]
+	}
+	e.encoded = append(e.encoded[:0], buf...)
+	return nil
+}
+
+// Flush encodes the last incomplete frame and one more silent frame to
+// drain the filter bank. Then another frame is encoded to drain the
+// bitstream and its incomplete output is discarded.
+func (e *shineEncoder) Flush() error {
+	if len(e.pcm) > 0 {
+		if err := e.encode(); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := e.encode(); err != nil {
+			return err
+		}
+	}
+	e.encoded = nil
+	return nil
+}
+
+// EncodingInfo implements InfoEncoder.
+func (e *shineEncoder) EncodingInfo() EncodingInfo {
+	samples := e.samples / e.channels
+	return EncodingInfo{
+		Samples: samples,
+		Frames:  e.frames,
+		Delay:   shineDelay,
+		Padding: e.frames*e.frameLen/e.channels - shineDelay - samples,
+	}
+}
+
+// validateShine checks that configuration is supported by shine.
+func validateShine(c encoderConfig) error {
+	if _, ok := c.brm.(CBR); !ok {
+		return fmt.Errorf("%w: %v backend supports only CBR", ErrInvalidParameter, Shine)
+	}
+	if c.opts.outSampleRate != 0 && c.opts.outSampleRate != c.sampleRate {
+		return fmt.Errorf("%w: %v backend doesn't support resampling", ErrInvalidParameter, Shine)
+	}
+	if c.opts.crc || c.opts.strictISO || len(c.opts.tuning) > 0 {
+		return fmt.Errorf("%w: %v backend doesn't support lame options", ErrInvalidParameter, Shine)
+	}
+	if c.channels == 2 && c.cm == Mono {
+		return fmt.Errorf("%w: %v backend doesn't mix stereo into mono, use downmix", ErrInvalidParameter, Shine)
+	}
+	return c.brm.validate(c.sampleRate)
+}
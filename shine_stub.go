@@ -0,0 +1,36 @@
+//go:build noshine
+// +build noshine
+
+package mp3
+
+import (
+	"fmt"
+	"io"
+)
+
+// shineAvailable reports whether the package is built with shine.
+const shineAvailable = false
+
+// shineEncoder is a placeholder for builds without shine. Sink rejects
+// Shine backend before the encoder is created.
+type shineEncoder struct{}
+
+// Init implements Encoder.
+func (e *shineEncoder) Init(io.Writer, EncoderParams) error {
+	return ErrBackendUnavailable
+}
+
+// Write implements Encoder.
+func (e *shineEncoder) Write([]byte) (int, error) {
+	return 0, ErrBackendUnavailable
+}
+
+// Flush implements Encoder.
+func (e *shineEncoder) Flush() error {
+	return ErrBackendUnavailable
+}
+
+// validateShine rejects the configuration, shine isn't compiled in.
+func validateShine(encoderConfig) error {
+	return fmt.Errorf("%w: %v", ErrBackendUnavailable, Shine)
+}
@@ -0,0 +1,148 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestShine(t *testing.T) {
+	tests := []struct {
+		bitRateMode mp3.BitRateMode
+		channelMode mp3.ChannelMode
+	}{
+		{bitRateMode: mp3.CBR(128), channelMode: mp3.JointStereo},
+		{bitRateMode: mp3.CBR(320), channelMode: mp3.Stereo},
+	}
+
+	for _, test := range tests {
+		inFile, err := os.Open(sample)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var out bytes.Buffer
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: mp3.Source(inFile),
+				Sink:   mp3.Sink(&out, test.bitRateMode, test.channelMode, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+			},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		inFile.Close()
+		if frames := splitFrames(t, out.Bytes()); len(frames) == 0 {
+			t.Fatalf("%v: no frames encoded", test.bitRateMode)
+		}
+
+		decoded := mock.Sink{Discard: true}
+		p, err = pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: mp3.Source(bytes.NewReader(out.Bytes())),
+				Sink:   decoded.Sink(),
+			},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decoded.Samples == 0 {
+			t.Errorf("%v: nothing was decoded", test.bitRateMode)
+		}
+	}
+}
+
+func TestShineMono(t *testing.T) {
+	tests := []struct {
+		sampleRate signal.Frequency
+		bitRate    mp3.CBR
+		frameSize  int
+	}{
+		// MPEG-2 mono at 64 kbps has 72 * 64000 / 22050 = 208 bytes frames.
+		{sampleRate: 22050, bitRate: 64, frameSize: 208},
+		// MPEG-1 mono at 128 kbps has 144 * 128000 / 44100 = 417 bytes frames.
+		{sampleRate: 44100, bitRate: 128, frameSize: 417},
+	}
+
+	for _, test := range tests {
+		source := mock.Source{
+			Channels:   1,
+			SampleRate: test.sampleRate,
+			Limit:      int(test.sampleRate),
+			Value:      0.5,
+		}
+		var out bytes.Buffer
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(&out, test.bitRate, mp3.Mono, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+			},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		encoded := out.Bytes()
+		for len(encoded) > 0 {
+			if encoded[0] != 0xff || encoded[3]>>6 != 0x03 {
+				t.Fatalf("%v Hz: invalid mono frame header: % x", test.sampleRate, encoded[:4])
+			}
+			size := test.frameSize + int(encoded[2]>>1&0x01)
+			if size > len(encoded) {
+				t.Fatalf("%v Hz: truncated frame", test.sampleRate)
+			}
+			encoded = encoded[size:]
+		}
+	}
+}
+
+func TestShineValidation(t *testing.T) {
+	tests := []struct {
+		bitRateMode mp3.BitRateMode
+		channelMode mp3.ChannelMode
+		channels    int
+		options     []mp3.SinkOption
+	}{
+		{bitRateMode: mp3.VBR(2), channelMode: mp3.JointStereo, channels: 2},
+		{bitRateMode: mp3.ABR(128), channelMode: mp3.JointStereo, channels: 2},
+		{bitRateMode: mp3.CBR(128), channelMode: mp3.JointStereo, channels: 2, options: []mp3.SinkOption{mp3.WithCRC()}},
+		{bitRateMode: mp3.CBR(128), channelMode: mp3.JointStereo, channels: 2, options: []mp3.SinkOption{mp3.WithOutputSampleRate(32000)}},
+		{bitRateMode: mp3.CBR(128), channelMode: mp3.JointStereo, channels: 2, options: []mp3.SinkOption{mp3.WithForceMS()}},
+		{bitRateMode: mp3.CBR(128), channelMode: mp3.Mono, channels: 2},
+		{bitRateMode: mp3.CBR(8), channelMode: mp3.Mono, channels: 1},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		options := append([]mp3.SinkOption{mp3.WithBackend(mp3.Shine)}, test.options...)
+		_, err := mp3.Sink(
+			&out,
+			test.bitRateMode,
+			test.channelMode,
+			mp3.DefaultEncodingQuality,
+			options...,
+		)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: test.channels})
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%v: expected invalid parameter error, got: %v", test.bitRateMode, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package mp3
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ShoutcastVersion is the version of Shoutcast source protocol.
+type ShoutcastVersion int
+
+const (
+	// Shoutcast1 is the password-based protocol of Shoutcast v1 servers.
+	// Source connects to the port next to the port of URL and metadata
+	// is updated with admin.cgi requests.
+	Shoutcast1 ShoutcastVersion = iota + 1
+	// Shoutcast2 is the Ultravox 2.1 protocol of Shoutcast v2 servers.
+	// Metadata is sent within the stream.
+	Shoutcast2
+)
+
+const (
+	ultravoxSync = 0x5a
+	// ultravoxMaxPayload is the max size of message payload.
+	ultravoxMaxPayload = 16377
+	// ultravoxMetadataHeader is the size of metadata message header.
+	ultravoxMetadataHeader = 6
+	ultravoxVersion        = "2.1"
+	// xteaDelta is the key schedule constant of XTEA cipher.
+	xteaDelta = 0x9e3779b9
+)
+
+// Ultravox message types.
+const (
+	ultravoxAuthenticate uint16 = 0x1001
+	ultravoxMimeType     uint16 = 0x1002
+	ultravoxSetupStream  uint16 = 0x1003
+	ultravoxStandby      uint16 = 0x1004
+	ultravoxTerminate    uint16 = 0x1005
+	ultravoxCipher       uint16 = 0x1009
+	ultravoxIcyName      uint16 = 0x1100
+	ultravoxIcyGenre     uint16 = 0x1101
+	ultravoxIcyURL       uint16 = 0x1102
+	ultravoxIcyPublic    uint16 = 0x1103
+	ultravoxXMLMetadata  uint16 = 0x3902
+	ultravoxMP3Data      uint16 = 0x7000
+)
+
+// shoutcastRequest returns the headers sent after Shoutcast v1 password
+// is accepted.
+func shoutcastRequest(ic Icecast, brm BitRateMode) string {
+	var b strings.Builder
+	header := func(key, value string) {
+		if value != "" {
+			b.WriteString(key + ":" + value + "\r\n")
+		}
+	}
+	header("content-type", "audio/mpeg")
+	header("icy-name", ic.Name)
+	header("icy-genre", ic.Genre)
+	header("icy-url", ic.StationURL)
+	header("icy-pub", icyPublic(ic.Public))
+	if cbr, ok := brm.(CBR); ok {
+		header("icy-br", strconv.Itoa(int(cbr)))
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// shoutcast1 sends the password and stream headers.
+func (w *icecastWriter) shoutcast1(conn net.Conn, br *bufio.Reader) error {
+	if _, err := conn.Write([]byte(w.password + "\r\n")); err != nil {
+		return fmt.Errorf("error sending Shoutcast password: %w", err)
+	}
+	status, err := textproto.NewReader(br).ReadLine()
+	if err != nil {
+		return fmt.Errorf("error reading Shoutcast response: %w", err)
+	}
+	if !strings.HasPrefix(status, "OK") {
+		return fmt.Errorf("source rejected by Shoutcast: %s", status)
+	}
+	if _, err := conn.Write([]byte(w.request)); err != nil {
+		return fmt.Errorf("error sending Shoutcast request: %w", err)
+	}
+	return nil
+}
+
+// ultravoxSetup returns the messages that configure Shoutcast v2
+// broadcast after authentication.
+func ultravoxSetup(ic Icecast, brm BitRateMode) [][]byte {
+	var bitRate int
+	switch v := brm.(type) {
+	case CBR:
+		bitRate = int(v)
+	case ABR:
+		bitRate = int(v)
+	}
+	setup := [][]byte{
+		ultravoxMessage(ultravoxMimeType, []byte("audio/mpeg")),
+		ultravoxMessage(ultravoxSetupStream, []byte(strconv.Itoa(bitRate)+":"+strconv.Itoa(bitRate))),
+	}
+	info := func(typ uint16, value string) {
+		if value != "" {
+			setup = append(setup, ultravoxMessage(typ, []byte(value)))
+		}
+	}
+	info(ultravoxIcyName, ic.Name)
+	info(ultravoxIcyGenre, ic.Genre)
+	info(ultravoxIcyURL, ic.StationURL)
+	info(ultravoxIcyPublic, icyPublic(ic.Public))
+	return setup
+}
+
+// shoutcast2 authenticates with the cipher key of the server, sets up
+// the broadcast and waits until the server is ready to receive data.
+func (w *icecastWriter) shoutcast2(conn net.Conn, br *bufio.Reader) error {
+	key, err := ultravoxRequest(conn, br, ultravoxMessage(ultravoxCipher, []byte(ultravoxVersion)))
+	if err != nil {
+		return err
+	}
+	auth := ultravoxVersion + ":" + strconv.Itoa(w.ic.StreamID) + ":" + xteaEncrypt(key, w.user) + ":" + xteaEncrypt(key, w.password)
+	messages := append([][]byte{ultravoxMessage(ultravoxAuthenticate, []byte(auth))}, w.setup...)
+	messages = append(messages, ultravoxMessage(ultravoxStandby, nil))
+	for _, m := range messages {
+		if _, err := ultravoxRequest(conn, br, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ultravoxRequest sends the message and reads the response of the same
+// type. It returns the value of acknowledgment.
+func ultravoxRequest(conn net.Conn, br *bufio.Reader, m []byte) (string, error) {
+	if _, err := conn.Write(m); err != nil {
+		return "", fmt.Errorf("error sending Shoutcast message: %w", err)
+	}
+	typ, payload, err := readUltravox(br)
+	if err != nil {
+		return "", fmt.Errorf("error reading Shoutcast response: %w", err)
+	}
+	if expected := binary.BigEndian.Uint16(m[2:]); typ != expected {
+		return "", fmt.Errorf("unexpected Shoutcast response %#x to message %#x", typ, expected)
+	}
+	resp := string(payload)
+	if resp != "ACK" && !strings.HasPrefix(resp, "ACK:") {
+		return "", fmt.Errorf("source rejected by Shoutcast: %s", resp)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(resp, "ACK"), ":"), nil
+}
+
+// ultravoxMessage returns the message with payload.
+func ultravoxMessage(typ uint16, payload []byte) []byte {
+	m := make([]byte, 6, 6+len(payload)+1)
+	m[0] = ultravoxSync
+	binary.BigEndian.PutUint16(m[2:], typ)
+	binary.BigEndian.PutUint16(m[4:], uint16(len(payload)))
+	m = append(m, payload...)
+	return append(m, 0)
+}
+
+// readUltravox reads a single message.
+func readUltravox(r io.Reader) (uint16, []byte, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != ultravoxSync {
+		return 0, nil, fmt.Errorf("invalid Ultravox sync byte %#x", header[0])
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[4:])+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint16(header[2:]), payload[:len(payload)-1], nil
+}
+
+// ultravoxData packs frames of p into data messages.
+func ultravoxData(p []byte) ([]byte, error) {
+	frames, rest, err := splitFrames(p)
+	if err != nil {
+		return nil, fmt.Errorf("error splitting MP3 frames: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("incomplete frame of %d bytes", len(rest))
+	}
+	var (
+		data    []byte
+		payload []byte
+	)
+	for _, frame := range frames {
+		if len(payload)+len(frame) > ultravoxMaxPayload {
+			data = append(data, ultravoxMessage(ultravoxMP3Data, payload)...)
+			payload = payload[:0]
+		}
+		payload = append(payload, frame...)
+	}
+	if len(payload) > 0 {
+		data = append(data, ultravoxMessage(ultravoxMP3Data, payload)...)
+	}
+	return data, nil
+}
+
+// ultravoxMetadata returns XML metadata messages with the title. Large
+// metadata is split into multiple messages.
+func ultravoxMetadata(id uint16, title string) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" ?><metadata><TIT2>`)
+	xml.EscapeText(&b, []byte(title))
+	b.WriteString("</TIT2></metadata>")
+	meta := b.String()
+	size := ultravoxMaxPayload - ultravoxMetadataHeader
+	span := (len(meta) + size - 1) / size
+	var data []byte
+	for i := 0; i < span; i++ {
+		end := (i + 1) * size
+		if end > len(meta) {
+			end = len(meta)
+		}
+		payload := make([]byte, ultravoxMetadataHeader, ultravoxMetadataHeader+end-i*size)
+		binary.BigEndian.PutUint16(payload, id)
+		binary.BigEndian.PutUint16(payload[2:], uint16(span))
+		binary.BigEndian.PutUint16(payload[4:], uint16(i+1))
+		payload = append(payload, meta[i*size:end]...)
+		data = append(data, ultravoxMessage(ultravoxXMLMetadata, payload)...)
+	}
+	return data
+}
+
+// xteaEncrypt encrypts s with XTEA cipher and returns it hex-encoded as
+// required by Ultravox authentication. Key and s are padded with zeros.
+func xteaEncrypt(key, s string) string {
+	var kb [16]byte
+	copy(kb[:], key)
+	var k [4]uint32
+	for i := range k {
+		k[i] = binary.BigEndian.Uint32(kb[i*4:])
+	}
+	data := make([]byte, (len(s)+7)/8*8)
+	copy(data, s)
+	var b strings.Builder
+	for i := 0; i < len(data); i += 8 {
+		v0, v1 := binary.BigEndian.Uint32(data[i:]), binary.BigEndian.Uint32(data[i+4:])
+		var sum uint32
+		for r := 0; r < 32; r++ {
+			v0 += (((v1 << 4) ^ (v1 >> 5)) + v1) ^ (sum + k[sum&3])
+			sum += xteaDelta
+			v1 += (((v0 << 4) ^ (v0 >> 5)) + v0) ^ (sum + k[(sum>>11)&3])
+		}
+		fmt.Fprintf(&b, "%08x%08x", v0, v1)
+	}
+	return b.String()
+}
+
+func icyPublic(public bool) string {
+	if public {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,289 @@
+package mp3_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// shoutcastStream is the stream received by Shoutcast server.
+type shoutcastStream struct {
+	// password, user and stream id of the source.
+	password string
+	user     string
+	sid      string
+	headers  map[string]string
+	data     []byte
+	// titles received within the stream or with admin requests.
+	titles []string
+}
+
+// shoutcast1Server accepts Shoutcast v1 sources on the port next to the
+// returned listener port. Admin requests are served on the listener.
+func shoutcast1Server(t *testing.T) (net.Listener, <-chan shoutcastStream) {
+	t.Helper()
+	var admin, source net.Listener
+	for i := 0; i < 100 && source == nil; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		if source, err = net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port+1)); err != nil {
+			l.Close()
+			continue
+		}
+		admin = l
+	}
+	if source == nil {
+		t.Fatalf("no free pair of ports")
+	}
+	titles := make(chan string, 100)
+	go http.Serve(admin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin.cgi" || r.FormValue("mode") != "updinfo" || r.FormValue("pass") != "hackme" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		titles <- r.FormValue("song")
+	}))
+	streams := make(chan shoutcastStream, 1)
+	go func() {
+		defer source.Close()
+		conn, err := source.Accept()
+		if err != nil {
+			close(streams)
+			return
+		}
+		defer conn.Close()
+		r := textproto.NewReader(bufio.NewReader(conn))
+		var s shoutcastStream
+		s.password, _ = r.ReadLine()
+		conn.Write([]byte("OK2\r\nicy-caps:11\r\n\r\n"))
+		s.headers = make(map[string]string)
+		for {
+			line, err := r.ReadLine()
+			if err != nil || line == "" {
+				break
+			}
+			kv := strings.SplitN(line, ":", 2)
+			s.headers[kv[0]] = kv[1]
+		}
+		s.data, _ = io.ReadAll(r.R)
+		close(titles)
+		for title := range titles {
+			s.titles = append(s.titles, title)
+		}
+		streams <- s
+	}()
+	return admin, streams
+}
+
+// shoutcast2Server accepts a single Ultravox source.
+func shoutcast2Server(t *testing.T) (net.Listener, <-chan shoutcastStream) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	streams := make(chan shoutcastStream, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(streams)
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		s := shoutcastStream{headers: make(map[string]string)}
+		for {
+			typ, payload, err := readUltravox(r)
+			if err != nil || typ == 0x1005 {
+				break
+			}
+			switch {
+			case typ == 0x1009:
+				conn.Write(ultravoxMessage(typ, "ACK:foobar"))
+			case typ == 0x1001:
+				fields := strings.Split(string(payload), ":")
+				s.sid = fields[1]
+				s.user = xteaDecrypt(t, "foobar", fields[2])
+				s.password = xteaDecrypt(t, "foobar", fields[3])
+				conn.Write(ultravoxMessage(typ, "ACK:Allow"))
+			case typ < 0x2000:
+				s.headers[strconv.FormatUint(uint64(typ), 16)] = string(payload)
+				conn.Write(ultravoxMessage(typ, "ACK"))
+			case typ == 0x3902:
+				xml := string(payload[6:])
+				s.titles = append(s.titles, xml[strings.Index(xml, "<TIT2>")+6:strings.Index(xml, "</TIT2>")])
+			case typ == 0x7000:
+				s.data = append(s.data, payload...)
+			}
+		}
+		streams <- s
+	}()
+	return l, streams
+}
+
+func readUltravox(r io.Reader) (uint16, []byte, error) {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[4:])+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint16(header[2:]), payload[:len(payload)-1], nil
+}
+
+func ultravoxMessage(typ uint16, payload string) []byte {
+	m := []byte{0x5a, 0, byte(typ >> 8), byte(typ), byte(len(payload) >> 8), byte(len(payload))}
+	return append(append(m, payload...), 0)
+}
+
+// xteaDecrypt decrypts hex-encoded XTEA blocks and trims zero padding.
+func xteaDecrypt(t *testing.T, key, s string) string {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return ""
+	}
+	var kb [16]byte
+	copy(kb[:], key)
+	var k [4]uint32
+	for i := range k {
+		k[i] = binary.BigEndian.Uint32(kb[i*4:])
+	}
+	const delta uint32 = 0x9e3779b9
+	for i := 0; i+8 <= len(data); i += 8 {
+		v0, v1 := binary.BigEndian.Uint32(data[i:]), binary.BigEndian.Uint32(data[i+4:])
+		// sum after 32 rounds of encryption.
+		sum := uint32(0xc6ef3720)
+		for r := 0; r < 32; r++ {
+			v1 -= (((v0 << 4) ^ (v0 >> 5)) + v0) ^ (sum + k[(sum>>11)&3])
+			sum -= delta
+			v0 -= (((v1 << 4) ^ (v1 >> 5)) + v1) ^ (sum + k[sum&3])
+		}
+		binary.BigEndian.PutUint32(data[i:], v0)
+		binary.BigEndian.PutUint32(data[i+4:], v1)
+	}
+	return strings.TrimRight(string(data), "\x00")
+}
+
+func TestShoutcastSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		version mp3.ShoutcastVersion
+		server  func(*testing.T) (net.Listener, <-chan shoutcastStream)
+		headers map[string]string
+	}{
+		{
+			name:    "v1",
+			version: mp3.Shoutcast1,
+			server:  shoutcast1Server,
+			headers: map[string]string{
+				"content-type": "audio/mpeg",
+				"icy-name":     "Radio",
+				"icy-pub":      "1",
+				"icy-br":       "128",
+			},
+		},
+		{
+			name:    "v2",
+			version: mp3.Shoutcast2,
+			server:  shoutcast2Server,
+			headers: map[string]string{
+				"1002": "audio/mpeg",
+				"1003": "128:128",
+				"1100": "Radio",
+				"1103": "1",
+			},
+		},
+	}
+	for _, test := range tests {
+		l, streams := test.server(t)
+		meta := mp3.ICYMetadata{StreamTitle: "First"}
+		ic := mp3.Icecast{
+			URL:       "http://" + l.Addr().String() + "/",
+			Password:  "hackme",
+			Name:      "Radio",
+			Public:    true,
+			Shoutcast: test.version,
+			StreamID:  2,
+			Metadata:  &meta,
+		}
+		var (
+			p       *pipe.Pipe
+			set     bool
+			applied int
+			err     error
+		)
+		ctx, cancel := context.WithCancel(context.Background())
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      1 << 30,
+			Value:      0.5,
+		}
+		p, err = pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.IcecastSink(ic, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithProgress(func(pr mp3.Progress) {
+						switch {
+						case pr.Frames > 10 && !set:
+							set = true
+							go p.Push(meta.SetStreamTitle("Second & last"))
+						case meta.StreamTitle == "First":
+							// wait until the title is applied.
+						case applied == 0:
+							applied = pr.Frames
+						case pr.Frames > 100 && pr.Frames > applied+10:
+							cancel()
+						}
+					}),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(ctx)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		cancel()
+		l.Close()
+		s := <-streams
+		if s.password != "hackme" {
+			t.Errorf("%s: unexpected password: %q", test.name, s.password)
+		}
+		if test.version == mp3.Shoutcast2 && (s.user != "source" || s.sid != "2") {
+			t.Errorf("%s: unexpected user %q and stream id %q", test.name, s.user, s.sid)
+		}
+		for k, v := range test.headers {
+			if s.headers[k] != v {
+				t.Errorf("%s: expected header %s: %q got: %q", test.name, k, v, s.headers[k])
+			}
+		}
+		if frames := splitFrames(t, s.data); len(frames) < 100 {
+			t.Errorf("%s: expected frames: 100 got: %d", test.name, len(frames))
+		}
+		if len(s.titles) != 2 || s.titles[0] != "First" || !strings.HasPrefix(s.titles[1], "Second &") {
+			t.Errorf("%s: unexpected titles: %q", test.name, s.titles)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+// silence is the amount of silence set either as duration or as number
+// of samples per channel.
+type silence struct {
+	duration time.Duration
+	samples  int
+}
+
+// WithSilencePadding makes the Sink encode silence of lead duration
+// before the stream and trail duration after it. Duration is rounded
+// down to the whole number of samples.
+func WithSilencePadding(lead, trail time.Duration) SinkOption {
+	return func(o *sinkOptions) {
+		if lead < 0 || trail < 0 {
+			o.fail(fmt.Errorf("%w: silence padding %v, %v is negative", ErrInvalidParameter, lead, trail))
+			return
+		}
+		o.leadSilence = silence{duration: lead}
+		o.trailSilence = silence{duration: trail}
+	}
+}
+
+// WithSilencePaddingSamples makes the Sink encode lead samples of
+// silence per channel before the stream and trail samples after it.
+// Samples are counted at the input sample rate.
+func WithSilencePaddingSamples(lead, trail int) SinkOption {
+	return func(o *sinkOptions) {
+		if lead < 0 || trail < 0 {
+			o.fail(fmt.Errorf("%w: silence padding %d, %d samples is negative", ErrInvalidParameter, lead, trail))
+			return
+		}
+		o.leadSilence = silence{samples: lead}
+		o.trailSilence = silence{samples: trail}
+	}
+}
+
+// length returns the number of samples per channel.
+func (s silence) length(sampleRate signal.Frequency) int {
+	if s.duration > 0 {
+		return int(int64(s.duration) * int64(sampleRate) / int64(time.Second))
+	}
+	return s.samples
+}
+
+// padder writes silence into the encoder before the first and after the
+// last buffer.
+type padder struct {
+	w        io.Writer
+	channels int
+	lead     int
+	trail    int
+	started  bool
+}
+
+func newPadder(w io.Writer, c encoderConfig) *padder {
+	return &padder{
+		w:        w,
+		channels: c.channels,
+		lead:     c.opts.leadSilence.length(c.sampleRate),
+		trail:    c.opts.trailSilence.length(c.sampleRate),
+	}
+}
+
+func (p *padder) sinkFunc(fn pipe.SinkFunc) pipe.SinkFunc {
+	if p.lead == 0 {
+		return fn
+	}
+	return func(floats signal.Floating) error {
+		if err := p.start(); err != nil {
+			return err
+		}
+		return fn(floats)
+	}
+}
+
+// flusher writes trailing silence before the encoder is flushed. It's
+// not written if the output is discarded.
+func (p *padder) flusher(fn pipe.FlushFunc, o sinkOptions) pipe.FlushFunc {
+	if p.lead == 0 && p.trail == 0 {
+		return fn
+	}
+	return func(ctx context.Context) error {
+		var err error
+		if !o.discard(ctx) {
+			if err = p.start(); err == nil {
+				err = p.write(p.trail)
+			}
+		}
+		if flushErr := fn(ctx); err == nil {
+			err = flushErr
+		}
+		return err
+	}
+}
+
+// start writes leading silence once.
+func (p *padder) start() error {
+	if p.started {
+		return nil
+	}
+	p.started = true
+	return p.write(p.lead)
+}
+
+// write encodes samples of silence by chunks.
+func (p *padder) write(samples int) error {
+	const chunkSamples = 1152
+	buf := make([]byte, chunkSamples*p.channels*bytesPerSample)
+	for samples > 0 {
+		n := chunkSamples
+		if n > samples {
+			n = samples
+		}
+		if _, err := p.w.Write(buf[:n*p.channels*bytesPerSample]); err != nil {
+			return fmt.Errorf("error writing silence: %w", err)
+		}
+		samples -= n
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkSilencePadding(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  mp3.SinkOption
+		samples int
+		err     error
+	}{
+		{name: "duration", option: mp3.WithSilencePadding(100*time.Millisecond, 200*time.Millisecond), samples: 44100 + 4410 + 8820},
+		{name: "samples", option: mp3.WithSilencePaddingSamples(1000, 500), samples: 44100 + 1500},
+		{name: "lead only", option: mp3.WithSilencePaddingSamples(1000, 0), samples: 44100 + 1000},
+		{name: "negative", option: mp3.WithSilencePadding(-time.Second, 0), err: mp3.ErrInvalidParameter},
+	}
+	for _, test := range tests {
+		var info mp3.EncodingInfo
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      44100,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+					test.option,
+				),
+			},
+		)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if info.Samples != test.samples {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, test.samples, info.Samples)
+		}
+	}
+}
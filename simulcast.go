@@ -0,0 +1,101 @@
+package mp3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// Rendition is the single output of SimulcastSink.
+type Rendition struct {
+	W           io.Writer
+	BitRateMode BitRateMode
+	// Options are applied after the options of SimulcastSink.
+	Options []SinkOption
+}
+
+// SimulcastSink encodes the input into multiple renditions at once, for
+// example, to deliver the same stream at different bit rates. Every
+// rendition has its own encoder that writes into its own writer, but all
+// of them read the same input buffer and are encoded concurrently.
+// Controller must be set in options of the rendition it controls.
+func SimulcastSink(renditions []Rendition, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		if len(renditions) == 0 {
+			return pipe.Sink{}, fmt.Errorf("%w: simulcast has no renditions", ErrInvalidParameter)
+		}
+		var opts sinkOptions
+		for _, option := range options {
+			option(&opts)
+		}
+		if opts.controller != nil {
+			return pipe.Sink{}, fmt.Errorf("%w: simulcast controller must be set per rendition", ErrInvalidParameter)
+		}
+		sinks := make([]pipe.Sink, 0, len(renditions))
+		for i, r := range renditions {
+			combined := append(append([]SinkOption(nil), options...), r.Options...)
+			sink, err := Sink(r.W, r.BitRateMode, cm, eq, combined...)(mctx, bufferSize, props)
+			if err != nil {
+				return pipe.Sink{}, fmt.Errorf("error allocating rendition %d: %w", i, err)
+			}
+			sinks = append(sinks, sink)
+		}
+		s := simulcast{sinks: sinks, errs: make([]error, len(sinks))}
+		return pipe.Sink{
+			Context:   mctx,
+			SinkFunc:  s.sinkFunc,
+			FlushFunc: s.flush,
+		}, nil
+	}
+}
+
+// simulcast passes every buffer to the sinks of all renditions.
+type simulcast struct {
+	sinks []pipe.Sink
+	errs  []error
+}
+
+func (s *simulcast) sinkFunc(floats signal.Floating) error {
+	if len(s.sinks) == 1 {
+		return renditionError(0, s.sinks[0].SinkFunc(floats))
+	}
+	// sinks only read the buffer, so it's shared without copying.
+	var wg sync.WaitGroup
+	wg.Add(len(s.sinks))
+	for i := range s.sinks {
+		go func(i int) {
+			defer wg.Done()
+			s.errs[i] = s.sinks[i].SinkFunc(floats)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range s.errs {
+		if err != nil {
+			return renditionError(i, err)
+		}
+	}
+	return nil
+}
+
+// flush flushes all renditions and returns the first error.
+func (s *simulcast) flush(ctx context.Context) error {
+	var err error
+	for i := range s.sinks {
+		if flushErr := renditionError(i, s.sinks[i].FlushFunc(ctx)); err == nil {
+			err = flushErr
+		}
+	}
+	return err
+}
+
+func renditionError(i int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("error encoding rendition %d: %w", i, err)
+}
@@ -0,0 +1,116 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSimulcastSink(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name     string
+		bitRates []int
+		options  []mp3.SinkOption
+		err      error
+	}{
+		{
+			name:     "single",
+			bitRates: []int{128},
+		},
+		{
+			name:     "multiple",
+			bitRates: []int{64, 128, 320},
+			options:  []mp3.SinkOption{mp3.WithCRC()},
+		},
+		{
+			name: "no renditions",
+			err:  mp3.ErrInvalidParameter,
+		},
+		{
+			name:     "controller",
+			bitRates: []int{64, 128},
+			options:  []mp3.SinkOption{mp3.WithController(&mp3.Controller{})},
+			err:      mp3.ErrInvalidParameter,
+		},
+	}
+	for _, test := range tests {
+		buffers := make([]bytes.Buffer, len(test.bitRates))
+		var renditions []mp3.Rendition
+		for i, br := range test.bitRates {
+			renditions = append(renditions, mp3.Rendition{
+				W:           &buffers[i],
+				BitRateMode: mp3.CBR(br),
+			})
+		}
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      100000,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.SimulcastSink(renditions, mp3.JointStereo, mp3.DefaultEncodingQuality, test.options...),
+			},
+		)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		for i, br := range test.bitRates {
+			frames := splitFrames(t, buffers[i].Bytes())
+			if len(frames) == 0 {
+				t.Fatalf("%s: rendition %d is empty", test.name, i)
+			}
+			for _, frame := range frames {
+				if got := frameBitRate(frame); got != br {
+					t.Fatalf("%s: rendition %d has frame of %d kbps, expected %d", test.name, i, got, br)
+				}
+			}
+		}
+	}
+}
+
+func TestSimulcastSinkError(t *testing.T) {
+	skipWithoutLame(t)
+	var good bytes.Buffer
+	renditions := []mp3.Rendition{
+		{W: &good, BitRateMode: mp3.CBR(128)},
+		{W: &failingWriter{limit: 1000}, BitRateMode: mp3.CBR(64)},
+	}
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      100000,
+		Value:      0.5,
+	}
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.SimulcastSink(renditions, mp3.JointStereo, mp3.DefaultEncodingQuality),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); !errors.Is(err, errWriter) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
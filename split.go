@@ -0,0 +1,167 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	mp3 "github.com/hajimehoshi/go-mp3"
+)
+
+// Track is the time range of the track in the stream.
+type Track struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// SilenceSplit configures SplitOnSilence.
+type SilenceSplit struct {
+	// Threshold is the level in dBFS below which the input is silent.
+	// Default is -50 dBFS.
+	Threshold float64
+	// MinSilence is the min duration of silence between tracks. Default
+	// is 2 seconds.
+	MinSilence time.Duration
+	// Path returns the path of the track file with the number that
+	// starts from zero.
+	Path func(track int) string
+}
+
+const (
+	defaultSilenceThreshold = -50
+	defaultMinSilence       = 2 * time.Second
+)
+
+// SplitOnSilence splits MP3 stream from r into track files without
+// re-encoding. The stream is decoded only to find silent passages, every
+// passage is cut in the middle at the nearest frame boundary. Tracks are
+// written the same way as Cut writes them and returned in order.
+func SplitOnSilence(r io.Reader, s SilenceSplit, options ...SinkOption) ([]Track, error) {
+	if s.Path == nil {
+		return nil, fmt.Errorf("%w: track path function is nil", ErrInvalidParameter)
+	}
+	if s.Threshold > 0 || math.IsNaN(s.Threshold) {
+		return nil, fmt.Errorf("%w: silence threshold %v dBFS is above full scale", ErrInvalidParameter, s.Threshold)
+	}
+	if s.MinSilence < 0 {
+		return nil, fmt.Errorf("%w: min silence %v is negative", ErrInvalidParameter, s.MinSilence)
+	}
+	if s.Threshold == 0 {
+		s.Threshold = defaultSilenceThreshold
+	}
+	if s.MinSilence == 0 {
+		s.MinSilence = defaultMinSilence
+	}
+	in, err := readCutInput(r)
+	if err != nil {
+		return nil, err
+	}
+	cuts, err := in.silenceCuts(math.Pow(10, s.Threshold/20), in.rate.Events(s.MinSilence))
+	if err != nil {
+		return nil, err
+	}
+	return in.split(cuts, s.Path, func(int) []SinkOption { return options })
+}
+
+// silenceCuts decodes the stream and returns sample positions of cuts
+// in the middle of silent passages.
+func (in cutInput) silenceCuts(threshold float64, minSilence int) ([]int, error) {
+	decoder, err := mp3.NewDecoder(bytes.NewReader(in.audio))
+	if err != nil {
+		return nil, fmt.Errorf("error creating MP3 decoder: %w", err)
+	}
+	var (
+		cuts []int
+		// decoded samples are shifted by encoder and decoder delays.
+		pos    = -in.info.Delay - decoderDelay
+		silent = -1
+		// current decoder always provides stereo.
+		buf = make([]byte, 4*in.perFrame)
+	)
+	for {
+		n, err := io.ReadFull(decoder, buf)
+		for i := 0; i+4 <= n; i, pos = i+4, pos+1 {
+			left := int16(binary.LittleEndian.Uint16(buf[i:]))
+			right := int16(binary.LittleEndian.Uint16(buf[i+2:]))
+			level := math.Max(math.Abs(float64(left)), math.Abs(float64(right))) / math.MaxInt16
+			switch {
+			case level < threshold && silent < 0:
+				silent = pos
+			case level >= threshold && silent >= 0:
+				// silence at the start of the stream doesn't separate
+				// tracks.
+				if silent > 0 && pos-silent >= minSilence {
+					cuts = append(cuts, in.frameBoundary(silent, pos))
+				}
+				silent = -1
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return cuts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading MP3 data: %w", err)
+		}
+	}
+}
+
+// frameBoundary returns the position of frame boundary that is nearest
+// to the middle of range [from, to) and within it.
+func (in cutInput) frameBoundary(from, to int) int {
+	offset := in.info.Delay + decoderDelay
+	mid := (from + to) / 2
+	pos := (mid+offset+in.perFrame/2)/in.perFrame*in.perFrame - offset
+	if pos < from || pos >= to {
+		return mid
+	}
+	return pos
+}
+
+// split writes tracks between cuts into files. Options are returned for
+// every track.
+func (in cutInput) split(cuts []int, path func(int) string, options func(int) []SinkOption) ([]Track, error) {
+	var (
+		tracks []Track
+		from   int
+		total  = in.samples()
+	)
+	for i := 0; i <= len(cuts); i++ {
+		to := total
+		if i < len(cuts) {
+			to = cuts[i]
+		}
+		if to > total {
+			to = total
+		}
+		if from >= to {
+			continue
+		}
+		if err := in.writeTrack(path(len(tracks)), from, to, options(len(tracks))); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, Track{
+			Start: in.rate.Duration(from),
+			End:   in.rate.Duration(to),
+		})
+		from = to
+	}
+	return tracks, nil
+}
+
+// writeTrack writes the file of the track.
+func (in cutInput) writeTrack(path string, from, to int, options []SinkOption) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := in.cut(f, from, to, options); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
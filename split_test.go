@@ -0,0 +1,192 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// tracksSource returns stereo 44100 Hz source of sine tracks separated by
+// silence. Durations alternate between tracks and silence.
+func tracksSource(durations ...time.Duration) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		var (
+			pos    int
+			bounds []int
+			total  int
+		)
+		for _, d := range durations {
+			total += signal.Frequency(44100).Events(d)
+			bounds = append(bounds, total)
+		}
+		return pipe.Source{
+			SourceFunc: func(out signal.Floating) (int, error) {
+				if pos == total {
+					return 0, io.EOF
+				}
+				n := out.Length()
+				if left := total - pos; n > left {
+					n = left
+				}
+				for i := 0; i < n; i++ {
+					var part int
+					for bounds[part] <= pos+i {
+						part++
+					}
+					v := 0.0
+					if part%2 == 0 {
+						v = 0.5 * math.Sin(2*math.Pi*440*float64(pos+i)/44100)
+					}
+					for c := 0; c < out.Channels(); c++ {
+						out.SetSample(i*out.Channels()+c, v)
+					}
+				}
+				pos += n
+				return n, nil
+			},
+			SignalProperties: pipe.SignalProperties{
+				Channels:   2,
+				SampleRate: 44100,
+			},
+		}, nil
+	}
+}
+
+// encodeTracks returns the stream of the source encoded into file with
+// Xing header.
+func encodeTracks(t *testing.T, source pipe.SourceAllocatorFunc) []byte {
+	t.Helper()
+	f, err := os.CreateTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source,
+			Sink:   mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return data
+}
+
+func TestSplitOnSilence(t *testing.T) {
+	input := encodeTracks(t, tracksSource(
+		time.Second, 3*time.Second, 2*time.Second, 2500*time.Millisecond, time.Second, time.Second,
+	))
+	dir, err := os.MkdirTemp("", "split")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := func(track int) string {
+		return filepath.Join(dir, strconv.Itoa(track)+".mp3")
+	}
+
+	var samples int
+	tracks, err := mp3.SplitOnSilence(bytes.NewReader(input), mp3.SilenceSplit{Path: path},
+		mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { samples += i.Samples }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// cuts are in the middle of silence between tracks.
+	expected := []mp3.Track{
+		{Start: 0, End: 2500 * time.Millisecond},
+		{Start: 2500 * time.Millisecond, End: 7250 * time.Millisecond},
+		{Start: 7250 * time.Millisecond, End: 10500 * time.Millisecond},
+	}
+	if len(tracks) != len(expected) {
+		t.Fatalf("expected tracks: %v got: %v", expected, tracks)
+	}
+	const tolerance = 50 * time.Millisecond
+	for i, track := range tracks {
+		if d := track.Start - expected[i].Start; d < -tolerance || d > tolerance {
+			t.Errorf("track %d: expected start: %v got: %v", i, expected[i].Start, track.Start)
+		}
+		if d := track.End - expected[i].End; d < -tolerance || d > tolerance {
+			t.Errorf("track %d: expected end: %v got: %v", i, expected[i].End, track.End)
+		}
+		if i > 0 && track.Start != tracks[i-1].End {
+			t.Errorf("track %d: expected start at the end of previous track: %v got: %v", i, tracks[i-1].End, track.Start)
+		}
+		data, err := os.ReadFile(path(i))
+		if err != nil {
+			t.Fatalf("track %d: unexpected error: %v", i, err)
+		}
+		if frames := splitFrames(t, data); string(frames[0][4+32:4+36]) != "Info" {
+			t.Errorf("track %d: expected Info tag", i)
+		}
+	}
+	if samples != signal.Frequency(44100).Events(10500*time.Millisecond) {
+		t.Errorf("expected samples: %d got: %d", signal.Frequency(44100).Events(10500*time.Millisecond), samples)
+	}
+}
+
+func TestSplitOnSilenceSingleTrack(t *testing.T) {
+	input, _ := encodeShine(t)
+	dir, err := os.MkdirTemp("", "split")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tracks, err := mp3.SplitOnSilence(bytes.NewReader(input), mp3.SilenceSplit{
+		Path: func(track int) string { return filepath.Join(dir, strconv.Itoa(track)+".mp3") },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tracks) != 1 || tracks[0].Start != 0 {
+		t.Errorf("expected single track got: %v", tracks)
+	}
+}
+
+func TestSplitOnSilenceInvalid(t *testing.T) {
+	path := func(int) string { return os.DevNull }
+	tests := []struct {
+		name  string
+		split mp3.SilenceSplit
+	}{
+		{
+			name: "no path",
+		},
+		{
+			name:  "threshold",
+			split: mp3.SilenceSplit{Path: path, Threshold: 3},
+		},
+		{
+			name:  "min silence",
+			split: mp3.SilenceSplit{Path: path, MinSilence: -time.Second},
+		},
+	}
+	for _, test := range tests {
+		_, err := mp3.SplitOnSilence(bytes.NewReader(nil), test.split)
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Stats contains statistics of the encoded stream.
+type Stats struct {
+	// Frames is the number of emitted frames including VBR header frame.
+	Frames int
+	// Bytes is the number of emitted bytes.
+	Bytes int64
+	// AverageBitRate is the bit rate of emitted frames in kbps.
+	AverageBitRate float64
+	// PeakBitRate is the highest bit rate of emitted frames in kbps.
+	PeakBitRate int
+	// Duration is the wall time of encoding from the first consumed
+	// buffer until the encoder is flushed.
+	Duration time.Duration
+}
+
+// WithStats sets the function that receives Stats after the encoder is
+// flushed successfully.
+func WithStats(fn func(Stats)) SinkOption {
+	return func(o *sinkOptions) {
+		o.stats = fn
+	}
+}
+
+// statsWriter counts frames and bytes emitted by the encoder.
+type statsWriter struct {
+	w       io.Writer
+	bytes   int64
+	frames  int
+	scanner frameScanner
+	// duration of emitted frames in seconds.
+	seconds float64
+	peak    int
+	start   time.Time
+	// behind is the number of bytes before the end of stream after
+	// seek. Encoders seek back to rewrite the header frame, these
+	// writes aren't counted.
+	behind int64
+}
+
+func (s *statsWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if s.behind > 0 {
+		s.behind -= int64(n)
+		if s.behind >= 0 || err != nil {
+			return n, err
+		}
+		// write went past the end of stream.
+		over := -s.behind
+		s.behind = 0
+		return n, s.count(p[n-int(over) : n])
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, s.count(p[:n])
+}
+
+// Seek implements io.Seeker.
+func (s *statsWriter) Seek(offset int64, whence int) (int64, error) {
+	ws, ok := s.w.(io.WriteSeeker)
+	if !ok {
+		return 0, errors.New("writer is not seekable")
+	}
+	cur, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	pos, err := ws.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	if s.behind += cur - pos; s.behind < 0 {
+		s.behind = 0
+	}
+	return pos, nil
+}
+
+// count updates stats with bytes written at the end of stream.
+func (s *statsWriter) count(p []byte) error {
+	s.bytes += int64(len(p))
+	err := s.scanner.scan(p, func(_ int, h frameHeader) {
+		s.frames++
+		s.seconds += float64(h.samples()) / float64(h.sampleRate)
+		if h.bitRate > s.peak {
+			s.peak = h.bitRate
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error parsing MP3 frame: %w", err)
+	}
+	return nil
+}
+
+// result returns the stats of flushed stream.
+func (s *statsWriter) result() Stats {
+	st := Stats{
+		Frames:      s.frames,
+		Bytes:       s.bytes,
+		PeakBitRate: s.peak,
+	}
+	if s.seconds > 0 {
+		st.AverageBitRate = float64(s.bytes) * 8 / 1000 / s.seconds
+	}
+	if !s.start.IsZero() {
+		st.Duration = time.Since(s.start)
+	}
+	return st
+}
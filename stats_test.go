@@ -0,0 +1,175 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkStats(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		bitRateMode mp3.BitRateMode
+		options     []mp3.SinkOption
+	}{
+		{bitRateMode: mp3.CBR(128)},
+		{bitRateMode: mp3.CBR(320), options: []mp3.SinkOption{mp3.WithBackend(mp3.Shine)}},
+	}
+	for _, test := range tests {
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      441000,
+			Value:      0.5,
+		}
+		var (
+			out   bytes.Buffer
+			stats mp3.Stats
+		)
+		options := append(test.options, mp3.WithStats(func(s mp3.Stats) { stats = s }))
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(&out, test.bitRateMode, mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.bitRateMode, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.bitRateMode, err)
+		}
+
+		bitRate := int(test.bitRateMode.(mp3.CBR))
+		if frames := len(splitFrames(t, out.Bytes())); stats.Frames != frames {
+			t.Errorf("%v: expected frames: %d got: %d", test.bitRateMode, frames, stats.Frames)
+		}
+		if stats.Bytes != int64(out.Len()) {
+			t.Errorf("%v: expected bytes: %d got: %d", test.bitRateMode, out.Len(), stats.Bytes)
+		}
+		if math.Abs(stats.AverageBitRate-float64(bitRate)) > 1 {
+			t.Errorf("%v: expected average bit rate: %d got: %f", test.bitRateMode, bitRate, stats.AverageBitRate)
+		}
+		if stats.PeakBitRate != bitRate {
+			t.Errorf("%v: expected peak bit rate: %d got: %d", test.bitRateMode, bitRate, stats.PeakBitRate)
+		}
+		if stats.Duration <= 0 {
+			t.Errorf("%v: duration wasn't measured", test.bitRateMode)
+		}
+	}
+}
+
+// seekingEncoder writes the frames on flush and rewrites the first one
+// the same way as lame backfills its tag.
+type seekingEncoder struct {
+	frames [][]byte
+	w      io.Writer
+	ws     io.WriteSeeker
+	start  int64
+}
+
+func (e *seekingEncoder) Init(w io.Writer, params mp3.EncoderParams) error {
+	e.w = w
+	if ws, ok := w.(io.WriteSeeker); ok {
+		if start, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			e.ws, e.start = ws, start
+		}
+	}
+	return nil
+}
+
+func (e *seekingEncoder) Write(pcm []byte) (int, error) {
+	return len(pcm), nil
+}
+
+func (e *seekingEncoder) Flush() error {
+	for _, frame := range e.frames {
+		if _, err := e.w.Write(frame); err != nil {
+			return err
+		}
+	}
+	if e.ws == nil {
+		return nil
+	}
+	end, err := e.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := e.ws.Seek(e.start, io.SeekStart); err != nil {
+		return err
+	}
+	tag := append([]byte(nil), e.frames[0]...)
+	copy(tag[4+32:], "Xing")
+	if _, err := e.ws.Write(tag); err != nil {
+		return err
+	}
+	_, err = e.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+func TestSinkStatsSeekable(t *testing.T) {
+	encoded := encode(t, mp3.WithBackend(mp3.Shine))
+	tests := []struct {
+		name    string
+		options []mp3.SinkOption
+	}{
+		{name: "stats"},
+		{name: "frame aligned", options: []mp3.SinkOption{mp3.WithFrameAlignedWrites(0)}},
+		{name: "low latency", options: []mp3.SinkOption{mp3.WithLowLatency(func(mp3.Latency) {})}},
+	}
+	for _, test := range tests {
+		encoder := seekingEncoder{frames: splitFrames(t, encoded)}
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      10000,
+			Value:      0.5,
+		}
+		var stats mp3.Stats
+		options := append(test.options,
+			mp3.WithEncoder(func() mp3.Encoder { return &encoder }),
+			mp3.WithStats(func(s mp3.Stats) { stats = s }),
+		)
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if encoder.ws == nil {
+			t.Fatalf("%s: expected seekable writer", test.name)
+		}
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(data) != len(encoded) || string(data[4+32:4+36]) != "Xing" {
+			t.Fatalf("%s: expected rewritten first frame of %d bytes stream got: %d bytes", test.name, len(encoded), len(data))
+		}
+		if stats.Frames != len(encoder.frames) || stats.Bytes != int64(len(encoded)) {
+			t.Errorf("%s: expected frames: %d bytes: %d got: %+v", test.name, len(encoder.frames), len(encoded), stats)
+		}
+	}
+}
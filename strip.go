@@ -0,0 +1,127 @@
+package mp3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// apeTagSize is the size of APE tag header and footer.
+	apeTagSize = 32
+	// stripBufferSize must fit the largest frame with the header of the
+	// next one.
+	stripBufferSize = 64 * 1024
+)
+
+// StripTags copies audio frames of MP3 stream from r into w and removes
+// ID3v2 tags at any position, ID3v1, APE and Lyrics3 tags and any other
+// data between frames. Frame after the tag or unknown data is copied
+// only if it's followed by another frame, the tag or the end of the
+// stream, so false frame headers in tags aren't mistaken for audio.
+// Xing/Info frame is copied as audio frame. It returns the number of
+// removed bytes.
+func StripTags(r io.Reader, w io.Writer) (int64, error) {
+	return walkFrames(r, func(frame []byte, _ frameHeader) error {
+		_, err := w.Write(frame)
+		return err
+	})
+}
+
+// walkFrames reads MP3 stream from r and calls fn for every audio frame
+// the way StripTags finds them. Frame is valid only until fn returns.
+// Memory is bound by the buffer size regardless of the stream length. It
+// returns the number of skipped bytes of tags and unknown data.
+func walkFrames(r io.Reader, fn func(frame []byte, h frameHeader) error) (int64, error) {
+	var (
+		br      = bufio.NewReaderSize(r, stripBufferSize)
+		removed int64
+		// inFrames is true if the previous data is the frame.
+		inFrames bool
+	)
+	discard := func(n int) error {
+		d, err := br.Discard(n)
+		removed += int64(d)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error reading MP3 data: %w", err)
+		}
+		return nil
+	}
+	for {
+		b, err := br.Peek(apeTagSize)
+		if len(b) == 0 {
+			if err != nil && err != io.EOF {
+				return removed, fmt.Errorf("error reading MP3 data: %w", err)
+			}
+			return removed, nil
+		}
+		if size := tagSize(b); size > 0 {
+			inFrames = false
+			if err := discard(size); err != nil {
+				return removed, err
+			}
+			continue
+		}
+		if h, err := parseFrameHeader(b); err == nil {
+			size := h.size()
+			p, _ := br.Peek(size + frameHeaderSize)
+			if len(p) >= size && (inFrames || isFrameFollowed(p[size:])) {
+				if err := fn(p[:size], h); err != nil {
+					return removed, err
+				}
+				br.Discard(size)
+				inFrames = true
+				continue
+			}
+		}
+		inFrames = false
+		// skip to the next byte that can start a frame or a tag.
+		next := 1
+		for next < len(b) && strings.IndexByte("\xffIATL", b[next]) < 0 {
+			next++
+		}
+		if err := discard(next); err != nil {
+			return removed, err
+		}
+	}
+}
+
+// tagSize returns the size of the tag at the start of b or zero if b
+// doesn't start with the tag that has known size. Lyrics3 tags don't
+// have size at the start and are skipped as unknown data.
+func tagSize(b []byte) int {
+	switch {
+	case bytes.HasPrefix(b, []byte("ID3")):
+		return id3v2TagSize(b)
+	case isID3v1(b):
+		return id3v1Size
+	case bytes.HasPrefix(b, []byte("APETAGEX")) && len(b) >= apeTagSize:
+		// header is followed by items and footer that are included
+		// into size.
+		if flags := binary.LittleEndian.Uint32(b[20:]); flags&(1<<29) != 0 {
+			return apeTagSize + int(binary.LittleEndian.Uint32(b[12:]))
+		}
+		return apeTagSize
+	}
+	return 0
+}
+
+// isFrameFollowed reports whether b is the end of the stream or starts
+// with the frame or the tag.
+func isFrameFollowed(b []byte) bool {
+	if len(b) < frameHeaderSize {
+		return len(b) == 0
+	}
+	if _, err := parseFrameHeader(b); err == nil {
+		return true
+	}
+	for _, prefix := range []string{"ID3", "TAG", "APET", "LYRI"} {
+		if bytes.HasPrefix(b, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
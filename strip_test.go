@@ -0,0 +1,82 @@
+package mp3_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+)
+
+// apeTag returns APE tag with header, footer and a single item.
+func apeTag() []byte {
+	item := append([]byte{5, 0, 0, 0, 0, 0, 0, 0}, "Title\x00Hello"...)
+	block := func(header bool) []byte {
+		b := append([]byte("APETAGEX"), make([]byte, 24)...)
+		binary.LittleEndian.PutUint32(b[8:], 2000)
+		binary.LittleEndian.PutUint32(b[12:], uint32(len(item)+32))
+		binary.LittleEndian.PutUint32(b[16:], 1)
+		flags := uint32(1 << 31)
+		if header {
+			flags |= 1 << 29
+		}
+		binary.LittleEndian.PutUint32(b[20:], flags)
+		return b
+	}
+	return append(append(block(true), item...), block(false)...)
+}
+
+func TestStripTags(t *testing.T) {
+	audio, err := encodeTagged()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tagged, err := encodeTagged(
+		mp3.WithID3v2(mp3.ID3v2{Text: []mp3.TextFrame{{ID: "TIT2", Value: "Title"}}, Padding: 100}),
+		mp3.WithID3v1(mp3.ID3v1{Title: "Title"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frames := splitFrames(t, audio)
+	id3v2 := tagged[:len(tagged)-len(audio)-128]
+	id3v1 := tagged[len(tagged)-128:]
+	apeFooter := apeTag()[len(apeTag())-32:]
+	lyrics := []byte("LYRICSBEGININD0000210EAL00005Hello000035LYRICS200")
+
+	tests := []struct {
+		name  string
+		parts [][]byte
+	}{
+		{
+			name:  "no tags",
+			parts: [][]byte{audio},
+		},
+		{
+			name:  "all tags",
+			parts: [][]byte{id3v2, audio, apeTag(), lyrics, id3v1},
+		},
+		{
+			name:  "tag between frames",
+			parts: [][]byte{bytes.Join(frames[:10], nil), id3v2, bytes.Join(frames[10:], nil)},
+		},
+		{
+			name:  "APE footer with garbage",
+			parts: [][]byte{audio, []byte("junk\xff\xfbdata"), apeFooter},
+		},
+	}
+	for _, test := range tests {
+		input := bytes.Join(test.parts, nil)
+		var buf bytes.Buffer
+		removed, err := mp3.StripTags(bytes.NewReader(input), &buf)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !bytes.Equal(buf.Bytes(), audio) {
+			t.Errorf("%s: expected audio of %d bytes got: %d", test.name, len(audio), buf.Len())
+		}
+		if removed != int64(len(input)-len(audio)) {
+			t.Errorf("%s: expected removed: %d got: %d", test.name, len(input)-len(audio), removed)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package mp3
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+// Stutter is the run of byte-identical consecutive frames. Buggy stream
+// recorders write it when they retry reading of the same frame.
+type Stutter struct {
+	// Frame is the index of the first frame of the run. Xing/Info frame
+	// isn't counted.
+	Frame int
+	// Repeats is the number of frames that repeat the first one.
+	Repeats int
+	// Start is the time of the first frame of the run.
+	Start time.Duration
+}
+
+// WithStutterRepair makes FrameSink drop frames that repeat the previous
+// one byte by byte, so the stream gets its correct duration. Frames
+// without coded audio, like encoded digital silence, are never dropped.
+// Function is called for every repaired run, it can be nil. Encoding
+// sinks ignore this option.
+func WithStutterRepair(fn func(Stutter)) SinkOption {
+	return func(o *sinkOptions) {
+		o.stutter = &stutterDetector{report: fn}
+	}
+}
+
+// FindStutters returns runs of byte-identical consecutive frames of the
+// stream from r. Frames without coded audio are ignored.
+func FindStutters(r io.Reader) ([]Stutter, error) {
+	in, err := readCutInput(r)
+	if err != nil {
+		return nil, err
+	}
+	var stutters []Stutter
+	d := stutterDetector{report: func(s Stutter) { stutters = append(stutters, s) }}
+	for _, frame := range in.frames {
+		h, _ := parseFrameHeader(frame)
+		d.repeated(frame, h)
+	}
+	d.finish()
+	return stutters, nil
+}
+
+// stutterDetector tracks runs of repeated frames.
+type stutterDetector struct {
+	report func(Stutter)
+	prev   []byte
+	run    Stutter
+	// frames and samples of the stream including repeats.
+	frames   int
+	samples  int
+	perFrame int
+}
+
+// repeated reports if the frame repeats the previous one with coded
+// audio. Run is reported when it ends.
+func (d *stutterDetector) repeated(frame []byte, h frameHeader) bool {
+	if d.prev != nil && bytes.Equal(frame, d.prev) && hasCodedAudio(frame, h) {
+		d.run.Repeats++
+		return true
+	}
+	d.finish()
+	d.run = Stutter{
+		Frame: d.frames,
+		Start: signal.Frequency(h.sampleRate).Duration(d.samples),
+	}
+	d.prev = append(d.prev[:0], frame...)
+	d.frames++
+	d.perFrame = h.samples()
+	d.samples += d.perFrame
+	return false
+}
+
+// finish reports the current run if it has repeats.
+func (d *stutterDetector) finish() {
+	if d.run.Repeats == 0 {
+		return
+	}
+	if d.report != nil {
+		d.report(d.run)
+	}
+	d.frames += d.run.Repeats
+	d.samples += d.run.Repeats * d.perFrame
+	d.run.Repeats = 0
+}
+
+// hasCodedAudio reports if any granule of the frame has big values.
+// Encoders can stuff bits into granules of silence, so part2_3_length
+// isn't reliable.
+func hasCodedAudio(frame []byte, h frameHeader) bool {
+	side := frame[frameHeaderSize:]
+	if h.protected {
+		side = side[2:]
+	}
+	granules, channels, offset, size := h.granuleLayout()
+	for i := 0; i < granules*channels; i++ {
+		// big_values are 9 bits after 12 bits of part2_3_length.
+		pos := offset + i*size + 12
+		v := uint16(side[pos/8])<<8 | uint16(side[pos/8+1])
+		if v>>(7-uint(pos%8))&0x1ff != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package mp3_test
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/signal"
+)
+
+func TestStutter(t *testing.T) {
+	frames := splitFrames(t, encodeTracks(t, chirpSource(time.Second)))
+	// frame 5 is repeated twice and frame 10 once, Info frame is kept.
+	var stuttered [][]byte
+	for i, frame := range frames {
+		stuttered = append(stuttered, frame)
+		switch i {
+		case 6:
+			stuttered = append(stuttered, frame, frame)
+		case 11:
+			stuttered = append(stuttered, frame)
+		}
+	}
+	input := bytes.Join(stuttered, nil)
+	start := func(frame int) time.Duration {
+		return signal.Frequency(44100).Duration(frame * 1152)
+	}
+	expected := []mp3.Stutter{
+		{Frame: 5, Repeats: 2, Start: start(5)},
+		{Frame: 12, Repeats: 1, Start: start(12)},
+	}
+
+	stutters, err := mp3.FindStutters(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(stutters, expected) {
+		t.Errorf("expected stutters: %+v got: %+v", expected, stutters)
+	}
+
+	f, err := os.CreateTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	var repaired []mp3.Stutter
+	fs, err := mp3.NewFrameSink(f, mp3.WithStutterRepair(func(s mp3.Stutter) { repaired = append(repaired, s) }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// repeats are split between writes.
+	for _, b := range [][]byte{input[:len(input)/3], input[len(input)/3:]} {
+		if _, err := fs.Write(b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := fs.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(repaired, expected) {
+		t.Errorf("expected repaired stutters: %+v got: %+v", expected, repaired)
+	}
+	output, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Info frame is written again with the frame count of repaired
+	// stream.
+	if !bytes.Equal(output, bytes.Join(frames, nil)) {
+		t.Errorf("expected original stream")
+	}
+}
+
+func TestStutterSilence(t *testing.T) {
+	frames := splitFrames(t, encodeTracks(t, tracksSource(0, time.Second)))
+	input := bytes.Join(append(frames, frames[len(frames)-1]), nil)
+	stutters, err := mp3.FindStutters(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stutters) != 0 {
+		t.Errorf("expected silent frames ignored got: %+v", stutters)
+	}
+}
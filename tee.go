@@ -0,0 +1,97 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TeePolicy determines how TeeWriter handles errors of its writers.
+type TeePolicy int
+
+const (
+	// FailOnAny makes TeeWriter fail on the first error of any writer.
+	FailOnAny TeePolicy = iota
+	// DropFailed makes TeeWriter stop writing into failed writers and
+	// continue with the rest. TeeWriter fails only when all writers have
+	// failed.
+	DropFailed
+)
+
+type (
+	// TeeWriter writes encoded stream into multiple writers. Writers
+	// receive data in the order they are provided. It can be used as
+	// the Sink writer to archive the stream and upload it at the same
+	// time. TeeWriter is not seekable, so VBR header isn't written.
+	TeeWriter struct {
+		policy  TeePolicy
+		writers []io.Writer
+		errs    []error
+	}
+
+	// TeeError is returned when writer of TeeWriter fails.
+	TeeError struct {
+		// Index of the failed writer.
+		Index int
+		Err   error
+	}
+)
+
+// Tee returns TeeWriter with provided policy.
+func Tee(policy TeePolicy, writers ...io.Writer) *TeeWriter {
+	return &TeeWriter{
+		policy:  policy,
+		writers: writers,
+		errs:    make([]error, len(writers)),
+	}
+}
+
+// Write writes p into all writers that haven't failed.
+func (t *TeeWriter) Write(p []byte) (int, error) {
+	var active int
+	for i, w := range t.writers {
+		if t.errs[i] != nil {
+			continue
+		}
+		n, err := w.Write(p)
+		if err == nil && n != len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			t.errs[i] = &TeeError{Index: i, Err: err}
+			if t.policy == FailOnAny {
+				return 0, t.errs[i]
+			}
+			continue
+		}
+		active++
+	}
+	if active == 0 {
+		return 0, t.firstErr()
+	}
+	return len(p), nil
+}
+
+// Errors returns errors of writers. Error is nil if writer hasn't
+// failed.
+func (t *TeeWriter) Errors() []error {
+	return append([]error(nil), t.errs...)
+}
+
+func (t *TeeWriter) firstErr() error {
+	for _, err := range t.errs {
+		if err != nil {
+			return err
+		}
+	}
+	return errors.New("tee has no writers")
+}
+
+func (e *TeeError) Error() string {
+	return fmt.Sprintf("error writing into tee writer %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the writer.
+func (e *TeeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,86 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// failingWriter fails after limit bytes are written.
+type failingWriter struct {
+	limit int
+}
+
+var errWriter = errors.New("writer failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return 0, errWriter
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestTee(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name   string
+		policy mp3.TeePolicy
+		failed bool
+	}{
+		{name: "drop failed", policy: mp3.DropFailed},
+		{name: "fail on any", policy: mp3.FailOnAny, failed: true},
+	}
+	for _, test := range tests {
+		var first, second bytes.Buffer
+		tee := mp3.Tee(test.policy, &first, &failingWriter{limit: 1000}, &second)
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      100000,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(tee, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		err = pipe.Wait(p.Start(context.Background()))
+
+		var teeErr *mp3.TeeError
+		if test.failed {
+			if !errors.As(err, &teeErr) || teeErr.Index != 1 || !errors.Is(err, errWriter) {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if first.Len() == 0 || !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Errorf("%s: writers received different streams: %d and %d bytes", test.name, first.Len(), second.Len())
+		}
+		errs := tee.Errors()
+		if errs[0] != nil || errs[2] != nil || !errors.As(errs[1], &teeErr) || teeErr.Index != 1 {
+			t.Errorf("%s: unexpected errors: %v", test.name, errs)
+		}
+	}
+}
+
+func TestTeeAllFailed(t *testing.T) {
+	tee := mp3.Tee(mp3.DropFailed, &failingWriter{}, &failingWriter{})
+	if _, err := io.WriteString(tee, "data"); !errors.Is(err, errWriter) {
+		t.Errorf("expected error: %v got: %v", errWriter, err)
+	}
+}
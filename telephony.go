@@ -0,0 +1,67 @@
+package mp3
+
+import (
+	"fmt"
+
+	"pipelined.dev/signal"
+)
+
+const (
+	// TelephonySampleRate is the sample rate of narrowband telephony. It's
+	// encoded as MPEG-2.5.
+	TelephonySampleRate signal.Frequency = 8000
+	// maxTelephonyBitRate is the max bit rate in kbps of telephony
+	// streams. Narrowband speech doesn't benefit from higher ones.
+	maxTelephonyBitRate = 64
+)
+
+// telephonyMix mixes both parties of stereo call recording into mono.
+var telephonyMix = DownmixMatrix{{0.5, 0.5}}
+
+// WithTelephony makes the Sink encode 8 kHz mono telephony, like IVR
+// prompts and call archives, as MPEG-2.5. Input at other sample rates is
+// resampled to 8 kHz unless output sample rate is set explicitly and
+// stereo input is mixed into mono unless downmix matrix is provided.
+// Channel mode must be Mono and bit rate must be allowed by MPEG-2.5 and
+// not exceed 64 kbps.
+func WithTelephony() SinkOption {
+	return func(o *sinkOptions) {
+		o.telephony = true
+	}
+}
+
+// resolveTelephony pins the output sample rate and downmix of telephony
+// stream.
+func (o *sinkOptions) resolveTelephony(channels int) {
+	if !o.telephony {
+		return
+	}
+	if o.outSampleRate == 0 {
+		o.outSampleRate = TelephonySampleRate
+	}
+	if o.downmix == nil && channels == 2 {
+		o.downmix = telephonyMix
+	}
+}
+
+// validateTelephony checks that the stream is MPEG-2.5 mono with
+// narrowband bit rate.
+func validateTelephony(brm BitRateMode, cm ChannelMode, outSampleRate signal.Frequency) error {
+	if outSampleRate > 12000 {
+		return fmt.Errorf("%w: telephony sample rate %v Hz isn't MPEG-2.5", ErrInvalidParameter, outSampleRate)
+	}
+	if cm != Mono {
+		return fmt.Errorf("%w: telephony requires %v channel mode, got %v", ErrInvalidParameter, Mono, cm)
+	}
+	var kbps int
+	switch v := brm.(type) {
+	case CBR:
+		kbps = int(v)
+	case ABR:
+		kbps = int(v)
+	}
+	if kbps > maxTelephonyBitRate {
+		return fmt.Errorf("%w: telephony bit rate %d kbps exceeds %d kbps", ErrInvalidParameter, kbps, maxTelephonyBitRate)
+	}
+	return nil
+}
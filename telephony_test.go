@@ -0,0 +1,115 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestTelephony(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		source := mock.Source{
+			Channels:   channels,
+			SampleRate: 8000,
+			Limit:      8000,
+			Value:      0.5,
+		}
+		var out bytes.Buffer
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(&out, mp3.CBR(16), mp3.Mono, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine), mp3.WithTelephony()),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%d channels: unexpected error: %v", channels, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%d channels: unexpected error: %v", channels, err)
+		}
+		header := out.Bytes()
+		if len(header) < 4 {
+			t.Fatalf("%d channels: expected encoded frames", channels)
+		}
+		// MPEG-2.5 version, 8 kHz and mono bits of the header.
+		if version, rate, mode := header[1]>>3&3, header[2]>>2&3, header[3]>>6; version != 0 || rate != 2 || mode != 3 {
+			t.Errorf("%d channels: expected MPEG-2.5 8 kHz mono header got: % x", channels, header[:4])
+		}
+	}
+}
+
+func TestTelephonyValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		brm        mp3.BitRateMode
+		cm         mp3.ChannelMode
+		sampleRate signal.Frequency
+		options    []mp3.SinkOption
+		err        bool
+	}{
+		{
+			name:       "preset",
+			brm:        mp3.TelephonyPreset.BitRateMode,
+			cm:         mp3.TelephonyPreset.ChannelMode,
+			sampleRate: 8000,
+			options:    mp3.TelephonyPreset.Options,
+		},
+		{
+			name:       "resampled",
+			brm:        mp3.CBR(32),
+			cm:         mp3.Mono,
+			sampleRate: 44100,
+		},
+		{
+			name:       "unsupported input sample rate",
+			brm:        mp3.CBR(8),
+			cm:         mp3.Mono,
+			sampleRate: 8001,
+		},
+		{
+			name:       "stereo mode",
+			brm:        mp3.CBR(16),
+			cm:         mp3.JointStereo,
+			sampleRate: 8000,
+			err:        true,
+		},
+		{
+			name:       "high bit rate",
+			brm:        mp3.ABR(96),
+			cm:         mp3.Mono,
+			sampleRate: 8000,
+			err:        true,
+		},
+		{
+			name:       "MPEG-1 bit rate",
+			brm:        mp3.CBR(320),
+			cm:         mp3.Mono,
+			sampleRate: 8000,
+			err:        true,
+		},
+		{
+			name:       "wideband output",
+			brm:        mp3.CBR(32),
+			cm:         mp3.Mono,
+			sampleRate: 8000,
+			options:    []mp3.SinkOption{mp3.WithOutputSampleRate(16000)},
+			err:        true,
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		options := append([]mp3.SinkOption{mp3.WithTelephony()}, test.options...)
+		_, err := mp3.Sink(&out, test.brm, test.cm, mp3.DefaultEncodingQuality, options...)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: test.sampleRate, Channels: 2})
+		if test.err != errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
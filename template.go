@@ -0,0 +1,168 @@
+package mp3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+// TagTemplate is ID3v2 tag with text fields that contain variables in
+// {name} form. Variables are resolved on flush and the tag is written
+// with deferred frames, so Reserve bytes must fit the resolved tag and
+// the writer must be seekable. Built-in variables are:
+//
+//	{date}     date of flush in YYYY-MM-DD format
+//	{time}     time of flush in HH:MM:SS format
+//	{duration} duration of the stream in [H:]MM:SS format
+//	{length}   duration of the stream in milliseconds
+//	{bitrate}  average bit rate of the stream in kbps
+//
+// Other variables can be set with Set mutation. Unknown variables are
+// kept as is. TagTemplate can be bound only to a single Sink.
+type TagTemplate struct {
+	Tag     ID3v2
+	Reserve int
+
+	mctx   mutable.Context
+	values map[string]string
+}
+
+// WithTagTemplate makes the Sink write the tag resolved from template.
+// Version and encoding of the tag are taken from the stream tag.
+func WithTagTemplate(t *TagTemplate) SinkOption {
+	return func(o *sinkOptions) {
+		if t.Reserve <= 0 {
+			o.fail(fmt.Errorf("%w: tag template reserve %d is not positive", ErrInvalidParameter, t.Reserve))
+			return
+		}
+		o.template = t
+	}
+}
+
+// Set returns the mutation that sets the value of the variable. It
+// panics if template isn't bound to the Sink yet.
+func (t *TagTemplate) Set(name, value string) mutable.Mutation {
+	return t.mctx.Mutate(func() error {
+		if t.values == nil {
+			t.values = make(map[string]string)
+		}
+		t.values[name] = value
+		return nil
+	})
+}
+
+func (t *TagTemplate) bind(mctx mutable.Context) {
+	t.mctx = mctx
+}
+
+// deferred returns frames of the resolved tag.
+func (t *TagTemplate) deferred(tag ID3v2, sampleRate signal.Frequency, stats *statsWriter) deferredFrames {
+	return deferredFrames{
+		reserve: t.Reserve,
+		frames: func(info EncodingInfo) ([]id3Frame, error) {
+			now := time.Now()
+			duration := time.Duration(int64(info.Samples) * int64(time.Second) / int64(sampleRate))
+			values := map[string]string{
+				"date":     now.Format("2006-01-02"),
+				"time":     now.Format("15:04:05"),
+				"duration": formatDuration(duration),
+				"length":   strconv.FormatInt(int64(duration/time.Millisecond), 10),
+				"bitrate":  strconv.Itoa(int(stats.result().AverageBitRate + 0.5)),
+			}
+			for k, v := range t.values {
+				values[k] = v
+			}
+			resolved := t.Tag.expand(func(s string) string {
+				return expandTemplate(s, values)
+			})
+			resolved.Version, resolved.Encoding = tag.Version, tag.Encoding
+			if err := resolved.validate(); err != nil {
+				return nil, err
+			}
+			return resolved.frames(), nil
+		},
+	}
+}
+
+// expandTemplate replaces known variables in s.
+func expandTemplate(s string, values map[string]string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(s[:start])
+		if v, ok := values[s[start+1:end]]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString(s[start : end+1])
+		}
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// formatDuration formats duration rounded to seconds.
+func formatDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
+
+// expand returns the copy of the tag with text fields mapped by fn.
+func (t ID3v2) expand(fn func(string) string) ID3v2 {
+	e := t
+	e.Text = append([]TextFrame(nil), t.Text...)
+	for i := range e.Text {
+		e.Text[i].Value = fn(e.Text[i].Value)
+		e.Text[i].Values = append([]string(nil), e.Text[i].Values...)
+		for j := range e.Text[i].Values {
+			e.Text[i].Values[j] = fn(e.Text[i].Values[j])
+		}
+	}
+	e.UserText = append([]UserText(nil), t.UserText...)
+	for i := range e.UserText {
+		e.UserText[i].Value = fn(e.UserText[i].Value)
+		e.UserText[i].Values = append([]string(nil), e.UserText[i].Values...)
+		for j := range e.UserText[i].Values {
+			e.UserText[i].Values[j] = fn(e.UserText[i].Values[j])
+		}
+	}
+	e.Comments = append([]Comment(nil), t.Comments...)
+	for i := range e.Comments {
+		e.Comments[i].Text = fn(e.Comments[i].Text)
+	}
+	e.UserURLs = append([]UserURL(nil), t.UserURLs...)
+	for i := range e.UserURLs {
+		e.UserURLs[i].URL = fn(e.UserURLs[i].URL)
+	}
+	e.Chapters = append([]Chapter(nil), t.Chapters...)
+	for i := range e.Chapters {
+		c := &e.Chapters[i]
+		c.Title = fn(c.Title)
+		c.Description = fn(c.Description)
+		c.URL = fn(c.URL)
+		c.Links = append([]UserURL(nil), c.Links...)
+		for j := range c.Links {
+			c.Links[j].URL = fn(c.Links[j].URL)
+		}
+	}
+	return e
+}
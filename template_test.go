@@ -0,0 +1,102 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestSinkTagTemplate(t *testing.T) {
+	f, err := os.CreateTemp("", "mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	template := mp3.TagTemplate{
+		Tag: mp3.ID3v2{
+			Text: []mp3.TextFrame{
+				{ID: "TIT2", Value: "Recording {date} {unknown}"},
+				{ID: "TLEN", Value: "{length}"},
+			},
+			UserText: []mp3.UserText{{Description: "SHOW", Value: "{show}"}},
+			Comments: []mp3.Comment{{Text: "{duration} at {bitrate} kbps"}},
+		},
+		Reserve: 256,
+	}
+	var (
+		info mp3.EncodingInfo
+		p    *pipe.Pipe
+		set  bool
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+		Limit:      1 << 30,
+		Value:      0.5,
+	}
+	p, err = pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink: mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+				mp3.WithBackend(mp3.Shine),
+				mp3.WithTagTemplate(&template),
+				mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+				mp3.WithProgress(func(pr mp3.Progress) {
+					switch {
+					case pr.Frames > 10 && !set:
+						set = true
+						go p.Push(template.Set("show", "Morning"))
+					case pr.Frames > 100:
+						cancel()
+					}
+				}),
+			),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(ctx)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	duration := time.Duration(info.Samples) * time.Second / 44100
+	expected := []id3Frame{
+		{id: "TIT2", body: []byte("\x03Recording " + time.Now().Format("2006-01-02") + " {unknown}")},
+		{id: "TLEN", body: []byte(fmt.Sprintf("\x03%d", duration/time.Millisecond))},
+		{id: "TXXX", body: []byte("\x03SHOW\x00Morning")},
+		{id: "COMM", body: []byte(fmt.Sprintf("\x03XXX\x00%02d:%02d at 128 kbps", int(duration.Round(time.Second).Minutes()), int(duration.Round(time.Second).Seconds())%60))},
+	}
+	frames, _ := parseID3v2(t, data)
+	if len(frames) != len(expected) {
+		t.Fatalf("expected frames: %d got: %d", len(expected), len(frames))
+	}
+	for i, f := range frames {
+		if f.id != expected[i].id || string(f.body) != string(expected[i].body) {
+			t.Errorf("expected frame %s %q got: %s %q", expected[i].id, expected[i].body, f.id, f.body)
+		}
+	}
+}
+
+func TestSinkTagTemplateReserve(t *testing.T) {
+	_, err := encodeTagged(mp3.WithTagTemplate(&mp3.TagTemplate{}))
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,92 @@
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteTimeoutError is returned when the writer doesn't complete the
+// write within the timeout.
+type WriteTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *WriteTimeoutError) Error() string {
+	return fmt.Sprintf("write timeout after %v", e.Timeout)
+}
+
+// WithWriteTimeout sets the timeout of every write into the Sink writer.
+// If the writer stalls, the Sink fails with WriteTimeoutError unless
+// fallback writer is provided. Stalled write can't be interrupted and
+// keeps running in background.
+func WithWriteTimeout(timeout time.Duration) SinkOption {
+	return func(o *sinkOptions) {
+		if timeout <= 0 {
+			o.fail(fmt.Errorf("%w: write timeout %v is not positive", ErrInvalidParameter, timeout))
+			return
+		}
+		o.writeTimeout = timeout
+	}
+}
+
+// WithFallbackWriter sets the writer that receives the stream after the
+// Sink writer stalls. The write that timed out is repeated into fallback,
+// so the data of that write may be present in both writers. Fallback
+// requires WithWriteTimeout to be set.
+func WithFallbackWriter(w io.Writer) SinkOption {
+	return func(o *sinkOptions) {
+		o.fallback = w
+	}
+}
+
+// timeoutWriter detects stalled writes.
+type timeoutWriter struct {
+	w        io.Writer
+	timeout  time.Duration
+	fallback io.Writer
+	stalled  bool
+}
+
+type writeResult struct {
+	n   int
+	err error
+}
+
+func (t *timeoutWriter) Write(p []byte) (int, error) {
+	if t.stalled {
+		if t.fallback == nil {
+			return 0, &WriteTimeoutError{Timeout: t.timeout}
+		}
+		return t.fallback.Write(p)
+	}
+	// stalled write keeps the buffer, so it can't be reused.
+	buf := append([]byte(nil), p...)
+	result := make(chan writeResult, 1)
+	go func() {
+		n, err := t.w.Write(buf)
+		result <- writeResult{n: n, err: err}
+	}()
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-result:
+		return r.n, r.err
+	case <-timer.C:
+		t.stalled = true
+		if t.fallback == nil {
+			return 0, &WriteTimeoutError{Timeout: t.timeout}
+		}
+		return t.fallback.Write(p)
+	}
+}
+
+// Seek implements io.Seeker. Seek is not limited by the timeout.
+func (t *timeoutWriter) Seek(offset int64, whence int) (int64, error) {
+	ws, ok := t.w.(io.WriteSeeker)
+	if !ok || t.stalled {
+		return 0, errors.New("writer is not seekable")
+	}
+	return ws.Seek(offset, whence)
+}
@@ -0,0 +1,96 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// stallingWriter blocks after limit bytes are written until released.
+type stallingWriter struct {
+	limit   int
+	release chan struct{}
+	bytes.Buffer
+}
+
+func (w *stallingWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		<-w.release
+		return 0, errors.New("released")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestSinkWriteTimeout(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		name     string
+		fallback bool
+	}{
+		{name: "error"},
+		{name: "fallback", fallback: true},
+	}
+	for _, test := range tests {
+		out := stallingWriter{limit: 10000, release: make(chan struct{})}
+		defer close(out.release)
+		var fallback bytes.Buffer
+		options := []mp3.SinkOption{mp3.WithWriteTimeout(10 * time.Millisecond)}
+		if test.fallback {
+			options = append(options, mp3.WithFallbackWriter(&fallback))
+		}
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      441000,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   mp3.Sink(&out, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		err = pipe.Wait(p.Start(context.Background()))
+
+		if !test.fallback {
+			var timeoutErr *mp3.WriteTimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Errorf("%s: expected timeout error got: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if out.Len() == 0 || fallback.Len() == 0 {
+			t.Errorf("%s: expected writes into both writers got: %d and %d bytes", test.name, out.Len(), fallback.Len())
+		}
+	}
+}
+
+func TestSinkFallbackWithoutTimeout(t *testing.T) {
+	source := mock.Source{
+		Channels:   2,
+		SampleRate: 44100,
+	}
+	_, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source: source.Source(),
+			Sink:   mp3.Sink(&bytes.Buffer{}, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithFallbackWriter(&bytes.Buffer{})),
+		},
+	)
+	if !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+}
@@ -0,0 +1,82 @@
+package mp3
+
+import (
+	"context"
+	"io"
+
+	"pipelined.dev/pipe"
+)
+
+// transcodeBufferSize is the buffer size of the transcoding pipe.
+const transcodeBufferSize = 1152
+
+// Transcoder re-encodes MP3 stream written into it with new settings,
+// like different bit rate. It manages the pipe with decoder and encoder
+// that runs in background. Its Write blocks until the decoder consumes
+// the data.
+type Transcoder struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+// Transcode decodes MP3 stream from r and encodes it into w with new
+// settings.
+func Transcode(r io.Reader, w io.Writer, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) error {
+	p, err := pipe.New(
+		transcodeBufferSize,
+		pipe.Line{
+			Source: Source(r),
+			Sink:   Sink(w, brm, cm, eq, options...),
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return pipe.Wait(p.Start(context.Background()))
+}
+
+// NewTranscoder returns Transcoder that writes into w. Options are
+// validated before the input is known, bit rate mode is validated once
+// the sample rate of the input is decoded.
+func NewTranscoder(w io.Writer, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) (*Transcoder, error) {
+	var opts sinkOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if err := eq.validate(); err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	t := Transcoder{
+		pw:   pw,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(t.done)
+		t.err = Transcode(pr, w, brm, cm, eq, options...)
+		// unblock writes if the pipe is done before the input.
+		if t.err != nil {
+			pr.CloseWithError(t.err)
+		} else {
+			pr.Close()
+		}
+	}()
+	return &t, nil
+}
+
+// Write implements io.Writer. Data can be split at any position. It
+// returns the error of the pipe if it has failed.
+func (t *Transcoder) Write(p []byte) (int, error) {
+	return t.pw.Write(p)
+}
+
+// Close ends the input and waits until the encoder is flushed.
+func (t *Transcoder) Close() error {
+	t.pw.Close()
+	<-t.done
+	return t.err
+}
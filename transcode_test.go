@@ -0,0 +1,110 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+)
+
+func TestTranscoder(t *testing.T) {
+	var in bytes.Buffer
+	if err := encodeStream(&in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inFrames := splitFrames(t, in.Bytes())
+	tests := []struct {
+		name    string
+		chunk   int
+		bitRate int
+	}{
+		{
+			name:    "small writes",
+			chunk:   100,
+			bitRate: 64,
+		},
+		{
+			name:    "single write",
+			chunk:   in.Len(),
+			bitRate: 192,
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		tr, err := mp3.NewTranscoder(&out, mp3.CBR(test.bitRate), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		data := in.Bytes()
+		for len(data) > 0 {
+			n := test.chunk
+			if n > len(data) {
+				n = len(data)
+			}
+			if _, err := tr.Write(data[:n]); err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+			data = data[n:]
+		}
+		if err := tr.Close(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		frames := splitFrames(t, out.Bytes())
+		// decoder and encoder delays add frames.
+		if len(frames) < len(inFrames) || len(frames) > len(inFrames)+3 {
+			t.Errorf("%s: expected frames: %d got: %d", test.name, len(inFrames), len(frames))
+		}
+		for _, frame := range frames {
+			if frameBitRate(frame) != test.bitRate {
+				t.Fatalf("%s: expected bit rate: %d got: %d", test.name, test.bitRate, frameBitRate(frame))
+			}
+		}
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	var in, out bytes.Buffer
+	if err := encodeStream(&in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mp3.Transcode(&in, &out, mp3.CBR(64), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, frame := range splitFrames(t, out.Bytes()) {
+		if frameBitRate(frame) != 64 {
+			t.Fatalf("expected bit rate: %d got: %d", 64, frameBitRate(frame))
+		}
+	}
+}
+
+func TestTranscoderErrors(t *testing.T) {
+	if _, err := mp3.NewTranscoder(&bytes.Buffer{}, mp3.CBR(128), mp3.JointStereo, 10); !errors.Is(err, mp3.ErrInvalidParameter) {
+		t.Errorf("expected error: %v got: %v", mp3.ErrInvalidParameter, err)
+	}
+
+	var in bytes.Buffer
+	if err := encodeStream(&in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr, err := mp3.NewTranscoder(&failingWriter{limit: 1000}, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, mp3.WithBackend(mp3.Shine))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var writeErr error
+	data := in.Bytes()
+	for len(data) > 0 && writeErr == nil {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		_, writeErr = tr.Write(data[:n])
+		data = data[n:]
+	}
+	if err := tr.Close(); !errors.Is(err, errWriter) {
+		t.Errorf("expected error: %v got: %v", errWriter, err)
+	}
+	if writeErr != nil && !errors.Is(writeErr, errWriter) {
+		t.Errorf("expected write error: %v got: %v", errWriter, writeErr)
+	}
+}
@@ -0,0 +1,78 @@
+package mp3
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+)
+
+// WithLeadingSilenceTrim makes the Sink drop input samples until the
+// first sample with amplitude at or above threshold in dBFS. Duration of
+// dropped input is reported in EncodingInfo.Trimmed.
+func WithLeadingSilenceTrim(threshold float64) SinkOption {
+	return func(o *sinkOptions) {
+		if threshold > 0 || math.IsNaN(threshold) {
+			o.fail(fmt.Errorf("%w: silence threshold %v dBFS is above full scale", ErrInvalidParameter, threshold))
+			return
+		}
+		o.trimSilence = true
+		o.trimThreshold = math.Pow(10, threshold/20)
+	}
+}
+
+// trimmer drops leading samples below the threshold.
+type trimmer struct {
+	threshold  float64
+	sampleRate signal.Frequency
+	trimmed    int
+	done       bool
+}
+
+// newTrimmer returns nil if trim is disabled. Otherwise info callback of
+// the config is wrapped to report trimmed duration.
+func newTrimmer(c *encoderConfig) *trimmer {
+	if !c.opts.trimSilence {
+		return nil
+	}
+	t := trimmer{
+		threshold:  c.opts.trimThreshold,
+		sampleRate: c.sampleRate,
+	}
+	if fn := c.opts.info; fn != nil {
+		c.opts.info = func(info EncodingInfo) {
+			info.Trimmed = t.duration()
+			fn(info)
+		}
+	}
+	return &t
+}
+
+func (t *trimmer) sinkFunc(fn pipe.SinkFunc) pipe.SinkFunc {
+	if t == nil {
+		return fn
+	}
+	return func(floats signal.Floating) error {
+		if t.done {
+			return fn(floats)
+		}
+		channels := floats.Channels()
+		for i := 0; i < floats.Len(); i++ {
+			if math.Abs(floats.Sample(i)) >= t.threshold {
+				t.done = true
+				start := i / channels
+				t.trimmed += start
+				return fn(floats.Slice(start, floats.Length()))
+			}
+		}
+		t.trimmed += floats.Length()
+		return nil
+	}
+}
+
+// duration returns the duration of dropped input.
+func (t *trimmer) duration() time.Duration {
+	return time.Duration(int64(t.trimmed) * int64(time.Second) / int64(t.sampleRate))
+}
@@ -0,0 +1,96 @@
+package mp3_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+func TestSinkLeadingSilenceTrim(t *testing.T) {
+	tests := []struct {
+		name      string
+		silence   int
+		threshold float64
+		samples   int
+		trimmed   time.Duration
+		err       error
+	}{
+		{name: "trimmed", silence: 4410, threshold: -60, samples: 44100, trimmed: 100 * time.Millisecond},
+		{name: "quiet signal", silence: 4410, threshold: -6, samples: 0, trimmed: time.Second + 100*time.Millisecond},
+		{name: "no silence", threshold: -60, samples: 44100},
+		{name: "above full scale", threshold: 1, err: mp3.ErrInvalidParameter},
+	}
+	for _, test := range tests {
+		var info mp3.EncodingInfo
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: leadingSilenceSource(test.silence, 44100, 0.1),
+				Sink: mp3.Sink(io.Discard, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+					mp3.WithLeadingSilenceTrim(test.threshold),
+				),
+			},
+		)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error: %v got: %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if info.Samples != test.samples {
+			t.Errorf("%s: expected samples: %d got: %d", test.name, test.samples, info.Samples)
+		}
+		if info.Trimmed != test.trimmed {
+			t.Errorf("%s: expected trimmed: %v got: %v", test.name, test.trimmed, info.Trimmed)
+		}
+	}
+}
+
+// leadingSilenceSource returns stereo 44100 Hz source of silence followed
+// by the signal of provided value.
+func leadingSilenceSource(silence, samples int, value float64) pipe.SourceAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int) (pipe.Source, error) {
+		var pos int
+		return pipe.Source{
+			SourceFunc: func(out signal.Floating) (int, error) {
+				if pos == silence+samples {
+					return 0, io.EOF
+				}
+				n := out.Length()
+				if left := silence + samples - pos; n > left {
+					n = left
+				}
+				for i := 0; i < n; i++ {
+					v := 0.0
+					if pos+i >= silence {
+						v = value
+					}
+					for c := 0; c < out.Channels(); c++ {
+						out.SetSample(i*out.Channels()+c, v)
+					}
+				}
+				pos += n
+				return n, nil
+			},
+			SignalProperties: pipe.SignalProperties{
+				Channels:   2,
+				SampleRate: 44100,
+			},
+		}, nil
+	}
+}
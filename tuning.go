@@ -0,0 +1,164 @@
+package mp3
+
+import (
+	"fmt"
+
+	"pipelined.dev/signal"
+)
+
+// WithForceMS forces mid/side coding of all joint stereo frames instead of
+// switching between mid/side and left/right per frame. It has effect only
+// for JointStereo mode.
+func WithForceMS() SinkOption {
+	return func(o *sinkOptions) {
+		o.tune(func(e *lameEncoder) {
+			e.setForceMS(true)
+		})
+	}
+}
+
+// WithATHAASensitivity shifts the sensitivity of automatic adjustment of
+// absolute threshold of hearing in dB. Positive values lower the
+// threshold. Lame uses 0 by default.
+func WithATHAASensitivity(db float64) SinkOption {
+	return func(o *sinkOptions) {
+		o.tune(func(e *lameEncoder) {
+			e.setATHAASensitivity(db)
+		})
+	}
+}
+
+// WithSFB21NoiseShaping adjusts allowed noise in scale factor band 21
+// (above ~16 kHz) in dB. Values: [-8..7.75] with 0.25 dB resolution.
+func WithSFB21NoiseShaping(db float64) SinkOption {
+	return func(o *sinkOptions) {
+		if err := validateNoiseShaping("sfb21", db); err != nil {
+			o.fail(err)
+			return
+		}
+		o.tune(func(e *lameEncoder) {
+			e.setNoiseShaping(nsSFB21, db)
+		})
+	}
+}
+
+// WithInterChannelMasking sets the ratio of masking between channels.
+// Typical values are around 0.0002. Values: [0..1]
+func WithInterChannelMasking(ratio float64) SinkOption {
+	return func(o *sinkOptions) {
+		if ratio < 0 || ratio > 1 {
+			o.fail(fmt.Errorf("%w: inter-channel masking %v is out of range [0..1]", ErrInvalidParameter, ratio))
+			return
+		}
+		o.tune(func(e *lameEncoder) {
+			e.setInterChannelRatio(ratio)
+		})
+	}
+}
+
+// NoiseShaping contains adjustments of allowed noise in dB for bass, alto
+// and treble ranges. Positive values allow more noise. Values: [-8..7.75]
+// with 0.25 dB resolution.
+type NoiseShaping struct {
+	Bass   float64
+	Alto   float64
+	Treble float64
+}
+
+// WithNoiseShaping adjusts psychoacoustic noise shaping per frequency
+// range.
+func WithNoiseShaping(ns NoiseShaping) SinkOption {
+	return func(o *sinkOptions) {
+		for _, v := range []struct {
+			name string
+			db   float64
+		}{
+			{"bass", ns.Bass},
+			{"alto", ns.Alto},
+			{"treble", ns.Treble},
+		} {
+			if err := validateNoiseShaping(v.name, v.db); err != nil {
+				o.fail(err)
+				return
+			}
+		}
+		o.tune(func(e *lameEncoder) {
+			e.setNoiseShaping(nsBass, ns.Bass)
+			e.setNoiseShaping(nsAlto, ns.Alto)
+			e.setNoiseShaping(nsTreble, ns.Treble)
+		})
+	}
+}
+
+// WithATHType selects the absolute threshold of hearing formula.
+// Values: [0..4]
+func WithATHType(t int) SinkOption {
+	return func(o *sinkOptions) {
+		if t < 0 || t > 4 {
+			o.fail(fmt.Errorf("%w: ATH type %d is out of range [0..4]", ErrInvalidParameter, t))
+			return
+		}
+		o.tune(func(e *lameEncoder) {
+			e.setATHType(t)
+		})
+	}
+}
+
+// WithATHLevel lowers the absolute threshold of hearing by provided dB.
+// Negative values raise the threshold.
+func WithATHLevel(db float64) SinkOption {
+	return func(o *sinkOptions) {
+		o.tune(func(e *lameEncoder) {
+			e.setATHLower(db)
+		})
+	}
+}
+
+// WithSubstepShaping selects the substep noise shaping method.
+// Values: [0..7]
+func WithSubstepShaping(mode int) SinkOption {
+	return func(o *sinkOptions) {
+		if mode < 0 || mode > 7 {
+			o.fail(fmt.Errorf("%w: substep shaping %d is out of range [0..7]", ErrInvalidParameter, mode))
+			return
+		}
+		o.tune(func(e *lameEncoder) {
+			e.setSubstep(mode)
+		})
+	}
+}
+
+// WithLowpass sets the frequency of the lowpass filter applied before
+// encoding. Lame selects it by the bit rate by default.
+func WithLowpass(cutoff signal.Frequency) SinkOption {
+	return func(o *sinkOptions) {
+		if cutoff <= 0 {
+			o.fail(fmt.Errorf("%w: lowpass frequency %v is not positive", ErrInvalidParameter, cutoff))
+			return
+		}
+		o.tune(func(e *lameEncoder) {
+			e.setLowpass(int(cutoff))
+		})
+	}
+}
+
+// WithHighpass sets the frequency of the highpass filter applied before
+// encoding. By default lame decides if it's needed by the bit rate.
+func WithHighpass(cutoff signal.Frequency) SinkOption {
+	return func(o *sinkOptions) {
+		if cutoff <= 0 {
+			o.fail(fmt.Errorf("%w: highpass frequency %v is not positive", ErrInvalidParameter, cutoff))
+			return
+		}
+		o.tune(func(e *lameEncoder) {
+			e.setHighpass(int(cutoff))
+		})
+	}
+}
+
+func validateNoiseShaping(name string, db float64) error {
+	if db < -8 || db > 7.75 {
+		return fmt.Errorf("%w: %s noise shaping %v dB is out of range [-8..7.75]", ErrInvalidParameter, name, db)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package mp3_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+func TestTuning(t *testing.T) {
+	skipWithoutLame(t)
+	tests := []struct {
+		options []mp3.SinkOption
+		err     bool
+	}{
+		{
+			options: []mp3.SinkOption{
+				mp3.WithForceMS(),
+				mp3.WithATHAASensitivity(-3),
+				mp3.WithSFB21NoiseShaping(-2.5),
+				mp3.WithInterChannelMasking(0.0002),
+			},
+		},
+		{
+			options: []mp3.SinkOption{
+				mp3.WithATHType(2),
+				mp3.WithATHLevel(3),
+				mp3.WithNoiseShaping(mp3.NoiseShaping{Bass: -1, Alto: 0.5, Treble: 2}),
+				mp3.WithSubstepShaping(3),
+			},
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithHighpass(100), mp3.WithLowpass(8000)},
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithATHType(5)},
+			err:     true,
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithNoiseShaping(mp3.NoiseShaping{Treble: -9})},
+			err:     true,
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithSubstepShaping(8)},
+			err:     true,
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithSFB21NoiseShaping(8)},
+			err:     true,
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithInterChannelMasking(-1)},
+			err:     true,
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithLowpass(0)},
+			err:     true,
+		},
+		{
+			options: []mp3.SinkOption{mp3.WithHighpass(-80)},
+			err:     true,
+		},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		_, err := mp3.Sink(
+			&out,
+			mp3.VBR(2),
+			mp3.JointStereo,
+			mp3.DefaultEncodingQuality,
+			test.options...,
+		)(mutable.Mutable(), bufferSize, pipe.SignalProperties{SampleRate: 44100, Channels: 2})
+		if test.err != errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !test.err {
+			encode(t, test.options...)
+		}
+	}
+}
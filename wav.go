@@ -0,0 +1,171 @@
+package mp3
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+)
+
+const (
+	// wavFormatMP3 is the WAVE format tag of MPEG Layer III.
+	wavFormatMP3 = 0x0055
+	// wavHeaderSize is the size of RIFF header with fmt, fact and data
+	// chunk headers.
+	wavHeaderSize = 12 + 8 + 30 + 8 + 4 + 8
+)
+
+// WAVSink allows to write mp3 stream into WAV container with MPEG Layer
+// III format tag. Such files are required for ingest by some broadcast
+// systems. Chunk sizes, average bit rate and sample length are written
+// on flush, so ws must be seekable.
+func WAVSink(ws io.WriteSeeker, brm BitRateMode, cm ChannelMode, eq EncodingQuality, options ...SinkOption) pipe.SinkAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Sink, error) {
+		cfg, err := newEncoderConfig(brm, cm, eq, props, options)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		start, err := ws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return pipe.Sink{}, fmt.Errorf("error writing WAV header: %w", err)
+		}
+		wav := wavWriter{
+			ws:         ws,
+			start:      start,
+			channels:   cfg.channels,
+			sampleRate: int(cfg.sampleRate),
+			outRate:    int(cfg.sampleRate),
+			policy:     cfg.opts.cancelPolicy,
+			infoFn:     cfg.opts.info,
+		}
+		if cfg.opts.outSampleRate != 0 {
+			wav.outRate = int(cfg.opts.outSampleRate)
+		}
+		if _, err := ws.Write(wav.header()); err != nil {
+			return pipe.Sink{}, fmt.Errorf("error writing WAV header: %w", err)
+		}
+		options = append(options[:len(options):len(options)], WithEncodingInfo(wav.setInfo))
+		sink, err := Sink(ws, brm, cm, eq, options...)(mctx, bufferSize, props)
+		if err != nil {
+			return pipe.Sink{}, err
+		}
+		return pipe.Sink{
+			Context:   sink.Context,
+			SinkFunc:  sink.SinkFunc,
+			FlushFunc: wav.flusher(sink.FlushFunc),
+		}, nil
+	}
+}
+
+// wavWriter writes RIFF header before the mp3 stream and backfills it
+// when the stream is complete.
+type wavWriter struct {
+	ws         io.WriteSeeker
+	start      int64
+	channels   int
+	sampleRate int
+	outRate    int
+	policy     CancelPolicy
+	infoFn     func(EncodingInfo)
+
+	info     EncodingInfo
+	dataSize int64
+}
+
+func (w *wavWriter) setInfo(info EncodingInfo) {
+	w.info = info
+	if w.infoFn != nil {
+		w.infoFn(info)
+	}
+}
+
+// flusher flushes the sink and overwrites the header.
+func (w *wavWriter) flusher(fn pipe.FlushFunc) pipe.FlushFunc {
+	return func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		if ctx.Err() != nil && w.policy == DiscardOnCancel {
+			return nil
+		}
+		if err := w.finish(); err != nil {
+			return fmt.Errorf("error writing WAV header: %w", err)
+		}
+		return nil
+	}
+}
+
+func (w *wavWriter) finish() error {
+	end, err := w.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	w.dataSize = end - w.start - wavHeaderSize
+	// chunks are padded to even size.
+	if w.dataSize%2 == 1 {
+		if _, err := w.ws.Write([]byte{0}); err != nil {
+			return err
+		}
+		end++
+	}
+	if _, err := w.ws.Seek(w.start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.ws.Write(w.header()); err != nil {
+		return err
+	}
+	_, err = w.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// samples returns the number of encoded samples per channel.
+func (w *wavWriter) samples() int64 {
+	return int64(w.info.Samples) * int64(w.outRate) / int64(w.sampleRate)
+}
+
+// header returns RIFF header with fmt chunk of MPEGLAYER3WAVEFORMAT
+// structure, fact chunk and data chunk header.
+func (w *wavWriter) header() []byte {
+	frameSamples := int64(1152)
+	if w.outRate < 32000 {
+		frameSamples = 576
+	}
+	var avgBytes, blockSize int64
+	if samples := w.samples(); samples > 0 {
+		avgBytes = w.dataSize * int64(w.outRate) / samples
+		blockSize = avgBytes * frameSamples / int64(w.outRate)
+	}
+	riffSize := wavHeaderSize - 8 + w.dataSize + w.dataSize%2
+
+	b := make([]byte, wavHeaderSize)
+	le := binary.LittleEndian
+	copy(b[0:], "RIFF")
+	le.PutUint32(b[4:], uint32(riffSize))
+	copy(b[8:], "WAVE")
+
+	copy(b[12:], "fmt ")
+	le.PutUint32(b[16:], 30)
+	le.PutUint16(b[20:], wavFormatMP3)
+	le.PutUint16(b[22:], uint16(w.channels))
+	le.PutUint32(b[24:], uint32(w.outRate))
+	le.PutUint32(b[28:], uint32(avgBytes))
+	le.PutUint16(b[32:], 1) // block align.
+	le.PutUint16(b[34:], 0) // bits per sample.
+	le.PutUint16(b[36:], 12)
+	le.PutUint16(b[38:], 1) // MPEGLAYER3_ID_MPEG.
+	le.PutUint32(b[40:], 2) // MPEGLAYER3_FLAG_PADDING_OFF.
+	le.PutUint16(b[44:], uint16(blockSize))
+	le.PutUint16(b[46:], 1) // frames per block.
+	le.PutUint16(b[48:], uint16(w.info.Delay))
+
+	copy(b[50:], "fact")
+	le.PutUint32(b[54:], 4)
+	le.PutUint32(b[58:], uint32(w.samples()))
+
+	copy(b[62:], "data")
+	le.PutUint32(b[66:], uint32(w.dataSize))
+	return b
+}
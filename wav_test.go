@@ -0,0 +1,93 @@
+package mp3_test
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+	"pipelined.dev/signal"
+)
+
+func TestWAVSink(t *testing.T) {
+	tests := []struct {
+		name       string
+		channels   int
+		sampleRate signal.Frequency
+		limit      int
+		brm        mp3.BitRateMode
+	}{
+		{name: "stereo", channels: 2, sampleRate: 44100, limit: 100000, brm: mp3.CBR(128)},
+		{name: "48 kHz", channels: 2, sampleRate: 48000, limit: 48000, brm: mp3.CBR(192)},
+	}
+	for _, test := range tests {
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+
+		var info mp3.EncodingInfo
+		source := mock.Source{
+			Channels:   test.channels,
+			SampleRate: test.sampleRate,
+			Limit:      test.limit,
+			Value:      0.5,
+		}
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink: mp3.WAVSink(f, test.brm, mp3.JointStereo, mp3.DefaultEncodingQuality,
+					mp3.WithBackend(mp3.Shine),
+					mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i }),
+				),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		f.Close()
+
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		le := binary.LittleEndian
+		if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" || string(data[12:16]) != "fmt " {
+			t.Fatalf("%s: invalid RIFF header: % x", test.name, data[:16])
+		}
+		if size := int(le.Uint32(data[4:])); size != len(data)-8 {
+			t.Errorf("%s: expected RIFF size: %d got: %d", test.name, len(data)-8, size)
+		}
+		if tag := le.Uint16(data[20:]); tag != 0x0055 {
+			t.Errorf("%s: expected format tag: 0x0055 got: %#04x", test.name, tag)
+		}
+		if channels := int(le.Uint16(data[22:])); channels != test.channels {
+			t.Errorf("%s: expected channels: %d got: %d", test.name, test.channels, channels)
+		}
+		if sampleRate := signal.Frequency(le.Uint32(data[24:])); sampleRate != test.sampleRate {
+			t.Errorf("%s: expected sample rate: %v got: %v", test.name, test.sampleRate, sampleRate)
+		}
+		if samples := int(le.Uint32(data[58:])); samples != test.limit || info.Samples != test.limit {
+			t.Errorf("%s: expected samples: %d got: %d info: %d", test.name, test.limit, samples, info.Samples)
+		}
+		if string(data[62:66]) != "data" {
+			t.Fatalf("%s: invalid data chunk: % x", test.name, data[62:66])
+		}
+		dataSize := int(le.Uint32(data[66:]))
+		if 70+dataSize+dataSize%2 != len(data) {
+			t.Fatalf("%s: expected data size: %d got: %d", test.name, len(data)-70, dataSize)
+		}
+		frames := splitFrames(t, data[70:70+dataSize])
+		if string(frames[0][4+32:4+36]) != "Info" {
+			t.Errorf("%s: expected Info tag", test.name)
+		}
+	}
+}
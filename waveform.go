@@ -0,0 +1,212 @@
+package mp3
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mutable"
+	"pipelined.dev/signal"
+)
+
+const (
+	// defaultSamplesPerPoint is the default number of samples per point
+	// of waveform.
+	defaultSamplesPerPoint = 256
+	// waveformVersion is the version of audiowaveform data format.
+	waveformVersion = 2
+	// waveformHeaderSize is the size of binary waveform header.
+	waveformHeaderSize = 24
+)
+
+type (
+	// Waveform configures WaveformProcessor.
+	Waveform struct {
+		// SamplesPerPoint is the number of samples per channel that are
+		// reduced into a single point. Default is 256.
+		SamplesPerPoint int
+		// RMS makes points hold root mean square of the samples instead
+		// of minimum and maximum.
+		RMS bool
+		// Bits is the resolution of the data, either 8 or 16. Default
+		// is 16.
+		Bits int
+	}
+
+	// WaveformData is the downsampled peak data of the signal. It's
+	// serialized into JSON and binary formats of audiowaveform, that
+	// are supported by web players.
+	WaveformData struct {
+		SampleRate      int
+		SamplesPerPoint int
+		Channels        int
+		Bits            int
+		// Data holds minimum and maximum of every channel for every
+		// point. RMS points hold negative and positive root mean
+		// square.
+		Data []int
+	}
+)
+
+// WaveformProcessor returns the processor that passes the signal through
+// unchanged and reduces it into waveform points. The data is passed to
+// fn when the pipe is flushed. The last point can have less samples.
+func WaveformProcessor(wf Waveform, fn func(WaveformData)) pipe.ProcessorAllocatorFunc {
+	return func(mctx mutable.Context, bufferSize int, props pipe.SignalProperties) (pipe.Processor, error) {
+		if fn == nil {
+			return pipe.Processor{}, fmt.Errorf("%w: waveform function is nil", ErrInvalidParameter)
+		}
+		if wf.SamplesPerPoint < 0 {
+			return pipe.Processor{}, fmt.Errorf("%w: samples per point: %d", ErrInvalidParameter, wf.SamplesPerPoint)
+		}
+		if wf.SamplesPerPoint == 0 {
+			wf.SamplesPerPoint = defaultSamplesPerPoint
+		}
+		switch wf.Bits {
+		case 0:
+			wf.Bits = 16
+		case 8, 16:
+		default:
+			return pipe.Processor{}, fmt.Errorf("%w: waveform bits: %d", ErrInvalidParameter, wf.Bits)
+		}
+		r := waveformReducer{
+			Waveform: wf,
+			data: WaveformData{
+				SampleRate:      int(props.SampleRate),
+				SamplesPerPoint: wf.SamplesPerPoint,
+				Channels:        props.Channels,
+				Bits:            wf.Bits,
+				Data:            []int{},
+			},
+			min: make([]float64, props.Channels),
+			max: make([]float64, props.Channels),
+		}
+		return pipe.Processor{
+			SignalProperties: props,
+			ProcessFunc: func(in, out signal.Floating) (int, error) {
+				r.write(in)
+				return signal.FloatingAsFloating(in, out), nil
+			},
+			FlushFunc: func(context.Context) error {
+				if r.pos > 0 {
+					r.addPoint()
+				}
+				fn(r.data)
+				return nil
+			},
+		}, nil
+	}
+}
+
+// waveformReducer accumulates samples of the current point.
+type waveformReducer struct {
+	Waveform
+	data WaveformData
+	// min and max per channel, max holds sum of squares for RMS.
+	min, max []float64
+	pos      int
+}
+
+func (r *waveformReducer) write(floats signal.Floating) {
+	channels := floats.Channels()
+	for i := 0; i < floats.Length(); i++ {
+		for c := 0; c < channels; c++ {
+			v := floats.Sample(i*channels + c)
+			switch {
+			case r.RMS:
+				r.max[c] += v * v
+			case r.pos == 0:
+				r.min[c], r.max[c] = v, v
+			default:
+				r.min[c], r.max[c] = math.Min(r.min[c], v), math.Max(r.max[c], v)
+			}
+		}
+		if r.pos++; r.pos == r.SamplesPerPoint {
+			r.addPoint()
+		}
+	}
+}
+
+// addPoint completes the current point.
+func (r *waveformReducer) addPoint() {
+	scale := float64(int(1)<<(r.Bits-1) - 1)
+	quantize := func(v float64) int {
+		return int(math.Round(math.Max(-1, math.Min(1, v)) * scale))
+	}
+	for c := range r.max {
+		if r.RMS {
+			rms := math.Sqrt(r.max[c] / float64(r.pos))
+			r.data.Data = append(r.data.Data, quantize(-rms), quantize(rms))
+		} else {
+			r.data.Data = append(r.data.Data, quantize(r.min[c]), quantize(r.max[c]))
+		}
+		r.min[c], r.max[c] = 0, 0
+	}
+	r.pos = 0
+}
+
+// Length returns the number of points.
+func (d WaveformData) Length() int {
+	if d.Channels == 0 {
+		return 0
+	}
+	return len(d.Data) / (2 * d.Channels)
+}
+
+// MarshalJSON returns the data in audiowaveform JSON format version 2.
+func (d WaveformData) MarshalJSON() ([]byte, error) {
+	data := d.Data
+	if data == nil {
+		data = []int{}
+	}
+	return json.Marshal(struct {
+		Version         int   `json:"version"`
+		Channels        int   `json:"channels"`
+		SampleRate      int   `json:"sample_rate"`
+		SamplesPerPixel int   `json:"samples_per_pixel"`
+		Bits            int   `json:"bits"`
+		Length          int   `json:"length"`
+		Data            []int `json:"data"`
+	}{
+		Version:         waveformVersion,
+		Channels:        d.Channels,
+		SampleRate:      d.SampleRate,
+		SamplesPerPixel: d.SamplesPerPoint,
+		Bits:            d.Bits,
+		Length:          d.Length(),
+		Data:            data,
+	})
+}
+
+// WriteTo writes the data in audiowaveform binary format version 2.
+func (d WaveformData) WriteTo(w io.Writer) (int64, error) {
+	var flags uint32
+	sampleSize := 2
+	switch d.Bits {
+	case 8:
+		flags, sampleSize = 1, 1
+	case 16:
+	default:
+		return 0, fmt.Errorf("%w: waveform bits: %d", ErrInvalidParameter, d.Bits)
+	}
+	b := make([]byte, waveformHeaderSize, waveformHeaderSize+len(d.Data)*sampleSize)
+	binary.LittleEndian.PutUint32(b[0:], waveformVersion)
+	binary.LittleEndian.PutUint32(b[4:], flags)
+	binary.LittleEndian.PutUint32(b[8:], uint32(d.SampleRate))
+	binary.LittleEndian.PutUint32(b[12:], uint32(d.SamplesPerPoint))
+	binary.LittleEndian.PutUint32(b[16:], uint32(d.Length()))
+	binary.LittleEndian.PutUint32(b[20:], uint32(d.Channels))
+	for _, v := range d.Data {
+		if sampleSize == 1 {
+			b = append(b, byte(int8(v)))
+		} else {
+			b = append(b, byte(uint16(v)), byte(uint16(v)>>8))
+		}
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
@@ -0,0 +1,174 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+// processWaveform returns waveform data of stereo 441 Hz sine with
+// period of 100 samples.
+func processWaveform(t *testing.T, wf mp3.Waveform, samples int) mp3.WaveformData {
+	t.Helper()
+	var data mp3.WaveformData
+	p, err := pipe.New(
+		bufferSize,
+		pipe.Line{
+			Source:     sineSource(441, 0.5, samples),
+			Processors: pipe.Processors(mp3.WaveformProcessor(wf, func(d mp3.WaveformData) { data = d })),
+			Sink:       (&mock.Sink{Discard: true}).Sink(),
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pipe.Wait(p.Start(context.Background())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return data
+}
+
+func TestWaveformProcessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		waveform mp3.Waveform
+		samples  int
+		length   int
+		// expected minimum and maximum of full points.
+		min, max int
+	}{
+		{
+			name:     "default",
+			waveform: mp3.Waveform{SamplesPerPoint: 100},
+			samples:  1000,
+			length:   10,
+			min:      -16384,
+			max:      16384,
+		},
+		{
+			name:     "8 bits with last point",
+			waveform: mp3.Waveform{SamplesPerPoint: 200, Bits: 8},
+			samples:  1050,
+			length:   6,
+			min:      -64,
+			max:      64,
+		},
+		{
+			name:     "rms",
+			waveform: mp3.Waveform{SamplesPerPoint: 100, RMS: true},
+			samples:  1000,
+			length:   10,
+			min:      -11585,
+			max:      11585,
+		},
+	}
+	for _, test := range tests {
+		data := processWaveform(t, test.waveform, test.samples)
+		if data.Channels != 2 || data.SampleRate != 44100 {
+			t.Errorf("%s: unexpected properties: %d channels %d Hz", test.name, data.Channels, data.SampleRate)
+		}
+		if data.Length() != test.length {
+			t.Fatalf("%s: expected length: %d got: %d", test.name, test.length, data.Length())
+		}
+		full := test.samples / test.waveform.SamplesPerPoint
+		for i := 0; i < full*data.Channels; i++ {
+			if data.Data[2*i] != test.min || data.Data[2*i+1] != test.max {
+				t.Fatalf("%s: point %d: expected: [%d %d] got: %v", test.name, i/data.Channels, test.min, test.max, data.Data[2*i:2*i+2])
+			}
+		}
+	}
+}
+
+func TestWaveformDataSerialize(t *testing.T) {
+	data := mp3.WaveformData{
+		SampleRate:      44100,
+		SamplesPerPoint: 256,
+		Channels:        1,
+		Bits:            8,
+		Data:            []int{-10, 20, -128, 127},
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"version":           2.0,
+		"channels":          1.0,
+		"sample_rate":       44100.0,
+		"samples_per_pixel": 256.0,
+		"bits":              8.0,
+		"length":            2.0,
+		"data":              []interface{}{-10.0, 20.0, -128.0, 127.0},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected JSON: %v got: %v", expected, decoded)
+	}
+
+	var buf bytes.Buffer
+	if _, err := data.WriteTo(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	header := make([]byte, 24)
+	for i, v := range []uint32{2, 1, 44100, 256, 2, 1} {
+		binary.LittleEndian.PutUint32(header[i*4:], v)
+	}
+	if expected := append(header, 0xf6, 20, 0x80, 127); !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("expected binary: %x got: %x", expected, buf.Bytes())
+	}
+
+	data.Bits, data.Data = 16, []int{-2, 300}
+	buf.Reset()
+	if _, err := data.WriteTo(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []byte{0xfe, 0xff, 0x2c, 0x01}; !bytes.Equal(buf.Bytes()[24:], expected) {
+		t.Errorf("expected 16 bit data: %x got: %x", expected, buf.Bytes()[24:])
+	}
+}
+
+func TestWaveformProcessorInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		waveform mp3.Waveform
+		fn       func(mp3.WaveformData)
+	}{
+		{
+			name: "nil function",
+		},
+		{
+			name:     "samples per point",
+			waveform: mp3.Waveform{SamplesPerPoint: -1},
+			fn:       func(mp3.WaveformData) {},
+		},
+		{
+			name:     "bits",
+			waveform: mp3.Waveform{Bits: 12},
+			fn:       func(mp3.WaveformData) {},
+		},
+	}
+	for _, test := range tests {
+		_, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source:     sineSource(441, 0.5, 100),
+				Processors: pipe.Processors(mp3.WaveformProcessor(test.waveform, test.fn)),
+				Sink:       (&mock.Sink{Discard: true}).Sink(),
+			},
+		)
+		if !errors.Is(err, mp3.ErrInvalidParameter) {
+			t.Errorf("%s: expected error: %v got: %v", test.name, mp3.ErrInvalidParameter, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// xingSize is the size of Xing header with all optional fields.
+	xingSize = 120
+	// lameExtSize is the size of LAME extension that follows Xing header.
+	lameExtSize = 36
+	// xingFlags indicate that frames, bytes, TOC and quality are present.
+	xingFlags = 0x0f
+	// xingExtEncoder is the encoder string of the extension written for
+	// streams that weren't encoded by LAME. It doesn't start with LAME,
+	// so decoders don't apply workarounds of LAME versions to them.
+	xingExtEncoder = "pipelined"
+)
+
+// xingWriter reserves a frame at the start of seekable output and
+// overwrites it on flush with Xing/Info header. It's used for encoders
+// that don't write the header on their own.
+type xingWriter struct {
+	ws     io.WriteSeeker
+	start  int64
+	header frameHeader
+	brm    BitRateMode
+
+	reserved bool
+	// index of audio frame offsets relative to the first audio frame.
+	index   frameIndex
+	written int64
+	crc     uint16
+	scanner frameScanner
+}
+
+// newXingWriter returns the writer if w is seekable and the header has to
+// be written. Otherwise nil is returned.
+func newXingWriter(w io.Writer, c encoderConfig) *xingWriter {
+	if c.opts.disableVBRTag {
+		return nil
+	}
+	ws, ok := w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	start, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil
+	}
+	return &xingWriter{
+		ws:     ws,
+		start:  start,
+		header: xingFrameHeader(c),
+		brm:    c.brm,
+	}
+}
+
+// xingFrameHeader returns the header of the frame that can fit Xing
+// header and LAME extension. CBR streams keep their bit rate.
+func xingFrameHeader(c encoderConfig) frameHeader {
+	sampleRate := c.sampleRate
+	if c.opts.outSampleRate != 0 {
+		sampleRate = c.opts.outSampleRate
+	}
+	h := frameHeader{
+		version:    mpeg1,
+		sampleRate: int(sampleRate),
+	}
+	switch {
+	case sampleRate < 16000:
+		h.version = mpeg25
+	case sampleRate < 32000:
+		h.version = mpeg2
+	}
+	switch {
+	case c.channels == 1:
+		h.mode = 3
+	case c.cm == JointStereo:
+		h.mode = 1
+	}
+	if cbr, ok := c.brm.(CBR); ok {
+		h.bitRate = int(cbr)
+		if h.size() >= h.xingOffset()+xingSize+lameExtSize {
+			return h
+		}
+	}
+	for _, br := range frameBitRates[h.version][1:] {
+		h.bitRate = br
+		if h.size() >= h.xingOffset()+xingSize+lameExtSize {
+			break
+		}
+	}
+	return h
+}
+
+// xingOffset returns the offset of Xing header in the frame.
+func (h frameHeader) xingOffset() int {
+	return frameHeaderSize + h.sideInfoSize()
+}
+
+// bytes returns encoded header.
+func (h frameHeader) bytes() []byte {
+	var bitRateIndex, sampleRateIndex int
+	for i, br := range frameBitRates[h.version] {
+		if br == h.bitRate {
+			bitRateIndex = i
+		}
+	}
+	for i, sr := range frameSampleRates[h.version] {
+		if sr == h.sampleRate {
+			sampleRateIndex = i
+		}
+	}
+	b := []byte{0xff, 0xe0 | byte(h.version)<<3 | 0x01<<1, byte(bitRateIndex<<4 | sampleRateIndex<<2), byte(h.mode << 6)}
+	if !h.protected {
+		b[1] |= 0x01
+	}
+	if h.padding {
+		b[2] |= 0x02
+	}
+	return b
+}
+
+// Write reserves the header frame before the first write and tracks
+// frames of the passed data.
+func (x *xingWriter) Write(p []byte) (int, error) {
+	if !x.reserved {
+		x.reserved = true
+		frame := make([]byte, x.header.size())
+		copy(frame, x.header.bytes())
+		if _, err := x.ws.Write(frame); err != nil {
+			return 0, err
+		}
+	}
+	n, err := x.ws.Write(p)
+	if err != nil {
+		return n, err
+	}
+	x.crc = crc16(x.crc, p)
+	err = x.scanner.scan(p, func(offset int, _ frameHeader) {
+		x.index.add(x.written + int64(offset))
+	})
+	if err != nil {
+		return n, fmt.Errorf("error parsing MP3 frame: %w", err)
+	}
+	x.written += int64(n)
+	return n, nil
+}
+
+// finish overwrites the reserved frame with the header.
+func (x *xingWriter) finish(info EncodingInfo) error {
+	if !x.reserved {
+		return nil
+	}
+	frame := x.frame(info)
+	end, err := x.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("error writing Xing header: %w", err)
+	}
+	if _, err := x.ws.Seek(x.start, io.SeekStart); err != nil {
+		return fmt.Errorf("error writing Xing header: %w", err)
+	}
+	if _, err := x.ws.Write(frame); err != nil {
+		return fmt.Errorf("error writing Xing header: %w", err)
+	}
+	if _, err := x.ws.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("error writing Xing header: %w", err)
+	}
+	return nil
+}
+
+// frame returns the header frame. Xing header is followed by extension
+// in LAME layout with encoder delay and padding.
+func (x *xingWriter) frame(info EncodingInfo) []byte {
+	frame := make([]byte, x.header.size())
+	copy(frame, x.header.bytes())
+	tagSize := int64(len(frame))
+	total := tagSize + x.written
+
+	xing := frame[x.header.xingOffset():]
+	if _, ok := x.brm.(CBR); ok {
+		copy(xing, "Info")
+	} else {
+		copy(xing, "Xing")
+	}
+	binary.BigEndian.PutUint32(xing[4:], xingFlags)
+	binary.BigEndian.PutUint32(xing[8:], uint32(x.index.frames))
+	binary.BigEndian.PutUint32(xing[12:], uint32(total))
+	for i := 0; i < 100 && x.index.frames > 0; i++ {
+		offset := tagSize + x.index.offset(i*x.index.frames/100)
+		toc := offset * 256 / total
+		if toc > 255 {
+			toc = 255
+		}
+		xing[16+i] = byte(toc)
+	}
+	if vbr, ok := x.brm.(VBR); ok {
+		binary.BigEndian.PutUint32(xing[116:], uint32(100-10*int(vbr)))
+	}
+
+	ext := xing[xingSize:]
+	copy(ext, xingExtEncoder)
+	switch brm := x.brm.(type) {
+	case CBR:
+		ext[9] = 1
+		ext[20] = byte(brm)
+	case ABR:
+		ext[9] = 2
+		if brm > 255 {
+			brm = 255
+		}
+		ext[20] = byte(brm)
+	case VBR:
+		ext[9] = 4
+	}
+	delay, padding := clamp12(info.Delay), clamp12(info.Padding)
+	ext[21] = byte(delay >> 4)
+	ext[22] = byte(delay<<4 | padding>>8)
+	ext[23] = byte(padding)
+	ext[24] = lameExtMisc(x.header)
+	binary.BigEndian.PutUint32(ext[28:], uint32(total))
+	binary.BigEndian.PutUint16(ext[32:], x.crc)
+	tagEnd := x.header.xingOffset() + xingSize + lameExtSize - 2
+	binary.BigEndian.PutUint16(ext[34:], crc16(0, frame[:tagEnd]))
+	return frame
+}
+
+// lameExtMisc returns misc byte of LAME extension with source sample
+// rate and stereo mode.
+func lameExtMisc(h frameHeader) byte {
+	var sampleRate, mode byte
+	switch {
+	case h.sampleRate > 48000:
+		sampleRate = 3
+	case h.sampleRate == 48000:
+		sampleRate = 2
+	case h.sampleRate == 44100:
+		sampleRate = 1
+	}
+	switch h.mode {
+	case 0:
+		mode = 1
+	case 1:
+		mode = 3
+	}
+	return sampleRate<<6 | mode<<2
+}
+
+func clamp12(v int) int {
+	switch {
+	case v < 0:
+		return 0
+	case v > 0xfff:
+		return 0xfff
+	}
+	return v
+}
+
+// crc16Table is the table of CRC-16 with 0x8005 polynomial used by LAME
+// extension.
+var crc16Table = func() (t [256]uint16) {
+	for i := range t {
+		crc := uint16(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+		t[i] = crc
+	}
+	return
+}()
+
+func crc16(crc uint16, b []byte) uint16 {
+	for _, v := range b {
+		crc = crc>>8 ^ crc16Table[byte(crc)^v]
+	}
+	return crc
+}
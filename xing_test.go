@@ -0,0 +1,127 @@
+package mp3_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"pipelined.dev/audio/mp3"
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/mock"
+)
+
+func TestXingHeader(t *testing.T) {
+	type sinkFunc func(*os.File, ...mp3.SinkOption) pipe.SinkAllocatorFunc
+	tests := []struct {
+		name    string
+		backend mp3.Backend
+		sink    sinkFunc
+		tag     string
+	}{
+		{
+			name:    "shine",
+			backend: mp3.Shine,
+			sink: func(f *os.File, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				options = append(options, mp3.WithBackend(mp3.Shine))
+				return mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+			tag: "Info",
+		},
+		{
+			name:    "parallel vbr",
+			backend: mp3.Lame,
+			sink: func(f *os.File, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				return mp3.ParallelSink(f, 2, mp3.VBR(2), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+			tag: "Xing",
+		},
+		{
+			name:    "without tag",
+			backend: mp3.Shine,
+			sink: func(f *os.File, options ...mp3.SinkOption) pipe.SinkAllocatorFunc {
+				options = append(options, mp3.WithBackend(mp3.Shine), mp3.WithoutVBRTag())
+				return mp3.Sink(f, mp3.CBR(128), mp3.JointStereo, mp3.DefaultEncodingQuality, options...)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if !test.backend.Available() {
+			continue
+		}
+		f, err := os.CreateTemp("", "mp3")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(f.Name())
+
+		// source has more frames than the index of the writer holds.
+		source := mock.Source{
+			Channels:   2,
+			SampleRate: 44100,
+			Limit:      1500000,
+			Value:      0.5,
+		}
+		var info mp3.EncodingInfo
+		p, err := pipe.New(
+			bufferSize,
+			pipe.Line{
+				Source: source.Source(),
+				Sink:   test.sink(f, mp3.WithEncodingInfo(func(i mp3.EncodingInfo) { info = i })),
+			},
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if err := pipe.Wait(p.Start(context.Background())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		f.Close()
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		frames := splitFrames(t, data)
+		xing := frames[0][4+32:]
+		if test.tag == "" {
+			if len(frames) != info.Frames {
+				t.Errorf("%s: expected %d frames got: %d", test.name, info.Frames, len(frames))
+			}
+			continue
+		}
+		if tag := string(xing[:4]); tag != test.tag {
+			t.Fatalf("%s: expected tag: %q got: %q", test.name, test.tag, tag)
+		}
+		if n := int(binary.BigEndian.Uint32(xing[8:])); n != info.Frames || n != len(frames)-1 {
+			t.Errorf("%s: expected frames: %d got: %d", test.name, len(frames)-1, n)
+		}
+		if n := int(binary.BigEndian.Uint32(xing[12:])); n != len(data) {
+			t.Errorf("%s: expected bytes: %d got: %d", test.name, len(data), n)
+		}
+		toc := xing[16:116]
+		for i := 1; i < len(toc); i++ {
+			if toc[i] < toc[i-1] {
+				t.Fatalf("%s: TOC isn't monotonic: %v", test.name, toc)
+			}
+		}
+		// TOC of constant bit rate is linear.
+		for i, v := range toc {
+			if test.tag == "Info" && (int(v) < i*256/100-1 || int(v) > i*256/100+1) {
+				t.Fatalf("%s: expected linear TOC got: %v", test.name, toc)
+			}
+		}
+		lame := xing[120:]
+		// extension of streams that LAME didn't encode isn't marked as LAME.
+		if bytes.HasPrefix(lame, []byte("LAME")) {
+			t.Errorf("%s: unexpected LAME encoder string: %q", test.name, lame[:9])
+		}
+		delay := int(lame[21])<<4 | int(lame[22])>>4
+		padding := int(lame[22]&0x0f)<<8 | int(lame[23])
+		if delay != info.Delay || padding != info.Padding {
+			t.Errorf("%s: expected delay: %d padding: %d got delay: %d padding: %d", test.name, info.Delay, info.Padding, delay, padding)
+		}
+	}
+}